@@ -26,6 +26,7 @@ cp ${BGO_SPACE}/bin/linux_386/ssm-document-worker ${BGO_SPACE}/bin/linux_386/lin
 cp ${BGO_SPACE}/bin/linux_386/ssm-cli ${BGO_SPACE}/bin/linux_386/linux/usr/bin/
 cp ${BGO_SPACE}/seelog_unix.xml ${BGO_SPACE}/bin/linux_386/linux/etc/amazon/ssm/seelog.xml.template
 cp ${BGO_SPACE}/amazon-ssm-agent.json.template ${BGO_SPACE}/bin/linux_386/linux/etc/amazon/ssm/
+cp -r ${BGO_SPACE}/packaging/trusted-keys ${BGO_SPACE}/bin/linux_386/linux/etc/amazon/ssm/
 cp ${BGO_SPACE}/RELEASENOTES.md ${BGO_SPACE}/bin/linux_386/linux/etc/amazon/ssm/RELEASENOTES.md
 cp ${BGO_SPACE}/README.md ${BGO_SPACE}/bin/linux_386/linux/etc/amazon/ssm/README.md
 cp ${BGO_SPACE}/packaging/linux/amazon-ssm-agent.conf ${BGO_SPACE}/bin/linux_386/linux/etc/init/
@@ -24,6 +24,8 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/framework/coremanager"
 	logger "github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/statestore"
+	"github.com/aws/amazon-ssm-agent/agent/updateutil"
 	"github.com/aws/amazon-ssm-agent/agent/version"
 )
 
@@ -57,10 +59,17 @@ func start(log logger.T, instanceIDPtr *string, regionPtr *string) (cpm *coreman
 		}
 	}()
 
+	// recover and check the local state store before the core modules read their state from it
+	if _, storeErr := statestore.OpenDefault(log); storeErr != nil {
+		log.Errorf("error occurred when opening the local state store: %v", storeErr)
+	}
+
 	if cpm, err = coremanager.NewCoreManager(instanceIDPtr, regionPtr, log); err != nil {
 		log.Errorf("error occurred when starting core manager: %v", err)
 		return
 	}
+	// record the registration before the modules report the health of the agent, for the health gate of the updater
+	updateutil.RecordAgentRegistration(log, version.Version)
 	cpm.Start()
 	return
 }
@@ -86,6 +95,9 @@ func stop(log logger.T, cpm *coremanager.CoreManager) {
 	log.Info("Stopping agent")
 	log.Flush()
 	cpm.Stop()
+	if err := statestore.CloseDefault(); err != nil {
+		log.Errorf("error occurred when closing the local state store: %v", err)
+	}
 	log.Info("Bye.")
 	log.Flush()
 }
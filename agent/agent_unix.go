@@ -2,7 +2,10 @@
 
 package main
 
-import logger "github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+import (
+	logger "github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
+)
 
 func main() {
 	// initialize logger
@@ -10,6 +13,8 @@ func main() {
 	defer log.Close()
 	defer log.Flush()
 
+	proxyconfig.SetProxySettings(log)
+
 	// parse input parameters
 	parseFlags(log)
 
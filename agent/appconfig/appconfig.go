@@ -41,11 +41,11 @@ func Config(reload bool) (SsmagentConfig, error) {
 			return agentConfig, nil
 		}
 
-		// Process config override
-		fmt.Printf("Applying config override from %s.\n", path)
+		// Process config override, logged to stderr so that it isn't mixed with the output of ssm-cli
+		log.Printf("Applying config override from %s.\n", path)
 
 		if err := jsonutil.UnmarshalFile(path, &agentConfig); err != nil {
-			fmt.Println("Failed to unmarshal config override. Fall back to default.")
+			log.Println("Failed to unmarshal config override. Fall back to default.")
 			return agentConfig, err
 		}
 		agentConfig.Os.Name = runtime.GOOS
@@ -110,16 +110,30 @@ func DefaultConfig() SsmagentConfig {
 		CommandRetryLimit:   DefaultCommandRetryLimit,
 	}
 	var ssm = SsmCfg{
-		HealthFrequencyMinutes:                DefaultSsmHealthFrequencyMinutes,
-		AssociationFrequencyMinutes:           DefaultSsmAssociationFrequencyMinutes,
-		AssociationRetryLimit:                 5,
-		CustomInventoryDefaultLocation:        DefaultCustomInventoryFolder,
-		AssociationLogsRetentionDurationHours: DefaultAssociationLogsRetentionDurationHours,
-		RunCommandLogsRetentionDurationHours:  DefaultRunCommandLogsRetentionDurationHours,
+		HealthFrequencyMinutes:                      DefaultSsmHealthFrequencyMinutes,
+		AssociationFrequencyMinutes:                 DefaultSsmAssociationFrequencyMinutes,
+		AssociationRetryLimit:                       5,
+		CustomInventoryDefaultLocation:              DefaultCustomInventoryFolder,
+		AssociationLogsRetentionDurationHours:       DefaultAssociationLogsRetentionDurationHours,
+		RunCommandLogsRetentionDurationHours:        DefaultRunCommandLogsRetentionDurationHours,
+		OfflineCommandResultsRetentionDurationHours: DefaultOfflineCommandResultsRetentionDurationHours,
+		OfflineCommandResultsMaxCount:               DefaultOfflineCommandResultsMaxCount,
+		InventorySink:                               DefaultInventorySink,
+		ParameterCacheTTLSeconds:                    DefaultParameterCacheTTLSeconds,
+		ParameterFile:                               DefaultParameterFilePath,
 	}
 	var agent = AgentInfo{
 		Name:                 "amazon-ssm-agent",
 		OrchestrationRootDir: defaultOrchestrationRootDirName,
+		OutputStreaming: OutputStreaming{
+			FileMaxSizeMB:        DefaultOutputStreamFileMaxSizeMB,
+			FileMaxBackups:       DefaultOutputStreamFileMaxBackups,
+			ReplyIntervalSeconds: DefaultOutputStreamReplyIntervalSeconds,
+		},
+		OutputLimits: DefaultOutputLimits(),
+		UpdateHealthGate: UpdateHealthGateCfg{
+			TimeoutSeconds: DefaultUpdateHealthGateTimeoutSeconds,
+		},
 	}
 	var os = OsInfo{
 		Lang:    "en-US",
@@ -28,6 +28,28 @@ func parser(config *SsmagentConfig) {
 	config.Agent.Name = getStringValue(config.Agent.Name, DefaultAgentName)
 	config.Agent.OrchestrationRootDir = getStringValue(config.Agent.OrchestrationRootDir, defaultOrchestrationRootDirName)
 	config.Agent.Region = getStringValue(config.Agent.Region, "")
+	config.Agent.DocumentWorkerLimits = ValidateResourceLimits(config.Agent.DocumentWorkerLimits)
+	config.Agent.OutputLimits = ValidateOutputLimits(config.Agent.OutputLimits, DefaultOutputLimits())
+	config.Agent.OutputStreaming.FileMaxSizeMB = getNumericValue(
+		config.Agent.OutputStreaming.FileMaxSizeMB,
+		DefaultOutputStreamFileMaxSizeMBMin,
+		DefaultOutputStreamFileMaxSizeMBMax,
+		DefaultOutputStreamFileMaxSizeMB)
+	config.Agent.OutputStreaming.FileMaxBackups = getNumericValue(
+		config.Agent.OutputStreaming.FileMaxBackups,
+		DefaultOutputStreamFileMaxBackupsMin,
+		DefaultOutputStreamFileMaxBackupsMax,
+		DefaultOutputStreamFileMaxBackups)
+	config.Agent.OutputStreaming.ReplyIntervalSeconds = getNumericValue(
+		config.Agent.OutputStreaming.ReplyIntervalSeconds,
+		DefaultOutputStreamReplyIntervalMin,
+		DefaultOutputStreamReplyIntervalMax,
+		DefaultOutputStreamReplyIntervalSeconds)
+	config.Agent.UpdateHealthGate.TimeoutSeconds = getNumericValue(
+		config.Agent.UpdateHealthGate.TimeoutSeconds,
+		DefaultUpdateHealthGateTimeoutSecondsMin,
+		DefaultUpdateHealthGateTimeoutSecondsMax,
+		DefaultUpdateHealthGateTimeoutSeconds)
 
 	// MDS config
 	config.Mds.CommandWorkersLimit = getNumericValue(
@@ -67,6 +89,19 @@ func parser(config *SsmagentConfig) {
 		config.Ssm.RunCommandLogsRetentionDurationHours,
 		DefaultStateOrchestrationLogsRetentionDurationHoursMin,
 		DefaultRunCommandLogsRetentionDurationHours)
+	config.Ssm.OfflineCommandResultsRetentionDurationHours = getNumericValueAboveMin(
+		config.Ssm.OfflineCommandResultsRetentionDurationHours,
+		DefaultOfflineCommandResultsRetentionDurationHoursMin,
+		DefaultOfflineCommandResultsRetentionDurationHours)
+	config.Ssm.OfflineCommandResultsMaxCount = getNumericValueAboveMin(
+		config.Ssm.OfflineCommandResultsMaxCount,
+		DefaultOfflineCommandResultsMaxCountMin,
+		DefaultOfflineCommandResultsMaxCount)
+	config.Ssm.ParameterCacheTTLSeconds = getNumericValueAboveMin(
+		config.Ssm.ParameterCacheTTLSeconds,
+		DefaultParameterCacheTTLSecondsMin,
+		DefaultParameterCacheTTLSeconds)
+	config.Ssm.ParameterFile = getStringValue(config.Ssm.ParameterFile, DefaultParameterFilePath)
 
 }
 
@@ -83,6 +118,45 @@ func GetDefaultEndPoint(region string, service string) string {
 	return endpoint
 }
 
+// ValidateResourceLimits returns the resource limits with the invalid limits removed
+func ValidateResourceLimits(limits ResourceLimits) ResourceLimits {
+	limits.CPUQuotaPercent = getNumericValueAboveMin(limits.CPUQuotaPercent, DefaultResourceLimitMin, DefaultResourceLimitMin)
+	limits.MemoryLimitMB = getNumericValueAboveMin(limits.MemoryLimitMB, DefaultResourceLimitMin, DefaultResourceLimitMin)
+	limits.PidsLimit = getNumericValueAboveMin(limits.PidsLimit, DefaultResourceLimitMin, DefaultResourceLimitMin)
+	limits.IOWeight = getNumericValue(limits.IOWeight, DefaultResourceLimitMin, DefaultIOWeightMax, DefaultResourceLimitMin)
+	return limits
+}
+
+// DefaultOutputLimits returns the default limits of the output of the steps
+func DefaultOutputLimits() OutputLimits {
+	return OutputLimits{
+		MaxStdoutLength: MaxStdoutLength,
+		MaxStderrLength: MaxStderrLength,
+		MaxOutputLength: DefaultMaxOutputLength,
+		Truncation:      OutputTruncationKeepHead,
+	}
+}
+
+// ValidateOutputLimits returns the output limits with the invalid limits replaced by the default limits
+func ValidateOutputLimits(limits OutputLimits, defaults OutputLimits) OutputLimits {
+	limits.MaxStdoutLength = getNumericValue(limits.MaxStdoutLength, DefaultOutputLengthMin, DefaultOutputLengthMax, defaults.MaxStdoutLength)
+	limits.MaxStderrLength = getNumericValue(limits.MaxStderrLength, DefaultOutputLengthMin, DefaultOutputLengthMax, defaults.MaxStderrLength)
+	limits.MaxOutputLength = getNumericValue(limits.MaxOutputLength, DefaultOutputLengthMin, DefaultOutputLengthMax, defaults.MaxOutputLength)
+	if !IsOutputTruncation(limits.Truncation) {
+		limits.Truncation = defaults.Truncation
+	}
+	return limits
+}
+
+// IsOutputTruncation returns true if the truncation is a supported truncation of the output
+func IsOutputTruncation(truncation string) bool {
+	switch truncation {
+	case OutputTruncationKeepHead, OutputTruncationKeepTail, OutputTruncationKeepHeadAndTail:
+		return true
+	}
+	return false
+}
+
 // getStringValue returns the default value if config is empty, else the config value
 func getStringValue(configValue string, defaultValue string) string {
 	if configValue == "" {
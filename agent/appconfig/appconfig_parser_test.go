@@ -14,6 +14,7 @@
 package appconfig
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -116,3 +117,39 @@ func TestGetNumeric64Value(t *testing.T) {
 		assert.Equal(t, test.Output, output)
 	}
 }
+
+func TestValidateResourceLimits(t *testing.T) {
+	limits := ValidateResourceLimits(ResourceLimits{CPUQuotaPercent: 150, MemoryLimitMB: -1, PidsLimit: 512, IOWeight: 20000})
+	assert.Equal(t, ResourceLimits{CPUQuotaPercent: 150, PidsLimit: 512}, limits)
+}
+
+func TestValidateOutputLimits(t *testing.T) {
+	agentLimits := OutputLimits{MaxStdoutLength: 48000, MaxStderrLength: 8000, MaxOutputLength: 2500, Truncation: OutputTruncationKeepTail}
+	limits := ValidateOutputLimits(OutputLimits{MaxStderrLength: 2000000, MaxOutputLength: 5000, Truncation: "KeepMiddle"}, agentLimits)
+	assert.Equal(t, OutputLimits{MaxStdoutLength: 48000, MaxStderrLength: 8000, MaxOutputLength: 5000, Truncation: OutputTruncationKeepTail}, limits)
+}
+
+func TestParameterCacheOptIn(t *testing.T) {
+	// the cache is disabled unless the configuration sets a validity
+	config := DefaultConfig()
+	parser(&config)
+	assert.Equal(t, 0, config.Ssm.ParameterCacheTTLSeconds)
+
+	config.Ssm.ParameterCacheTTLSeconds = -1
+	parser(&config)
+	assert.Equal(t, 0, config.Ssm.ParameterCacheTTLSeconds)
+
+	config.Ssm.ParameterCacheTTLSeconds = 600
+	parser(&config)
+	assert.Equal(t, 600, config.Ssm.ParameterCacheTTLSeconds)
+}
+
+func TestArtifactSignatureOptIn(t *testing.T) {
+	// the verification is disabled unless the configuration requires it
+	assert.False(t, DefaultConfig().Agent.ArtifactSignature.Required)
+
+	// the configuration override is unmarshaled over the default configuration
+	config := DefaultConfig()
+	assert.NoError(t, json.Unmarshal([]byte(`{"Agent": {"ArtifactSignature": {"Required": true, "TrustedKeyFiles": ["/etc/keys/mirror.pem"]}}}`), &config))
+	assert.Equal(t, ArtifactSignatureCfg{Required: true, TrustedKeyFiles: []string{"/etc/keys/mirror.pem"}}, config.Agent.ArtifactSignature)
+}
@@ -53,6 +53,49 @@ const (
 	DefaultRunCommandLogsRetentionDurationHours            = 336 // 14 days default retention
 	DefaultStateOrchestrationLogsRetentionDurationHoursMin = 8   // Min retention of 8hrs as some processes may not timeout before this and don't want logs to be deleted before the process completes
 
+	//aws-ssm-agent retention of the results of commands submitted locally
+	DefaultOfflineCommandResultsRetentionDurationHours    = 336 // 14 days default retention
+	DefaultOfflineCommandResultsRetentionDurationHoursMin = 1
+	DefaultOfflineCommandResultsMaxCount                  = 1000
+	DefaultOfflineCommandResultsMaxCountMin               = 1
+
+	//aws-ssm-agent sink of the inventory data, SSM Inventory by default
+	DefaultInventorySink = "SSM"
+
+	//aws-ssm-agent validity of the cached values of the SSM parameters, zero disabling the cache
+	DefaultParameterCacheTTLSeconds    = 0
+	DefaultParameterCacheTTLSecondsMin = 0
+
+	//aws-ssm-agent resource limits of the document workers, zero being no limit
+	DefaultResourceLimitMin = 0
+	DefaultIOWeightMax      = 10000
+
+	//aws-ssm-agent limits of the output of the steps reported in their results
+	OutputTruncationKeepHead        = "KeepHead"
+	OutputTruncationKeepTail        = "KeepTail"
+	OutputTruncationKeepHeadAndTail = "KeepHeadAndTail"
+	DefaultMaxOutputLength          = 2500
+	DefaultOutputLengthMin          = 100
+	DefaultOutputLengthMax          = 1048576
+
+	//aws-ssm-agent sinks the output of the running steps can be streamed to
+	OutputStreamSinkFile                    = "File"
+	OutputStreamSinkReply                   = "Reply"
+	DefaultOutputStreamFileMaxSizeMB        = 10
+	DefaultOutputStreamFileMaxSizeMBMin     = 1
+	DefaultOutputStreamFileMaxSizeMBMax     = 1024
+	DefaultOutputStreamFileMaxBackups       = 3
+	DefaultOutputStreamFileMaxBackupsMin    = 0
+	DefaultOutputStreamFileMaxBackupsMax    = 100
+	DefaultOutputStreamReplyIntervalSeconds = 30
+	DefaultOutputStreamReplyIntervalMin     = 5
+	DefaultOutputStreamReplyIntervalMax     = 3600
+
+	//aws-ssm-agent window the updated agent has to pass the health gate of the update
+	DefaultUpdateHealthGateTimeoutSeconds    = 300
+	DefaultUpdateHealthGateTimeoutSecondsMin = 60
+	DefaultUpdateHealthGateTimeoutSecondsMax = 3600
+
 	//aws-ssm-agent bookkeeping constants for long running plugins
 	LongRunningPluginsLocation         = "longrunningplugins"
 	LongRunningPluginsHealthCheck      = "healthcheck"
@@ -60,6 +103,9 @@ const (
 	LongRunningPluginDataStoreFileName = "store"
 	PluginNameLongRunningPluginInvoker = "lrpminvoker"
 
+	//aws-ssm-agent folder of the local state store within the data store path
+	StateStoreLocation = "statestore"
+
 	//aws-ssm-agent bookkeeping constants for inventory plugin
 	InventoryRootDirName         = "inventory"
 	CustomInventoryRootDirName   = "custom"
@@ -67,6 +113,9 @@ const (
 	RoleInventoryRootDirName     = "role"
 	InventoryContentHashFileName = "contentHash"
 
+	//aws-ssm-agent bookkeeping constants for the output streamed by the running steps
+	OutputStreamRootDirName = "outputstream"
+
 	//aws-ssm-agent bookkeeping constants for compliance
 	ComplianceRootDirName         = "compliance"
 	ComplianceContentHashFileName = "contentHash"
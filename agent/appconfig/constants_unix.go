@@ -25,6 +25,9 @@ const (
 	// AppConfigPath is the path of the AppConfig
 	AppConfigPath = DefaultProgramFolder + AppConfigFileName
 
+	// ProxyEnvFilePath is the path of the drop-in file of the proxy environment variables of the agent
+	ProxyEnvFilePath = DefaultProgramFolder + "proxy.env"
+
 	// PackageRoot specifies the directory under which packages will be downloaded and installed
 	PackageRoot = "/var/lib/amazon/ssm/packages"
 
@@ -78,6 +81,12 @@ const (
 	// Default Custom Inventory Inventory Folder
 	DefaultCustomInventoryFolder = DefaultDataStorePath + "inventory/custom"
 
+	// DefaultParameterFilePath is the path of the local file of SSM parameter values
+	DefaultParameterFilePath = DefaultProgramFolder + "parameters.json"
+
+	// TrustedKeysFolder is the folder of the public keys bundled with the agent to verify the signatures of the artifacts
+	TrustedKeysFolder = DefaultProgramFolder + "trusted-keys"
+
 	DefaultDocumentWorker = "/usr/bin/ssm-document-worker"
 
 	// Used to capture and return exit code for windows powershell script execution - empty for unix shell script case
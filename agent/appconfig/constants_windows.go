@@ -144,6 +144,12 @@ var DefaultCustomInventoryFolder string
 // Plugin folder path
 var PluginFolder string
 
+// DefaultParameterFilePath is the path of the local file of SSM parameter values
+var DefaultParameterFilePath string
+
+// TrustedKeysFolder is the folder of the public keys bundled with the agent to verify the signatures of the artifacts
+var TrustedKeysFolder string
+
 func init() {
 	/*
 		System environment variable "AllUsersProfile" maps to following locations in different locations:
@@ -167,6 +173,8 @@ func init() {
 	DefaultDocumentWorker = filepath.Join(DefaultProgramFolder, "ssm-document-worker.exe")
 	ManifestCacheDirectory = filepath.Join(EnvProgramFiles, ManifestCacheFolder)
 	AppConfigPath = filepath.Join(DefaultProgramFolder, AppConfigFileName)
+	DefaultParameterFilePath = filepath.Join(DefaultProgramFolder, "parameters.json")
+	TrustedKeysFolder = filepath.Join(DefaultProgramFolder, "trusted-keys")
 	DefaultDataStorePath = filepath.Join(SSMDataPath, "InstanceData")
 	PackageRoot = filepath.Join(SSMDataPath, "Packages")
 	PackageLockRoot = filepath.Join(SSMDataPath, "Locks\\Packages")
@@ -42,6 +42,21 @@ type SsmCfg struct {
 	CustomInventoryDefaultLocation        string
 	AssociationLogsRetentionDurationHours int
 	RunCommandLogsRetentionDurationHours  int
+	// retention of the results of the commands submitted locally with ssm-cli
+	OfflineCommandResultsRetentionDurationHours int
+	OfflineCommandResultsMaxCount               int
+	// sink the inventory data is sent to (SSM, File, HTTP or Stdout) and its destination, the path of the file
+	// or the url of the endpoint
+	InventorySink            string
+	InventorySinkDestination string
+	// folder the File sink may write to, the inventory folder of the instance when empty, and urls of the endpoints
+	// the HTTP sink may post to besides the configured destination. Documents can't export the inventory elsewhere.
+	InventoryExportDirectory string
+	InventoryHTTPEndpoints   []string
+	// validity of the values of the SSM parameters cached when resolving the documents, the cache being disabled by
+	// default with zero, and the path of the local file of parameter values resolved before Parameter Store
+	ParameterCacheTTLSeconds int
+	ParameterFile            string
 }
 
 // AgentInfo represents metadata for amazon-ssm-agent
@@ -51,6 +66,102 @@ type AgentInfo struct {
 	Region               string
 	OrchestrationRootDir string
 	DownloadRootDir      string
+	// limits of the resources of each document worker process and its child processes, only applied on Linux
+	DocumentWorkerLimits ResourceLimits
+	// streaming of the output of the running steps, only done by the document workers
+	OutputStreaming OutputStreaming
+	// limits of the output of the steps reported in their results
+	OutputLimits OutputLimits
+	// proxy of the requests of the agent, only applied on Linux and macOS where it overrides the proxy.env drop-in
+	// file and the environment of the agent
+	Proxy ProxyCfg
+	// verification of the detached signatures of the agent update and package artifacts
+	ArtifactSignature ArtifactSignatureCfg
+	// repository of the agent updates, overridden by the source of the aws:updateSsmAgent steps
+	UpdateSource UpdateSourceCfg
+	// verification of the health of the updated agent, which is rolled back when it isn't healthy
+	UpdateHealthGate UpdateHealthGateCfg
+	// maintenance windows during which the agent updates are refused
+	UpdateBlackoutWindows []BlackoutWindow
+}
+
+// ProxyCfg represents the proxy settings of the agent, a proxy being a URL or a host[:port] and the hosts bypassing
+// the proxies being separated by commas
+type ProxyCfg struct {
+	HttpProxy  string
+	HttpsProxy string
+	NoProxy    string
+}
+
+// ArtifactSignatureCfg represents the verification of the detached signatures of the downloaded artifacts. When
+// Required is true, the artifacts which aren't signed by a trusted key are rejected, the trusted keys being the PEM
+// public keys of the TrustedKeysFolder bundled with the agent and of the TrustedKeyFiles. The verification is opt-in,
+// the published artifacts not being signed yet.
+type ArtifactSignatureCfg struct {
+	Required        bool
+	TrustedKeyFiles []string
+}
+
+// UpdateSourceCfg represents the repository of the manifest and packages of the agent updates. Location is the URL of
+// the manifest in S3, the https base URL of a mirror, or a local directory or file:// URL, the S3 buckets of the region
+// being used when it is empty. Location overrides the default S3 manifest the AWS-UpdateSSMAgent document passes,
+// only another source given by the document wins. CABundle is the PEM file of the certificate authorities trusted for
+// the mirror in addition to those of the system.
+type UpdateSourceCfg struct {
+	Location string
+	CABundle string
+}
+
+// UpdateHealthGateCfg represents the health gate of the agent updates. When Enabled is true, the updated agent must
+// register, pass its health check and complete a probe document from the local command folder within TimeoutSeconds,
+// otherwise the updater rolls back to the previous version.
+type UpdateHealthGateCfg struct {
+	Enabled        bool
+	TimeoutSeconds int
+}
+
+// BlackoutWindow represents a daily maintenance window during which the agent updates are refused. Start and End are
+// UTC times in the HH:MM format, a window ending before its start ending the next day. Days restricts the window to
+// the days it starts on, Monday or Mon for instance, the window applying every day when Days is empty.
+type BlackoutWindow struct {
+	Start string
+	End   string
+	Days  []string
+}
+
+// OutputLimits limits the output of the steps reported in their results, the complete output being kept in the
+// orchestration folder. The zero values of the limits of a step keep the limits of the agent.
+type OutputLimits struct {
+	// MaxStdoutLength and MaxStderrLength limit the standard output and error of a step, in bytes
+	MaxStdoutLength int `json:"maxStdoutLength" yaml:"maxStdoutLength"`
+	MaxStderrLength int `json:"maxStderrLength" yaml:"maxStderrLength"`
+	// MaxOutputLength limits the output of a step combining its standard output and error
+	MaxOutputLength int `json:"maxOutputLength" yaml:"maxOutputLength"`
+	// Truncation is the part of the output kept when it exceeds its limit, KeepHead, KeepTail or KeepHeadAndTail
+	Truncation string `json:"truncation" yaml:"truncation"`
+}
+
+// OutputStreaming configures the sinks the output of the running steps is streamed to while the steps run, no sink
+// disabling the streaming
+type OutputStreaming struct {
+	// Sinks is the list of sinks, File and Reply
+	Sinks []string
+	// the File sink writes the output to files rotated once they exceed FileMaxSizeMB, keeping FileMaxBackups files
+	FileMaxSizeMB  int
+	FileMaxBackups int
+	// the Reply sink sends the output of the running step with the InProgress status at most every ReplyIntervalSeconds
+	ReplyIntervalSeconds int
+}
+
+// ResourceLimits represents the limits of the resources a document worker and its child processes can use, zero being
+// no limit. Documents can override the limits of the agent configuration.
+type ResourceLimits struct {
+	// CPUQuotaPercent is the share of one CPU the processes can use, 200 being two CPUs
+	CPUQuotaPercent int `json:"cpuQuotaPercent" yaml:"cpuQuotaPercent"`
+	MemoryLimitMB   int `json:"memoryLimitMB" yaml:"memoryLimitMB"`
+	PidsLimit       int `json:"pidsLimit" yaml:"pidsLimit"`
+	// IOWeight is the relative weight of the block I/O of the processes, from 1 to 10000 with 100 being the default
+	IOWeight int `json:"ioWeight" yaml:"ioWeight"`
 }
 
 // MfsCfg represents configuration for HummingBird service (MFS)
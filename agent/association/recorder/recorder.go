@@ -15,38 +15,44 @@
 package recorder
 
 import (
-	"fmt"
-	"os"
 	"path"
-	"sync"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
-	"github.com/aws/amazon-ssm-agent/agent/fileutil"
-	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/statestore"
 )
 
 // AssociatedDocumentName represents file recording the name of the last associated document
 const AssociatedDocumentName = "InstanceDocument.json"
 
+// StateStoreBucket is the bucket of the local state store holding the last associated document of each instance
+const StateStoreBucket = "associations"
+
 // AssociatedDocument contains the association name
 type AssociatedDocument struct {
 	AssociationID string
 }
 
-var lock sync.RWMutex
+// openStore returns the local state store, the file written by the previous versions of the agent is migrated on read
+var openStore = statestore.OpenDefault
 
 // HasExecuted returns if given document has been executed
-func HasExecuted(instanceID string, associationName string) bool {
-	lock.Lock()
-	defer lock.Unlock()
-
-	fileName := getFileName(instanceID)
-	if !fileutil.Exists(fileName) {
+func HasExecuted(log log.T, instanceID string, associationName string) bool {
+	store, err := openStore(log)
+	if err != nil {
+		log.Errorf("Failed to open the local state store: %v", err)
 		return false
 	}
+	fileName := getFileName(instanceID)
+	if _, err = statestore.MigrateFile(log, store, StateStoreBucket, instanceID, fileName); err != nil {
+		log.Warnf("Failed to migrate the last associated document %v: %v", fileName, err)
+	}
 
 	var assoDoc AssociatedDocument
-	if err := jsonutil.UnmarshalFile(fileName, &assoDoc); err != nil {
+	if err = store.View(func(tx statestore.Tx) error {
+		_, err := statestore.GetJSON(tx, StateStoreBucket, instanceID, &assoDoc)
+		return err
+	}); err != nil {
 		return false
 	}
 
@@ -54,47 +60,21 @@ func HasExecuted(instanceID string, associationName string) bool {
 }
 
 // UpdateAssociatedDocument persist last executed association name
-func UpdateAssociatedDocument(InstanceID string, associationName string) error {
-	lock.Lock()
-	defer lock.Unlock()
-	var err error
-	var content string
-
-	fileName := getFileName(InstanceID)
-	location := getLocation(InstanceID)
-
-	//verify if parent folder exist
-	if !fileutil.Exists(location) {
-		if err = fileutil.MakeDirs(location); err != nil {
-			return fmt.Errorf("cannot make directory of %v because: %v", location, err)
-		}
+func UpdateAssociatedDocument(log log.T, instanceID string, associationName string) error {
+	store, err := openStore(log)
+	if err != nil {
+		return err
 	}
 
 	associatedDoc := AssociatedDocument{}
 	associatedDoc.AssociationID = associationName
-	if content, err = jsonutil.Marshal(associatedDoc); err != nil {
-		return err
-	}
-
-	//it's fine even if we overwrite the content of previous file
-	if _, err = fileutil.WriteIntoFileWithPermissions(
-		fileName,
-		content,
-		os.FileMode(int(appconfig.ReadWriteAccess))); err != nil {
-		return err
-	}
-	return nil
-}
-
-// getLocation returns the full path for recording last associated document.
-func getLocation(instanceID string) string {
-	return path.Join(appconfig.DefaultDataStorePath,
-		instanceID,
-		appconfig.DefaultDocumentRootDirName,
-		appconfig.DefaultLocationOfAssociation)
+	//it's fine even if we overwrite the previous association
+	return store.Update(func(tx statestore.Tx) error {
+		return statestore.PutJSON(tx, StateStoreBucket, instanceID, associatedDoc)
+	})
 }
 
-// getFileName returns the full file name of the last associated document.
+// getFileName returns the full file name of the last associated document written by the previous versions of the agent.
 func getFileName(instanceID string) string {
 	return path.Join(appconfig.DefaultDataStorePath,
 		instanceID,
@@ -0,0 +1,43 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package recorder
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/statestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateAssociatedDocument(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store, _, err := statestore.Open(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+	openStore = func(log.T) (statestore.Store, error) { return store, nil }
+	defer func() { openStore = statestore.OpenDefault }()
+	logger := log.NewMockLog()
+	instanceID := "i-recorder-test"
+
+	assert.False(t, HasExecuted(logger, instanceID, "association-1"))
+	assert.NoError(t, UpdateAssociatedDocument(logger, instanceID, "association-1"))
+	assert.True(t, HasExecuted(logger, instanceID, "association-1"))
+	assert.False(t, HasExecuted(logger, instanceID, "association-2"))
+	assert.False(t, HasExecuted(logger, "i-other-instance", "association-1"))
+}
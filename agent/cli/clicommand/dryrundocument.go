@@ -0,0 +1,219 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clicommand
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/docparser"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/plugin"
+	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	dryRunDocument           = "dry-run-document"
+	dryRunDocumentContent    = "content"
+	dryRunDocumentParameters = "parameters"
+)
+
+const dryRunDocumentHelp = `NAME:
+    {{.DryRunDocumentName}}
+
+DESCRIPTION
+SYNOPSIS
+    {{.DryRunDocumentName}}
+    {{.ContentFlag}}
+    [{{.ParametersFlag}}]
+
+PARAMETERS
+    {{.ContentFlag}} (string) JSON or URL to command document.
+
+    {{.ParametersFlag}} (string) JSON object of the values of the document parameters.
+    The default values of the document are used for the parameters not provided.
+
+EXAMPLES
+    This example reports what a document would do on this instance without executing it.
+    The parameters are resolved, including the SSM parameters when the service can be reached,
+    the preconditions are evaluated and the input of every step is validated.
+
+    Command:
+
+      {{.SsmCliName}} {{.DryRunDocumentName}} {{.ContentFlag}} file:///tmp/document.json {{.ParametersFlag}} '{"commands":["date"]}'
+
+    Output:
+
+      runShell (aws:runShellScript): execute
+          Dry run: step would be executed. Step name: runShell
+
+OUTPUT
+    For every step, whether it would be executed, skipped or failed, and why.
+`
+
+type dryRunDocumentHelpParams struct {
+	SsmCliName         string
+	DryRunDocumentName string
+	ContentFlag        string
+	ParametersFlag     string
+}
+
+func init() {
+	cliutil.Register(&DryRunDocumentCommand{})
+}
+
+type DryRunDocumentCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the dry-run-document cli command
+func (c *DryRunDocumentCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation, params := c.validateDryRunDocumentInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	err, content := SendOfflineCommand{}.loadContent(parameters[dryRunDocumentContent][0])
+	if err != nil {
+		return err, ""
+	}
+
+	// the steps aren't executed, the orchestration directory only exists for the plugins resolving paths within it
+	orchestrationDir, err := ioutil.TempDir("", "ssm-dry-run")
+	if err != nil {
+		return err, ""
+	}
+	defer os.RemoveAll(orchestrationDir)
+
+	config, err := appconfig.Config(false)
+	if err != nil {
+		config = appconfig.DefaultConfig()
+	}
+	ctx := context.Default(ssmlog.SSMLogger(false), config)
+	defer ctx.Log().Flush()
+
+	parserInfo := docparser.DocumentParserInfo{
+		OrchestrationDir:  orchestrationDir,
+		DefaultWorkingDir: orchestrationDir,
+		DryRun:            true,
+	}
+	steps, err := docparser.ParseDocument(ctx.Log(), &content, parserInfo, params)
+	if err != nil {
+		return err, ""
+	}
+
+	resChan := make(chan contracts.PluginResult, len(steps))
+	results := runpluginutil.RunPlugins(ctx, steps, contracts.IOConfiguration{OrchestrationDirectory: orchestrationDir}, plugin.RegisteredWorkerPlugins(ctx), resChan, task.NewChanneledCancelFlag())
+	return nil, formatDryRunReport(steps, results)
+}
+
+// Help prints help for the dry-run-document cli command
+func (c *DryRunDocumentCommand) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("DryRunDocumentHelp").Parse(dryRunDocumentHelp)
+		params := dryRunDocumentHelpParams{
+			cliutil.SsmCliName,
+			dryRunDocument,
+			cliutil.FormatFlag(dryRunDocumentContent),
+			cliutil.FormatFlag(dryRunDocumentParameters),
+		}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (DryRunDocumentCommand) Name() string {
+	return dryRunDocument
+}
+
+// validateDryRunDocumentInput checks the subcommands and parameters for required values, format, and unsupported values
+func (DryRunDocumentCommand) validateDryRunDocumentInput(subcommands []string, parameters map[string][]string) (validation []string, params map[string]interface{}) {
+	validation = make([]string, 0)
+	if subcommands != nil && len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", dryRunDocument, subcommands), "")
+		return validation, params // invalid subcommand is an attempt to execute something that really isn't this command, so the rest of the validation is skipped in this case
+	}
+
+	// look for required parameters
+	if _, exists := parameters[dryRunDocumentContent]; !exists {
+		validation = append(validation, fmt.Sprintf("%v is required", cliutil.FormatFlag(dryRunDocumentContent)))
+	} else if len(parameters[dryRunDocumentContent]) != 1 {
+		validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(dryRunDocumentContent)))
+	} else {
+		// must be valid json or a valid URI
+		val := parameters[dryRunDocumentContent][0]
+		if !cliutil.ValidJson(val) && !cliutil.ValidUrl(val) {
+			validation = append(validation, fmt.Sprintf("%v value must be valid json or a URL", cliutil.FormatFlag(dryRunDocumentContent)))
+		}
+	}
+
+	params = make(map[string]interface{})
+	if values, exists := parameters[dryRunDocumentParameters]; exists {
+		if len(values) != 1 || json.Unmarshal([]byte(values[0]), &params) != nil {
+			validation = append(validation, fmt.Sprintf("%v value must be a json object", cliutil.FormatFlag(dryRunDocumentParameters)))
+		}
+	}
+
+	// look for unsupported parameters
+	for key := range parameters {
+		switch key {
+		case dryRunDocumentContent, dryRunDocumentParameters:
+		default:
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+		}
+	}
+	return validation, params
+}
+
+// formatDryRunReport describes, in the order of the document, whether each step would be executed, skipped or failed
+func formatDryRunReport(steps []contracts.PluginState, results map[string]*contracts.PluginResult) string {
+	var report bytes.Buffer
+	for _, step := range steps {
+		result, found := results[step.Id]
+		if !found {
+			continue
+		}
+		operation := "fail"
+		switch result.Status {
+		case contracts.ResultStatusSuccess:
+			operation = "execute"
+		case contracts.ResultStatusSkipped:
+			operation = "skip"
+		}
+		reason := result.Output
+		if reason == nil && result.Error != nil {
+			reason = result.Error.Error()
+		}
+		fmt.Fprintf(&report, "%v (%v): %v\n", step.Id, step.Name, operation)
+		if reason != nil {
+			fmt.Fprintf(&report, "    %v\n", reason)
+		}
+	}
+	return strings.TrimSuffix(report.String(), "\n")
+}
@@ -21,31 +21,60 @@ import (
 	"path"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/runcommand/resultstore"
 )
 
 const (
-	getCommand          = "get-offline-command-invocation"
-	getCommandCommandID = "command-id"
-	getCommandDetails   = "details"
+	getCommand              = "get-offline-command-invocation"
+	getCommandCommandID     = "command-id"
+	getCommandDetails       = "details"
+	getCommandStatus        = "status"
+	getCommandStartedAfter  = "started-after"
+	getCommandStartedBefore = "started-before"
 )
 
+// getCommandDateFormats are the formats accepted by the date filters, dates without a time zone are in UTC
+var getCommandDateFormats = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+// getCommandInput is the validated input of the get-offline-command-invocation cli command
+type getCommandInput struct {
+	commandID   string
+	showDetails bool
+	filter      resultstore.Filter
+}
+
 const getCommandHelp = `NAME:
     {{.GetCommandName}}
 
 DESCRIPTION
 SYNOPSIS
     {{.GetCommandName}}
-    {{.CommandIdFlag}}
-    {{.DetailsFlag}}
+    [{{.CommandIdFlag}}]
+    [{{.DetailsFlag}}]
+    [{{.StatusFlag}}]
+    [{{.StartedAfterFlag}}]
+    [{{.StartedBeforeFlag}}]
 
 PARAMETERS
     {{.CommandIdFlag}} (string) Command ID from {{.SendCommandName}}.
+    All the commands submitted locally are listed when not provided.
+
+    {{.DetailsFlag}} (boolean) true if provided. Returns the output of each plugin of the command.
 
-    {{.DetailsFlag}} (boolean) true if provided.
+    {{.StatusFlag}} (list) Lists only the commands with one of these statuses, e.g. Success Failed.
+
+    {{.StartedAfterFlag}} (string) Lists only the commands started at or after this date, e.g. 2018-03-01 or 2018-03-01T10:00:00Z.
+
+    {{.StartedBeforeFlag}} (string) Lists only the commands started before this date.
 
 EXAMPLES
     This example gets status for a command run by the local amazon-ssm-agent service.
@@ -56,18 +85,29 @@ EXAMPLES
 
     Output:
 
-      Completed
+      Success
+
+    This example lists the commands that failed since March 1st 2018.
+
+    Command:
+
+      {{.SsmCliName}} {{.GetCommandName}} {{.StatusFlag}} Failed TimedOut {{.StartedAfterFlag}} 2018-03-01
 
 OUTPUT
-    Status of command - Pending, In Progress, Complete, or Corrupt
+    Status of command - Pending, In Progress, Corrupt or the status of the completed command, e.g. Success or Failed.
+    JSON with the output of each plugin of the command when {{.DetailsFlag}} is provided.
+    JSON list of the commands, most recent first, when {{.CommandIdFlag}} is not provided.
 `
 
 type getCommandHelpParams struct {
-	SsmCliName      string
-	GetCommandName  string
-	SendCommandName string
-	CommandIdFlag   string
-	DetailsFlag     string
+	SsmCliName        string
+	GetCommandName    string
+	SendCommandName   string
+	CommandIdFlag     string
+	DetailsFlag       string
+	StatusFlag        string
+	StartedAfterFlag  string
+	StartedBeforeFlag string
 }
 
 func init() {
@@ -80,20 +120,37 @@ type GetOfflineCommand struct {
 
 // Execute validates and executes the get-offline-command-invocation cli command
 func (c *GetOfflineCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
-	validation, commandID, showDetails := c.validateGetCommandInput(subcommands, parameters)
+	validation, input := c.validateGetCommandInput(subcommands, parameters)
 	// return validation errors if any were found
 	if len(validation) > 0 {
 		return errors.New(strings.Join(validation, "\n")), ""
 	}
 
-	return c.getCommandStatus(commandID, showDetails)
+	config, err := appconfig.Config(false)
+	if err != nil {
+		config = appconfig.DefaultConfig()
+	}
+	store := resultstore.NewResultStore(config)
+	if input.commandID == "" {
+		return c.listCommands(store, input.filter)
+	}
+	return c.getCommandStatus(store, input.commandID, input.showDetails)
 }
 
 // Help prints help for the get-offline-command-invocation cli command
 func (c *GetOfflineCommand) Help() string {
 	if len(c.helpText) == 0 {
 		t, _ := template.New("GetOfflineCommandHelp").Parse(getCommandHelp)
-		params := getCommandHelpParams{cliutil.SsmCliName, getCommand, sendCommand, cliutil.FormatFlag(getCommandCommandID), cliutil.FormatFlag(getCommandDetails)}
+		params := getCommandHelpParams{
+			cliutil.SsmCliName,
+			getCommand,
+			sendCommand,
+			cliutil.FormatFlag(getCommandCommandID),
+			cliutil.FormatFlag(getCommandDetails),
+			cliutil.FormatFlag(getCommandStatus),
+			cliutil.FormatFlag(getCommandStartedAfter),
+			cliutil.FormatFlag(getCommandStartedBefore),
+		}
 		buf := new(bytes.Buffer)
 		t.Execute(buf, params)
 		c.helpText = buf.String()
@@ -107,47 +164,110 @@ func (GetOfflineCommand) Name() string {
 }
 
 // validateGetCommandInput checks the subcommands and parameters for required values, format, and unsupported values
-func (GetOfflineCommand) validateGetCommandInput(subcommands []string, parameters map[string][]string) (validation []string, commandID string, showDetails bool) {
+func (GetOfflineCommand) validateGetCommandInput(subcommands []string, parameters map[string][]string) (validation []string, input getCommandInput) {
 	validation = make([]string, 0)
 
 	if subcommands != nil && len(subcommands) > 0 {
 		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", getCommand, subcommands), "")
-		return validation, "", false // invalid subcommand is an attempt to execute something that really isn't this command, so the rest of the validation is skipped in this case
-	}
-
-	// look for required parameters
-	if _, exists := parameters[getCommandCommandID]; !exists {
-		validation = append(validation, fmt.Sprintf("%v is required", cliutil.FormatFlag(getCommandCommandID)))
-	} else if len(parameters[getCommandCommandID]) != 1 {
-		validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v",
-			cliutil.FormatFlag(getCommandCommandID)))
-	} else {
-		// must be a 36 character UUID
-		commandID = parameters[getCommandCommandID][0]
-		if commandIdLen := len(commandID); commandIdLen != 36 {
-			validation = append(validation,
-				fmt.Sprintf("Invalid length for parameter %v.  Length was %v should be 36",
-					cliutil.FormatFlag(getCommandCommandID), commandIdLen))
+		return validation, input // invalid subcommand is an attempt to execute something that really isn't this command, so the rest of the validation is skipped in this case
+	}
+
+	if _, exists := parameters[getCommandCommandID]; exists {
+		if len(parameters[getCommandCommandID]) != 1 {
+			validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v",
+				cliutil.FormatFlag(getCommandCommandID)))
+		} else {
+			// must be a 36 character UUID
+			input.commandID = parameters[getCommandCommandID][0]
+			if commandIdLen := len(input.commandID); commandIdLen != 36 {
+				validation = append(validation,
+					fmt.Sprintf("Invalid length for parameter %v.  Length was %v should be 36",
+						cliutil.FormatFlag(getCommandCommandID), commandIdLen))
+			}
+		}
+		// filters only apply when listing commands
+		for _, key := range []string{getCommandStatus, getCommandStartedAfter, getCommandStartedBefore} {
+			if _, exists := parameters[key]; exists {
+				validation = append(validation, fmt.Sprintf("%v can't be used with %v",
+					cliutil.FormatFlag(key), cliutil.FormatFlag(getCommandCommandID)))
+			}
 		}
 	}
-	_, showDetails = parameters[getCommandDetails]
-	if showDetails && len(parameters[getCommandDetails]) > 0 {
+	_, input.showDetails = parameters[getCommandDetails]
+	if input.showDetails && len(parameters[getCommandDetails]) > 0 {
 		validation = append(validation, fmt.Sprintf("flag %v should not have any values", cliutil.FormatFlag(getCommandDetails)))
+	} else if input.showDetails && input.commandID == "" {
+		validation = append(validation, fmt.Sprintf("flag %v requires %v", cliutil.FormatFlag(getCommandDetails), cliutil.FormatFlag(getCommandCommandID)))
 	}
 
+	if statuses, exists := parameters[getCommandStatus]; exists {
+		if len(statuses) == 0 {
+			validation = append(validation, fmt.Sprintf("expected at least 1 value for parameter %v", cliutil.FormatFlag(getCommandStatus)))
+		}
+		for _, status := range statuses {
+			input.filter.Status = append(input.filter.Status, contracts.ResultStatus(status))
+		}
+	}
+	input.filter.StartedAfter = validateDateParameter(parameters, getCommandStartedAfter, &validation)
+	input.filter.StartedBefore = validateDateParameter(parameters, getCommandStartedBefore, &validation)
+
 	// look for unsupported parameters
 	for key := range parameters {
-		if key != getCommandCommandID && key != getCommandDetails {
+		switch key {
+		case getCommandCommandID, getCommandDetails, getCommandStatus, getCommandStartedAfter, getCommandStartedBefore:
+		default:
 			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
 		}
 	}
-	return validation, commandID, showDetails
+	return validation, input
+}
+
+// validateDateParameter parses the value of a date parameter, the zero time is returned when the parameter is not provided
+func validateDateParameter(parameters map[string][]string, key string, validation *[]string) time.Time {
+	values, exists := parameters[key]
+	if !exists {
+		return time.Time{}
+	}
+	if len(values) != 1 {
+		*validation = append(*validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+		return time.Time{}
+	}
+	for _, format := range getCommandDateFormats {
+		if date, err := time.Parse(format, values[0]); err == nil {
+			return date
+		}
+	}
+	*validation = append(*validation, fmt.Sprintf("invalid date %v for parameter %v, expected a date such as 2018-03-01 or 2018-03-01T10:00:00Z",
+		values[0], cliutil.FormatFlag(key)))
+	return time.Time{}
+}
+
+// listCommands returns the commands submitted locally that match the filter as json
+func (GetOfflineCommand) listCommands(store *resultstore.ResultStore, filter resultstore.Filter) (error, string) {
+	invocations := store.List(log.SilentLogger(), filter)
+	result, err := jsonutil.MarshalIndent(invocations)
+	return err, result
 }
 
-// getCommandStatus looks for the command in the local orchestration folders and returns status and optionally details
-func (c *GetOfflineCommand) getCommandStatus(commandID string, showDetails bool) (error, string) {
+// getCommandStatus looks for the command in the local result index and orchestration folders and returns status and optionally details
+func (c *GetOfflineCommand) getCommandStatus(store *resultstore.ResultStore, commandID string, showDetails bool) (error, string) {
+	if details, err := store.Get(log.SilentLogger(), commandID); err == nil {
+		if showDetails {
+			result, err := jsonutil.MarshalIndent(details)
+			return err, result
+		}
+		switch details.Status {
+		case contracts.ResultStatusNotStarted:
+			return nil, "Pending"
+		case contracts.ResultStatusInProgress:
+			return nil, "In Progress"
+		default:
+			return nil, string(details.Status)
+		}
+	}
+
 	// Look for file with commandID as name in each orchestration folder
-	// If found, return status (or lots of details if showDetails is set)
+	// If found, return status
 	if c.isCommandCompleted(commandID) {
 		return nil, "Complete"
 	}
@@ -169,18 +289,14 @@ func (c *GetOfflineCommand) isCommandCompleted(commandID string) bool {
 	return fileutil.Exists(path.Join(appconfig.LocalCommandRootCompleted, commandID))
 }
 
+// isCommandInState looks for the state of the command without migrating nor writing the state of the running agent
 func (GetOfflineCommand) isCommandInState(stateFolder string, commandID string) bool {
 	// TODO:MF: Find a way to get the current instanceID instead of trying all possible folders
 	dirs, _ := fileutil.GetDirectoryNames(appconfig.DefaultDataStorePath)
+	docMgr := docmanager.NewDocumentFileMgr(appconfig.DefaultDataStorePath, appconfig.DefaultDocumentRootDirName, appconfig.DefaultLocationOfState)
 
 	for _, dir := range dirs {
-		potentialFolder := path.Join(appconfig.DefaultDataStorePath,
-			dir,
-			appconfig.DefaultDocumentRootDirName,
-			appconfig.DefaultLocationOfState,
-			stateFolder,
-			commandID)
-		if fileutil.Exists(potentialFolder) {
+		if docMgr.HasDocumentState(log.SilentLogger(), commandID, dir, stateFolder) {
 			return true
 		}
 	}
@@ -0,0 +1,230 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outputstream"
+)
+
+const (
+	tailOutput          = "tail-output"
+	tailOutputCommandID = "command-id"
+	tailOutputStep      = "step"
+	tailOutputStream    = "stream"
+	tailOutputBytes     = "bytes"
+
+	defaultTailOutputBytes = 4096
+)
+
+// tailOutputInput is the validated input of the tail-output cli command
+type tailOutputInput struct {
+	commandID string
+	step      string
+	stream    string
+	bytes     int64
+}
+
+const tailOutputHelp = `NAME:
+    {{.TailOutputName}}
+
+DESCRIPTION
+SYNOPSIS
+    {{.TailOutputName}}
+    {{.CommandIdFlag}}
+    [{{.StepFlag}}]
+    [{{.StreamFlag}}]
+    [{{.BytesFlag}}]
+
+PARAMETERS
+    {{.CommandIdFlag}} (string) ID of the command.
+
+    {{.StepFlag}} (string) ID of the step of the command, e.g. aws:runShellScript or the name of the step.
+    The step which wrote its output last is used when not provided.
+
+    {{.StreamFlag}} (string) stdout or stderr. Defaults to stdout.
+
+    {{.BytesFlag}} (number) Number of bytes to print from the end of the output. Defaults to {{.DefaultBytes}}.
+
+EXAMPLES
+    This example prints the end of the output written so far by a running command.
+    The output is only available when the {{.FileSink}} sink of OutputStreaming is enabled in the agent configuration.
+
+    Command:
+
+      {{.SsmCliName}} {{.TailOutputName}} {{.CommandIdFlag}} 01234567-890a-bcde-f012-34567890abcd
+
+OUTPUT
+    The end of the output of the step.
+`
+
+type tailOutputHelpParams struct {
+	SsmCliName     string
+	TailOutputName string
+	CommandIdFlag  string
+	StepFlag       string
+	StreamFlag     string
+	BytesFlag      string
+	DefaultBytes   int
+	FileSink       string
+}
+
+func init() {
+	cliutil.Register(&TailOutputCommand{})
+}
+
+type TailOutputCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the tail-output cli command
+func (c *TailOutputCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation, input := c.validateTailOutputInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	dir, found := c.findStreamDir(input.commandID)
+	if !found {
+		return fmt.Errorf("No output streamed for command ID %v", input.commandID), ""
+	}
+	step := input.step
+	if step == "" {
+		var err error
+		if step, err = outputstream.LatestStep(dir); err != nil {
+			return err, ""
+		}
+	}
+	output, err := outputstream.ReadTail(dir, step, input.stream, input.bytes)
+	return err, output
+}
+
+// Help prints help for the tail-output cli command
+func (c *TailOutputCommand) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("TailOutputHelp").Parse(tailOutputHelp)
+		params := tailOutputHelpParams{
+			cliutil.SsmCliName,
+			tailOutput,
+			cliutil.FormatFlag(tailOutputCommandID),
+			cliutil.FormatFlag(tailOutputStep),
+			cliutil.FormatFlag(tailOutputStream),
+			cliutil.FormatFlag(tailOutputBytes),
+			defaultTailOutputBytes,
+			appconfig.OutputStreamSinkFile,
+		}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (TailOutputCommand) Name() string {
+	return tailOutput
+}
+
+// validateTailOutputInput checks the subcommands and parameters for required values, format, and unsupported values
+func (TailOutputCommand) validateTailOutputInput(subcommands []string, parameters map[string][]string) (validation []string, input tailOutputInput) {
+	validation = make([]string, 0)
+
+	if subcommands != nil && len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", tailOutput, subcommands), "")
+		return validation, input // invalid subcommand is an attempt to execute something that really isn't this command, so the rest of the validation is skipped in this case
+	}
+
+	if len(parameters[tailOutputCommandID]) != 1 {
+		validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v",
+			cliutil.FormatFlag(tailOutputCommandID)))
+	} else {
+		// must be a 36 character UUID
+		input.commandID = parameters[tailOutputCommandID][0]
+		if commandIdLen := len(input.commandID); commandIdLen != 36 {
+			validation = append(validation,
+				fmt.Sprintf("Invalid length for parameter %v.  Length was %v should be 36",
+					cliutil.FormatFlag(tailOutputCommandID), commandIdLen))
+		}
+	}
+
+	if values, exists := parameters[tailOutputStep]; exists {
+		if len(values) != 1 {
+			validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(tailOutputStep)))
+		} else {
+			input.step = values[0]
+		}
+	}
+
+	outputConfig := iohandler.DefaultOutputConfig()
+	input.stream = outputConfig.StdoutFileName
+	if values, exists := parameters[tailOutputStream]; exists {
+		if len(values) != 1 || (values[0] != outputConfig.StdoutFileName && values[0] != outputConfig.StderrFileName) {
+			validation = append(validation, fmt.Sprintf("expected %v or %v for parameter %v",
+				outputConfig.StdoutFileName, outputConfig.StderrFileName, cliutil.FormatFlag(tailOutputStream)))
+		} else {
+			input.stream = values[0]
+		}
+	}
+
+	input.bytes = defaultTailOutputBytes
+	if values, exists := parameters[tailOutputBytes]; exists {
+		var err error
+		if len(values) == 1 {
+			input.bytes, err = strconv.ParseInt(values[0], 10, 64)
+		}
+		if len(values) != 1 || err != nil || input.bytes <= 0 {
+			validation = append(validation, fmt.Sprintf("expected a positive number for parameter %v", cliutil.FormatFlag(tailOutputBytes)))
+		}
+	}
+
+	// look for unsupported parameters
+	for key := range parameters {
+		switch key {
+		case tailOutputCommandID, tailOutputStep, tailOutputStream, tailOutputBytes:
+		default:
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+		}
+	}
+	return validation, input
+}
+
+// findStreamDir looks for the folder the output of the command is streamed to
+func (TailOutputCommand) findStreamDir(commandID string) (string, bool) {
+	// the cli doesn't know the instance ID, so all the instance folders are searched
+	dirs, _ := fileutil.GetDirectoryNames(appconfig.DefaultDataStorePath)
+
+	for _, dir := range dirs {
+		potentialFolder := path.Join(appconfig.DefaultDataStorePath,
+			dir,
+			appconfig.OutputStreamRootDirName,
+			commandID)
+		if fileutil.Exists(potentialFolder) {
+			return potentialFolder, true
+		}
+	}
+
+	return "", false
+}
@@ -16,6 +16,8 @@ package contracts
 
 import (
 	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 )
 
 // DocumentType defines the type of document persists locally.
@@ -49,6 +51,10 @@ type PluginState struct {
 type OSProcInfo struct {
 	Pid       int
 	StartTime time.Time
+	// StartTicks and BootID identify the process on Linux, the start time of the process in clock ticks after the
+	// boot and the boot it started in, so that a reused pid or a reboot isn't mistaken for the process
+	StartTicks uint64
+	BootID     string
 }
 
 // DocumentInfo represents information stored as interim state for a document
@@ -75,8 +81,29 @@ type IOConfiguration struct {
 	OrchestrationDirectory string
 	OutputS3BucketName     string
 	OutputS3KeyPrefix      string
+	// CloudWatchLogGroupName is the log group the output of the steps is sent to, no output is sent when empty
+	CloudWatchLogGroupName string
+	// CloudWatchLogStreamPrefix prefixes the names of the log streams of the steps
+	CloudWatchLogStreamPrefix string
+	// OutputLimits limits the output of the steps reported in their results
+	OutputLimits appconfig.OutputLimits
+	// StreamOutput requests the output of the steps to be streamed while they run
+	StreamOutput bool
+	// OutputStream receives the streamed output, it's set by the process running the steps
+	OutputStream OutputStream `json:"-"`
+}
+
+// OutputChunk is a part of the output of a running step
+type OutputChunk struct {
+	PluginID string
+	// Stream is the output the chunk was written to, stdout or stderr
+	Stream string
+	Data   string
 }
 
+// OutputStream receives the output chunks of the running steps in the order the steps wrote them
+type OutputStream func(chunk OutputChunk)
+
 // DocumentState represents information relevant to a command that gets executed by agent
 type DocumentState struct {
 	DocumentInformation        DocumentInfo
@@ -85,6 +112,7 @@ type DocumentState struct {
 	InstancePluginsInformation []PluginState
 	CancelInformation          CancelCommandInfo
 	IOConfig                   IOConfiguration
+	ResourceLimits             appconfig.ResourceLimits
 }
 
 // IsRebootRequired returns if reboot is needed
@@ -15,6 +15,10 @@
 // necessary for communication and sharing within the agent.
 package contracts
 
+import (
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
 // ResultStatus provides the granular status of a plugin.
 // These are internal states maintained by agent during the execution of a command/config
 type ResultStatus string
@@ -171,18 +175,46 @@ type PluginConfig struct {
 	Description string      `json:"description" yaml:"description"`
 }
 
+const (
+	// OnFailureContinue runs the next step when a step fails
+	OnFailureContinue = "continue"
+	// OnFailureExit stops the document when a step fails
+	OnFailureExit = "exit"
+	// OnFailureSuccessAndExit stops the document when a step fails and reports the step as successful
+	OnFailureSuccessAndExit = "successAndExit"
+)
+
 // InstancePluginConfig stores plugin configuration
 type InstancePluginConfig struct {
-	Action        string              `json:"action" yaml:"action"` // plugin name
-	Inputs        interface{}         `json:"inputs" yaml:"inputs"` // Properties
-	MaxAttempts   int                 `json:"maxAttempts" yaml:"maxAttempts"`
-	Name          string              `json:"name" yaml:"name"` // unique identifier
-	OnFailure     string              `json:"onFailure" yaml:"onFailure"`
-	Settings      interface{}         `json:"settings" yaml:"settings"`
-	Timeout       int                 `json:"timeoutSeconds" yaml:"timeoutSeconds"`
-	Preconditions map[string][]string `json:"precondition" yaml:"precondition"`
+	Action        string                 `json:"action" yaml:"action"` // plugin name
+	Inputs        interface{}            `json:"inputs" yaml:"inputs"` // Properties
+	MaxAttempts   int                    `json:"maxAttempts" yaml:"maxAttempts"`
+	Name          string                 `json:"name" yaml:"name"` // unique identifier
+	OnFailure     string                 `json:"onFailure" yaml:"onFailure"`
+	Outputs       []StepOutput           `json:"outputs" yaml:"outputs"`
+	Settings      interface{}            `json:"settings" yaml:"settings"`
+	Timeout       int                    `json:"timeoutSeconds" yaml:"timeoutSeconds"`
+	Preconditions map[string]interface{} `json:"precondition" yaml:"precondition"`
+	// OutputLimits overrides the limits of the output of the step set in the agent configuration
+	OutputLimits appconfig.OutputLimits `json:"outputLimits" yaml:"outputLimits"`
 }
 
+// StepOutput declares a named output of a step captured from its standard output with one of the selectors,
+// later steps reference it as {{ stepName.outputName }}
+type StepOutput struct {
+	Name     string `json:"name" yaml:"name"`
+	Regex    string `json:"regex" yaml:"regex"`       // first capture group of the regex, or the whole match
+	JSONPath string `json:"jsonPath" yaml:"jsonPath"` // path of a value in the json printed by the step
+	Key      string `json:"key" yaml:"key"`           // value of the last key=value line for the key
+}
+
+// Outputs that every step provides to the steps that follow it
+const (
+	StepOutputStatus         = "status"
+	StepOutputExitCode       = "exitCode"
+	StepOutputStandardOutput = "output"
+)
+
 // DocumentContent object which represents ssm document content.
 type DocumentContent struct {
 	SchemaVersion string                   `json:"schemaVersion" yaml:"schemaVersion"`
@@ -190,6 +222,16 @@ type DocumentContent struct {
 	RuntimeConfig map[string]*PluginConfig `json:"runtimeConfig" yaml:"runtimeConfig"`
 	MainSteps     []*InstancePluginConfig  `json:"mainSteps" yaml:"mainSteps"`
 	Parameters    map[string]*Parameter    `json:"parameters" yaml:"parameters"`
+	// RunAsUser is the local user the commands of the aws:runShellScript steps run as, the orchestration directories and
+	// output files of the steps being given to the user once they ran. Only aws:runShellScript supports it: the steps of
+	// the other plugins fail when a user is set, and the document worker itself still runs as the agent user.
+	RunAsUser string `json:"runAsUser" yaml:"runAsUser"`
+	// ResourceLimits overrides the resource limits of the document worker set in the agent configuration
+	ResourceLimits appconfig.ResourceLimits `json:"resourceLimits" yaml:"resourceLimits"`
+	// DryRun requests the steps to be validated and reported without being executed
+	DryRun bool `json:"dryRun" yaml:"dryRun"`
+	// TimeoutSeconds is the execution timeout of the steps whose plugin accepts a timeout and that don't set their own
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty" yaml:"timeoutSeconds"`
 }
 
 // AdditionalInfo section in agent response
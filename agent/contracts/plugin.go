@@ -15,8 +15,12 @@
 package contracts
 
 import (
+	"bytes"
+	"encoding/json"
+	"sort"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/updateutil"
@@ -28,18 +32,53 @@ const (
 
 // PluginResult represents a plugin execution result.
 type PluginResult struct {
-	PluginID           string       `json:"pluginID"`
-	PluginName         string       `json:"pluginName"`
-	Status             ResultStatus `json:"status"`
-	Code               int          `json:"code"`
-	Output             interface{}  `json:"output"`
-	StartDateTime      time.Time    `json:"startDateTime"`
-	EndDateTime        time.Time    `json:"endDateTime"`
-	OutputS3BucketName string       `json:"outputS3BucketName"`
-	OutputS3KeyPrefix  string       `json:"outputS3KeyPrefix"`
-	Error              error        `json:"-"`
-	StandardOutput     string       `json:"standardOutput"`
-	StandardError      string       `json:"standardError"`
+	PluginID           string            `json:"pluginID"`
+	PluginName         string            `json:"pluginName"`
+	Status             ResultStatus      `json:"status"`
+	Code               int               `json:"code"`
+	Output             interface{}       `json:"output"`
+	StartDateTime      time.Time         `json:"startDateTime"`
+	EndDateTime        time.Time         `json:"endDateTime"`
+	OutputS3BucketName string            `json:"outputS3BucketName"`
+	OutputS3KeyPrefix  string            `json:"outputS3KeyPrefix"`
+	Error              error             `json:"-"`
+	StandardOutput     string            `json:"standardOutput"`
+	StandardError      string            `json:"standardError"`
+	Attempts           []PluginAttempt   `json:"attempts,omitempty"`
+	StepOutputs        map[string]string `json:"stepOutputs,omitempty"`
+	// PeakMemoryBytes is the peak memory used by the document worker and its processes up to the end of the step,
+	// CPUTimeMillis the CPU time they used during the step; both are only reported for workers in a control group
+	PeakMemoryBytes int64 `json:"peakMemoryBytes,omitempty"`
+	CPUTimeMillis   int64 `json:"cpuTimeMillis,omitempty"`
+	// FailureReason tells why the step failed when the exit code doesn't
+	FailureReason string `json:"failureReason,omitempty"`
+	// StandardOutputTruncation and StandardErrorTruncation are only set when the output exceeded its limit
+	StandardOutputTruncation *OutputTruncation `json:"standardOutputTruncation,omitempty"`
+	StandardErrorTruncation  *OutputTruncation `json:"standardErrorTruncation,omitempty"`
+	// ExitedOnFailure is set on the step that stopped the document through its onFailure action, a resumed document
+	// relies on it as successAndExit reports the failed step as Success
+	ExitedOnFailure bool `json:"exitedOnFailure,omitempty"`
+}
+
+// OutputTruncation describes the part of the output of a step dropped from its result.
+type OutputTruncation struct {
+	TotalBytes   int `json:"totalBytes"`
+	DroppedBytes int `json:"droppedBytes"`
+	// FullOutputPath is the local file holding the complete output
+	FullOutputPath string `json:"fullOutputPath,omitempty"`
+}
+
+// FailureReasonOutOfMemory is the reason of the failure of steps whose processes were killed by the OOM killer
+const FailureReasonOutOfMemory = "OutOfMemory"
+
+// PluginAttempt represents the result of a single attempt of a step that is configured with maxAttempts.
+type PluginAttempt struct {
+	Attempt       int          `json:"attempt"`
+	Status        ResultStatus `json:"status"`
+	Code          int          `json:"code"`
+	Error         string       `json:"error,omitempty"`
+	StartDateTime time.Time    `json:"startDateTime"`
+	EndDateTime   time.Time    `json:"endDateTime"`
 }
 
 // IPlugin is interface for authoring a functionality of work.
@@ -75,9 +114,84 @@ type Configuration struct {
 	PluginName              string
 	PluginID                string
 	DefaultWorkingDirectory string
-	Preconditions           map[string][]string
+	Preconditions           Preconditions
 	IsPreconditionEnabled   bool
+	MaxAttempts             int
+	OnFailure               string
+	Outputs                 []StepOutput
 	CurrentAssociations     []string
+	RunAsUser               string
+	OutputLimits            appconfig.OutputLimits
+	// DryRun reports whether the step would be executed instead of executing it
+	DryRun bool
+	// TimeoutSeconds is the execution timeout of the document, it applies to the plugins accepting timeoutSeconds
+	TimeoutSeconds int
+}
+
+// Operators of the step preconditions of cross-platform documents
+const (
+	PreconditionStringEquals              = "StringEquals"
+	PreconditionStringNotEquals           = "StringNotEquals"
+	PreconditionStringLike                = "StringLike"
+	PreconditionVersionGreaterThanOrEqual = "VersionGreaterThanOrEqual"
+	PreconditionAnd                       = "And"
+	PreconditionOr                        = "Or"
+	PreconditionNot                       = "Not"
+)
+
+// Precondition is a step precondition of a cross-platform document. Comparison operators apply to the
+// Arguments while And, Or and Not apply to the nested Conditions.
+type Precondition struct {
+	Operator   string
+	Arguments  []PreconditionArgument `json:",omitempty"`
+	Conditions []Precondition         `json:",omitempty"`
+}
+
+// Preconditions are the step preconditions of a document. The agents without precondition operands persisted them in
+// the document state as a map of each operator to its resolved arguments, which is still accepted when it's loaded.
+type Preconditions []Precondition
+
+// UnmarshalJSON unmarshals the list of preconditions, or the map of preconditions persisted by the previous agents
+func (p *Preconditions) UnmarshalJSON(data []byte) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var preconditions []Precondition
+		if err := json.Unmarshal(data, &preconditions); err != nil {
+			return err
+		}
+		*p = preconditions
+		return nil
+	}
+
+	var legacy map[string][]string
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	var operators []string
+	for operator := range legacy {
+		operators = append(operators, operator)
+	}
+	sort.Strings(operators)
+	preconditions := Preconditions{}
+	for _, operator := range operators {
+		precondition := Precondition{Operator: operator}
+		// the arguments were persisted once the document parameters were replaced
+		for _, argument := range legacy[operator] {
+			precondition.Arguments = append(precondition.Arguments, PreconditionArgument{
+				InitialArgumentValue:  argument,
+				ResolvedArgumentValue: argument,
+			})
+		}
+		preconditions = append(preconditions, precondition)
+	}
+	*p = preconditions
+	return nil
+}
+
+// PreconditionArgument is an operand of a precondition, it keeps the value written in the document
+// along with the value obtained once the document parameters are replaced.
+type PreconditionArgument struct {
+	InitialArgumentValue  string
+	ResolvedArgumentValue string
 }
 
 // Plugin wraps the plugin configuration and plugin result.
@@ -0,0 +1,71 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package contracts contains objects for parsing and encoding MDS/SSM messages.
+package contracts
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDocumentStateOfPreviousAgent(t *testing.T) {
+	var docState DocumentState
+	assert.NoError(t, jsonutil.UnmarshalFile("testdata/docstate_previous_agent.json", &docState))
+
+	assert.Len(t, docState.InstancePluginsInformation, 2)
+	assert.Equal(t, Preconditions{
+		{
+			Operator: PreconditionStringEquals,
+			Arguments: []PreconditionArgument{
+				{InitialArgumentValue: "platformType", ResolvedArgumentValue: "platformType"},
+				{InitialArgumentValue: "Linux", ResolvedArgumentValue: "Linux"},
+			},
+		},
+	}, docState.InstancePluginsInformation[0].Configuration.Preconditions)
+	assert.Empty(t, docState.InstancePluginsInformation[1].Configuration.Preconditions)
+}
+
+func TestPreconditionsRoundTrip(t *testing.T) {
+	configuration := Configuration{
+		Preconditions: Preconditions{
+			{
+				Operator: PreconditionNot,
+				Conditions: []Precondition{
+					{
+						Operator: PreconditionStringEquals,
+						Arguments: []PreconditionArgument{
+							{InitialArgumentValue: "{{ Platform }}", ResolvedArgumentValue: "Windows"},
+							{InitialArgumentValue: "platformType", ResolvedArgumentValue: "platformType"},
+						},
+					},
+				},
+			},
+		},
+	}
+	content, err := json.Marshal(configuration)
+	assert.NoError(t, err)
+
+	var loaded Configuration
+	assert.NoError(t, json.Unmarshal(content, &loaded))
+	assert.Equal(t, configuration.Preconditions, loaded.Preconditions)
+}
+
+func TestInstancePluginConfigAcceptsPreviousPreconditions(t *testing.T) {
+	var step InstancePluginConfig
+	assert.NoError(t, json.Unmarshal([]byte(`{"action":"aws:runShellScript","precondition":{"StringEquals":["platformType","Linux"]}}`), &step))
+	assert.Equal(t, map[string]interface{}{"StringEquals": []interface{}{"platformType", "Linux"}}, step.Preconditions)
+}
@@ -24,8 +24,11 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/parameterstore"
 	"github.com/aws/amazon-ssm-agent/agent/updateutil"
 
+	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -33,6 +36,8 @@ const (
 	preconditionSchemaVersion string = "2.2"
 )
 
+var stepOutputNameRegex = regexp.MustCompile("^[a-zA-Z0-9]+$")
+
 // DocumentParserInfo represents the parsed information from the request
 type DocumentParserInfo struct {
 	OrchestrationDir  string
@@ -41,6 +46,11 @@ type DocumentParserInfo struct {
 	MessageId         string
 	DocumentId        string
 	DefaultWorkingDir string
+	// the output of the steps is sent to CloudWatch Logs when CloudWatchLogGroup is set
+	CloudWatchLogGroup        string
+	CloudWatchLogStreamPrefix string
+	// DryRun validates and reports the steps without executing them, whether or not the document requests it
+	DryRun bool
 }
 
 // InitializeDocState is a method to obtain the state of the document.
@@ -56,9 +66,11 @@ func InitializeDocState(log log.T,
 	docState.DocumentType = documentType
 	docState.DocumentInformation = docInfo
 	docState.IOConfig = contracts.IOConfiguration{
-		OrchestrationDirectory: parserInfo.OrchestrationDir,
-		OutputS3BucketName:     parserInfo.S3Bucket,
-		OutputS3KeyPrefix:      parserInfo.S3Prefix,
+		OrchestrationDirectory:    parserInfo.OrchestrationDir,
+		OutputS3BucketName:        parserInfo.S3Bucket,
+		OutputS3KeyPrefix:         parserInfo.S3Prefix,
+		CloudWatchLogGroupName:    parserInfo.CloudWatchLogGroup,
+		CloudWatchLogStreamPrefix: parserInfo.CloudWatchLogStreamPrefix,
 	}
 
 	pluginInfo, err := ParseDocument(log, docContent, parserInfo, params)
@@ -66,6 +78,7 @@ func InitializeDocState(log log.T,
 		return
 	}
 	docState.InstancePluginsInformation = pluginInfo
+	docState.ResourceLimits = appconfig.ValidateResourceLimits(docContent.ResourceLimits)
 	return docState, nil
 }
 
@@ -78,11 +91,15 @@ func ParseDocument(log log.T,
 	if err = validateSchema(docContent.SchemaVersion); err != nil {
 		return
 	}
-	if err = getValidatedParameters(log, params, docContent); err != nil {
+	if parserInfo.DryRun {
+		docContent.DryRun = true
+	}
+	var validParameters map[string]interface{}
+	if validParameters, err = getValidatedParameters(log, params, docContent); err != nil {
 		return
 	}
 
-	return parseDocumentContent(*docContent, parserInfo)
+	return parseDocumentContent(log, *docContent, parserInfo, validParameters)
 }
 
 // ParseParameters is a method to parse the ssm parameters into a string map interface
@@ -113,7 +130,7 @@ func ParseParameters(log log.T, params map[string][]*string, paramsDef map[strin
 }
 
 // parseDocumentContent parses an SSM Document and returns the plugin information
-func parseDocumentContent(docContent contracts.DocumentContent, parserInfo DocumentParserInfo) (pluginsInfo []contracts.PluginState, err error) {
+func parseDocumentContent(log log.T, docContent contracts.DocumentContent, parserInfo DocumentParserInfo, params map[string]interface{}) (pluginsInfo []contracts.PluginState, err error) {
 
 	switch docContent.SchemaVersion {
 	case "1.0", "1.2":
@@ -121,7 +138,7 @@ func parseDocumentContent(docContent contracts.DocumentContent, parserInfo Docum
 
 	case "2.0", "2.0.1", "2.0.2", "2.0.3", "2.2":
 
-		return parsePluginStateForV20Schema(docContent, parserInfo.OrchestrationDir, parserInfo.S3Bucket, parserInfo.S3Prefix, parserInfo.MessageId, parserInfo.DocumentId, parserInfo.DefaultWorkingDir)
+		return parsePluginStateForV20Schema(log, docContent, parserInfo.OrchestrationDir, parserInfo.S3Bucket, parserInfo.S3Prefix, parserInfo.MessageId, parserInfo.DocumentId, parserInfo.DefaultWorkingDir, params)
 
 	default:
 		return pluginsInfo, fmt.Errorf("Unsupported document")
@@ -152,6 +169,9 @@ func parsePluginStateForV10Schema(
 			PluginName:              pluginName,
 			PluginID:                pluginName,
 			DefaultWorkingDirectory: defaultWorkingDir,
+			RunAsUser:               docContent.RunAsUser,
+			DryRun:                  docContent.DryRun,
+			TimeoutSeconds:          docContent.TimeoutSeconds,
 		}
 		pluginConfigurations = append(pluginConfigurations, &config)
 	}
@@ -168,8 +188,10 @@ func parsePluginStateForV10Schema(
 
 // parsePluginStateForV20Schema initializes instancePluginsInfo for the docState. Used by document v2.0.
 func parsePluginStateForV20Schema(
+	log log.T,
 	docContent contracts.DocumentContent,
-	orchestrationDir, s3Bucket, s3Prefix, messageID, documentID, defaultWorkingDir string) (pluginsInfo []contracts.PluginState, err error) {
+	orchestrationDir, s3Bucket, s3Prefix, messageID, documentID, defaultWorkingDir string,
+	params map[string]interface{}) (pluginsInfo []contracts.PluginState, err error) {
 
 	if len(docContent.MainSteps) == 0 {
 		return pluginsInfo, fmt.Errorf("Unsupported schema format")
@@ -183,6 +205,15 @@ func parsePluginStateForV20Schema(
 	// getPluginConfigurations converts from PluginConfig (structure from the MDS message) to plugin.Configuration (structure expected by the plugin)
 	for _, instancePluginConfig := range docContent.MainSteps {
 		pluginName := instancePluginConfig.Action
+		if err = validateOnFailure(instancePluginConfig); err != nil {
+			return
+		}
+		if err = validateOutputs(instancePluginConfig); err != nil {
+			return
+		}
+		if err = validateOutputLimits(instancePluginConfig); err != nil {
+			return
+		}
 		config := contracts.Configuration{
 			Settings:                instancePluginConfig.Settings,
 			Properties:              instancePluginConfig.Inputs,
@@ -193,9 +224,16 @@ func parsePluginStateForV20Schema(
 			BookKeepingFileName:     documentID,
 			PluginName:              pluginName,
 			PluginID:                instancePluginConfig.Name,
-			Preconditions:           instancePluginConfig.Preconditions,
+			Preconditions:           parsePreconditions(log, instancePluginConfig.Preconditions, params),
 			IsPreconditionEnabled:   isPreconditionEnabled,
 			DefaultWorkingDirectory: defaultWorkingDir,
+			MaxAttempts:             instancePluginConfig.MaxAttempts,
+			OnFailure:               instancePluginConfig.OnFailure,
+			Outputs:                 instancePluginConfig.Outputs,
+			RunAsUser:               docContent.RunAsUser,
+			OutputLimits:            instancePluginConfig.OutputLimits,
+			DryRun:                  docContent.DryRun,
+			TimeoutSeconds:          docContent.TimeoutSeconds,
 		}
 
 		var plugin contracts.PluginState
@@ -207,6 +245,23 @@ func parsePluginStateForV20Schema(
 	return
 }
 
+// validateOutputLimits checks that the step declares a supported truncation and non negative output limits
+func validateOutputLimits(step *contracts.InstancePluginConfig) error {
+	limits := step.OutputLimits
+	if limits.Truncation != "" && !appconfig.IsOutputTruncation(limits.Truncation) {
+		return fmt.Errorf("Unsupported outputLimits truncation '%s' for step %s, supported values are %s, %s and %s",
+			limits.Truncation,
+			step.Name,
+			appconfig.OutputTruncationKeepHead,
+			appconfig.OutputTruncationKeepTail,
+			appconfig.OutputTruncationKeepHeadAndTail)
+	}
+	if limits.MaxStdoutLength < 0 || limits.MaxStderrLength < 0 || limits.MaxOutputLength < 0 {
+		return fmt.Errorf("Invalid outputLimits for step %s, the limits must not be negative", step.Name)
+	}
+	return nil
+}
+
 // validateSchema checks if the document schema version is supported by this agent version
 func validateSchema(documentSchemaVersion string) error {
 	// Check if the document version is supported by this agent version
@@ -219,8 +274,61 @@ func validateSchema(documentSchemaVersion string) error {
 	return nil
 }
 
+// validateOnFailure checks that the step declares a supported onFailure action and a non negative maxAttempts
+func validateOnFailure(step *contracts.InstancePluginConfig) error {
+	switch step.OnFailure {
+	case "", contracts.OnFailureContinue, contracts.OnFailureExit, contracts.OnFailureSuccessAndExit:
+	default:
+		return fmt.Errorf("Unsupported onFailure value '%s' for step %s, supported values are %s, %s and %s",
+			step.OnFailure,
+			step.Name,
+			contracts.OnFailureContinue,
+			contracts.OnFailureExit,
+			contracts.OnFailureSuccessAndExit)
+	}
+	if step.MaxAttempts < 0 {
+		return fmt.Errorf("Invalid maxAttempts value %d for step %s", step.MaxAttempts, step.Name)
+	}
+	return nil
+}
+
+// validateOutputs checks that the outputs of the step have unique names and a single valid selector
+func validateOutputs(step *contracts.InstancePluginConfig) error {
+	names := make(map[string]bool)
+	for _, output := range step.Outputs {
+		if !stepOutputNameRegex.MatchString(output.Name) {
+			return fmt.Errorf("Invalid output name '%s' for step %s, output names must be alphanumeric", output.Name, step.Name)
+		}
+		switch output.Name {
+		case contracts.StepOutputStatus, contracts.StepOutputExitCode, contracts.StepOutputStandardOutput:
+			return fmt.Errorf("Output name '%s' of step %s is reserved", output.Name, step.Name)
+		}
+		if names[output.Name] {
+			return fmt.Errorf("Duplicate output name '%s' for step %s", output.Name, step.Name)
+		}
+		names[output.Name] = true
+
+		selectors := 0
+		for _, selector := range []string{output.Regex, output.JSONPath, output.Key} {
+			if selector != "" {
+				selectors++
+			}
+		}
+		if selectors != 1 {
+			return fmt.Errorf("Output '%s' of step %s must have exactly one of regex, jsonPath or key", output.Name, step.Name)
+		}
+		if output.Regex != "" {
+			if _, err := regexp.Compile(output.Regex); err != nil {
+				return fmt.Errorf("Invalid regex for output '%s' of step %s: %v", output.Name, step.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
 // getValidatedParameters validates the parameters and modifies the document content by replacing all ssm parameters with their actual values.
-func getValidatedParameters(log log.T, params map[string]interface{}, docContent *contracts.DocumentContent) error {
+// The validated parameters are returned so that they can be applied to the step preconditions.
+func getValidatedParameters(log log.T, params map[string]interface{}, docContent *contracts.DocumentContent) (map[string]interface{}, error) {
 
 	//ValidateParameterNames
 	validParameters := parameters.ValidParameters(log, params)
@@ -235,11 +343,88 @@ func getValidatedParameters(log log.T, params map[string]interface{}, docContent
 	log.Info("Validating SSM parameters")
 	// Validates SSM parameters
 	if err := parameterstore.ValidateSSMParameters(log, docContent.Parameters, validParameters); err != nil {
-		return err
+		if !isUnreachableInDryRun(log, docContent, err) {
+			return nil, err
+		}
 	}
 
 	err := replaceValidatedPluginParameters(docContent, validParameters, log)
-	return err
+	return validParameters, err
+}
+
+// parsePreconditions converts the precondition of a step into its typed form. Each argument keeps the value
+// written in the document so that the evaluator can tell document parameters from literal values.
+func parsePreconditions(log log.T, preconditions map[string]interface{}, params map[string]interface{}) []contracts.Precondition {
+	var operators []string
+	for operator := range preconditions {
+		operators = append(operators, operator)
+	}
+	// map iteration order is random, sort the operators to evaluate and report them consistently
+	sort.Strings(operators)
+
+	var result []contracts.Precondition
+	for _, operator := range operators {
+		result = append(result, parsePrecondition(log, operator, preconditions[operator], params))
+	}
+	return result
+}
+
+// parsePrecondition parses the operands of a precondition operator, strings are the arguments of a comparison
+// while objects are the nested preconditions of a composition
+func parsePrecondition(log log.T, operator string, operands interface{}, params map[string]interface{}) contracts.Precondition {
+	precondition := contracts.Precondition{Operator: operator}
+
+	values, isList := operands.([]interface{})
+	if !isList {
+		// Not takes a single precondition object rather than a list
+		values = []interface{}{operands}
+	}
+
+	for _, value := range values {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			precondition.Conditions = append(precondition.Conditions, parseNestedPrecondition(log, value, params))
+		case map[interface{}]interface{}:
+			// yaml documents decode nested objects with interface keys
+			nested := make(map[string]interface{})
+			for k, v := range value {
+				nested[fmt.Sprint(k)] = v
+			}
+			precondition.Conditions = append(precondition.Conditions, parseNestedPrecondition(log, nested, params))
+		default:
+			argument := fmt.Sprint(value)
+			precondition.Arguments = append(precondition.Arguments, contracts.PreconditionArgument{
+				InitialArgumentValue:  argument,
+				ResolvedArgumentValue: resolvePreconditionArgument(log, argument, params),
+			})
+		}
+	}
+	return precondition
+}
+
+// parseNestedPrecondition parses a precondition object, an object with several operators requires all of them to hold
+func parseNestedPrecondition(log log.T, precondition map[string]interface{}, params map[string]interface{}) contracts.Precondition {
+	conditions := parsePreconditions(log, precondition, params)
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	return contracts.Precondition{Operator: contracts.PreconditionAnd, Conditions: conditions}
+}
+
+// resolvePreconditionArgument replaces the document parameters referenced by a precondition argument
+func resolvePreconditionArgument(log log.T, argument string, params map[string]interface{}) string {
+	switch resolved := parameters.ReplaceParameters(argument, params, log).(type) {
+	case string:
+		return resolved
+	default:
+		// a StringList or StringMap parameter compares as its json form
+		resolvedJSON, err := json.Marshal(resolved)
+		if err != nil {
+			log.Errorf("Failed to resolve precondition argument %v: %v", argument, err)
+			return argument
+		}
+		return string(resolvedJSON)
+	}
 }
 
 // replaceValidatedPluginParameters replaces parameters with their values, within the plugin Properties.
@@ -261,12 +446,12 @@ func replaceValidatedPluginParameters(
 
 			logger.Debug("Resolving SSM parameters")
 			// Resolves SSM parameters
-			if updatedRuntimeConfig[pluginName].Settings, err = parameterstore.Resolve(logger, updatedRuntimeConfig[pluginName].Settings); err != nil {
+			if updatedRuntimeConfig[pluginName].Settings, err = resolveSSMParameters(logger, docContent, updatedRuntimeConfig[pluginName].Settings); err != nil {
 				return err
 			}
 
 			// Resolves SSM parameters
-			if updatedRuntimeConfig[pluginName].Properties, err = parameterstore.Resolve(logger, updatedRuntimeConfig[pluginName].Properties); err != nil {
+			if updatedRuntimeConfig[pluginName].Properties, err = resolveSSMParameters(logger, docContent, updatedRuntimeConfig[pluginName].Properties); err != nil {
 				return err
 			}
 		}
@@ -284,12 +469,12 @@ func replaceValidatedPluginParameters(
 
 			logger.Debug("Resolving SSM parameters")
 			// Resolves SSM parameters
-			if updatedMainSteps[index].Settings, err = parameterstore.Resolve(logger, updatedMainSteps[index].Settings); err != nil {
+			if updatedMainSteps[index].Settings, err = resolveSSMParameters(logger, docContent, updatedMainSteps[index].Settings); err != nil {
 				return err
 			}
 
 			// Resolves SSM parameters
-			if updatedMainSteps[index].Inputs, err = parameterstore.Resolve(logger, updatedMainSteps[index].Inputs); err != nil {
+			if updatedMainSteps[index].Inputs, err = resolveSSMParameters(logger, docContent, updatedMainSteps[index].Inputs); err != nil {
 				return err
 			}
 		}
@@ -299,6 +484,26 @@ func replaceValidatedPluginParameters(
 	return nil
 }
 
+// resolveSSMParameters resolves the ssm parameters of the input, a dry run keeps the parameters it can't resolve
+// when the service can't be called
+func resolveSSMParameters(log log.T, docContent *contracts.DocumentContent, input interface{}) (interface{}, error) {
+	resolved, err := parameterstore.Resolve(log, input)
+	if err != nil && isUnreachableInDryRun(log, docContent, err) {
+		return input, nil
+	}
+	return resolved, err
+}
+
+// isUnreachableInDryRun returns true if the ssm parameters couldn't be resolved because the service couldn't be
+// called during a dry run, in which case the steps are validated with the parameters left unresolved
+func isUnreachableInDryRun(log log.T, docContent *contracts.DocumentContent, err error) bool {
+	if !docContent.DryRun || !parameterstore.IsServiceError(err) {
+		return false
+	}
+	log.Warnf("Dry run: SSM parameters can't be resolved, they are left unresolved: %v", err)
+	return true
+}
+
 // isPreConditionEnabled checks if precondition support is enabled by checking document schema version
 func isPreconditionEnabled(schemaVersion string) (response bool) {
 	response = false
@@ -21,10 +21,13 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/parameterstore"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 const (
@@ -38,6 +41,15 @@ const (
 const parameterdocument = `{"schemaVersion":"1.2","description":"","parameters":{"commands":{"type":"StringList"}},"runtimeConfig":{"aws:runPowerShellScript":{"properties":[{"id":"0.aws:runPowerShellScript","runCommand":"{{ commands }}"}]}}}`
 const invaliddocument = `{"schemaVersion":"1.2","description":"PowerShell.","FOO":"bar"}`
 const testparameters = `{"commands":["date"]}`
+const onFailureDocument = `{"schemaVersion":"2.2","description":"","mainSteps":[{"action":"aws:runShellScript","name":"runShell","maxAttempts":3,"onFailure":"exit","inputs":{"runCommand":["date"]}}]}`
+const invalidOnFailureDocument = `{"schemaVersion":"2.2","description":"","mainSteps":[{"action":"aws:runShellScript","name":"runShell","onFailure":"abort","inputs":{"runCommand":["date"]}}]}`
+const outputsDocument = `{"schemaVersion":"2.2","description":"","mainSteps":[{"action":"aws:runShellScript","name":"runShell","outputs":[{"name":"version","regex":"version (\\S+)"},{"name":"id","jsonPath":"$.id"}],"inputs":{"runCommand":["date"]}}]}`
+const runAsUserDocument = `{"schemaVersion":"2.2","description":"","runAsUser":"deploy","mainSteps":[{"action":"aws:runShellScript","name":"runShell","inputs":{"runCommand":["id"]}},{"action":"aws:runShellScript","name":"runShellAsOps","inputs":{"runCommand":["id"],"runAsUser":"ops"}}]}`
+const outputLimitsDocument = `{"schemaVersion":"2.2","description":"","mainSteps":[{"action":"aws:runShellScript","name":"runShell","outputLimits":{"maxStdoutLength":100000,"truncation":"KeepTail"},"inputs":{"runCommand":["date"]}}]}`
+const invalidOutputLimitsDocument = `{"schemaVersion":"2.2","description":"","mainSteps":[{"action":"aws:runShellScript","name":"runShell","outputLimits":{"truncation":"KeepMiddle"},"inputs":{"runCommand":["date"]}}]}`
+const resourceLimitsDocument = `{"schemaVersion":"2.2","description":"","resourceLimits":{"memoryLimitMB":512,"pidsLimit":-1},"mainSteps":[{"action":"aws:runShellScript","name":"runShell","inputs":{"runCommand":["date"]}}]}`
+const invalidOutputsDocument = `{"schemaVersion":"2.2","description":"","mainSteps":[{"action":"aws:runShellScript","name":"runShell","outputs":[{"name":"version","regex":"v(","key":"VERSION"}],"inputs":{"runCommand":["date"]}}]}`
+const preconditionDocument = `{"schemaVersion":"2.2","description":"","parameters":{"distribution":{"type":"String","default":"Ubuntu"}},"mainSteps":[{"action":"aws:runShellScript","name":"runShell","precondition":{"StringEquals":["platformType","Linux"],"Or":[{"StringEquals":["platformName","{{ distribution }}"]},{"Not":{"StringLike":["instanceType","t2.*"]}}]},"inputs":{"runCommand":["date"]}}]}`
 
 var sampleMessageFiles = []string{
 	"testdata/sampleMessageVersion2_0.json",
@@ -146,6 +158,47 @@ func TestInitializeDocState_Valid(t *testing.T) {
 	assert.Equal(t, testWorkingDir, pluginInfo[0].Configuration.DefaultWorkingDirectory)
 }
 
+func TestInitializeDocState_ResourceLimits(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir:  testOrchDir,
+		S3Bucket:          testS3Bucket,
+		S3Prefix:          testS3Prefix,
+		MessageId:         testMessageID,
+		DocumentId:        testDocumentID,
+		DefaultWorkingDir: testWorkingDir,
+	}
+	var testDocContent contracts.DocumentContent
+	err := json.Unmarshal([]byte(resourceLimitsDocument), &testDocContent)
+	assert.NoError(t, err)
+
+	docState, err := InitializeDocState(mockLog, contracts.SendCommand, &testDocContent, contracts.DocumentInfo{}, testParserInfo, nil)
+
+	// invalid limits are ignored
+	assert.NoError(t, err)
+	assert.Equal(t, appconfig.ResourceLimits{MemoryLimitMB: 512}, docState.ResourceLimits)
+}
+
+func TestInitializeDocState_CloudWatchOutput(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir:          testOrchDir,
+		MessageId:                 testMessageID,
+		DocumentId:                testDocumentID,
+		CloudWatchLogGroup:        "/aws/ssm/AWS-RunShellScript",
+		CloudWatchLogStreamPrefix: "commandID/instanceID",
+	}
+	var testDocContent contracts.DocumentContent
+	err := json.Unmarshal(loadFile(t, "../runcommand/mds/testdata/validcommand12.json"), &testDocContent)
+	assert.NoError(t, err)
+
+	docState, err := InitializeDocState(mockLog, contracts.SendCommand, &testDocContent, contracts.DocumentInfo{}, testParserInfo, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/aws/ssm/AWS-RunShellScript", docState.IOConfig.CloudWatchLogGroupName)
+	assert.Equal(t, "commandID/instanceID", docState.IOConfig.CloudWatchLogStreamPrefix)
+}
+
 func TestParseDocument_EmptyDocContent(t *testing.T) {
 	mockLog := log.NewMockLog()
 	testParserInfo := DocumentParserInfo{
@@ -211,6 +264,242 @@ func TestParseDocument_InvalidSchema(t *testing.T) {
 	assert.Contains(t, err.Error(), "Document with schema version 9999.0 is not supported by this version of ssm agent")
 }
 
+func TestParseDocument_OnFailureAndMaxAttempts(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir:  testOrchDir,
+		S3Bucket:          testS3Bucket,
+		S3Prefix:          testS3Prefix,
+		MessageId:         testMessageID,
+		DocumentId:        testDocumentID,
+		DefaultWorkingDir: testWorkingDir,
+	}
+	var testDocContent contracts.DocumentContent
+	err := json.Unmarshal([]byte(onFailureDocument), &testDocContent)
+	assert.NoError(t, err)
+
+	pluginsInfo, err := ParseDocument(mockLog, &testDocContent, testParserInfo, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(pluginsInfo))
+	assert.Equal(t, 3, pluginsInfo[0].Configuration.MaxAttempts)
+	assert.Equal(t, contracts.OnFailureExit, pluginsInfo[0].Configuration.OnFailure)
+}
+
+func TestParseDocument_DryRun(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir:  testOrchDir,
+		S3Bucket:          testS3Bucket,
+		S3Prefix:          testS3Prefix,
+		MessageId:         testMessageID,
+		DocumentId:        testDocumentID,
+		DefaultWorkingDir: testWorkingDir,
+		DryRun:            true,
+	}
+	var testDocContent contracts.DocumentContent
+	err := json.Unmarshal([]byte(onFailureDocument), &testDocContent)
+	assert.NoError(t, err)
+
+	pluginsInfo, err := ParseDocument(mockLog, &testDocContent, testParserInfo, nil)
+
+	assert.NoError(t, err)
+	assert.True(t, pluginsInfo[0].Configuration.DryRun)
+}
+
+func TestIsUnreachableInDryRun(t *testing.T) {
+	mockLog := log.NewMockLog()
+	mockLog.On("Warnf", mock.Anything, mock.Anything).Return(nil)
+	serviceErr := parameterstore.ServiceError{Err: fmt.Errorf("RequestError: send request failed")}
+
+	assert.True(t, isUnreachableInDryRun(mockLog, &contracts.DocumentContent{DryRun: true}, serviceErr))
+	assert.False(t, isUnreachableInDryRun(mockLog, &contracts.DocumentContent{}, serviceErr))
+	assert.False(t, isUnreachableInDryRun(mockLog, &contracts.DocumentContent{DryRun: true}, fmt.Errorf("Input contains invalid parameters")))
+}
+
+func TestParseDocument_InvalidOnFailure(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir:  testOrchDir,
+		S3Bucket:          testS3Bucket,
+		S3Prefix:          testS3Prefix,
+		MessageId:         testMessageID,
+		DocumentId:        testDocumentID,
+		DefaultWorkingDir: testWorkingDir,
+	}
+	var testDocContent contracts.DocumentContent
+	err := json.Unmarshal([]byte(invalidOnFailureDocument), &testDocContent)
+	assert.NoError(t, err)
+
+	_, err = ParseDocument(mockLog, &testDocContent, testParserInfo, nil)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Unsupported onFailure value 'abort' for step runShell")
+}
+
+func TestParseDocument_OutputLimits(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir:  testOrchDir,
+		S3Bucket:          testS3Bucket,
+		S3Prefix:          testS3Prefix,
+		MessageId:         testMessageID,
+		DocumentId:        testDocumentID,
+		DefaultWorkingDir: testWorkingDir,
+	}
+	var testDocContent contracts.DocumentContent
+	err := json.Unmarshal([]byte(outputLimitsDocument), &testDocContent)
+	assert.NoError(t, err)
+
+	pluginsInfo, err := ParseDocument(mockLog, &testDocContent, testParserInfo, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, appconfig.OutputLimits{MaxStdoutLength: 100000, Truncation: appconfig.OutputTruncationKeepTail}, pluginsInfo[0].Configuration.OutputLimits)
+}
+
+func TestParseDocument_InvalidOutputLimits(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir:  testOrchDir,
+		S3Bucket:          testS3Bucket,
+		S3Prefix:          testS3Prefix,
+		MessageId:         testMessageID,
+		DocumentId:        testDocumentID,
+		DefaultWorkingDir: testWorkingDir,
+	}
+	var testDocContent contracts.DocumentContent
+	err := json.Unmarshal([]byte(invalidOutputLimitsDocument), &testDocContent)
+	assert.NoError(t, err)
+
+	_, err = ParseDocument(mockLog, &testDocContent, testParserInfo, nil)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Unsupported outputLimits truncation 'KeepMiddle' for step runShell")
+}
+
+func TestParseDocument_Outputs(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir:  testOrchDir,
+		S3Bucket:          testS3Bucket,
+		S3Prefix:          testS3Prefix,
+		MessageId:         testMessageID,
+		DocumentId:        testDocumentID,
+		DefaultWorkingDir: testWorkingDir,
+	}
+	var testDocContent contracts.DocumentContent
+	err := json.Unmarshal([]byte(outputsDocument), &testDocContent)
+	assert.NoError(t, err)
+
+	pluginsInfo, err := ParseDocument(mockLog, &testDocContent, testParserInfo, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(pluginsInfo))
+	assert.Equal(t, []contracts.StepOutput{
+		{Name: "version", Regex: `version (\S+)`},
+		{Name: "id", JSONPath: "$.id"},
+	}, pluginsInfo[0].Configuration.Outputs)
+}
+
+func TestParseDocument_RunAsUser(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir:  testOrchDir,
+		S3Bucket:          testS3Bucket,
+		S3Prefix:          testS3Prefix,
+		MessageId:         testMessageID,
+		DocumentId:        testDocumentID,
+		DefaultWorkingDir: testWorkingDir,
+	}
+	var testDocContent contracts.DocumentContent
+	err := json.Unmarshal([]byte(runAsUserDocument), &testDocContent)
+	assert.NoError(t, err)
+
+	pluginsInfo, err := ParseDocument(mockLog, &testDocContent, testParserInfo, nil)
+
+	// the user of the document is passed to every step, the steps overriding it through their inputs
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(pluginsInfo))
+	for _, pluginInfo := range pluginsInfo {
+		assert.Equal(t, "deploy", pluginInfo.Configuration.RunAsUser)
+	}
+}
+
+func TestValidateOutputs(t *testing.T) {
+	testCases := []struct {
+		outputs []contracts.StepOutput
+		err     string
+	}{
+		{[]contracts.StepOutput{{Name: "version", Regex: "v(", Key: "VERSION"}}, "must have exactly one of regex, jsonPath or key"},
+		{[]contracts.StepOutput{{Name: "version"}}, "must have exactly one of regex, jsonPath or key"},
+		{[]contracts.StepOutput{{Name: "version", Regex: "v("}}, "Invalid regex for output 'version' of step runShell"},
+		{[]contracts.StepOutput{{Name: "my.version", Key: "VERSION"}}, "Invalid output name 'my.version'"},
+		{[]contracts.StepOutput{{Name: "status", Key: "STATUS"}}, "Output name 'status' of step runShell is reserved"},
+		{[]contracts.StepOutput{{Name: "version", Key: "A"}, {Name: "version", Key: "B"}}, "Duplicate output name 'version'"},
+	}
+
+	for _, testCase := range testCases {
+		err := validateOutputs(&contracts.InstancePluginConfig{Name: "runShell", Outputs: testCase.outputs})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), testCase.err)
+	}
+
+	var testDocContent contracts.DocumentContent
+	err := json.Unmarshal([]byte(invalidOutputsDocument), &testDocContent)
+	assert.NoError(t, err)
+	_, err = ParseDocument(log.NewMockLog(), &testDocContent, DocumentParserInfo{}, nil)
+	assert.Error(t, err)
+}
+
+func TestParseDocument_Preconditions(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir:  testOrchDir,
+		S3Bucket:          testS3Bucket,
+		S3Prefix:          testS3Prefix,
+		MessageId:         testMessageID,
+		DocumentId:        testDocumentID,
+		DefaultWorkingDir: testWorkingDir,
+	}
+	var testDocContent contracts.DocumentContent
+	err := json.Unmarshal([]byte(preconditionDocument), &testDocContent)
+	assert.NoError(t, err)
+
+	pluginsInfo, err := ParseDocument(mockLog, &testDocContent, testParserInfo, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(pluginsInfo))
+	argument := func(initial, resolved string) contracts.PreconditionArgument {
+		return contracts.PreconditionArgument{InitialArgumentValue: initial, ResolvedArgumentValue: resolved}
+	}
+	expected := contracts.Preconditions{
+		{
+			Operator: "Or",
+			Conditions: []contracts.Precondition{
+				{
+					Operator:  "StringEquals",
+					Arguments: []contracts.PreconditionArgument{argument("platformName", "platformName"), argument("{{ distribution }}", "Ubuntu")},
+				},
+				{
+					Operator: "Not",
+					Conditions: []contracts.Precondition{
+						{
+							Operator:  "StringLike",
+							Arguments: []contracts.PreconditionArgument{argument("instanceType", "instanceType"), argument("t2.*", "t2.*")},
+						},
+					},
+				},
+			},
+		},
+		{
+			Operator:  "StringEquals",
+			Arguments: []contracts.PreconditionArgument{argument("platformType", "platformType"), argument("Linux", "Linux")},
+		},
+	}
+	assert.Equal(t, expected, pluginsInfo[0].Configuration.Preconditions)
+	assert.True(t, pluginsInfo[0].Configuration.IsPreconditionEnabled)
+}
+
 func TestParseDocument_ValidParameters(t *testing.T) {
 	mockLog := log.NewMockLog()
 
@@ -48,6 +48,8 @@ type T interface {
 
 // ShellCommandExecuter is specially added for testing purposes
 type ShellCommandExecuter struct {
+	// RunAsUser is the local user the commands run as, the agent user when empty
+	RunAsUser string
 }
 
 type timeoutSignal struct {
@@ -70,7 +72,7 @@ type timeoutSignal struct {
 // For byte buffer output, the reader will be a reader over the buffer, which will accumulate the entire output.  Be careful
 // not to use the byte buffer approach for extremely large output (or unknown output) because it could take up a large amount
 // of memory.
-func (ShellCommandExecuter) Execute(
+func (e ShellCommandExecuter) Execute(
 	log log.T,
 	workingDir string,
 	stdoutFilePath string,
@@ -81,6 +83,7 @@ func (ShellCommandExecuter) Execute(
 	commandArguments []string,
 ) (stdout io.Reader, stderr io.Reader, exitCode int, errs []error) {
 
+	var outputFiles []*os.File
 	var stdoutWriter io.Writer
 	var stdoutBuf *bytes.Buffer
 	if stdoutFilePath != "" {
@@ -93,6 +96,7 @@ func (ShellCommandExecuter) Execute(
 		}
 		stdoutWriter = stdoutFileWriter
 		defer stdoutFileWriter.Close()
+		outputFiles = append(outputFiles, stdoutFileWriter)
 	} else {
 		stdoutBuf = bytes.NewBuffer(nil)
 		stdoutWriter = stdoutBuf
@@ -110,6 +114,7 @@ func (ShellCommandExecuter) Execute(
 		}
 		stderrWriter = stderrFileWriter
 		defer stderrFileWriter.Close() // ExecuteCommand creates a copy of the handle
+		outputFiles = append(outputFiles, stderrFileWriter)
 	} else {
 		stderrBuf = bytes.NewBuffer(nil)
 		stderrWriter = stderrBuf
@@ -121,8 +126,18 @@ func (ShellCommandExecuter) Execute(
 	// the actual writing to the files. So, when using files, it does not matter when we close our copies of the file
 	// writers as long as it is after the process starts.
 
+	// the output files belong to the user the commands run as, they are given through the handles of the agent
+	if e.RunAsUser != "" {
+		for _, outputFile := range outputFiles {
+			if err := giveFileToRunAsUser(outputFile, e.RunAsUser); err != nil {
+				errs = append(errs, fmt.Errorf("failed to give %v to %v: %v", outputFile.Name(), e.RunAsUser, err))
+				return
+			}
+		}
+	}
+
 	var err error
-	exitCode, err = ExecuteCommand(log, cancelFlag, workingDir, stdoutWriter, stderrWriter, executionTimeout, commandName, commandArguments)
+	exitCode, err = executeCommand(log, e.RunAsUser, cancelFlag, workingDir, stdoutWriter, stderrWriter, executionTimeout, commandName, commandArguments)
 	if err != nil {
 		errs = append(errs, err)
 	}
@@ -159,7 +174,7 @@ func (ShellCommandExecuter) Execute(
 }
 
 // NewExecute executes a list of shell commands in the given working directory and provides the stdout and stderr writers.
-func (ShellCommandExecuter) NewExecute(
+func (e ShellCommandExecuter) NewExecute(
 	log log.T,
 	workingDir string,
 	stdoutWriter io.Writer,
@@ -169,7 +184,7 @@ func (ShellCommandExecuter) NewExecute(
 	commandName string,
 	commandArguments []string,
 ) (exitCode int, err error) {
-	exitCode, err = ExecuteCommand(log, cancelFlag, workingDir, stdoutWriter, stderrWriter, executionTimeout, commandName, commandArguments)
+	exitCode, err = executeCommand(log, e.RunAsUser, cancelFlag, workingDir, stdoutWriter, stderrWriter, executionTimeout, commandName, commandArguments)
 	return
 }
 
@@ -179,7 +194,7 @@ func (ShellCommandExecuter) NewExecute(
 // even though some errors are reported. For example, if the command got killed while executing,
 // the streams will have whatever data was printed up to the kill point, and the errors will
 // indicate that the process got terminated.
-func (ShellCommandExecuter) StartExe(
+func (e ShellCommandExecuter) StartExe(
 	log log.T,
 	workingDir string,
 	stdoutWriter io.Writer,
@@ -188,7 +203,7 @@ func (ShellCommandExecuter) StartExe(
 	commandName string,
 	commandArguments []string,
 ) (process *os.Process, exitCode int, err error) {
-	process, exitCode, err = StartCommand(log, cancelFlag, workingDir, stdoutWriter, stderrWriter, commandName, commandArguments)
+	process, exitCode, err = startCommand(log, e.RunAsUser, cancelFlag, workingDir, stdoutWriter, stderrWriter, commandName, commandArguments)
 	return
 }
 
@@ -254,6 +269,20 @@ func ExecuteCommand(log log.T,
 	commandName string,
 	commandArguments []string,
 ) (exitCode int, err error) {
+	return executeCommand(log, "", cancelFlag, workingDir, stdoutWriter, stderrWriter, executionTimeout, commandName, commandArguments)
+}
+
+// executeCommand executes the given commands as the given user, the agent user when empty
+func executeCommand(log log.T,
+	runAsUser string,
+	cancelFlag task.CancelFlag,
+	workingDir string,
+	stdoutWriter io.Writer,
+	stderrWriter io.Writer,
+	executionTimeout int,
+	commandName string,
+	commandArguments []string,
+) (exitCode int, err error) {
 
 	stdoutInterruptable, stopStdout := newWriter(stdoutWriter)
 	stderrInterruptable, stopStderr := newWriter(stderrWriter)
@@ -285,6 +314,15 @@ func ExecuteCommand(log log.T,
 	// configure environment variables
 	prepareEnvironment(command)
 
+	// configure the user the command runs as
+	if runAsUser != "" {
+		if err = prepareRunAsUser(command, runAsUser); err != nil {
+			log.Errorf("unable to run the command as %v: %v", runAsUser, err)
+			exitCode = 1
+			return
+		}
+	}
+
 	log.Debug()
 	log.Debugf("Running in directory %v, command: %v %v", workingDir, commandName, commandArguments)
 	log.Debug()
@@ -389,6 +427,19 @@ func StartCommand(log log.T,
 	commandName string,
 	commandArguments []string,
 ) (process *os.Process, exitCode int, err error) {
+	return startCommand(log, "", cancelFlag, workingDir, stdoutWriter, stderrWriter, commandName, commandArguments)
+}
+
+// startCommand starts the given commands as the given user, the agent user when empty
+func startCommand(log log.T,
+	runAsUser string,
+	cancelFlag task.CancelFlag,
+	workingDir string,
+	stdoutWriter io.Writer,
+	stderrWriter io.Writer,
+	commandName string,
+	commandArguments []string,
+) (process *os.Process, exitCode int, err error) {
 
 	command := exec.Command(commandName, commandArguments...)
 	command.Dir = workingDir
@@ -402,6 +453,15 @@ func StartCommand(log log.T,
 	// configure environment variables
 	prepareEnvironment(command)
 
+	// configure the user the command runs as
+	if runAsUser != "" {
+		if err = prepareRunAsUser(command, runAsUser); err != nil {
+			log.Errorf("unable to run the command as %v: %v", runAsUser, err)
+			exitCode = 1
+			return
+		}
+	}
+
 	log.Debug()
 	log.Debugf("Running in directory %v, command: %v %v", workingDir, commandName, commandArguments)
 	log.Debug()
@@ -0,0 +1,34 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package executers contains general purpose (shell) command executing objects.
+package executers
+
+import (
+	"fmt"
+)
+
+// WithRunAsUser returns an executer running the commands as the given user, or the executer itself when no user is given.
+// Only ShellCommandExecuter can run commands as another user, other executers are rejected rather than silently
+// running the commands as the agent user.
+func WithRunAsUser(executer T, runAsUser string) (T, error) {
+	if runAsUser == "" {
+		return executer, nil
+	}
+	shellExecuter, isShellExecuter := executer.(ShellCommandExecuter)
+	if !isShellExecuter {
+		return nil, fmt.Errorf("running commands as %v is not supported by %T", runAsUser, executer)
+	}
+	shellExecuter.RunAsUser = runAsUser
+	return shellExecuter, nil
+}
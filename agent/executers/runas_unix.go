@@ -0,0 +1,194 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package executers
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	defaultRunAsShell = "/bin/sh"
+	defaultRunAsPath  = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	// envVarSSMPrefix is the prefix of the environment variables set by ssm agent
+	envVarSSMPrefix = "AWS_SSM_"
+)
+
+// runAsAccount is the local account the commands run as
+type runAsAccount struct {
+	name    string
+	uid     uint32
+	gid     uint32
+	groups  []uint32
+	homeDir string
+	shell   string
+}
+
+// decoupling the account database and the file system for easy testability
+var (
+	lchown         = os.Lchown
+	lookupUser     = user.Lookup
+	lookupGroupIds = func(account *user.User) ([]string, error) {
+		return account.GroupIds()
+	}
+	// lookupShell returns the login shell of the user, getent also covers the accounts that aren't in /etc/passwd
+	lookupShell = func(name string) string {
+		if output, err := exec.Command("getent", "passwd", name).Output(); err == nil {
+			if fields := strings.Split(strings.TrimSpace(string(output)), ":"); len(fields) == 7 && fields[6] != "" {
+				return fields[6]
+			}
+		}
+		return defaultRunAsShell
+	}
+)
+
+// lookupRunAsAccount returns the ids, supplementary groups, home directory and shell of the user
+func lookupRunAsAccount(name string) (account runAsAccount, err error) {
+	localUser, err := lookupUser(name)
+	if err != nil {
+		return account, fmt.Errorf("unable to find the user %v: %v", name, err)
+	}
+	account.name = localUser.Username
+	account.homeDir = localUser.HomeDir
+	account.shell = lookupShell(localUser.Username)
+	if account.uid, err = parseID(localUser.Uid); err != nil {
+		return account, err
+	}
+	if account.gid, err = parseID(localUser.Gid); err != nil {
+		return account, err
+	}
+
+	groupIds, err := lookupGroupIds(localUser)
+	if err != nil {
+		return account, fmt.Errorf("unable to find the groups of the user %v: %v", name, err)
+	}
+	for _, groupID := range groupIds {
+		gid, err := parseID(groupID)
+		if err != nil {
+			return account, err
+		}
+		account.groups = append(account.groups, gid)
+	}
+	return account, nil
+}
+
+func parseID(id string) (uint32, error) {
+	value, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid user or group id %v", id)
+	}
+	return uint32(value), nil
+}
+
+// prepareRunAsUser makes the command run with the ids, supplementary groups and login environment of the user
+func prepareRunAsUser(command *exec.Cmd, runAsUser string) error {
+	account, err := lookupRunAsAccount(runAsUser)
+	if err != nil {
+		return err
+	}
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    account.uid,
+		Gid:    account.gid,
+		Groups: account.groups,
+	}
+	command.Env = loginEnvironment(account, command.Env)
+	// the commands run in the home directory of the user, a relative working directory being relative to it
+	if !filepath.IsAbs(command.Dir) {
+		command.Dir = filepath.Join(account.homeDir, command.Dir)
+	}
+	return nil
+}
+
+// loginEnvironment returns the environment of a login of the user. Only the locale and the ssm agent variables
+// are kept from the environment of the agent.
+func loginEnvironment(account runAsAccount, agentEnv []string) []string {
+	env := []string{
+		fmtEnvVariable("HOME", account.homeDir),
+		fmtEnvVariable("USER", account.name),
+		fmtEnvVariable("LOGNAME", account.name),
+		fmtEnvVariable("SHELL", account.shell),
+		fmtEnvVariable("PATH", defaultRunAsPath),
+	}
+	for _, variable := range agentEnv {
+		if strings.HasPrefix(variable, envVarSSMPrefix) || strings.HasPrefix(variable, "LANG=") || strings.HasPrefix(variable, "LC_") {
+			env = append(env, variable)
+		}
+	}
+	return env
+}
+
+// ChownToRunAsUser makes the user the commands run as the owner of the file, a symbolic link itself being changed
+// rather than the file it points to
+func ChownToRunAsUser(path string, runAsUser string) error {
+	account, err := lookupRunAsAccount(runAsUser)
+	if err != nil {
+		return err
+	}
+	return lchown(path, int(account.uid), int(account.gid))
+}
+
+// GiveToRunAsUser makes the user the commands run as the owner of the directory and of everything in it, once the agent
+// is done writing to it. The entries are changed deepest first and the directory last, so that the user can't replace
+// an entry the agent has yet to change. Symbolic links are changed themselves rather than the files they point to.
+func GiveToRunAsUser(dir string, runAsUser string) error {
+	account, err := lookupRunAsAccount(runAsUser)
+	if err != nil {
+		return err
+	}
+	var paths []string
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(paths) - 1; i >= 0; i-- {
+		if err = lchown(paths[i], int(account.uid), int(account.gid)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// giveFileToRunAsUser makes the user the commands run as the owner of a file the agent opened, the file being changed
+// through the handle of the agent rather than its path. Only regular files with a single link are changed.
+func giveFileToRunAsUser(file *os.File, runAsUser string) error {
+	account, err := lookupRunAsAccount(runAsUser)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if stat, isStat := info.Sys().(*syscall.Stat_t); !info.Mode().IsRegular() || !isStat || stat.Nlink != 1 {
+		return fmt.Errorf("%v is not a regular file with a single link", file.Name())
+	}
+	return file.Chown(int(account.uid), int(account.gid))
+}
@@ -0,0 +1,152 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package executers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockAccountDatabase replaces the account lookups with a single user and returns a function restoring them
+func mockAccountDatabase(account *user.User, groupIds []string) func() {
+	originalLookupUser, originalLookupGroupIds, originalLookupShell := lookupUser, lookupGroupIds, lookupShell
+	lookupUser = func(name string) (*user.User, error) {
+		if name != account.Username {
+			return nil, user.UnknownUserError(name)
+		}
+		return account, nil
+	}
+	lookupGroupIds = func(*user.User) ([]string, error) { return groupIds, nil }
+	lookupShell = func(string) string { return "/bin/bash" }
+	return func() {
+		lookupUser, lookupGroupIds, lookupShell = originalLookupUser, originalLookupGroupIds, originalLookupShell
+	}
+}
+
+func TestPrepareRunAsUser(t *testing.T) {
+	defer mockAccountDatabase(&user.User{Uid: "1001", Gid: "1002", Username: "deploy", HomeDir: "/home/deploy"}, []string{"1002", "27"})()
+
+	command := exec.Command("id")
+	prepareProcess(command)
+	command.Env = []string{"PATH=/opt/agent/bin", "HOME=/root", "LANG=en_US.UTF-8", "AWS_SSM_INSTANCE_ID=i-1234", "AWS_ACCESS_KEY_ID=secret"}
+
+	err := prepareRunAsUser(command, "deploy")
+
+	assert.NoError(t, err)
+	assert.True(t, command.SysProcAttr.Setpgid)
+	assert.Equal(t, &syscall.Credential{Uid: 1001, Gid: 1002, Groups: []uint32{1002, 27}}, command.SysProcAttr.Credential)
+	assert.Equal(t, []string{
+		"HOME=/home/deploy",
+		"USER=deploy",
+		"LOGNAME=deploy",
+		"SHELL=/bin/bash",
+		fmt.Sprintf("PATH=%v", defaultRunAsPath),
+		"LANG=en_US.UTF-8",
+		"AWS_SSM_INSTANCE_ID=i-1234",
+	}, command.Env)
+	assert.Equal(t, "/home/deploy", command.Dir)
+
+	command = exec.Command("id")
+	command.Dir = "scripts"
+	assert.NoError(t, prepareRunAsUser(command, "deploy"))
+	assert.Equal(t, "/home/deploy/scripts", command.Dir)
+
+	command = exec.Command("id")
+	command.Dir = "/opt/scripts"
+	assert.NoError(t, prepareRunAsUser(command, "deploy"))
+	assert.Equal(t, "/opt/scripts", command.Dir)
+}
+
+func TestPrepareRunAsUser_UnknownUser(t *testing.T) {
+	defer mockAccountDatabase(&user.User{Uid: "1001", Gid: "1002", Username: "deploy", HomeDir: "/home/deploy"}, nil)()
+
+	command := exec.Command("id")
+	err := prepareRunAsUser(command, "nobody-here")
+
+	assert.Error(t, err)
+	assert.Nil(t, command.SysProcAttr)
+}
+
+func TestWithRunAsUser(t *testing.T) {
+	executer, err := WithRunAsUser(ShellCommandExecuter{}, "deploy")
+	assert.NoError(t, err)
+	assert.Equal(t, "deploy", executer.(ShellCommandExecuter).RunAsUser)
+
+	// commands must not silently run as the agent user
+	_, err = WithRunAsUser(&MockCommandExecuter{}, "deploy")
+	assert.Error(t, err)
+
+	original := &MockCommandExecuter{}
+	executer, err = WithRunAsUser(original, "")
+	assert.NoError(t, err)
+	assert.Equal(t, original, executer)
+}
+
+func TestGiveToRunAsUser(t *testing.T) {
+	defer mockAccountDatabase(&user.User{Uid: "1001", Gid: "1002", Username: "deploy", HomeDir: "/home/deploy"}, nil)()
+	var changed []string
+	lchown = func(path string, uid int, gid int) error {
+		assert.Equal(t, 1001, uid)
+		assert.Equal(t, 1002, gid)
+		changed = append(changed, path)
+		return nil
+	}
+	defer func() { lchown = os.Lchown }()
+
+	dir, err := ioutil.TempDir("", "runas")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "step"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "step", "stdout"), nil, 0600))
+	assert.NoError(t, os.Symlink("/etc", filepath.Join(dir, "link")))
+
+	assert.NoError(t, GiveToRunAsUser(dir, "deploy"))
+
+	// the entries are changed before the directory they are in, the link isn't followed
+	assert.Equal(t, []string{
+		filepath.Join(dir, "step", "stdout"),
+		filepath.Join(dir, "step"),
+		filepath.Join(dir, "link"),
+		dir,
+	}, changed)
+}
+
+func TestGiveFileToRunAsUser(t *testing.T) {
+	// the account of the test keeps the files changeable without privileges
+	uid, gid := strconv.Itoa(os.Getuid()), strconv.Itoa(os.Getgid())
+	defer mockAccountDatabase(&user.User{Uid: uid, Gid: gid, Username: "deploy", HomeDir: "/home/deploy"}, nil)()
+
+	dir, err := ioutil.TempDir("", "runas")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file, err := os.Create(filepath.Join(dir, "stdout"))
+	assert.NoError(t, err)
+	defer file.Close()
+	assert.NoError(t, giveFileToRunAsUser(file, "deploy"))
+
+	// a file with another link could be a file of the agent linked by the user
+	assert.NoError(t, os.Link(filepath.Join(dir, "stdout"), filepath.Join(dir, "linked")))
+	assert.Error(t, giveFileToRunAsUser(file, "deploy"))
+}
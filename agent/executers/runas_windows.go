@@ -0,0 +1,42 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package executers
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// prepareRunAsUser fails as running commands as another user requires the password of the user on Windows
+func prepareRunAsUser(command *exec.Cmd, runAsUser string) error {
+	return fmt.Errorf("running commands as another user is not supported on Windows")
+}
+
+// ChownToRunAsUser fails as running commands as another user is not supported on Windows
+func ChownToRunAsUser(path string, runAsUser string) error {
+	return fmt.Errorf("running commands as another user is not supported on Windows")
+}
+
+// GiveToRunAsUser fails as running commands as another user is not supported on Windows
+func GiveToRunAsUser(dir string, runAsUser string) error {
+	return fmt.Errorf("running commands as another user is not supported on Windows")
+}
+
+// giveFileToRunAsUser fails as running commands as another user is not supported on Windows
+func giveFileToRunAsUser(file *os.File, runAsUser string) error {
+	return fmt.Errorf("running commands as another user is not supported on Windows")
+}
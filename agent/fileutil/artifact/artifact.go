@@ -18,9 +18,12 @@ import (
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -31,6 +34,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/aws/amazon-ssm-agent/agent/s3util"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	"github.com/aws/aws-sdk-go/aws"
@@ -50,10 +54,16 @@ type DownloadInput struct {
 	SourceURL            string
 	DestinationDirectory string
 	SourceChecksums      map[string]string
+	// VerifySignature is true if the detached signature of the file, at SignatureURL(SourceURL), is verified when the
+	// configuration of the agent requires the artifacts to be signed
+	VerifySignature bool
+	// CABundle is the PEM file of the certificate authorities trusted by the https download in addition to those of
+	// the system
+	CABundle string
 }
 
 // httpDownload attempts to download a file via http/s call
-func httpDownload(log log.T, fileURL string, destFile string) (output DownloadOutput, err error) {
+func httpDownload(log log.T, fileURL string, destFile string, caBundle string) (output DownloadOutput, err error) {
 	log.Debugf("attempting to download as http/https download %v", destFile)
 	eTagFile := destFile + ".etag"
 	var check http.Client
@@ -68,7 +78,15 @@ func httpDownload(log log.T, fileURL string, destFile string) (output DownloadOu
 		request.Header.Add("If-None-Match", existingETag)
 	}
 
+	transport := proxyconfig.NewTransport()
+	if caBundle != "" {
+		if transport.TLSClientConfig, err = tlsConfig(caBundle); err != nil {
+			return
+		}
+	}
+
 	check = http.Client{
+		Transport: transport,
 		CheckRedirect: func(r *http.Request, via []*http.Request) error {
 			r.URL.Opaque = r.URL.Path
 			return nil
@@ -116,6 +134,22 @@ func httpDownload(log log.T, fileURL string, destFile string) (output DownloadOu
 	return
 }
 
+// tlsConfig returns the TLS configuration trusting the certificate authorities of the system and of the CA bundle
+func tlsConfig(caBundle string) (*tls.Config, error) {
+	data, err := ioutil.ReadFile(caBundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CA bundle %v: %v", caBundle, err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM encoded certificate found in the CA bundle %v", caBundle)
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
+
 // awsConfig creates a config and sets region and credential information given an S3 URL
 func awsConfig(log log.T, amazonS3URL s3util.AmazonS3URL) (config *aws.Config, err error) {
 	config = sdkutil.AwsConfig()
@@ -356,12 +390,12 @@ func Download(log log.T, input DownloadInput) (output DownloadOutput, err error)
 			tempOutput, err = s3Download(log, amazonS3URL, output.LocalFilePath)
 			// if s3 download fails, attempt http/https download as fallback
 			if err != nil {
-				tempOutput, err = httpDownload(log, input.SourceURL, output.LocalFilePath)
+				tempOutput, err = httpDownload(log, input.SourceURL, output.LocalFilePath, input.CABundle)
 			}
 			output = tempOutput
 		} else {
 			// simple http/https download
-			output, err = httpDownload(log, input.SourceURL, output.LocalFilePath)
+			output, err = httpDownload(log, input.SourceURL, output.LocalFilePath, input.CABundle)
 		}
 
 		if err != nil {
@@ -374,6 +408,9 @@ func Download(log log.T, input DownloadInput) (output DownloadOutput, err error)
 		}
 	}
 
+	if err == nil && output.IsHashMatched && input.VerifySignature {
+		err = verifyDownloadSignature(log, input, output)
+	}
 	return
 }
 
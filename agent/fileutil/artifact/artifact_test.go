@@ -0,0 +1,52 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package artifact
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpDownloadWithCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "amazon-ssm-agent")
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	caBundle := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(caBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	// the certificate of the mirror isn't trusted by the system
+	_, err = httpDownload(newLog(), server.URL+"/amazon-ssm-agent.tar.gz", filepath.Join(dir, "untrusted"), "")
+	assert.Error(t, err)
+
+	output, err := httpDownload(newLog(), server.URL+"/amazon-ssm-agent.tar.gz", filepath.Join(dir, "trusted"), caBundle)
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadFile(output.LocalFilePath)
+	assert.Equal(t, "amazon-ssm-agent", string(content))
+
+	_, err = httpDownload(newLog(), server.URL+"/amazon-ssm-agent.tar.gz", filepath.Join(dir, "invalid"), filepath.Join(dir, "trusted"))
+	assert.Error(t, err)
+}
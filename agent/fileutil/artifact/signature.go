@@ -0,0 +1,205 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package artifact
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// SignatureExtension is the extension of the detached signature of an artifact, published next to the artifact
+const SignatureExtension = ".sig"
+
+// trustedKeyExtension is the extension of the public keys of appconfig.TrustedKeysFolder
+const trustedKeyExtension = ".pem"
+
+var loadSignatureConfig = func() appconfig.ArtifactSignatureCfg {
+	// the default configuration is returned when the configuration can't be loaded
+	config, _ := appconfig.Config(false)
+	return config.Agent.ArtifactSignature
+}
+
+// ecdsaSignature is the ASN.1 structure of an ECDSA signature
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// verifyDownloadSignature downloads the detached signature of the downloaded file and verifies it, if the
+// configuration of the agent requires the artifacts to be signed
+func verifyDownloadSignature(log log.T, input DownloadInput, output DownloadOutput) error {
+	config := loadSignatureConfig()
+	if !config.Required {
+		return nil
+	}
+
+	signatureInput := DownloadInput{
+		SourceURL:            SignatureURL(input.SourceURL),
+		DestinationDirectory: input.DestinationDirectory,
+		CABundle:             input.CABundle,
+	}
+	signatureOutput, err := Download(log, signatureInput)
+	if err != nil {
+		return fmt.Errorf("%v is unsigned, failed to download its signature %v: %v", input.SourceURL, signatureInput.SourceURL, err)
+	}
+
+	if err = VerifySignature(log, output.LocalFilePath, signatureOutput.LocalFilePath); err != nil {
+		return fmt.Errorf("failed to verify the signature of %v: %v", input.SourceURL, err)
+	}
+	log.Infof("Verified the signature of %v", input.SourceURL)
+	return nil
+}
+
+// SignatureURL returns the location of the detached signature of the artifact, the query of a URL being kept
+func SignatureURL(sourceURL string) string {
+	if fileURL, err := url.Parse(sourceURL); err == nil && fileURL.Scheme != "" && fileURL.Opaque == "" {
+		fileURL.Path += SignatureExtension
+		if fileURL.RawPath != "" {
+			fileURL.RawPath += SignatureExtension
+		}
+		return fileURL.String()
+	}
+	return sourceURL + SignatureExtension
+}
+
+// VerifySignature verifies the detached signature of the file with the trusted public keys. The signature is the
+// PKCS #1 v1.5 RSA signature or the ASN.1 ECDSA signature of the SHA-256 digest of the file, raw or base64 encoded,
+// as created by openssl dgst -sha256 -sign.
+func VerifySignature(log log.T, filePath string, signaturePath string) error {
+	keys, err := trustedKeys(log, loadSignatureConfig())
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no trusted public key found in %v or the TrustedKeyFiles of the configuration", appconfig.TrustedKeysFolder)
+	}
+
+	signature, err := ioutil.ReadFile(signaturePath)
+	if err != nil {
+		return err
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err == nil {
+		signature = decoded
+	}
+
+	digest, err := sha256Digest(filePath)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if verify(key, digest, signature) {
+			return nil
+		}
+	}
+	return errors.New("the signature doesn't match any trusted public key")
+}
+
+// trustedKeys returns the public keys bundled with the agent and the public keys of the configuration
+func trustedKeys(log log.T, config appconfig.ArtifactSignatureCfg) (keys []crypto.PublicKey, err error) {
+	var paths []string
+	if paths, err = filepath.Glob(filepath.Join(appconfig.TrustedKeysFolder, "*"+trustedKeyExtension)); err != nil {
+		return
+	}
+	paths = append(paths, config.TrustedKeyFiles...)
+
+	for _, path := range paths {
+		fileKeys, err := loadPublicKeys(path)
+		if err != nil {
+			log.Warnf("Failed to load the trusted public keys of %v: %v", path, err)
+			continue
+		}
+		keys = append(keys, fileKeys...)
+	}
+	return keys, nil
+}
+
+// loadPublicKeys returns the PEM encoded RSA and ECDSA public keys of the file
+func loadPublicKeys(path string) (keys []crypto.PublicKey, err error) {
+	var data []byte
+	if data, err = ioutil.ReadFile(path); err != nil {
+		return
+	}
+
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		var key interface{}
+		if key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			keys = append(keys, key)
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T", key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM encoded public key found")
+	}
+	return keys, nil
+}
+
+// verify returns true if the signature of the digest is made by the private key of the public key
+func verify(key crypto.PublicKey, digest []byte, signature []byte) bool {
+	switch publicKey := key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		var ecdsaSig ecdsaSignature
+		if rest, err := asn1.Unmarshal(signature, &ecdsaSig); err != nil || len(rest) != 0 {
+			return false
+		}
+		return ecdsaSig.R != nil && ecdsaSig.S != nil && ecdsa.Verify(publicKey, digest, ecdsaSig.R, ecdsaSig.S)
+	}
+	return false
+}
+
+func sha256Digest(filePath string) ([]byte, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err = io.Copy(hasher, f); err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
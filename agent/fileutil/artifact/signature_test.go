@@ -0,0 +1,191 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package artifact
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testContent = []byte("amazon-ssm-agent.tar.gz")
+
+// signatureTest creates an artifact and the trusted public key of its signer in a temporary folder
+type signatureTest struct {
+	dir      string
+	artifact string
+	rsaKey   *rsa.PrivateKey
+	ecdsaKey *ecdsa.PrivateKey
+}
+
+func newSignatureTest(t *testing.T, required bool) *signatureTest {
+	dir, err := ioutil.TempDir("", "signature")
+	assert.NoError(t, err)
+	test := &signatureTest{dir: dir, artifact: filepath.Join(dir, "amazon-ssm-agent.tar.gz")}
+	assert.NoError(t, ioutil.WriteFile(test.artifact, testContent, 0600))
+
+	test.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	test.ecdsaKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	keyFile := filepath.Join(dir, "keys.pem")
+	writePublicKeys(t, keyFile, &test.rsaKey.PublicKey, &test.ecdsaKey.PublicKey)
+	loadSignatureConfig = func() appconfig.ArtifactSignatureCfg {
+		return appconfig.ArtifactSignatureCfg{Required: required, TrustedKeyFiles: []string{keyFile, filepath.Join(dir, "missing.pem")}}
+	}
+	return test
+}
+
+func (test *signatureTest) cleanUp() {
+	os.RemoveAll(test.dir)
+}
+
+func (test *signatureTest) sign(t *testing.T, signer crypto.Signer, content []byte) []byte {
+	digest := sha256.Sum256(content)
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	assert.NoError(t, err)
+	return signature
+}
+
+func (test *signatureTest) writeSignature(t *testing.T, signature []byte) {
+	assert.NoError(t, ioutil.WriteFile(test.artifact+SignatureExtension, signature, 0600))
+}
+
+func writePublicKeys(t *testing.T, path string, keys ...crypto.PublicKey) {
+	var data []byte
+	for _, key := range keys {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		assert.NoError(t, err)
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})...)
+	}
+	assert.NoError(t, ioutil.WriteFile(path, data, 0600))
+}
+
+func newLog() *log.Mock {
+	logMock := log.NewMockLog()
+	logMock.On("Warnf", mock.Anything, mock.Anything).Return(nil)
+	return logMock
+}
+
+func TestVerifySignature(t *testing.T) {
+	test := newSignatureTest(t, true)
+	defer test.cleanUp()
+	rsaSignature := test.sign(t, test.rsaKey, testContent)
+	ecdsaSignature := test.sign(t, test.ecdsaKey, testContent)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	testCases := []struct {
+		name      string
+		signature []byte
+		valid     bool
+	}{
+		{"rsa", rsaSignature, true},
+		{"ecdsa", ecdsaSignature, true},
+		{"base64", []byte(base64.StdEncoding.EncodeToString(rsaSignature) + "\n"), true},
+		{"other content", test.sign(t, test.rsaKey, []byte("amazon-ssm-agent.zip")), false},
+		{"untrusted key", test.sign(t, otherKey, testContent), false},
+		{"truncated", rsaSignature[:128], false},
+		{"empty", []byte{}, false},
+	}
+	for _, testCase := range testCases {
+		test.writeSignature(t, testCase.signature)
+		err := VerifySignature(newLog(), test.artifact, test.artifact+SignatureExtension)
+		assert.Equal(t, testCase.valid, err == nil, testCase.name)
+	}
+}
+
+func TestVerifySignatureWithoutTrustedKeys(t *testing.T) {
+	test := newSignatureTest(t, true)
+	defer test.cleanUp()
+	test.writeSignature(t, test.sign(t, test.rsaKey, testContent))
+	loadSignatureConfig = func() appconfig.ArtifactSignatureCfg {
+		return appconfig.ArtifactSignatureCfg{Required: true}
+	}
+
+	err := VerifySignature(newLog(), test.artifact, test.artifact+SignatureExtension)
+
+	assert.Error(t, err)
+}
+
+func TestDownloadVerifiesSignature(t *testing.T) {
+	test := newSignatureTest(t, true)
+	defer test.cleanUp()
+	input := DownloadInput{SourceURL: test.artifact, DestinationDirectory: test.dir, VerifySignature: true}
+
+	// the unsigned artifacts are rejected
+	_, err := Download(newLog(), input)
+	assert.Error(t, err)
+
+	test.writeSignature(t, test.sign(t, test.ecdsaKey, testContent))
+	output, err := Download(newLog(), input)
+	assert.NoError(t, err)
+	assert.Equal(t, test.artifact, output.LocalFilePath)
+
+	test.writeSignature(t, test.sign(t, test.ecdsaKey, []byte("amazon-ssm-agent.zip")))
+	_, err = Download(newLog(), input)
+	assert.Error(t, err)
+
+	// the signature of the artifacts downloaded without VerifySignature isn't verified
+	input.VerifySignature = false
+	_, err = Download(newLog(), input)
+	assert.NoError(t, err)
+}
+
+func TestDownloadSignatureNotRequired(t *testing.T) {
+	test := newSignatureTest(t, false)
+	defer test.cleanUp()
+
+	_, err := Download(newLog(), DownloadInput{SourceURL: test.artifact, DestinationDirectory: test.dir, VerifySignature: true})
+
+	assert.NoError(t, err)
+}
+
+func TestLoadPublicKeys(t *testing.T) {
+	test := newSignatureTest(t, true)
+	defer test.cleanUp()
+
+	keys, err := loadPublicKeys(filepath.Join(test.dir, "keys.pem"))
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	_, err = loadPublicKeys(test.artifact)
+	assert.EqualError(t, err, "no PEM encoded public key found")
+}
+
+func TestSignatureURL(t *testing.T) {
+	assert.Equal(t, "https://s3.amazonaws.com/amazon-ssm-us-east-1/ssm-agent-manifest.json.sig",
+		SignatureURL("https://s3.amazonaws.com/amazon-ssm-us-east-1/ssm-agent-manifest.json"))
+	assert.Equal(t, "https://mirror.example.com/ssm/amazon-ssm-agent.tar.gz.sig?token=abc",
+		SignatureURL("https://mirror.example.com/ssm/amazon-ssm-agent.tar.gz?token=abc"))
+	assert.Equal(t, "/var/lib/amazon/ssm/amazon-ssm-agent.tar.gz.sig",
+		SignatureURL("/var/lib/amazon/ssm/amazon-ssm-agent.tar.gz"))
+	assert.Equal(t, `C:\ProgramData\Amazon\amazon-ssm-agent.zip.sig`,
+		SignatureURL(`C:\ProgramData\Amazon\amazon-ssm-agent.zip`))
+}
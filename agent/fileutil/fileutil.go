@@ -211,6 +211,38 @@ func WriteIntoFileWithPermissionsExtended(absolutePath, content string, perm os.
 	return
 }
 
+// WriteFileAtomic writes the file through a temporary file of the same folder, which is synced to the disk before it
+// replaces the file, so that a crash leaves either the previous or the new content rather than a partial file
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	return writeFileAtomic(filename, data, func(tempFile *os.File) error { return tempFile.Chmod(perm) })
+}
+
+// writeFileAtomic writes the file through a temporary file, prepare setting the permissions of the temporary file
+// before the data is written to it
+func writeFileAtomic(filename string, data []byte, prepare func(tempFile *os.File) error) (err error) {
+	tempFile, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tempFile.Name())
+		}
+	}()
+	if err = prepare(tempFile); err == nil {
+		if _, err = tempFile.Write(data); err == nil {
+			err = tempFile.Sync()
+		}
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), filename)
+}
+
 // GetFileModificationTime returns the modification time of the file
 func GetFileModificationTime(srcPath string) (modificationTime time.Time, err error) {
 
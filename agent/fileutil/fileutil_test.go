@@ -15,6 +15,7 @@ package fileutil
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -209,6 +210,30 @@ func TestMoveFile(t *testing.T) {
 	fs = osFS{}
 }
 
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileutil")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "updatecontext.json")
+
+	// the file is created and then replaced, leaving no temporary file behind
+	for _, content := range []string{`{"Current":{}}`, `{"Histories":[]}`} {
+		assert.NoError(t, WriteFileAtomic(path, []byte(content), 0600))
+		data, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	// a write to a missing folder fails without leaving a temporary file
+	assert.Error(t, WriteFileAtomic(filepath.Join(dir, "missing", "updatecontext.json"), []byte("{}"), 0600))
+	files, err = ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
 func TestUnderDir(t *testing.T) {
 	// Remove one or more directory levels
 	assert.True(t, isUnderDir(`~/foo/bar/../`, `~/foo`))
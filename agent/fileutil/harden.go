@@ -1,7 +1,6 @@
 package fileutil
 
 import (
-	"io/ioutil"
 	"os"
 	"path/filepath"
 )
@@ -10,28 +9,13 @@ const (
 	RWPermission = 0600
 )
 
-// HardenedWriteFile calls ioutil.WriteFile and guarantees a hardened permission
-// control. If the file already exists, it hardens the permissions before
-// writing data to it.
+// HardenedWriteFile writes the file atomically and guarantees a hardened permission
+// control. The data is written to a temporary file whose permissions are hardened
+// before the data is written to it, the temporary file then replaces the file.
 func HardenedWriteFile(filename string, data []byte) (err error) {
-
-	if _, err = os.Stat(filename); err != nil {
-		if os.IsNotExist(err) {
-			os.Create(filename)
-		} else {
-			return
-		}
-	}
-
-	if err = Harden(filename); err != nil {
-		return
-	}
-
-	if err = ioutil.WriteFile(filename, data, RWPermission); err != nil {
-		return
-	}
-
-	return
+	return writeFileAtomic(filename, data, func(tempFile *os.File) error {
+		return Harden(tempFile.Name())
+	})
 }
 
 // RecursivelyHarden the files and directory under the specified path.
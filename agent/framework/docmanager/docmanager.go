@@ -11,11 +11,11 @@
 // either express or implied. See the License for the specific language governing
 // permissions and limitations under the License.
 
-// Package docmanager helps persist documents state to disk
+// Package docmanager helps persist documents state in the local state store
 package docmanager
 
 import (
-	"os"
+	"fmt"
 	"path"
 	"path/filepath"
 	"time"
@@ -25,10 +25,14 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/statestore"
 )
 
 const (
 	maxLogFileDeletions int = 100
+
+	// StateStoreBucket is the prefix of the buckets of the local state store holding the document states
+	StateStoreBucket = "documents"
 )
 
 type validString func(string) bool
@@ -39,11 +43,19 @@ type DocumentMgr interface {
 	PersistDocumentState(log log.T, fileName, instanceID, locationFolder string, state contracts.DocumentState)
 	GetDocumentState(log log.T, fileName, instanceID, locationFolder string) contracts.DocumentState
 	RemoveDocumentState(log log.T, fileName, instanceID, locationFolder string)
+	ListDocumentStates(log log.T, instanceID, locationFolder string) []string
 }
 
-//TODO use class lock instead of global lock?
-//TODO decouple the DocState model to better fit the service-processor-executer architecture
-//DocumentFileMgr encapsulate the file access and perform bookkeeping operations at the specified file location
+// openStore returns the local state store, the state files written by the previous versions of the agent are migrated
+// on first use
+var openStore = statestore.OpenDefault
+
+// openReadOnlyStore returns the local state store opened read-only, for the lookups that never migrate nor write
+var openReadOnlyStore = statestore.OpenDefaultReadOnly
+
+// TODO decouple the DocState model to better fit the service-processor-executer architecture
+// DocumentFileMgr persists the document states in the local state store, one bucket per instance and location folder,
+// and migrates the files the previous versions of the agent wrote at the specified file location
 type DocumentFileMgr struct {
 	dataStorePath string
 	rootDirName   string
@@ -60,96 +72,155 @@ func NewDocumentFileMgr(dataStorePath, rootDirName, stateLocation string) *Docum
 
 func (d *DocumentFileMgr) MoveDocumentState(log log.T, fileName, instanceID, srcLocationFolder, dstLocationFolder string) {
 
-	absoluteSource := path.Join(d.dataStorePath,
-		instanceID,
-		d.rootDirName,
-		d.stateLocation,
-		srcLocationFolder)
-
-	absoluteDestination := path.Join(d.dataStorePath,
-		instanceID,
-		d.rootDirName,
-		d.stateLocation,
-		dstLocationFolder)
-
-	if s, err := fileutil.MoveFile(fileName, absoluteSource, absoluteDestination); s && err == nil {
-		log.Debugf("moved file %v from %v to %v successfully", fileName, srcLocationFolder, dstLocationFolder)
+	store, err := d.open(log, instanceID, srcLocationFolder, dstLocationFolder)
+	if err == nil {
+		err = store.Update(func(tx statestore.Tx) error {
+			content, found := tx.Get(documentBucket(instanceID, srcLocationFolder), fileName)
+			if !found {
+				return fmt.Errorf("document state not found in %v", srcLocationFolder)
+			}
+			if err := tx.Put(documentBucket(instanceID, dstLocationFolder), fileName, content); err != nil {
+				return err
+			}
+			return tx.Delete(documentBucket(instanceID, srcLocationFolder), fileName)
+		})
+	}
+	if err == nil {
+		log.Debugf("moved document state %v from %v to %v successfully", fileName, srcLocationFolder, dstLocationFolder)
 	} else {
-		log.Debugf("moving file %v from %v to %v failed with error %v", fileName, srcLocationFolder, dstLocationFolder, err)
+		log.Debugf("moving document state %v from %v to %v failed with error %v", fileName, srcLocationFolder, dstLocationFolder, err)
 	}
-
 }
 
 func (d *DocumentFileMgr) PersistDocumentState(log log.T, fileName, instanceID, locationFolder string, state contracts.DocumentState) {
 
-	absoluteFileName := path.Join(path.Join(d.dataStorePath,
-		instanceID,
-		d.rootDirName,
-		d.stateLocation,
-		locationFolder), fileName)
-
 	content, err := jsonutil.Marshal(state)
 	if err != nil {
 		log.Errorf("encountered error with message %v while marshalling %v to string", err, state)
+		return
+	}
+	log.Tracef("persisting interim state %v of %v", jsonutil.Indent(content), fileName)
+
+	store, err := d.open(log, instanceID, locationFolder)
+	if err == nil {
+		err = store.Update(func(tx statestore.Tx) error {
+			if _, found := tx.Get(documentBucket(instanceID, locationFolder), fileName); found {
+				log.Debugf("overwriting interim state of %v", fileName)
+			}
+			return tx.Put(documentBucket(instanceID, locationFolder), fileName, []byte(content))
+		})
+	}
+	if err == nil {
+		log.Debugf("successfully persisted interim state in %v", locationFolder)
 	} else {
-		if fileutil.Exists(absoluteFileName) {
-			log.Debugf("overwriting contents of %v", absoluteFileName)
-		}
-		log.Tracef("persisting interim state %v in file %v", jsonutil.Indent(content), absoluteFileName)
-		if s, err := fileutil.WriteIntoFileWithPermissions(absoluteFileName, jsonutil.Indent(content), os.FileMode(int(appconfig.ReadWriteAccess))); s && err == nil {
-			log.Debugf("successfully persisted interim state in %v", locationFolder)
-		} else {
-			log.Debugf("persisting interim state in %v failed with error %v", locationFolder, err)
-		}
+		log.Debugf("persisting interim state in %v failed with error %v", locationFolder, err)
 	}
 }
 
 func (d *DocumentFileMgr) GetDocumentState(log log.T, fileName, instanceID, locationFolder string) contracts.DocumentState {
 
-	absoluteFileName := path.Join(path.Join(d.dataStorePath,
-		instanceID,
-		d.rootDirName,
-		d.stateLocation,
-		locationFolder), fileName)
-
 	var commandState contracts.DocumentState
-	err := jsonutil.UnmarshalFile(absoluteFileName, &commandState)
+	store, err := d.open(log, instanceID, locationFolder)
+	if err == nil {
+		err = store.View(func(tx statestore.Tx) error {
+			found, err := statestore.GetJSON(tx, documentBucket(instanceID, locationFolder), fileName, &commandState)
+			if err == nil && !found {
+				err = fmt.Errorf("document state not found in %v", locationFolder)
+			}
+			return err
+		})
+	}
 	if err != nil {
-		log.Errorf("encountered error with message %v while reading Interim state of command from file - %v", err, fileName)
+		log.Errorf("encountered error with message %v while reading Interim state of command - %v", err, fileName)
 	} else {
-		//logging interim state as read from the file
+		//logging interim state as read from the store
 		jsonString, err := jsonutil.Marshal(commandState)
 		if err != nil {
 			log.Errorf("encountered error with message %v while marshalling %v to string", err, commandState)
 		} else {
-			log.Tracef("interim CommandState read from file-system - %v", jsonutil.Indent(jsonString))
+			log.Tracef("interim CommandState read from the local state store - %v", jsonutil.Indent(jsonString))
 		}
 	}
 
 	return commandState
 }
 
-// RemoveData deletes the fileName from locationFolder under defaultLogDir/instanceID
+// RemoveDocumentState deletes the state of the document from locationFolder
 func (d *DocumentFileMgr) RemoveDocumentState(log log.T, commandID, instanceID, locationFolder string) {
 
-	absoluteFileName := docStateFileName(commandID, instanceID, locationFolder)
-
-	err := fileutil.DeleteFile(absoluteFileName)
+	store, err := d.open(log, instanceID, locationFolder)
+	if err == nil {
+		err = store.Update(func(tx statestore.Tx) error {
+			return tx.Delete(documentBucket(instanceID, locationFolder), commandID)
+		})
+	}
 	if err != nil {
-		log.Errorf("encountered error %v while deleting file %v", err, absoluteFileName)
+		log.Errorf("encountered error %v while deleting document state %v from %v", err, commandID, locationFolder)
 	} else {
-		log.Debugf("successfully deleted file %v", absoluteFileName)
+		log.Debugf("successfully deleted document state %v from %v", commandID, locationFolder)
 	}
 }
 
-//TODO rework this part
-// DocumentStateDir returns absolute filename where command states are persisted
-func DocumentStateDir(instanceID, locationFolder string) string {
-	return filepath.Join(appconfig.DefaultDataStorePath,
-		instanceID,
-		appconfig.DefaultDocumentRootDirName,
-		appconfig.DefaultLocationOfState,
-		locationFolder)
+// ListDocumentStates returns the names of the document states of locationFolder, sorted
+func (d *DocumentFileMgr) ListDocumentStates(log log.T, instanceID, locationFolder string) (fileNames []string) {
+
+	store, err := d.open(log, instanceID, locationFolder)
+	if err == nil {
+		err = store.View(func(tx statestore.Tx) error {
+			fileNames = tx.Keys(documentBucket(instanceID, locationFolder))
+			return nil
+		})
+	}
+	if err != nil {
+		log.Errorf("encountered error %v while listing the document states of %v", err, locationFolder)
+	}
+	return fileNames
+}
+
+// HasDocumentState returns true if the state of the document is in locationFolder, in the local state store or in a
+// state file the agent hasn't migrated yet. The store is opened read-only and nothing is migrated, so that the programs
+// reading the state of a running agent, e.g. ssm-cli, never write to it.
+func (d *DocumentFileMgr) HasDocumentState(log log.T, fileName, instanceID, locationFolder string) bool {
+	if fileutil.Exists(path.Join(d.dataStorePath, instanceID, d.rootDirName, d.stateLocation, locationFolder, fileName)) {
+		return true
+	}
+	store, err := openReadOnlyStore()
+	if err != nil {
+		log.Debugf("encountered error %v while opening the local state store", err)
+		return false
+	}
+	defer store.Close()
+
+	found := false
+	err = store.View(func(tx statestore.Tx) error {
+		_, found = tx.Get(documentBucket(instanceID, locationFolder), fileName)
+		return nil
+	})
+	if err != nil {
+		log.Debugf("encountered error %v while looking for the document state %v in %v", err, fileName, locationFolder)
+	}
+	return found
+}
+
+// open returns the local state store once the state files of the location folders written by the previous versions
+// of the agent are migrated
+func (d *DocumentFileMgr) open(log log.T, instanceID string, locationFolders ...string) (statestore.Store, error) {
+	store, err := openStore(log)
+	if err != nil {
+		return nil, err
+	}
+	for _, locationFolder := range locationFolders {
+		dir := path.Join(d.dataStorePath, instanceID, d.rootDirName, d.stateLocation, locationFolder)
+		if _, err = statestore.MigrateDir(log, store, documentBucket(instanceID, locationFolder), dir); err != nil {
+			log.Warnf("Failed to migrate the document states of %v: %v", dir, err)
+		}
+	}
+	return store, nil
+}
+
+// documentBucket returns the bucket of the local state store holding the document states of the location folder
+func documentBucket(instanceID, locationFolder string) string {
+	return path.Join(StateStoreBucket, instanceID, locationFolder)
 }
 
 // orchestrationDir returns the absolute path of the orchestration directory
@@ -228,8 +299,3 @@ func isOlderThan(log log.T, fileFullPath string, retentionDurationHours int) boo
 	// Check whether the current time is after modification time plus the retention duration
 	return modificationTime.Add(time.Hour * time.Duration(retentionDurationHours)).Before(time.Now())
 }
-
-// docStateFileName returns absolute filename where command states are persisted
-func docStateFileName(fileName, instanceID, locationFolder string) string {
-	return path.Join(DocumentStateDir(instanceID, locationFolder), fileName)
-}
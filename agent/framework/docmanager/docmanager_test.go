@@ -0,0 +1,127 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/statestore"
+	"github.com/stretchr/testify/assert"
+)
+
+const testInstanceID = "i-1234567890"
+
+// setUp returns a DocumentFileMgr of a temporary folder with a state store in it, the returned function removing it
+func setUp(t *testing.T) (docMgr *DocumentFileMgr, dataStorePath string, tearDown func()) {
+	dataStorePath, err := ioutil.TempDir("", "docmanager")
+	assert.NoError(t, err)
+	store, _, err := statestore.Open(filepath.Join(dataStorePath, appconfig.StateStoreLocation))
+	assert.NoError(t, err)
+	openStore = func(log.T) (statestore.Store, error) { return store, nil }
+	openReadOnlyStore = func() (statestore.Store, error) {
+		return statestore.OpenReadOnly(filepath.Join(dataStorePath, appconfig.StateStoreLocation))
+	}
+	docMgr = NewDocumentFileMgr(dataStorePath, appconfig.DefaultDocumentRootDirName, appconfig.DefaultLocationOfState)
+	return docMgr, dataStorePath, func() {
+		store.Close()
+		os.RemoveAll(dataStorePath)
+		openStore = statestore.OpenDefault
+		openReadOnlyStore = statestore.OpenDefaultReadOnly
+	}
+}
+
+func newDocumentState(documentID string) contracts.DocumentState {
+	docState := contracts.DocumentState{DocumentType: contracts.SendCommand}
+	docState.DocumentInformation.DocumentID = documentID
+	docState.DocumentInformation.InstanceID = testInstanceID
+	return docState
+}
+
+func TestDocumentStateLifecycle(t *testing.T) {
+	docMgr, _, tearDown := setUp(t)
+	defer tearDown()
+	logger := log.NewMockLog()
+	docState := newDocumentState("command1")
+
+	docMgr.PersistDocumentState(logger, "command1", testInstanceID, appconfig.DefaultLocationOfPending, docState)
+	assert.Equal(t, []string{"command1"}, docMgr.ListDocumentStates(logger, testInstanceID, appconfig.DefaultLocationOfPending))
+
+	docMgr.MoveDocumentState(logger, "command1", testInstanceID, appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
+	assert.Empty(t, docMgr.ListDocumentStates(logger, testInstanceID, appconfig.DefaultLocationOfPending))
+	assert.Equal(t, []string{"command1"}, docMgr.ListDocumentStates(logger, testInstanceID, appconfig.DefaultLocationOfCurrent))
+
+	docState.DocumentInformation.RunCount = 1
+	docMgr.PersistDocumentState(logger, "command1", testInstanceID, appconfig.DefaultLocationOfCurrent, docState)
+	assert.Equal(t, docState, docMgr.GetDocumentState(logger, "command1", testInstanceID, appconfig.DefaultLocationOfCurrent))
+
+	docMgr.RemoveDocumentState(logger, "command1", testInstanceID, appconfig.DefaultLocationOfCurrent)
+	assert.Empty(t, docMgr.ListDocumentStates(logger, testInstanceID, appconfig.DefaultLocationOfCurrent))
+	assert.Equal(t, contracts.DocumentState{}, docMgr.GetDocumentState(logger, "command1", testInstanceID, appconfig.DefaultLocationOfCurrent))
+}
+
+func TestMoveMissingDocumentState(t *testing.T) {
+	docMgr, _, tearDown := setUp(t)
+	defer tearDown()
+	logger := log.NewMockLog()
+
+	docMgr.MoveDocumentState(logger, "command1", testInstanceID, appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCorrupt)
+
+	assert.Empty(t, docMgr.ListDocumentStates(logger, testInstanceID, appconfig.DefaultLocationOfCorrupt))
+}
+
+func TestDocumentStatesAreMigrated(t *testing.T) {
+	docMgr, dataStorePath, tearDown := setUp(t)
+	defer tearDown()
+	logger := log.NewMockLog()
+	legacyDir := filepath.Join(dataStorePath, testInstanceID, appconfig.DefaultDocumentRootDirName,
+		appconfig.DefaultLocationOfState, appconfig.DefaultLocationOfCurrent)
+	assert.NoError(t, os.MkdirAll(legacyDir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(legacyDir, "command1"),
+		[]byte(`{"DocumentInformation":{"DocumentID":"command1","InstanceID":"i-1234567890"},"DocumentType":"SendCommand"}`), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(legacyDir, "command2"), []byte(`{"DocumentInfor`), 0600))
+
+	assert.Equal(t, []string{"command1"}, docMgr.ListDocumentStates(logger, testInstanceID, appconfig.DefaultLocationOfCurrent))
+	assert.Equal(t, newDocumentState("command1"), docMgr.GetDocumentState(logger, "command1", testInstanceID, appconfig.DefaultLocationOfCurrent))
+
+	// the migrated file is deleted, the corrupt one is kept for investigation
+	files, err := ioutil.ReadDir(legacyDir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "command2"+statestore.CorruptSuffix, files[0].Name())
+}
+
+func TestHasDocumentState(t *testing.T) {
+	docMgr, dataStorePath, tearDown := setUp(t)
+	defer tearDown()
+	logger := log.NewMockLog()
+	docMgr.PersistDocumentState(logger, "command1", testInstanceID, appconfig.DefaultLocationOfPending, newDocumentState("command1"))
+	legacyDir := filepath.Join(dataStorePath, testInstanceID, appconfig.DefaultDocumentRootDirName,
+		appconfig.DefaultLocationOfState, appconfig.DefaultLocationOfCurrent)
+	assert.NoError(t, os.MkdirAll(legacyDir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(legacyDir, "command2"), []byte(`{}`), 0600))
+
+	assert.True(t, docMgr.HasDocumentState(logger, "command1", testInstanceID, appconfig.DefaultLocationOfPending))
+	assert.False(t, docMgr.HasDocumentState(logger, "command1", testInstanceID, appconfig.DefaultLocationOfCurrent))
+	assert.True(t, docMgr.HasDocumentState(logger, "command2", testInstanceID, appconfig.DefaultLocationOfCurrent))
+
+	// the state file the agent hasn't migrated yet is left in place
+	_, err := os.Stat(filepath.Join(legacyDir, "command2"))
+	assert.NoError(t, err)
+}
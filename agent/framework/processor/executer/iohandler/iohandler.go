@@ -18,7 +18,9 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"path/filepath"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/iomodule"
@@ -49,8 +51,8 @@ func DefaultOutputConfig() PluginConfig {
 	return PluginConfig{
 		StdoutFileName:        "stdout",
 		StderrFileName:        "stderr",
-		MaxStdoutLength:       24000,
-		MaxStderrLength:       8000,
+		MaxStdoutLength:       appconfig.MaxStdoutLength,
+		MaxStderrLength:       appconfig.MaxStderrLength,
 		OutputTruncatedSuffix: "--output truncated--",
 	}
 }
@@ -97,6 +99,11 @@ type DefaultIOHandler struct {
 	stdout   string
 	stderr   string
 	ioConfig contracts.IOConfiguration
+	// size of the complete stdout and stderr, and the files they're written to
+	stdoutSize int
+	stderrSize int
+	stdoutPath string
+	stderrPath string
 	//refreshassociation and invoker write a different output rather than merging stdout and stderr
 	output interface{}
 
@@ -118,6 +125,7 @@ func NewDefaultIOHandler(log log.T, ioConfig contracts.IOConfiguration) *Default
 func (out *DefaultIOHandler) Init(log log.T, filePath ...string) {
 
 	pluginConfig := DefaultOutputConfig()
+	limits := out.outputLimits()
 	// Create path to output location for file and s3
 	fullPath := out.ioConfig.OrchestrationDirectory
 	s3KeyPrefix := out.ioConfig.OutputS3KeyPrefix
@@ -125,6 +133,11 @@ func (out *DefaultIOHandler) Init(log log.T, filePath ...string) {
 		fullPath = fileutil.BuildPath(fullPath, element)
 		s3KeyPrefix = fileutil.BuildS3Path(s3KeyPrefix, element)
 	}
+	// The log streams are named after the step
+	logStreamPrefix := out.ioConfig.CloudWatchLogStreamPrefix
+	if len(filePath) > 0 {
+		logStreamPrefix = fileutil.BuildS3Path(logStreamPrefix, filePath[len(filePath)-1])
+	}
 
 	// Initialize file output module
 	stdoutFile := iomodule.File{
@@ -134,18 +147,39 @@ func (out *DefaultIOHandler) Init(log log.T, filePath ...string) {
 		OutputS3KeyPrefix:      s3KeyPrefix,
 	}
 
+	out.stdoutPath = filepath.Join(fullPath, pluginConfig.StdoutFileName)
+
 	// Initialize console output module
 	stdoutConsole := iomodule.CommandOutput{
-		OutputLimit:            pluginConfig.MaxStdoutLength,
+		OutputLimit:            limits.MaxStdoutLength,
+		Truncation:             limits.Truncation,
+		TruncatedMarker:        pluginConfig.OutputTruncatedSuffix,
 		OutputString:           &out.stdout,
+		OutputSize:             &out.stdoutSize,
 		FileName:               pluginConfig.StdoutFileName,
 		OrchestrationDirectory: fullPath,
 	}
 
+	stdoutModules := []iomodule.IOModule{stdoutFile, stdoutConsole}
+	// Initialize CloudWatch Logs output module if the output is sent to CloudWatch Logs
+	if out.ioConfig.CloudWatchLogGroupName != "" {
+		stdoutModules = append(stdoutModules, iomodule.CloudWatchLogs{
+			LogGroupName:  out.ioConfig.CloudWatchLogGroupName,
+			LogStreamName: fileutil.BuildS3Path(logStreamPrefix, pluginConfig.StdoutFileName),
+		})
+	}
+	// Initialize output stream module if the output is streamed while the plugin runs
+	if out.ioConfig.OutputStream != nil {
+		stdoutModules = append(stdoutModules, iomodule.OutputStream{
+			StreamName: pluginConfig.StdoutFileName,
+			Stream:     out.ioConfig.OutputStream,
+		})
+	}
+
 	log.Debug("Initializing the Stdout Multi-writer with file and console listeners")
 	// Get a multi-writer for standard output
 	out.StdoutWriter = multiwriter.NewDocumentIOMultiWriter()
-	out.RegisterOutputSource(log, out.StdoutWriter, stdoutFile, stdoutConsole)
+	out.RegisterOutputSource(log, out.StdoutWriter, stdoutModules...)
 
 	// Initialize file error module
 	stderrFile := iomodule.File{
@@ -155,18 +189,39 @@ func (out *DefaultIOHandler) Init(log log.T, filePath ...string) {
 		OutputS3KeyPrefix:      s3KeyPrefix,
 	}
 
+	out.stderrPath = filepath.Join(fullPath, pluginConfig.StderrFileName)
+
 	// Initialize console error module
 	stderrConsole := iomodule.CommandOutput{
-		OutputLimit:            pluginConfig.MaxStderrLength,
+		OutputLimit:            limits.MaxStderrLength,
+		Truncation:             limits.Truncation,
+		TruncatedMarker:        pluginConfig.OutputTruncatedSuffix,
 		OutputString:           &out.stderr,
+		OutputSize:             &out.stderrSize,
 		FileName:               pluginConfig.StderrFileName,
 		OrchestrationDirectory: fullPath,
 	}
 
+	stderrModules := []iomodule.IOModule{stderrFile, stderrConsole}
+	// Initialize CloudWatch Logs error module if the output is sent to CloudWatch Logs
+	if out.ioConfig.CloudWatchLogGroupName != "" {
+		stderrModules = append(stderrModules, iomodule.CloudWatchLogs{
+			LogGroupName:  out.ioConfig.CloudWatchLogGroupName,
+			LogStreamName: fileutil.BuildS3Path(logStreamPrefix, pluginConfig.StderrFileName),
+		})
+	}
+	// Initialize error stream module if the output is streamed while the plugin runs
+	if out.ioConfig.OutputStream != nil {
+		stderrModules = append(stderrModules, iomodule.OutputStream{
+			StreamName: pluginConfig.StderrFileName,
+			Stream:     out.ioConfig.OutputStream,
+		})
+	}
+
 	log.Debug("Initializing the Stderr Multi-writer with file and console listeners")
 	// Get a multi-writer for standard error
 	out.StderrWriter = multiwriter.NewDocumentIOMultiWriter()
-	out.RegisterOutputSource(log, out.StderrWriter, stderrFile, stderrConsole)
+	out.RegisterOutputSource(log, out.StderrWriter, stderrModules...)
 }
 
 // RegisterOutputSource returns a new output source by creating a multiwriter for the output modules.
@@ -205,7 +260,48 @@ func (out *DefaultIOHandler) Close(log log.T) {
 
 // String returns the output by concatenating stdout and stderr
 func (out DefaultIOHandler) String() (response string) {
-	return TruncateOutput(out.stdout, out.stderr, MaximumPluginOutputSize)
+	limits := out.outputLimits()
+	return TruncateOutputWith(out.stdout, out.stderr, limits.MaxOutputLength, limits.Truncation)
+}
+
+// outputLimits returns the limits of the output of the io configuration, the default limits replacing the ones not set
+func (out DefaultIOHandler) outputLimits() appconfig.OutputLimits {
+	return appconfig.ValidateOutputLimits(out.ioConfig.OutputLimits, appconfig.DefaultOutputLimits())
+}
+
+// GetTruncatedStdout returns the stdout within its limit, along with the description of the truncation of the stdout
+// if it exceeded its limit
+func (out DefaultIOHandler) GetTruncatedStdout() (string, *contracts.OutputTruncation) {
+	limits := out.outputLimits()
+	return truncateStream(out.stdout, out.stdoutSize, limits.MaxStdoutLength, limits.Truncation, out.stdoutPath)
+}
+
+// GetTruncatedStderr returns the stderr within its limit, along with the description of the truncation of the stderr
+// if it exceeded its limit
+func (out DefaultIOHandler) GetTruncatedStderr() (string, *contracts.OutputTruncation) {
+	limits := out.outputLimits()
+	return truncateStream(out.stderr, out.stderrSize, limits.MaxStderrLength, limits.Truncation, out.stderrPath)
+}
+
+// truncateStream truncates the output if the output modules didn't, and describes the part of the output of size bytes
+// which was dropped
+func truncateStream(output string, size int, limit int, truncation string, path string) (string, *contracts.OutputTruncation) {
+	marker := DefaultOutputConfig().OutputTruncatedSuffix
+	// the output appended without writer isn't truncated yet
+	if len(output) > size {
+		size = len(output)
+	}
+	if size <= limit {
+		return output, nil
+	}
+	if len(output) > limit {
+		output = iomodule.Truncate(output, output, limit-len(marker), truncation, marker)
+	}
+	return output, &contracts.OutputTruncation{
+		TotalBytes:     size,
+		DroppedBytes:   size - (len(output) - len(marker)),
+		FullOutputPath: path,
+	}
 }
 
 // GetOutput returns the output to be appended to the response
@@ -260,11 +356,13 @@ func (out *DefaultIOHandler) SetStatus(status contracts.ResultStatus) {
 // SetStdout sets the stdout
 func (out *DefaultIOHandler) SetStdout(stdout string) {
 	out.stdout = stdout
+	out.stdoutSize = len(stdout)
 }
 
 // SetStderr sets the stderr
 func (out *DefaultIOHandler) SetStderr(stderr string) {
 	out.stderr = stderr
+	out.stderrSize = len(stderr)
 }
 
 // SetExitCode sets the exit code
@@ -296,6 +394,13 @@ func (out *DefaultIOHandler) Merge(log log.T, mergeOutput *DefaultIOHandler) {
 	stderrBuffer.WriteString(mergeOutput.GetStderr())
 	out.stderr = stderrBuffer.String()
 
+	out.stdoutSize += mergeOutput.stdoutSize
+	out.stderrSize += mergeOutput.stderrSize
+	if out.stdoutPath == "" {
+		out.stdoutPath = mergeOutput.stdoutPath
+		out.stderrPath = mergeOutput.stderrPath
+	}
+
 	if out.ExitCode == 0 {
 		out.ExitCode = mergeOutput.GetExitCode()
 	}
@@ -396,6 +501,11 @@ func (out *DefaultIOHandler) AppendErrorf(format string, params ...interface{})
 
 // TruncateOutput truncates the output
 func TruncateOutput(stdout string, stderr string, capacity int) (response string) {
+	return TruncateOutputWith(stdout, stderr, capacity, appconfig.OutputTruncationKeepHead)
+}
+
+// TruncateOutputWith truncates the output keeping the head, the tail or both of stdout and stderr
+func TruncateOutputWith(stdout string, stderr string, capacity int, truncation string) (response string) {
 	outputSize := len(stdout)
 	errorSize := len(stderr)
 
@@ -418,16 +528,19 @@ func TruncateOutput(stdout string, stderr string, capacity int) (response string
 	// truncate out and error when both exceed the size
 	if outputSize > availableSpace/2 && errorSize > availableSpace/2 {
 		truncateSize := availableSpace - len(truncateError) - len(truncateOut)
-		return fmt.Sprint(stdout[:truncateSize/2], truncateOut, errorTitle, stderr[:truncateSize/2], truncateError)
+		return fmt.Sprint(
+			iomodule.Truncate(stdout, stdout, truncateSize/2, truncation, truncateOut),
+			errorTitle,
+			iomodule.Truncate(stderr, stderr, truncateSize/2, truncation, truncateError))
 	}
 
 	// truncate error when output is short
 	if outputSize < availableSpace/2 {
 		truncateSize := availableSpace - len(truncateError)
-		return fmt.Sprint(stdout, errorTitle, stderr[:truncateSize-outputSize], truncateError)
+		return fmt.Sprint(stdout, errorTitle, iomodule.Truncate(stderr, stderr, truncateSize-outputSize, truncation, truncateError))
 	}
 
 	// truncate output when error is short
 	truncateSize := availableSpace - len(truncateOut)
-	return fmt.Sprint(stdout[:truncateSize-errorSize], truncateOut, errorTitle, stderr)
+	return fmt.Sprint(iomodule.Truncate(stdout, stdout, truncateSize-errorSize, truncation, truncateOut), errorTitle, stderr)
 }
@@ -15,10 +15,12 @@ package iohandler
 
 import (
 	"fmt"
-	"testing"
-
+	"io/ioutil"
+	"os"
 	"sync"
+	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/iomodule/mock"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/multiwriter/mock"
@@ -67,6 +69,31 @@ func TestTruncateOutput(t *testing.T) {
 	}
 }
 
+func TestTruncateOutputKeepTail(t *testing.T) {
+	actual := TruncateOutputWith(longMessage, "", sampleSize, appconfig.OutputTruncationKeepTail)
+	assert.Equal(t, "\n---Output truncated---is is a sample text.\n1234567890. This is a sample text. This is a sample text", actual)
+}
+
+func TestGetTruncatedStdout(t *testing.T) {
+	output := DefaultIOHandler{
+		ioConfig:   contracts.IOConfiguration{OutputLimits: appconfig.OutputLimits{MaxStdoutLength: sampleSize}},
+		stdoutPath: "orchestrationDir/step1/stdout",
+	}
+	output.SetStdout(longMessage)
+
+	stdout, truncation := output.GetTruncatedStdout()
+	stderr, noTruncation := output.GetTruncatedStderr()
+
+	assert.Equal(t, sampleSize, len(stdout))
+	assert.Equal(t, &contracts.OutputTruncation{
+		TotalBytes:     len(longMessage),
+		DroppedBytes:   len(longMessage) - sampleSize + len(DefaultOutputConfig().OutputTruncatedSuffix),
+		FullOutputPath: "orchestrationDir/step1/stdout",
+	}, truncation)
+	assert.Equal(t, "", stderr)
+	assert.Nil(t, noTruncation)
+}
+
 var logger = log.NewMockLog()
 
 func TestRegisterOutputSource(t *testing.T) {
@@ -74,6 +101,8 @@ func TestRegisterOutputSource(t *testing.T) {
 
 	mockDocumentIOMultiWriter.On("AddWriter", mock.Anything).Times(2)
 	wg := new(sync.WaitGroup)
+	// the mocked AddWriter doesn't count the readers
+	wg.Add(2)
 	mockDocumentIOMultiWriter.On("GetWaitGroup").Return(wg)
 
 	// Create multiple test IOModules
@@ -84,6 +113,31 @@ func TestRegisterOutputSource(t *testing.T) {
 
 	output := DefaultIOHandler{}
 	output.RegisterOutputSource(logger, mockDocumentIOMultiWriter, testModule1, testModule2)
+	wg.Wait()
+}
+
+func TestInitWithOutputStream(t *testing.T) {
+	orchestrationDir, err := ioutil.TempDir("", "iohandler")
+	assert.NoError(t, err)
+	defer os.RemoveAll(orchestrationDir)
+	var lock sync.Mutex
+	streamed := make(map[string]string)
+	ioConfig := contracts.IOConfiguration{
+		OrchestrationDirectory: orchestrationDir,
+		OutputStream: func(chunk contracts.OutputChunk) {
+			lock.Lock()
+			defer lock.Unlock()
+			streamed[chunk.Stream] += chunk.Data
+		},
+	}
+
+	output := NewDefaultIOHandler(logger, ioConfig)
+	output.Init(logger, "aws:runShellScript", "step1")
+	output.AppendInfo("Info message")
+	output.AppendError("Error message")
+	output.Close(logger)
+
+	assert.Equal(t, map[string]string{"stdout": "Info message", "stderr": "Error message"}, streamed)
 }
 
 func TestSucceeded(t *testing.T) {
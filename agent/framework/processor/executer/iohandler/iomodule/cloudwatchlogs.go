@@ -0,0 +1,170 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iomodule
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher"
+	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher/cloudwatchlogsinterface"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+const (
+	// limits of the PutLogEvents API
+	cloudWatchLogsMaxBatchEvents = 10000
+	cloudWatchLogsMaxBatchSize   = 1048576
+	cloudWatchLogsEventOverhead  = 26
+	cloudWatchLogsMaxEventSize   = 262144 - cloudWatchLogsEventOverhead
+
+	// cloudWatchLogsFlushInterval is how long the lines are batched before being sent
+	cloudWatchLogsFlushInterval = 5 * time.Second
+	// cloudWatchLogsLineBuffer is the number of lines read from the output while a batch is sent
+	cloudWatchLogsLineBuffer = 1000
+)
+
+// newCloudWatchLogsService creates the service publishing the output, overridden by the tests
+var newCloudWatchLogsService = func() cloudwatchlogsinterface.ICloudWatchLogsService {
+	return cloudwatchlogspublisher.NewCloudWatchLogsService()
+}
+
+// CloudWatchLogs handles sending the output to a CloudWatch Logs log stream.
+type CloudWatchLogs struct {
+	LogGroupName  string
+	LogStreamName string
+}
+
+// cloudWatchLogsBatch is the log events sent by a single PutLogEvents call
+type cloudWatchLogsBatch struct {
+	events []*cloudwatchlogs.InputLogEvent
+	size   int
+}
+
+// Read reads the lines of the stream and sends them in batches to the log stream.
+func (c CloudWatchLogs) Read(log log.T, reader *io.PipeReader) {
+	defer func() { reader.Close() }()
+
+	service := newCloudWatchLogsService()
+	if err := c.createLogGroupAndStream(log, service); err != nil {
+		log.Errorf("Failed to create the log stream %v of the log group %v: %v", c.LogStreamName, c.LogGroupName, err)
+		// keep reading so that the writers of the stream aren't blocked
+		io.Copy(ioutil.Discard, reader)
+		return
+	}
+	sequenceToken := service.GetSequenceTokenForStream(log, c.LogGroupName, c.LogStreamName)
+
+	lines := make(chan string, cloudWatchLogsLineBuffer)
+	go readLines(log, reader, lines)
+
+	ticker := time.NewTicker(cloudWatchLogsFlushInterval)
+	defer ticker.Stop()
+	var batch cloudWatchLogsBatch
+	flush := func() {
+		if len(batch.events) == 0 {
+			return
+		}
+		var err error
+		if sequenceToken, err = service.PutLogEvents(log, batch.events, c.LogGroupName, c.LogStreamName, sequenceToken); err != nil {
+			// the service already retried with a new sequence token, skipping the batch
+			log.Errorf("Failed to send the output to the log stream %v, skipping %v lines: %v", c.LogStreamName, len(batch.events), err)
+			sequenceToken = service.GetSequenceTokenForStream(log, c.LogGroupName, c.LogStreamName)
+		}
+		batch = cloudWatchLogsBatch{}
+	}
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				flush()
+				return
+			}
+			for _, event := range newLogEvents(line, time.Now()) {
+				if !batch.fits(event) {
+					flush()
+				}
+				batch.add(event)
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// createLogGroupAndStream creates the log group and the log stream if they don't exist
+func (c CloudWatchLogs) createLogGroupAndStream(log log.T, service cloudwatchlogsinterface.ICloudWatchLogsService) error {
+	if !service.IsLogGroupPresent(log, c.LogGroupName) {
+		if err := service.CreateLogGroup(log, c.LogGroupName); err != nil {
+			return err
+		}
+	}
+	if !service.IsLogStreamPresent(log, c.LogGroupName, c.LogStreamName) {
+		if err := service.CreateLogStream(log, c.LogGroupName, c.LogStreamName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLines sends the lines of the stream to the channel and closes it once the stream is closed
+func readLines(log log.T, reader io.Reader, lines chan<- string) {
+	defer close(lines)
+	bufReader := bufio.NewReader(reader)
+	for {
+		line, err := bufReader.ReadString('\n')
+		if line = strings.TrimSuffix(line, "\n"); len(line) > 0 {
+			lines <- line
+		}
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			log.Errorf("Error reading the stream: %v", err)
+			return
+		}
+	}
+}
+
+// newLogEvents returns the log events of a line, splitting the lines exceeding the maximum size of an event
+func newLogEvents(line string, timestamp time.Time) (events []*cloudwatchlogs.InputLogEvent) {
+	millis := timestamp.UnixNano() / int64(time.Millisecond)
+	for len(line) > 0 {
+		message := line
+		if len(message) > cloudWatchLogsMaxEventSize {
+			message = message[:cloudWatchLogsMaxEventSize]
+		}
+		line = line[len(message):]
+		events = append(events, &cloudwatchlogs.InputLogEvent{
+			Message:   aws.String(message),
+			Timestamp: aws.Int64(millis),
+		})
+	}
+	return
+}
+
+// fits returns true if the event can be added to the batch without exceeding the limits of a batch
+func (b *cloudWatchLogsBatch) fits(event *cloudwatchlogs.InputLogEvent) bool {
+	return len(b.events) < cloudWatchLogsMaxBatchEvents &&
+		b.size+len(*event.Message)+cloudWatchLogsEventOverhead <= cloudWatchLogsMaxBatchSize
+}
+
+func (b *cloudWatchLogsBatch) add(event *cloudwatchlogs.InputLogEvent) {
+	b.events = append(b.events, event)
+	b.size += len(*event.Message) + cloudWatchLogsEventOverhead
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iomodule
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher/cloudwatchlogsinterface"
+	cloudwatchlogsmock "github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher/mock"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testLogGroup  = "/aws/ssm/AWS-RunShellScript"
+	testLogStream = "commandID/instanceID/step1/stdout"
+)
+
+func readWithService(t *testing.T, service cloudwatchlogsinterface.ICloudWatchLogsService, output string) {
+	newCloudWatchLogsService = func() cloudwatchlogsinterface.ICloudWatchLogsService { return service }
+	module := CloudWatchLogs{LogGroupName: testLogGroup, LogStreamName: testLogStream}
+	r, w := io.Pipe()
+	done := make(chan bool)
+	go func() {
+		module.Read(logger, r)
+		done <- true
+	}()
+
+	w.Write([]byte(output))
+	w.Close()
+	<-done
+}
+
+func messages(events []*cloudwatchlogs.InputLogEvent) (messages []string) {
+	for _, event := range events {
+		messages = append(messages, *event.Message)
+	}
+	return
+}
+
+// TestCloudWatchLogs tests the lines are sent to the log stream created by the module
+func TestCloudWatchLogs(t *testing.T) {
+	service := new(cloudwatchlogsmock.CloudWatchLogsServiceMock)
+	service.On("IsLogGroupPresent", logger, testLogGroup).Return(false)
+	service.On("CreateLogGroup", logger, testLogGroup).Return(nil)
+	service.On("IsLogStreamPresent", logger, testLogGroup, testLogStream).Return(false)
+	service.On("CreateLogStream", logger, testLogGroup, testLogStream).Return(nil)
+	service.On("GetSequenceTokenForStream", logger, testLogGroup, testLogStream).Return(nil)
+	var sent []string
+	service.On("PutLogEvents", logger, mock.Anything, testLogGroup, testLogStream, (*string)(nil)).Run(func(args mock.Arguments) {
+		sent = messages(args.Get(1).([]*cloudwatchlogs.InputLogEvent))
+	}).Return(aws.String("token"), nil)
+
+	readWithService(t, service, "first line\n\nsecond line")
+
+	service.AssertExpectations(t)
+	assert.Equal(t, []string{"first line", "second line"}, sent)
+}
+
+// TestCloudWatchLogsCreateStreamFailed tests the output is discarded when the log stream cannot be created
+func TestCloudWatchLogsCreateStreamFailed(t *testing.T) {
+	service := new(cloudwatchlogsmock.CloudWatchLogsServiceMock)
+	service.On("IsLogGroupPresent", logger, testLogGroup).Return(true)
+	service.On("IsLogStreamPresent", logger, testLogGroup, testLogStream).Return(false)
+	service.On("CreateLogStream", logger, testLogGroup, testLogStream).Return(errors.New("AccessDeniedException"))
+
+	readWithService(t, service, "first line\n")
+
+	service.AssertExpectations(t)
+	service.AssertNotCalled(t, "PutLogEvents", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestCloudWatchLogsBatches tests the batches are split to fit the limits of the PutLogEvents API
+func TestCloudWatchLogsBatches(t *testing.T) {
+	service := new(cloudwatchlogsmock.CloudWatchLogsServiceMock)
+	service.On("IsLogGroupPresent", logger, testLogGroup).Return(true)
+	service.On("IsLogStreamPresent", logger, testLogGroup, testLogStream).Return(true)
+	service.On("GetSequenceTokenForStream", logger, testLogGroup, testLogStream).Return(aws.String("token1"))
+	var batches [][]string
+	service.On("PutLogEvents", logger, mock.Anything, testLogGroup, testLogStream, aws.String("token1")).Run(func(args mock.Arguments) {
+		batches = append(batches, messages(args.Get(1).([]*cloudwatchlogs.InputLogEvent)))
+	}).Return(aws.String("token2"), nil).Once()
+	service.On("PutLogEvents", logger, mock.Anything, testLogGroup, testLogStream, aws.String("token2")).Run(func(args mock.Arguments) {
+		batches = append(batches, messages(args.Get(1).([]*cloudwatchlogs.InputLogEvent)))
+	}).Return(aws.String("token3"), nil).Once()
+
+	line := strings.Repeat("a", cloudWatchLogsMaxEventSize)
+	readWithService(t, service, strings.Repeat(line+"\n", 4)+"last line\n")
+
+	service.AssertExpectations(t)
+	assert.Equal(t, [][]string{{line, line, line, line}, {"last line"}}, batches)
+}
+
+// TestNewLogEvents tests the lines exceeding the size of an event are split
+func TestNewLogEvents(t *testing.T) {
+	timestamp := time.Unix(1500000000, 0)
+	line := strings.Repeat("a", cloudWatchLogsMaxEventSize) + "end"
+
+	events := newLogEvents(line, timestamp)
+
+	assert.Equal(t, []string{strings.Repeat("a", cloudWatchLogsMaxEventSize), "end"}, messages(events))
+	assert.Equal(t, int64(1500000000000), *events[1].Timestamp)
+}
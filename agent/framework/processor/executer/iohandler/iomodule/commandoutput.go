@@ -14,27 +14,41 @@
 package iomodule
 
 import (
-	"bufio"
 	"bytes"
 	"io"
-
 	"path/filepath"
 
-	"fmt"
-
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 )
 
+// commandOutputReadSize is the number of bytes read from the stream at once
+const commandOutputReadSize = 4096
+
 // CommandOutput handles writing output to a string.
 type CommandOutput struct {
 	// limit to the number of bytes to be written to the output string
-	OutputLimit            int
-	OutputString           *string
+	OutputLimit int
+	// Truncation is the part of the output kept when it exceeds the limit, the head by default
+	Truncation string
+	// TruncatedMarker replaces the part of the output dropped when it exceeds the limit
+	TruncatedMarker string
+	OutputString    *string
+	// OutputSize receives the size of the complete output when set
+	OutputSize             *int
 	FileName               string
 	OrchestrationDirectory string
 }
 
+// truncatedOutput keeps the beginning and the end of an output along with its size
+type truncatedOutput struct {
+	limit int
+	head  []byte
+	tail  []byte
+	size  int
+}
+
 func (c CommandOutput) Read(log log.T, reader *io.PipeReader) {
 	defer func() { reader.Close() }()
 	filePath := filepath.Join(c.OrchestrationDirectory, c.FileName)
@@ -44,11 +58,7 @@ func (c CommandOutput) Read(log log.T, reader *io.PipeReader) {
 	buf, err = fileutil.ReadAllText(filePath)
 
 	if buf != "" {
-		if len(buf) > c.OutputLimit {
-			buffer.WriteString(buf[:c.OutputLimit])
-		} else {
-			buffer.WriteString(buf)
-		}
+		buffer.WriteString(buf)
 	}
 	if err != nil {
 		log.Errorf("Error reading %v at path %v", c.FileName, filePath)
@@ -57,25 +67,77 @@ func (c CommandOutput) Read(log log.T, reader *io.PipeReader) {
 }
 
 func (c CommandOutput) ReadPipeAndFile(log log.T, reader *io.PipeReader, buffer bytes.Buffer) {
-	// Read byte by byte
-	scanner := bufio.NewScanner(reader)
-	scanner.Split(bufio.ScanBytes)
+	output := truncatedOutput{limit: c.OutputLimit}
+	output.write(buffer.Bytes())
 
-	outputLimit := buffer.Len()
-	for scanner.Scan() {
-		// Check if size of output is greater than the output limit
-		outputLimit++
-		if outputLimit > c.OutputLimit {
+	// The whole stream is read to keep its end and to count its size
+	data := make([]byte, commandOutputReadSize)
+	for {
+		n, err := reader.Read(data)
+		output.write(data[:n])
+		if err == io.EOF {
 			break
+		} else if err != nil {
+			log.Error("Error reading the stream")
+			break
+		}
+	}
+	*c.OutputString = output.String(c.Truncation, c.TruncatedMarker)
+	if c.OutputSize != nil {
+		*c.OutputSize = output.size
+	}
+	log.Debugf("Number of bytes written to console output: %v", len(*c.OutputString))
+}
+
+// write adds the data to the output, only the first and the last limit bytes are kept
+func (o *truncatedOutput) write(data []byte) {
+	o.size += len(data)
+	if room := o.limit - len(o.head); room > 0 {
+		if room > len(data) {
+			room = len(data)
 		}
-		buffer.WriteString(scanner.Text())
+		o.head = append(o.head, data[:room]...)
 	}
-	// Clear contents of string to avoid duplicate output
-	*c.OutputString = ""
-	*c.OutputString = fmt.Sprintf("%v%v", *c.OutputString, buffer.String())
-	log.Debugf("Number of bytes written to console output: %v", outputLimit-1)
+	o.tail = append(o.tail, data...)
+	if len(o.tail) > o.limit {
+		o.tail = o.tail[len(o.tail)-o.limit:]
+	}
+}
+
+// String returns the output, truncated with the marker if it exceeds the limit
+func (o *truncatedOutput) String(truncation string, marker string) string {
+	if o.size <= o.limit {
+		return string(o.head)
+	}
+	return Truncate(string(o.head), string(o.tail), o.limit-len(marker), truncation, marker)
+}
+
+// Truncate returns size bytes of an output along with the marker where the output was truncated. The head, the tail or
+// both are kept according to the truncation, head and tail being the beginning and the end of the output.
+func Truncate(head string, tail string, size int, truncation string, marker string) string {
+	if size < 0 {
+		size = 0
+	}
+	switch truncation {
+	case appconfig.OutputTruncationKeepTail:
+		return marker + suffix(tail, size)
+	case appconfig.OutputTruncationKeepHeadAndTail:
+		return prefix(head, size/2) + marker + suffix(tail, size-size/2)
+	default:
+		return prefix(head, size) + marker
+	}
+}
+
+func prefix(output string, size int) string {
+	if len(output) > size {
+		return output[:size]
+	}
+	return output
+}
 
-	if err := scanner.Err(); err != nil {
-		log.Error("Error with the scanner while reading the stream")
+func suffix(output string, size int) string {
+	if len(output) > size {
+		return output[len(output)-size:]
 	}
+	return output
 }
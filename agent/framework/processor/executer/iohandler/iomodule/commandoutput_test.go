@@ -9,6 +9,7 @@ import (
 
 	"bytes"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/stretchr/testify/assert"
 )
@@ -132,3 +133,37 @@ func testFileCommandOutput(pipeTestCase string, fileTestCase string, limit int)
 	return stdout
 
 }
+
+// TestCommandOutputTruncation tests the part of the output kept by the CommandOutput module when it exceeds the limit
+func TestCommandOutputTruncation(t *testing.T) {
+	input := "0123456789abcdefghijklmnopqrstuvwxyz"
+	testCases := []struct {
+		truncation string
+		output     string
+	}{
+		{appconfig.OutputTruncationKeepHead, "0123456789abcd[..]"},
+		{appconfig.OutputTruncationKeepTail, "[..]mnopqrstuvwxyz"},
+		{appconfig.OutputTruncationKeepHeadAndTail, "0123456[..]tuvwxyz"},
+	}
+
+	for _, testCase := range testCases {
+		r, w := io.Pipe()
+		var stdout string
+		var size int
+		stdoutConsole := CommandOutput{
+			OutputLimit:     18,
+			Truncation:      testCase.truncation,
+			TruncatedMarker: "[..]",
+			OutputString:    &stdout,
+			OutputSize:      &size,
+		}
+		go func() {
+			w.Write([]byte(input))
+			w.Close()
+		}()
+		stdoutConsole.ReadPipeAndFile(logger, r, bytes.Buffer{})
+
+		assert.Equal(t, testCase.output, stdout, testCase.truncation)
+		assert.Equal(t, len(input), size)
+	}
+}
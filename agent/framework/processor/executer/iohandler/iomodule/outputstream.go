@@ -0,0 +1,49 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iomodule
+
+import (
+	"io"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// outputChunkSize is the maximum size of the chunks sent to the output stream
+const outputChunkSize = 4096
+
+// OutputStream handles sending the output to an output stream while it's written.
+type OutputStream struct {
+	// StreamName is the name of the output, stdout or stderr
+	StreamName string
+	Stream     contracts.OutputStream
+}
+
+// Read reads from the pipe and sends each write to the output stream as soon as it's read.
+func (o OutputStream) Read(log log.T, reader *io.PipeReader) {
+	defer func() { reader.Close() }()
+	buffer := make([]byte, outputChunkSize)
+	for {
+		n, err := reader.Read(buffer)
+		if n > 0 {
+			o.Stream(contracts.OutputChunk{Stream: o.StreamName, Data: string(buffer[:n])})
+		}
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			log.Errorf("Error reading the %v stream: %v", o.StreamName, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iomodule
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOutputStream tests the OutputStream module
+func TestOutputStream(t *testing.T) {
+	var chunks []contracts.OutputChunk
+	module := OutputStream{
+		StreamName: "stdout",
+		Stream:     func(chunk contracts.OutputChunk) { chunks = append(chunks, chunk) },
+	}
+	r, w := io.Pipe()
+	done := make(chan bool)
+	go func() {
+		module.Read(logger, r)
+		done <- true
+	}()
+
+	w.Write([]byte("first line\n"))
+	w.Write([]byte(strings.Repeat("a", outputChunkSize+10)))
+	w.Close()
+	<-done
+
+	assert.Equal(t, []contracts.OutputChunk{
+		{Stream: "stdout", Data: "first line\n"},
+		{Stream: "stdout", Data: strings.Repeat("a", outputChunkSize)},
+		{Stream: "stdout", Data: strings.Repeat("a", 10)},
+	}, chunks)
+}
@@ -0,0 +1,117 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package cgroup limits and measures the resources used by the document workers and their child processes with
+// Linux control groups
+package cgroup
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// workersGroupName is the control group holding the control groups of the document workers
+const workersGroupName = "ssm-document-worker"
+
+// Usage is the resource usage of the processes of a control group since its creation
+type Usage struct {
+	CPUTime         time.Duration
+	PeakMemoryBytes int64
+	OOMKills        int64
+}
+
+// ControlGroup is the control group of a document worker
+type ControlGroup interface {
+	// SetLimits applies the resource limits to the processes of the group, zero limits are left unlimited
+	SetLimits(limits appconfig.ResourceLimits) error
+	// AddProcess moves the process into the group, the child processes it starts afterwards inherit the group
+	AddProcess(pid int) error
+	// Usage returns the resource usage of the processes of the group
+	Usage() (Usage, error)
+	// Remove deletes the group, which fails while processes are still in the group
+	Remove() error
+}
+
+// MergeLimits returns the limits with the limits of the override replacing the default ones
+func MergeLimits(defaults appconfig.ResourceLimits, override appconfig.ResourceLimits) appconfig.ResourceLimits {
+	if override.CPUQuotaPercent != 0 {
+		defaults.CPUQuotaPercent = override.CPUQuotaPercent
+	}
+	if override.MemoryLimitMB != 0 {
+		defaults.MemoryLimitMB = override.MemoryLimitMB
+	}
+	if override.PidsLimit != 0 {
+		defaults.PidsLimit = override.PidsLimit
+	}
+	if override.IOWeight != 0 {
+		defaults.IOWeight = override.IOWeight
+	}
+	return defaults
+}
+
+// UsageMeter reports the resource usage of the control group of the current document worker in the results of its steps
+type UsageMeter struct {
+	log   log.T
+	group ControlGroup
+	last  Usage
+}
+
+// NewUsageMeter creates a meter for the control group of the current process, the meter reports nothing when the
+// process isn't in the control group of a document worker
+func NewUsageMeter(log log.T) *UsageMeter {
+	group, err := Self()
+	if err != nil {
+		log.Debugf("resource usage is not reported: %v", err)
+		return &UsageMeter{log: log}
+	}
+	meter := &UsageMeter{log: log, group: group}
+	if meter.last, err = group.Usage(); err != nil {
+		log.Errorf("failed to read the resource usage of the document worker: %v", err)
+	}
+	return meter
+}
+
+// Measure sets the resource usage of the step in its result. A failed step during which the OOM killer killed
+// processes of the group is reported as out of memory.
+func (m *UsageMeter) Measure(res *contracts.PluginResult) {
+	if m.group == nil {
+		return
+	}
+	usage, err := m.group.Usage()
+	if err != nil {
+		m.log.Errorf("failed to read the resource usage of step %v: %v", res.PluginID, err)
+		return
+	}
+	res.CPUTimeMillis = int64((usage.CPUTime - m.last.CPUTime) / time.Millisecond)
+	res.PeakMemoryBytes = usage.PeakMemoryBytes
+	if usage.OOMKills > m.last.OOMKills && res.Status == contracts.ResultStatusFailed {
+		res.FailureReason = contracts.FailureReasonOutOfMemory
+		message := fmt.Sprintf("%v process(es) of the step were killed by the OOM killer, the memory limit of the document worker was exceeded", usage.OOMKills-m.last.OOMKills)
+		res.StandardError = appendLine(res.StandardError, message)
+		if output, isString := res.Output.(string); isString {
+			res.Output = appendLine(output, message)
+		}
+	}
+	m.last = usage
+}
+
+func appendLine(text string, line string) string {
+	if text == "" {
+		return line
+	}
+	return fmt.Sprintf("%v\n%v", text, line)
+}
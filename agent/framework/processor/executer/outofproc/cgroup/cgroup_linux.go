@@ -0,0 +1,434 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package cgroup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+const (
+	// unifiedController is the key of the single directory of a group of the unified (v2) hierarchy
+	unifiedController = ""
+	// agentGroupName is the leaf group the processes of the agent service move into in the unified hierarchy, as the
+	// controllers can only be enabled for the children of a group that holds no process
+	agentGroupName = "ssm-agent"
+
+	cpuPeriodMicroseconds = 100000
+	// the default block I/O weights of the unified and legacy hierarchies, used to convert the weights
+	defaultIOWeight    = 100
+	defaultBlkioWeight = 500
+	minBlkioWeight     = 10
+	maxBlkioWeight     = 1000
+)
+
+// legacyControllers are the controllers used with the legacy (v1) hierarchies, each mounted in its own directory
+var legacyControllers = []string{"cpu", "cpuacct", "memory", "pids", "blkio"}
+
+// unifiedControllers are the controllers enabled for the document workers in the unified hierarchy
+var unifiedControllers = []string{"cpu", "memory", "pids", "io"}
+
+// decoupling the file system for easy testability
+var (
+	cgroupRoot     = "/sys/fs/cgroup"
+	procSelfCgroup = "/proc/self/cgroup"
+)
+
+// controlGroup is a control group of the unified hierarchy, or the groups of the same path in the legacy hierarchies
+type controlGroup struct {
+	// dirs are the directories of the group by controller, with a single directory for the unified hierarchy
+	dirs map[string]string
+}
+
+// isUnified returns whether the control groups use the unified (v2) hierarchy
+func isUnified() bool {
+	_, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	return err == nil
+}
+
+// groupDirs returns the directories of the group of the given path by controller
+func groupDirs(groupPath string) (dirs map[string]string, err error) {
+	if _, err = os.Stat(cgroupRoot); err != nil {
+		return nil, fmt.Errorf("control groups are not available: %v", err)
+	}
+	dirs = make(map[string]string)
+	if isUnified() {
+		dirs[unifiedController] = filepath.Join(cgroupRoot, groupPath)
+		return
+	}
+	for _, controller := range legacyControllers {
+		if _, err := os.Stat(filepath.Join(cgroupRoot, controller)); err == nil {
+			dirs[controller] = filepath.Join(cgroupRoot, controller, groupPath)
+		}
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no control group controller is mounted in %v", cgroupRoot)
+	}
+	return
+}
+
+// workersGroupPath returns the path of the group holding the groups of the document workers. In the unified hierarchy
+// the group is created under the group of the agent service, which systemd delegates to the agent, i.e.
+// /system.slice/amazon-ssm-agent.service/ssm-document-worker. The legacy hierarchies keep it under their root.
+func workersGroupPath() (string, error) {
+	if !isUnified() {
+		return "/" + workersGroupName, nil
+	}
+	servicePath, err := serviceGroupPath()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(servicePath, workersGroupName), nil
+}
+
+// serviceGroupPath returns the path of the group of the agent service in the unified hierarchy
+func serviceGroupPath() (string, error) {
+	content, err := ioutil.ReadFile(procSelfCgroup)
+	if err != nil {
+		return "", err
+	}
+	// the unified hierarchy has ID 0 and no controller
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) == 3 && fields[0] == "0" && fields[1] == "" {
+			if path.Base(fields[2]) == agentGroupName {
+				return path.Dir(fields[2]), nil
+			}
+			return fields[2], nil
+		}
+	}
+	return "", fmt.Errorf("the process is not in a control group of the unified hierarchy")
+}
+
+// Create creates the control group of a document worker
+func Create(name string) (ControlGroup, error) {
+	workersPath, err := workersGroupPath()
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := groupDirs(path.Join(workersPath, name))
+	if err != nil {
+		return nil, err
+	}
+	if unifiedDir, isUnified := dirs[unifiedController]; isUnified {
+		serviceDir := filepath.Dir(filepath.Dir(unifiedDir))
+		if err = leaveServiceGroup(serviceDir); err != nil {
+			return nil, err
+		}
+		// the controllers must be enabled down from the group of the service for the children of the parent group,
+		// which has no process of its own
+		if err = enableControllers(serviceDir); err != nil {
+			return nil, err
+		}
+		if err = enableControllers(filepath.Dir(unifiedDir)); err != nil {
+			return nil, err
+		}
+	}
+	group := &controlGroup{dirs: dirs}
+	for _, dir := range dirs {
+		if err = os.MkdirAll(dir, appconfig.ReadWriteExecuteAccess); err != nil {
+			group.Remove()
+			return nil, fmt.Errorf("failed to create control group %v: %v", dir, err)
+		}
+	}
+	return group, nil
+}
+
+// Open returns the existing control group of a document worker
+func Open(name string) (ControlGroup, error) {
+	workersPath, err := workersGroupPath()
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := groupDirs(path.Join(workersPath, name))
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if _, err = os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("control group %v not found: %v", dir, err)
+		}
+	}
+	return &controlGroup{dirs: dirs}, nil
+}
+
+// Self returns the control group of the current process if the process is a document worker in its control group
+func Self() (ControlGroup, error) {
+	content, err := ioutil.ReadFile(procSelfCgroup)
+	if err != nil {
+		return nil, err
+	}
+	// each line is hierarchy-ID:controller-list:cgroup-path, the unified hierarchy has ID 0 and no controller
+	groupPath := ""
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) == 3 && strings.Contains(fields[2], "/"+workersGroupName+"/") {
+			groupPath = fields[2]
+			break
+		}
+	}
+	if groupPath == "" {
+		return nil, fmt.Errorf("the process is not in the control group of a document worker")
+	}
+	dirs, err := groupDirs(groupPath)
+	if err != nil {
+		return nil, err
+	}
+	return &controlGroup{dirs: dirs}, nil
+}
+
+// leaveServiceGroup moves the processes of the group of the agent service into the leaf group of the agent, so that the
+// controllers can be enabled for the groups of the document workers. The root group is exempt from the rule.
+func leaveServiceGroup(serviceDir string) error {
+	if serviceDir == cgroupRoot {
+		return nil
+	}
+	procs, err := readString(filepath.Join(serviceDir, "cgroup.procs"))
+	if err != nil {
+		return err
+	}
+	if procs == "" {
+		return nil
+	}
+	agentDir := filepath.Join(serviceDir, agentGroupName)
+	if err = os.MkdirAll(agentDir, appconfig.ReadWriteExecuteAccess); err != nil {
+		return fmt.Errorf("failed to create control group %v: %v", agentDir, err)
+	}
+	agentGroup := &controlGroup{dirs: map[string]string{unifiedController: agentDir}}
+	for _, field := range strings.Fields(procs) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			return fmt.Errorf("invalid process %v in %v", field, serviceDir)
+		}
+		// the processes that exited meanwhile can't be moved
+		if err = agentGroup.AddProcess(pid); err != nil && fileExists(filepath.Join("/proc", field)) {
+			return err
+		}
+	}
+	return nil
+}
+
+// enableControllers enables the available controllers used by the document workers for the children of the group
+func enableControllers(dir string) error {
+	if err := os.MkdirAll(dir, appconfig.ReadWriteExecuteAccess); err != nil {
+		return fmt.Errorf("failed to create control group %v: %v", dir, err)
+	}
+	available, err := readString(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+	var enabled []string
+	for _, controller := range unifiedControllers {
+		for _, availableController := range strings.Fields(available) {
+			if controller == availableController {
+				enabled = append(enabled, "+"+controller)
+			}
+		}
+	}
+	if len(enabled) == 0 {
+		return nil
+	}
+	return writeString(filepath.Join(dir, "cgroup.subtree_control"), strings.Join(enabled, " "))
+}
+
+// SetLimits applies the resource limits to the processes of the group, zero limits are left unlimited
+func (g *controlGroup) SetLimits(limits appconfig.ResourceLimits) error {
+	var errs []string
+	set := func(controller string, file string, value string) {
+		dir, found := g.dirs[controller]
+		if !found {
+			errs = append(errs, fmt.Sprintf("%v controller is not available", controller))
+			return
+		}
+		if err := writeString(filepath.Join(dir, file), value); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if _, isUnified := g.dirs[unifiedController]; isUnified {
+		if limits.CPUQuotaPercent > 0 {
+			set(unifiedController, "cpu.max", fmt.Sprintf("%v %v", limits.CPUQuotaPercent*cpuPeriodMicroseconds/100, cpuPeriodMicroseconds))
+		}
+		if limits.MemoryLimitMB > 0 {
+			set(unifiedController, "memory.max", strconv.FormatInt(int64(limits.MemoryLimitMB)<<20, 10))
+		}
+		if limits.PidsLimit > 0 {
+			set(unifiedController, "pids.max", strconv.Itoa(limits.PidsLimit))
+		}
+		if limits.IOWeight > 0 {
+			set(unifiedController, "io.weight", fmt.Sprintf("default %v", limits.IOWeight))
+		}
+	} else {
+		if limits.CPUQuotaPercent > 0 {
+			set("cpu", "cpu.cfs_period_us", strconv.Itoa(cpuPeriodMicroseconds))
+			set("cpu", "cpu.cfs_quota_us", strconv.Itoa(limits.CPUQuotaPercent*cpuPeriodMicroseconds/100))
+		}
+		if limits.MemoryLimitMB > 0 {
+			set("memory", "memory.limit_in_bytes", strconv.FormatInt(int64(limits.MemoryLimitMB)<<20, 10))
+		}
+		if limits.PidsLimit > 0 {
+			set("pids", "pids.max", strconv.Itoa(limits.PidsLimit))
+		}
+		if limits.IOWeight > 0 {
+			set("blkio", "blkio.weight", strconv.Itoa(blkioWeight(limits.IOWeight)))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to set resource limits: %v", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// blkioWeight converts a weight of the unified hierarchy to the range of the weights of the legacy hierarchy
+func blkioWeight(ioWeight int) int {
+	weight := ioWeight * defaultBlkioWeight / defaultIOWeight
+	if weight < minBlkioWeight {
+		return minBlkioWeight
+	}
+	if weight > maxBlkioWeight {
+		return maxBlkioWeight
+	}
+	return weight
+}
+
+// AddProcess moves the process into the group, the child processes it starts afterwards inherit the group
+func (g *controlGroup) AddProcess(pid int) error {
+	for _, dir := range g.dirs {
+		if err := writeString(filepath.Join(dir, "cgroup.procs"), strconv.Itoa(pid)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Usage returns the CPU time, peak memory and OOM kills of the processes of the group, the usage the kernel doesn't
+// track is left zero
+func (g *controlGroup) Usage() (usage Usage, err error) {
+	if dir, isUnified := g.dirs[unifiedController]; isUnified {
+		var usec int64
+		if usec, err = readKeyedValue(filepath.Join(dir, "cpu.stat"), "usage_usec"); err != nil {
+			return
+		}
+		usage.CPUTime = time.Duration(usec) * time.Microsecond
+		// memory.peak requires linux 5.19
+		if usage.PeakMemoryBytes, err = readOptionalValue(filepath.Join(dir, "memory.peak")); err != nil {
+			return
+		}
+		if fileExists(filepath.Join(dir, "memory.events")) {
+			usage.OOMKills, err = readKeyedValue(filepath.Join(dir, "memory.events"), "oom_kill")
+		}
+		return
+	}
+
+	if dir, found := g.dirs["cpuacct"]; found {
+		var nsec int64
+		if nsec, err = readOptionalValue(filepath.Join(dir, "cpuacct.usage")); err != nil {
+			return
+		}
+		usage.CPUTime = time.Duration(nsec)
+	}
+	if dir, found := g.dirs["memory"]; found {
+		if usage.PeakMemoryBytes, err = readOptionalValue(filepath.Join(dir, "memory.max_usage_in_bytes")); err != nil {
+			return
+		}
+		// oom_kill requires linux 4.13
+		if oomControl, readErr := readString(filepath.Join(dir, "memory.oom_control")); readErr == nil {
+			usage.OOMKills, _ = parseKeyedValue(oomControl, "oom_kill")
+		}
+	}
+	return
+}
+
+// Remove deletes the group, which fails while processes are still in the group
+func (g *controlGroup) Remove() error {
+	var errs []string
+	for _, dir := range g.dirs {
+		if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to remove control group: %v", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func readString(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func writeString(path string, value string) error {
+	// the control group files are created by the kernel, only their content can be written
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err = file.WriteString(value); err != nil {
+		return fmt.Errorf("failed to write %v to %v: %v", value, path, err)
+	}
+	return nil
+}
+
+// readOptionalValue returns the number in the file, or zero if the file doesn't exist
+func readOptionalValue(path string) (int64, error) {
+	content, err := readString(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(content, 10, 64)
+}
+
+// readKeyedValue returns the value of the key in a file of "key value" lines
+func readKeyedValue(path string, key string) (int64, error) {
+	content, err := readString(path)
+	if err != nil {
+		return 0, err
+	}
+	value, err := parseKeyedValue(content, key)
+	if err != nil {
+		return 0, fmt.Errorf("%v in %v", err, path)
+	}
+	return value, nil
+}
+
+func parseKeyedValue(content string, key string) (int64, error) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("%v not found", key)
+}
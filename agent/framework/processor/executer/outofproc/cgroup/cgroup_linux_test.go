@@ -0,0 +1,221 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package cgroup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// setCgroupRoot replaces the control group file system with a temporary folder and returns a function restoring it
+func setCgroupRoot(t *testing.T) (string, func()) {
+	root, err := ioutil.TempDir("", "cgroup")
+	assert.NoError(t, err)
+	originalRoot, originalProcSelfCgroup := cgroupRoot, procSelfCgroup
+	cgroupRoot, procSelfCgroup = root, filepath.Join(root, "self-cgroup")
+	return root, func() {
+		cgroupRoot, procSelfCgroup = originalRoot, originalProcSelfCgroup
+		os.RemoveAll(root)
+	}
+}
+
+// writeFiles creates the files with their content in the directory, as the kernel creates the control group files
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	assert.NoError(t, os.MkdirAll(dir, appconfig.ReadWriteExecuteAccess))
+	for name, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), appconfig.ReadWriteAccess))
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestUnifiedControlGroup(t *testing.T) {
+	root, restore := setCgroupRoot(t)
+	defer restore()
+	writeFiles(t, root, map[string]string{
+		"cgroup.controllers": "cpuset cpu io memory pids",
+		"self-cgroup":        "0::/system.slice/amazon-ssm-agent.service\n",
+	})
+	service := filepath.Join(root, "system.slice", "amazon-ssm-agent.service")
+	writeFiles(t, service, map[string]string{"cgroup.controllers": "cpu memory pids", "cgroup.subtree_control": "", "cgroup.procs": "1234\n"})
+	writeFiles(t, filepath.Join(service, agentGroupName), map[string]string{"cgroup.procs": ""})
+	parent := filepath.Join(service, workersGroupName)
+	writeFiles(t, parent, map[string]string{"cgroup.controllers": "cpu memory pids", "cgroup.subtree_control": ""})
+
+	group, err := Create("command-1")
+	assert.NoError(t, err)
+
+	// the group of the document worker is created in the group of the agent service, which the agent left for its leaf
+	// group to enable the controllers of its children
+	assert.Equal(t, "1234", readFile(t, filepath.Join(service, agentGroupName, "cgroup.procs")))
+	assert.Equal(t, "+cpu +memory +pids", readFile(t, filepath.Join(service, "cgroup.subtree_control")))
+	// controllers unavailable to the parent can't be enabled
+	assert.Equal(t, "+cpu +memory +pids", readFile(t, filepath.Join(parent, "cgroup.subtree_control")))
+	dir := filepath.Join(parent, "command-1")
+	writeFiles(t, dir, map[string]string{
+		"cgroup.procs":  "",
+		"cpu.max":       "max 100000",
+		"memory.max":    "max",
+		"pids.max":      "max",
+		"cpu.stat":      "usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000",
+		"memory.peak":   "73400320",
+		"memory.events": "low 0\nhigh 0\nmax 4\noom 1\noom_kill 1",
+	})
+
+	assert.NoError(t, group.SetLimits(appconfig.ResourceLimits{CPUQuotaPercent: 50, MemoryLimitMB: 256, PidsLimit: 100}))
+	assert.Equal(t, "50000 100000", readFile(t, filepath.Join(dir, "cpu.max")))
+	assert.Equal(t, "268435456", readFile(t, filepath.Join(dir, "memory.max")))
+	assert.Equal(t, "100", readFile(t, filepath.Join(dir, "pids.max")))
+	// the io controller isn't enabled
+	assert.Error(t, group.SetLimits(appconfig.ResourceLimits{IOWeight: 200}))
+
+	assert.NoError(t, group.AddProcess(4242))
+	assert.Equal(t, "4242", readFile(t, filepath.Join(dir, "cgroup.procs")))
+
+	usage, err := group.Usage()
+	assert.NoError(t, err)
+	assert.Equal(t, Usage{CPUTime: 2500 * time.Millisecond, PeakMemoryBytes: 73400320, OOMKills: 1}, usage)
+}
+
+func TestLegacyControlGroup(t *testing.T) {
+	root, restore := setCgroupRoot(t)
+	defer restore()
+	for _, controller := range []string{"cpu", "cpuacct", "memory", "blkio"} {
+		writeFiles(t, filepath.Join(root, controller, workersGroupName, "command-1"), map[string]string{"cgroup.procs": ""})
+	}
+	writeFiles(t, filepath.Join(root, "cpu", workersGroupName, "command-1"), map[string]string{"cpu.cfs_period_us": "", "cpu.cfs_quota_us": ""})
+	writeFiles(t, filepath.Join(root, "cpuacct", workersGroupName, "command-1"), map[string]string{"cpuacct.usage": "1500000000"})
+	writeFiles(t, filepath.Join(root, "memory", workersGroupName, "command-1"), map[string]string{
+		"memory.max_usage_in_bytes": "1048576",
+		"memory.oom_control":        "oom_kill_disable 0\nunder_oom 0\noom_kill 2",
+	})
+	writeFiles(t, filepath.Join(root, "blkio", workersGroupName, "command-1"), map[string]string{"blkio.weight": ""})
+
+	group, err := Open("command-1")
+	assert.NoError(t, err)
+
+	assert.NoError(t, group.SetLimits(appconfig.ResourceLimits{CPUQuotaPercent: 200, IOWeight: 1}))
+	assert.Equal(t, "200000", readFile(t, filepath.Join(root, "cpu", workersGroupName, "command-1", "cpu.cfs_quota_us")))
+	assert.Equal(t, "10", readFile(t, filepath.Join(root, "blkio", workersGroupName, "command-1", "blkio.weight")))
+	// the pids controller isn't mounted
+	assert.Error(t, group.SetLimits(appconfig.ResourceLimits{PidsLimit: 100}))
+
+	usage, err := group.Usage()
+	assert.NoError(t, err)
+	assert.Equal(t, Usage{CPUTime: 1500 * time.Millisecond, PeakMemoryBytes: 1048576, OOMKills: 2}, usage)
+}
+
+func TestRemove(t *testing.T) {
+	root, restore := setCgroupRoot(t)
+	defer restore()
+	// the agent runs in the root group, i.e. in a container
+	writeFiles(t, root, map[string]string{"cgroup.controllers": "cpu memory", "cgroup.subtree_control": "", "self-cgroup": "0::/\n"})
+	writeFiles(t, filepath.Join(root, workersGroupName), map[string]string{"cgroup.controllers": "", "cgroup.subtree_control": ""})
+
+	group, err := Create("command-1")
+	assert.NoError(t, err)
+	assert.NoError(t, group.Remove())
+
+	_, err = os.Stat(filepath.Join(root, workersGroupName, "command-1"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = Open("command-1")
+	assert.Error(t, err)
+}
+
+func TestSelf(t *testing.T) {
+	root, restore := setCgroupRoot(t)
+	defer restore()
+	writeFiles(t, root, map[string]string{"cgroup.controllers": "cpu memory"})
+
+	writeFiles(t, root, map[string]string{"self-cgroup": "0::/system.slice/amazon-ssm-agent.service\n"})
+	_, err := Self()
+	assert.Error(t, err)
+
+	writeFiles(t, root, map[string]string{"self-cgroup": "0::/" + workersGroupName + "/command-1\n"})
+	group, err := Self()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, workersGroupName, "command-1"), group.(*controlGroup).dirs[unifiedController])
+
+	writeFiles(t, root, map[string]string{"self-cgroup": "0::/system.slice/amazon-ssm-agent.service/" + workersGroupName + "/command-1\n"})
+	group, err = Self()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "system.slice", "amazon-ssm-agent.service", workersGroupName, "command-1"), group.(*controlGroup).dirs[unifiedController])
+}
+
+func TestServiceGroupPath(t *testing.T) {
+	root, restore := setCgroupRoot(t)
+	defer restore()
+
+	writeFiles(t, root, map[string]string{"self-cgroup": "12:pids:/system.slice/amazon-ssm-agent.service\n0::/system.slice/amazon-ssm-agent.service\n"})
+	servicePath, err := serviceGroupPath()
+	assert.NoError(t, err)
+	assert.Equal(t, "/system.slice/amazon-ssm-agent.service", servicePath)
+
+	// the agent already moved into its leaf group
+	writeFiles(t, root, map[string]string{"self-cgroup": "0::/system.slice/amazon-ssm-agent.service/" + agentGroupName + "\n"})
+	servicePath, err = serviceGroupPath()
+	assert.NoError(t, err)
+	assert.Equal(t, "/system.slice/amazon-ssm-agent.service", servicePath)
+
+	writeFiles(t, root, map[string]string{"self-cgroup": "4:memory:/system.slice/amazon-ssm-agent.service\n"})
+	_, err = serviceGroupPath()
+	assert.Error(t, err)
+}
+
+func TestUsageMeter(t *testing.T) {
+	root, restore := setCgroupRoot(t)
+	defer restore()
+	dir := filepath.Join(root, workersGroupName, "command-1")
+	writeFiles(t, root, map[string]string{"cgroup.controllers": "cpu memory", "self-cgroup": "0::/" + workersGroupName + "/command-1\n"})
+	writeFiles(t, dir, map[string]string{"cpu.stat": "usage_usec 1000", "memory.events": "oom_kill 0"})
+
+	meter := NewUsageMeter(log.NewMockLog())
+
+	writeFiles(t, dir, map[string]string{"cpu.stat": "usage_usec 3001000", "memory.peak": "2097152"})
+	res := contracts.PluginResult{PluginID: "step1", Status: contracts.ResultStatusSuccess}
+	meter.Measure(&res)
+	assert.Equal(t, int64(3000), res.CPUTimeMillis)
+	assert.Equal(t, int64(2097152), res.PeakMemoryBytes)
+	assert.Empty(t, res.FailureReason)
+
+	// the step failed while processes were killed by the OOM killer
+	writeFiles(t, dir, map[string]string{"cpu.stat": "usage_usec 4001000", "memory.events": "oom_kill 1"})
+	res = contracts.PluginResult{PluginID: "step2", Status: contracts.ResultStatusFailed, Code: 137, Output: "Killed"}
+	meter.Measure(&res)
+	assert.Equal(t, int64(1000), res.CPUTimeMillis)
+	assert.Equal(t, contracts.FailureReasonOutOfMemory, res.FailureReason)
+	assert.Contains(t, res.StandardError, "OOM killer")
+	assert.Contains(t, res.Output, "Killed\n")
+}
+
+func TestMergeLimits(t *testing.T) {
+	limits := MergeLimits(
+		appconfig.ResourceLimits{CPUQuotaPercent: 100, MemoryLimitMB: 1024},
+		appconfig.ResourceLimits{MemoryLimitMB: 256, PidsLimit: 64})
+	assert.Equal(t, appconfig.ResourceLimits{CPUQuotaPercent: 100, MemoryLimitMB: 256, PidsLimit: 64}, limits)
+}
@@ -0,0 +1,37 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd netbsd openbsd windows
+
+package cgroup
+
+import (
+	"errors"
+)
+
+var errNotSupported = errors.New("control groups are only supported on Linux")
+
+// Create fails as control groups are only supported on Linux
+func Create(name string) (ControlGroup, error) {
+	return nil, errNotSupported
+}
+
+// Open fails as control groups are only supported on Linux
+func Open(name string) (ControlGroup, error) {
+	return nil, errNotSupported
+}
+
+// Self fails as control groups are only supported on Linux
+func Self() (ControlGroup, error) {
+	return nil, errNotSupported
+}
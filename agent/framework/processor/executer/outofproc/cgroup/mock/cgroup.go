@@ -0,0 +1,44 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cgroupmock
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/cgroup"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockedControlGroup struct {
+	mock.Mock
+}
+
+func (m *MockedControlGroup) SetLimits(limits appconfig.ResourceLimits) error {
+	args := m.Called(limits)
+	return args.Error(0)
+}
+
+func (m *MockedControlGroup) AddProcess(pid int) error {
+	args := m.Called(pid)
+	return args.Error(0)
+}
+
+func (m *MockedControlGroup) Usage() (cgroup.Usage, error) {
+	args := m.Called()
+	return args.Get(0).(cgroup.Usage), args.Error(1)
+}
+
+func (m *MockedControlGroup) Remove() error {
+	args := m.Called()
+	return args.Error(0)
+}
@@ -1,6 +1,7 @@
 package channel
 
 import (
+	"os"
 	"path"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
@@ -58,3 +59,43 @@ func CreateFileChannel(log log.T, mode Mode, filename string) (Channel, error, b
 	f, err := NewFileWatcherChannel(log, mode, path.Join(appconfig.DefaultDataStorePath, instanceID, defaultFileChannelPath, filename))
 	return f, err, false
 }
+
+//CreateChannel opens the channel of "documentID" under the default root dir and returns the found flag as CreateFileChannel.
+//The master prefers the unix socket channel and falls back to the file channel where the socket cannot be created, the
+//presence of the socket in the channel directory tells the worker and a restarted master which channel is used.
+func CreateChannel(log log.T, mode Mode, name string) (Channel, error, bool) {
+	instanceID, err := platform.InstanceID()
+	if err != nil {
+		log.Errorf("failed to load instance ID: %v", err)
+		return nil, err, false
+	}
+	return createChannel(log, mode, path.Join(appconfig.DefaultDataStorePath, instanceID, defaultFileChannelPath, name))
+}
+
+func createChannel(log log.T, mode Mode, dir string) (Channel, error, bool) {
+	found := fileutil.Exists(dir)
+	hasSocket := fileutil.Exists(path.Join(dir, defaultSocketFileName))
+	if mode == ModeWorker {
+		if !hasSocket {
+			f, err := NewFileWatcherChannel(log, mode, dir)
+			return f, err, found
+		}
+		//the master only listens on the socket
+		s, err := newSocketChannel(log, mode, dir)
+		return s, err, found
+	}
+	if found && !hasSocket {
+		log.Infof("channel: %v found, the worker uses the file channel", dir)
+		f, err := NewFileWatcherChannel(log, mode, dir)
+		return f, err, found
+	}
+	s, err := newSocketChannel(log, mode, dir)
+	if err == nil || found {
+		return s, err, found
+	}
+	log.Infof("unix socket channel not available, creating a new file channel: %v", err)
+	//leave no socket behind, the worker would connect to it
+	os.Remove(path.Join(dir, defaultSocketFileName))
+	f, err := NewFileWatcherChannel(log, mode, dir)
+	return f, err, found
+}
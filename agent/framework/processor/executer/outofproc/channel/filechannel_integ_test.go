@@ -13,7 +13,7 @@
 
 // +build integration
 
-//Package channel defines and implements the communication interface between agent and command runner process
+// Package channel defines and implements the communication interface between agent and command runner process
 package channel
 
 import (
@@ -62,7 +62,7 @@ func TestChannelDuplexTransmission(t *testing.T) {
 
 }
 
-//agent channel is reopened, and starts receiving only after re-open
+// agent channel is reopened, and starts receiving only after re-open
 func TestChannelReopen(t *testing.T) {
 	done := make(chan bool)
 	agentChannel, err := NewFileWatcherChannel(log.NewMockLogWithContext("AGENT"), ModeMaster, path.Join(defaultRootDir, channelName))
@@ -89,7 +89,7 @@ func TestChannelReopen(t *testing.T) {
 	newAgentChannel.Destroy()
 }
 
-//verify the given set of messages are received
+// verify the given set of messages are received
 func verifyReceive(t *testing.T, ch Channel, messages []string, name string, done chan bool) {
 
 	//timer := time.After(5 * time.Second)
@@ -102,7 +102,7 @@ func verifyReceive(t *testing.T, ch Channel, messages []string, name string, don
 	done <- true
 }
 
-//send a given set of messages
+// send a given set of messages
 func send(ch Channel, messages []string, name string) {
 
 	for _, testMsg := range messages {
@@ -0,0 +1,62 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package channel
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	//the socket is created in the channel directory, so that a restarted master finds the channel of its orphan worker
+	defaultSocketFileName = "ipc.sock"
+	//frame header: 1 byte frame version followed by the 4 bytes big endian length of the datagram
+	frameVersion    = 1
+	frameHeaderSize = 5
+	maxFrameSize    = 64 * 1024 * 1024
+)
+
+//writeFrame writes a raw json datagram as a single frame, the datagram itself carries its messaging version
+func writeFrame(w io.Writer, datagram string) error {
+	if len(datagram) > maxFrameSize {
+		return fmt.Errorf("datagram size %v exceeds the maximum frame size %v", len(datagram), maxFrameSize)
+	}
+	frame := make([]byte, frameHeaderSize+len(datagram))
+	frame[0] = frameVersion
+	binary.BigEndian.PutUint32(frame[1:frameHeaderSize], uint32(len(datagram)))
+	copy(frame[frameHeaderSize:], datagram)
+	_, err := w.Write(frame)
+	return err
+}
+
+//readFrame reads the next frame and returns the datagram it carries
+func readFrame(r io.Reader) (string, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", err
+	}
+	if header[0] != frameVersion {
+		return "", fmt.Errorf("unsupported frame version %v", header[0])
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return "", fmt.Errorf("frame size %v exceeds the maximum frame size %v", size, maxFrameSize)
+	}
+	datagram := make([]byte, size)
+	if _, err := io.ReadFull(r, datagram); err != nil {
+		return "", err
+	}
+	return string(datagram), nil
+}
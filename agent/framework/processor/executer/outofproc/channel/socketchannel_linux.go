@@ -0,0 +1,333 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package channel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	//only the agent user can connect to the socket
+	defaultSocketFileMode = 0600
+)
+
+var (
+	//peerCredentials returns the credentials of the process at the other end of the connection
+	peerCredentials = getPeerCredentials
+	//interval between two attempts of the worker to connect again to a restarted master
+	reconnectInterval = time.Second
+	//how long the worker waits at close time for the master to come back and receive the pending datagrams
+	flushTimeout = 30 * time.Second
+	//how long a write to a peer that stopped reading blocks the channel before the connection is dropped
+	writeTimeout = 10 * time.Second
+)
+
+//socketChannel transmits the datagrams over a unix domain socket, the master listens on the socket and the worker
+//connects to it. Datagrams sent while no peer is connected are kept in memory and sent once the peer (re)connects.
+type socketChannel struct {
+	logger        log.T
+	mode          Mode
+	dir           string
+	path          string
+	listener      *net.UnixListener
+	conn          *net.UnixConn
+	pending       []string
+	onMessageChan chan string
+	done          chan bool
+	readers       sync.WaitGroup
+	mu            sync.Mutex
+	closed        bool
+}
+
+//newSocketChannel creates a socket channel, the socket is created in the channel directory dir
+//the master removes the socket left by a previous agent run and listens on it, the worker connects to it
+//only the master channel has the privilege to remove the dir at destroy time
+func newSocketChannel(logger log.T, mode Mode, dir string) (Channel, error) {
+	ch := &socketChannel{
+		logger:        logger,
+		mode:          mode,
+		dir:           dir,
+		path:          path.Join(dir, defaultSocketFileName),
+		onMessageChan: make(chan string, defaultChannelBufferSize),
+		done:          make(chan bool),
+	}
+	if mode == ModeMaster {
+		if err := ch.listen(); err != nil {
+			return nil, err
+		}
+		go ch.accept()
+		return ch, nil
+	}
+	conn, err := ch.dial()
+	if err != nil {
+		return nil, err
+	}
+	ch.connect(conn)
+	return ch, nil
+}
+
+func (ch *socketChannel) listen() (err error) {
+	if err = createIfNotExist(ch.dir); err != nil {
+		ch.logger.Errorf("failed to create directory: %v", err)
+		return
+	}
+	//the socket of a previous agent run is stale, the orphan worker connects again once the master listens
+	os.Remove(ch.path)
+	if ch.listener, err = net.ListenUnix("unix", &net.UnixAddr{Name: ch.path, Net: "unix"}); err != nil {
+		ch.logger.Errorf("failed to listen on socket %v: %v", ch.path, err)
+		return
+	}
+	//keep the socket when the channel is closed, a closed channel is reusable until destroyed
+	ch.listener.SetUnlinkOnClose(false)
+	if err = os.Chmod(ch.path, defaultSocketFileMode); err != nil {
+		ch.logger.Errorf("failed to restrict access to socket %v: %v", ch.path, err)
+		ch.listener.Close()
+		os.Remove(ch.path)
+	}
+	return
+}
+
+func (ch *socketChannel) dial() (*net.UnixConn, error) {
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: ch.path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	if err = ch.authenticate(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+//authenticate makes sure the peer runs as the same user as this process, the agent user
+func (ch *socketChannel) authenticate(conn *net.UnixConn) error {
+	cred, err := peerCredentials(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read peer credentials: %v", err)
+	}
+	if int(cred.Uid) != os.Geteuid() {
+		return fmt.Errorf("peer process %v runs as uid %v, expected uid %v", cred.Pid, cred.Uid, os.Geteuid())
+	}
+	ch.logger.Debugf("peer process %v authenticated on socket %v", cred.Pid, ch.path)
+	return nil
+}
+
+func getPeerCredentials(conn *net.UnixConn) (*syscall.Ucred, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	if err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return nil, err
+	}
+	return cred, credErr
+}
+
+//accept the worker connections until the channel is closed, a worker connecting again replaces its broken connection
+func (ch *socketChannel) accept() {
+	log := ch.logger
+	log.Debugf("%v listener started on socket: %v", ch.mode, ch.path)
+	for {
+		conn, err := ch.listener.AcceptUnix()
+		if err != nil {
+			if ch.isClosed() {
+				return
+			}
+			log.Errorf("socket listener encountered error: %v", err)
+			time.Sleep(reconnectInterval)
+			continue
+		}
+		if err = ch.authenticate(conn); err != nil {
+			log.Errorf("rejected connection on socket %v: %v", ch.path, err)
+			conn.Close()
+			continue
+		}
+		ch.connect(conn)
+	}
+}
+
+//connect sends the pending datagrams to the new connection and starts receiving from it
+func (ch *socketChannel) connect(conn *net.UnixConn) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.closed {
+		conn.Close()
+		return
+	}
+	if ch.conn != nil {
+		ch.conn.Close()
+	}
+	for len(ch.pending) > 0 {
+		if err := writeFrameWithDeadline(conn, ch.pending[0]); err != nil {
+			ch.logger.Errorf("failed to send pending message: %v", err)
+			conn.Close()
+			return
+		}
+		ch.pending = ch.pending[1:]
+	}
+	ch.conn = conn
+	ch.readers.Add(1)
+	go ch.read(conn)
+}
+
+func (ch *socketChannel) read(conn *net.UnixConn) {
+	defer ch.readers.Done()
+	for {
+		datagram, err := readFrame(conn)
+		if err != nil {
+			ch.disconnect(conn, err)
+			return
+		}
+		select {
+		case ch.onMessageChan <- datagram:
+		case <-ch.done:
+			return
+		}
+	}
+}
+
+//disconnect drops a broken connection, the worker tries to connect again to the master
+func (ch *socketChannel) disconnect(conn *net.UnixConn, err error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	conn.Close()
+	if ch.closed || ch.conn != conn {
+		return
+	}
+	ch.logger.Infof("peer disconnected from socket %v: %v", ch.path, err)
+	ch.conn = nil
+	if ch.mode == ModeWorker {
+		go ch.reconnect()
+	}
+}
+
+func (ch *socketChannel) reconnect() {
+	for !ch.isClosed() {
+		time.Sleep(reconnectInterval)
+		conn, err := ch.dial()
+		if err != nil {
+			ch.logger.Debugf("failed to connect to socket %v: %v", ch.path, err)
+			continue
+		}
+		ch.logger.Infof("connected again to socket %v", ch.path)
+		ch.connect(conn)
+		return
+	}
+}
+
+func (ch *socketChannel) isClosed() bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.closed
+}
+
+func (ch *socketChannel) hasPending() bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return !ch.closed && len(ch.pending) > 0
+}
+
+// Send writes the datagram to the connection, or keeps it until the peer connects
+func (ch *socketChannel) Send(datagram string) error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.closed {
+		return errors.New("channel already closed")
+	}
+	if ch.conn != nil {
+		err := writeFrameWithDeadline(ch.conn, datagram)
+		if err == nil {
+			return nil
+		}
+		//the reader drops the broken connection
+		ch.logger.Errorf("failed to write to socket %v: %v, message kept until the peer reconnects", ch.path, err)
+		ch.conn.Close()
+	}
+	ch.pending = append(ch.pending, datagram)
+	return nil
+}
+
+//writeFrameWithDeadline writes the datagram to the connection, the write fails once writeTimeout elapsed so that a
+//stuck peer doesn't block the callers of the channel waiting for its mutex
+func writeFrameWithDeadline(conn *net.UnixConn, datagram string) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	return writeFrame(conn, datagram)
+}
+
+func (ch *socketChannel) GetMessage() <-chan string {
+	return ch.onMessageChan
+}
+
+// Close a socket channel
+//the worker first waits for the pending datagrams to be sent, then the connections are closed and the receiving
+//go channel is closed once the readers returned
+func (ch *socketChannel) Close() {
+	if ch.mode == ModeWorker {
+		for deadline := time.Now().Add(flushTimeout); ch.hasPending() && time.Now().Before(deadline); {
+			time.Sleep(reconnectInterval)
+		}
+	}
+	ch.mu.Lock()
+	if ch.closed {
+		ch.mu.Unlock()
+		return
+	}
+	log := ch.logger
+	log.Infof("channel %v requested close", ch.path)
+	if len(ch.pending) > 0 {
+		log.Errorf("dropping %v messages not sent to the peer", len(ch.pending))
+	}
+	ch.closed = true
+	close(ch.done)
+	if ch.listener != nil {
+		ch.listener.Close()
+	}
+	if ch.conn != nil {
+		ch.conn.Close()
+	}
+	ch.mu.Unlock()
+	go func() {
+		ch.readers.Wait()
+		close(ch.onMessageChan)
+		log.Infof("channel %v closed", ch.path)
+	}()
+}
+
+func (ch *socketChannel) Destroy() {
+	ch.Close()
+	//only master can remove the dir at close
+	if ch.mode == ModeMaster {
+		ch.logger.Debug("master removing directory...")
+		if err := os.RemoveAll(ch.dir); err != nil {
+			ch.logger.Errorf("failed to remove directory %v : %v", ch.dir, err)
+		}
+	}
+}
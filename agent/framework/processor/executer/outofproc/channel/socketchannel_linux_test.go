@@ -0,0 +1,186 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package channel
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var socketLogger = log.NewMockLog()
+
+//newTestChannelDir returns the path of a channel directory in a temporary folder and a function deleting it
+func newTestChannelDir(t *testing.T) (string, func()) {
+	root, err := ioutil.TempDir("", "socketchannel")
+	assert.NoError(t, err)
+	return path.Join(root, "documentID"), func() { os.RemoveAll(root) }
+}
+
+func receive(t *testing.T, ch Channel) string {
+	select {
+	case datagram := <-ch.GetMessage():
+		return datagram
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "message not received")
+		return ""
+	}
+}
+
+func TestSocketChannelDuplexTransmission(t *testing.T) {
+	dir, cleanup := newTestChannelDir(t)
+	defer cleanup()
+
+	master, err := newSocketChannel(socketLogger, ModeMaster, dir)
+	assert.NoError(t, err)
+	// the master sends before the worker connects
+	assert.NoError(t, master.Send("pluginconfig"))
+	info, err := os.Stat(path.Join(dir, defaultSocketFileName))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(defaultSocketFileMode), info.Mode().Perm())
+
+	worker, err := newSocketChannel(socketLogger, ModeWorker, dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "pluginconfig", receive(t, worker))
+	for _, datagram := range []string{"reply", "complete"} {
+		assert.NoError(t, worker.Send(datagram))
+	}
+	assert.Equal(t, "reply", receive(t, master))
+	assert.Equal(t, "complete", receive(t, master))
+
+	worker.Close()
+	_, more := <-worker.GetMessage()
+	assert.False(t, more)
+	assert.Error(t, worker.Send("reply"))
+
+	master.Destroy()
+	_, more = <-master.GetMessage()
+	assert.False(t, more)
+	assert.False(t, fileutil.Exists(dir))
+}
+
+func TestSocketChannelMasterRestart(t *testing.T) {
+	defer func(interval time.Duration) { reconnectInterval = interval }(reconnectInterval)
+	reconnectInterval = 10 * time.Millisecond
+	dir, cleanup := newTestChannelDir(t)
+	defer cleanup()
+
+	master, err := newSocketChannel(socketLogger, ModeMaster, dir)
+	assert.NoError(t, err)
+	worker, err := newSocketChannel(socketLogger, ModeWorker, dir)
+	assert.NoError(t, err)
+	assert.NoError(t, master.Send("pluginconfig"))
+	assert.Equal(t, "pluginconfig", receive(t, worker))
+
+	// the agent stops, the socket stays for the next agent run
+	master.Close()
+	assert.True(t, fileutil.Exists(path.Join(dir, defaultSocketFileName)))
+	assert.NoError(t, worker.Send("reply"))
+
+	// the worker connects to the new master and sends the message kept meanwhile
+	master, err = newSocketChannel(socketLogger, ModeMaster, dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "reply", receive(t, master))
+
+	worker.Close()
+	master.Destroy()
+}
+
+func TestSocketChannelSendToStuckPeer(t *testing.T) {
+	defer func(timeout time.Duration) { writeTimeout = timeout }(writeTimeout)
+	writeTimeout = 50 * time.Millisecond
+	dir, cleanup := newTestChannelDir(t)
+	defer cleanup()
+
+	master, err := newSocketChannel(socketLogger, ModeMaster, dir)
+	assert.NoError(t, err)
+	worker, err := newSocketChannel(socketLogger, ModeWorker, dir)
+	assert.NoError(t, err)
+	assert.NoError(t, master.Send("pluginconfig"))
+	assert.Equal(t, "pluginconfig", receive(t, worker))
+
+	// the worker stops receiving, its buffer and the socket buffer fill up and the writes time out instead of blocking the channel
+	datagram := strings.Repeat("x", 256*1024)
+	start := time.Now()
+	for i := 0; i < 2*defaultChannelBufferSize && !master.(*socketChannel).hasPending(); i++ {
+		assert.NoError(t, master.Send(datagram))
+	}
+	assert.True(t, master.(*socketChannel).hasPending())
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	worker.Close()
+	master.Destroy()
+}
+
+func TestSocketChannelRejectsOtherUsers(t *testing.T) {
+	defer func() { peerCredentials = getPeerCredentials }()
+	peerCredentials = func(conn *net.UnixConn) (*syscall.Ucred, error) {
+		return &syscall.Ucred{Pid: 1234, Uid: uint32(os.Geteuid() + 1)}, nil
+	}
+	dir, cleanup := newTestChannelDir(t)
+	defer cleanup()
+
+	master, err := newSocketChannel(socketLogger, ModeMaster, dir)
+	assert.NoError(t, err)
+	defer master.Destroy()
+
+	_, err = newSocketChannel(socketLogger, ModeWorker, dir)
+	assert.Error(t, err)
+}
+
+func TestCreateChannelNegotiation(t *testing.T) {
+	dir, cleanup := newTestChannelDir(t)
+	defer cleanup()
+
+	// a new master prefers the socket channel, the worker finds the socket
+	master, err, found := createChannel(socketLogger, ModeMaster, dir)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.IsType(t, &socketChannel{}, master)
+	worker, err, _ := createChannel(socketLogger, ModeWorker, dir)
+	assert.NoError(t, err)
+	assert.IsType(t, &socketChannel{}, worker)
+	worker.Close()
+	master.Close()
+
+	// a restarted master listens on the socket again
+	master, err, found = createChannel(socketLogger, ModeMaster, dir)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.IsType(t, &socketChannel{}, master)
+	master.Destroy()
+
+	// a worker started with the file channel keeps using it
+	assert.NoError(t, os.MkdirAll(dir, defaultFileCreateMode))
+	master, err, found = createChannel(socketLogger, ModeMaster, dir)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.IsType(t, &fileWatcherChannel{}, master)
+	worker, err, _ = createChannel(socketLogger, ModeWorker, dir)
+	assert.NoError(t, err)
+	assert.IsType(t, &fileWatcherChannel{}, worker)
+	worker.Close()
+	master.Destroy()
+}
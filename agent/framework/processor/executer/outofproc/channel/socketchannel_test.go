@@ -0,0 +1,53 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package channel
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrames(t *testing.T) {
+	var buffer bytes.Buffer
+	datagrams := []string{`{"version":"1.0","type":"pluginconfig","content":"{}"}`, "", `{"version":"1.0","type":"complete"}`}
+	for _, datagram := range datagrams {
+		assert.NoError(t, writeFrame(&buffer, datagram))
+	}
+	assert.Equal(t, byte(frameVersion), buffer.Bytes()[0])
+
+	for _, expected := range datagrams {
+		datagram, err := readFrame(&buffer)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, datagram)
+	}
+	_, err := readFrame(&buffer)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadFrameInvalid(t *testing.T) {
+	// unknown frame version
+	_, err := readFrame(bytes.NewReader([]byte{2, 0, 0, 0, 1, 'a'}))
+	assert.Error(t, err)
+
+	// oversized frame
+	_, err = readFrame(bytes.NewReader([]byte{frameVersion, 0xff, 0xff, 0xff, 0xff}))
+	assert.Error(t, err)
+
+	// truncated datagram
+	_, err = readFrame(bytes.NewReader([]byte{frameVersion, 0, 0, 0, 4, 'a'}))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
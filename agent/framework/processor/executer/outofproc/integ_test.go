@@ -68,9 +68,12 @@ func setup(t *testing.T) *TestCase {
 		go fakeProcess.fakeWorker(fakeProcess.t, docID)
 		return fakeProcess, nil
 	}
-	processFinder = func(log log.T, procinfo contracts.OSProcInfo) bool {
+	processAttacher = func(log log.T, procinfo contracts.OSProcInfo) (proc.OSProcess, error) {
 		assert.Equal(t, testPid, procinfo.Pid)
-		return fakeProcess != nil && fakeProcess.live
+		if fakeProcess == nil || !fakeProcess.live {
+			return nil, errors.New("process not found")
+		}
+		return fakeProcess, nil
 	}
 	return testCase
 }
@@ -376,6 +379,10 @@ func (p *FakeProcess) StartTime() time.Time {
 	return testStartDateTime
 }
 
+func (p *FakeProcess) ProcInfo() contracts.OSProcInfo {
+	return contracts.OSProcInfo{Pid: testPid, StartTime: testStartDateTime}
+}
+
 func (p *FakeProcess) Wait() error {
 	//once the child is detached (controlled by our test engine), Wait() is illegal since the Executer is no longer the direct parent of the child
 	if !p.attached {
@@ -10,9 +10,12 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/basicexecuter"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/cgroup"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/channel"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/messaging"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/proc"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outputstream"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
@@ -23,8 +26,6 @@ type Backend messaging.MessagingBackend
 const (
 	//TODO prolong this value once we go to production
 	defaultZombieProcessTimeout = 3 * time.Second
-	//command maximum timeout
-	defaultOrphanProcessTimeout = 172800 * time.Second
 )
 
 type OutOfProcExecuter struct {
@@ -32,26 +33,31 @@ type OutOfProcExecuter struct {
 	docState   *contracts.DocumentState
 	ctx        context.T
 	cancelFlag task.CancelFlag
+	//control group limiting the resources of the document worker, nil if the worker runs without
+	workerGroup cgroup.ControlGroup
+	//whether the OOM killer killed the document worker
+	workerOOMKilled bool
 }
 
 var channelCreator = func(log log.T, mode channel.Mode, documentID string) (channel.Channel, error, bool) {
-	return channel.CreateFileChannel(log, mode, documentID)
+	return channel.CreateChannel(log, mode, documentID)
 }
 
-var processFinder = func(log log.T, procinfo contracts.OSProcInfo) bool {
-	//If ProcInfo is not initailized
-	//pid 0 is reserved for kernel on both linux and windows, so the assumption is safe here
-	if procinfo.Pid == 0 {
-		return false
-	}
-
-	return proc.IsProcessExists(log, procinfo.Pid, procinfo.StartTime)
+// processAttacher returns a handle of the document worker started by a previous agent run if it's still running
+var processAttacher = func(log log.T, procinfo contracts.OSProcInfo) (proc.OSProcess, error) {
+	return proc.AttachProcess(procinfo)
 }
 
 var processCreator = func(name string, argv []string) (proc.OSProcess, error) {
 	return proc.StartProcess(name, argv)
 }
 
+var cgroupCreator = cgroup.Create
+
+var cgroupOpener = cgroup.Open
+
+var outputStreamCreator = outputstream.New
+
 func NewOutOfProcExecuter(ctx context.T) *OutOfProcExecuter {
 	return &OutOfProcExecuter{
 		BasicExecuter: *basicexecuter.NewBasicExecuter(ctx),
@@ -106,8 +112,14 @@ func (e *OutOfProcExecuter) Run(
 //Executer however does hold a timer to the worker to forcefully termniate both of them
 func (e *OutOfProcExecuter) messaging(log log.T, ipc channel.Channel, resChan chan contracts.DocumentResult, cancelFlag task.CancelFlag, stopTimer chan bool) {
 
+	//the worker only streams the output of the plugins if a sink receives it
+	var stream contracts.OutputStream
+	if sink := e.outputStream(log, resChan); sink != nil {
+		defer sink.Close()
+		stream = sink.Write
+	}
 	//handoff reply functionalities to data backend.
-	backend := messaging.NewExecuterBackend(resChan, e.docState, cancelFlag)
+	backend := messaging.NewExecuterBackend(resChan, e.docState, cancelFlag, stream)
 	//handoff the data backend to messaging worker
 	if err := messaging.Messaging(log, ipc, backend, stopTimer); err != nil {
 		//the messaging worker encountered error, either ipc run into error or data backend throws error
@@ -124,6 +136,49 @@ func (e *OutOfProcExecuter) messaging(log log.T, ipc channel.Channel, resChan ch
 	}
 }
 
+// outputStream creates the sinks of the output streamed by the running plugins, partial results are only replied to Run Command
+func (e *OutOfProcExecuter) outputStream(log log.T, resChan chan contracts.DocumentResult) outputstream.Sink {
+	var reply outputstream.ReplyFunc
+	if e.docState.DocumentType == contracts.SendCommand {
+		reply = func(pluginID string, stdout string, stderr string) {
+			resChan <- e.partialResult(pluginID, stdout, stderr)
+		}
+	}
+	info := e.docState.DocumentInformation
+	sink := outputStreamCreator(log, e.ctx.AppConfig().Agent.OutputStreaming, info.InstanceID, info.DocumentID, reply)
+	e.docState.IOConfig.StreamOutput = sink != nil
+	return sink
+}
+
+// partialResult reports the output written so far by a running plugin along with the results of the completed plugins
+func (e *OutOfProcExecuter) partialResult(pluginID string, stdout string, stderr string) contracts.DocumentResult {
+	var docResult contracts.DocumentResult
+	docResult.MessageID = e.docState.DocumentInformation.MessageID
+	docResult.AssociationID = e.docState.DocumentInformation.AssociationID
+	docResult.DocumentName = e.docState.DocumentInformation.DocumentName
+	docResult.NPlugins = len(e.docState.InstancePluginsInformation)
+	docResult.DocumentVersion = e.docState.DocumentInformation.DocumentVersion
+	docResult.Status = contracts.ResultStatusInProgress
+	docResult.LastPlugin = pluginID
+	docResult.PluginResults = make(map[string]*contracts.PluginResult)
+	for _, pluginState := range e.docState.InstancePluginsInformation {
+		res := pluginState.Result
+		if pluginState.Id == pluginID {
+			res.PluginID = pluginID
+			res.PluginName = pluginState.Name
+			res.Status = contracts.ResultStatusInProgress
+			res.StandardOutput = stdout
+			res.StandardError = stderr
+			res.Output = iohandler.TruncateOutput(stdout, stderr, iohandler.MaximumPluginOutputSize)
+		} else if res.Status == "" {
+			//not run yet
+			continue
+		}
+		docResult.PluginResults[pluginState.Id] = &res
+	}
+	return docResult
+}
+
 func (e *OutOfProcExecuter) generateUnexpectedFailResult(errMsg string) contracts.DocumentResult {
 	var docResult contracts.DocumentResult
 	docResult.MessageID = e.docState.DocumentInformation.MessageID
@@ -136,6 +191,10 @@ func (e *OutOfProcExecuter) generateUnexpectedFailResult(errMsg string) contract
 	res := e.docState.InstancePluginsInformation[0].Result
 	res.Output = errMsg
 	res.Status = contracts.ResultStatusFailed
+	if e.workerOOMKilled {
+		res.Output = "document process was killed by the OOM killer, the memory limit of the document worker was exceeded"
+		res.FailureReason = contracts.FailureReasonOutOfMemory
+	}
 	docResult.PluginResults[e.docState.InstancePluginsInformation[0].Id] = &res
 	return docResult
 }
@@ -154,16 +213,18 @@ func (e *OutOfProcExecuter) initialize(stopTimer chan bool) (ipc channel.Channel
 	}
 	if found {
 		log.Info("discovered old channel object, trying to find detached process...")
-		var stopTime time.Duration
 		procInfo := e.docState.DocumentInformation.ProcInfo
-		if processFinder(log, procInfo) {
-			log.Infof("found orphan process: %v, start time: %v", procInfo.Pid, procInfo.StartTime)
-			stopTime = defaultOrphanProcessTimeout
+		if process, attachErr := processAttacher(log, procInfo); attachErr == nil {
+			log.Infof("found orphan process: %v, start time: %v, reattaching to it", procInfo.Pid, procInfo.StartTime)
+			//the worker kept running in its control group
+			if group, groupErr := cgroupOpener(documentID); groupErr == nil {
+				e.workerGroup = group
+			}
+			go e.WaitForProcess(stopTimer, process)
 		} else {
-			log.Infof("process: %v not found, treat as exited", procInfo.Pid)
-			stopTime = defaultZombieProcessTimeout
+			log.Infof("process: %v not found, treat as exited: %v", procInfo.Pid, attachErr)
+			go timeout(stopTimer, defaultZombieProcessTimeout, e.cancelFlag)
 		}
-		go timeout(stopTimer, stopTime, e.cancelFlag)
 	} else {
 		log.Debug("channel not found, starting a new process...")
 		var process proc.OSProcess
@@ -175,10 +236,8 @@ func (e *OutOfProcExecuter) initialize(stopTimer chan bool) (ipc channel.Channel
 		} else {
 			log.Debugf("successfully launched new process: %v", process.Pid())
 		}
-		e.docState.DocumentInformation.ProcInfo = contracts.OSProcInfo{
-			Pid:       process.Pid(),
-			StartTime: process.StartTime(),
-		}
+		e.docState.DocumentInformation.ProcInfo = process.ProcInfo()
+		e.limitResources(log, documentID, process.Pid())
 		//TODO add command timeout as well, in case process get stuck
 		go e.WaitForProcess(stopTimer, process)
 
@@ -187,6 +246,51 @@ func (e *OutOfProcExecuter) initialize(stopTimer chan bool) (ipc channel.Channel
 	return
 }
 
+// limitResources moves the document worker into its own control group, limited by the resource limits of the agent config
+// overridden by the ones of the document. The worker does not start any plugin before receiving the document from the
+// messaging worker, so all its child processes inherit the control group.
+func (e *OutOfProcExecuter) limitResources(log log.T, documentID string, pid int) {
+	limits := cgroup.MergeLimits(e.ctx.AppConfig().Agent.DocumentWorkerLimits, e.docState.ResourceLimits)
+	group, err := cgroupCreator(documentID)
+	if err != nil {
+		if limits != (appconfig.ResourceLimits{}) {
+			log.Errorf("failed to create control group, document worker runs without resource limits: %v", err)
+		} else {
+			log.Debugf("document worker runs without control group: %v", err)
+		}
+		return
+	}
+	if err = group.SetLimits(limits); err != nil {
+		log.Errorf("failed to limit the resources of the document worker: %v", err)
+	}
+	if err = group.AddProcess(pid); err != nil {
+		log.Errorf("failed to move process %v into its control group: %v", pid, err)
+		group.Remove()
+		return
+	}
+	e.workerGroup = group
+}
+
+// releaseResources removes the control group of the document worker once the worker exited, recording whether the
+// OOM killer killed processes of the group. The OOM kills are checked whatever the exit of the worker, whose exit
+// status is unknown when the worker was reattached after a restart of the agent. They are only reported when the
+// worker fails without sending the result of the document.
+func (e *OutOfProcExecuter) releaseResources(log log.T) {
+	if e.workerGroup == nil {
+		return
+	}
+	if usage, err := e.workerGroup.Usage(); err != nil {
+		log.Errorf("failed to read the resource usage of the document worker: %v", err)
+	} else if usage.OOMKills > 0 {
+		log.Errorf("the OOM killer killed %v processes of the document worker", usage.OOMKills)
+		e.workerOOMKilled = true
+	}
+	//processes the worker left behind keep the group alive
+	if err := e.workerGroup.Remove(); err != nil {
+		log.Debugf("control group of the document worker not removed: %v", err)
+	}
+}
+
 func (e *OutOfProcExecuter) WaitForProcess(stopTimer chan bool, process proc.OSProcess) {
 	log := e.ctx.Log()
 	//TODO revisit this feature, it has done sides of killing the document worker too fast -- the worker might busy doing s3 upload
@@ -201,11 +305,13 @@ func (e *OutOfProcExecuter) WaitForProcess(stopTimer chan bool, process proc.OSP
 	//		process.Kill()
 	//	}
 	//}()
-	if err := process.Wait(); err != nil {
+	err := process.Wait()
+	if err != nil {
 		log.Errorf("process: %v exited unsuccessfully, error message: %v", process.Pid(), err)
 	} else {
 		log.Debugf("process: %v exited successfully, trying to stop messaging worker", process.Pid())
 	}
+	e.releaseResources(log)
 	//waitReturned = true
 	timeout(stopTimer, defaultZombieProcessTimeout, e.cancelFlag)
 }
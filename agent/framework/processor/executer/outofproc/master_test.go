@@ -7,9 +7,13 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	executermocks "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/mock"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/cgroup"
+	cgroupmock "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/cgroup/mock"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/channel"
 	channelmock "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/channel/mock"
 	procmock "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/proc/mock"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outputstream"
+	outputstreammock "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outputstream/mock"
 
 	"errors"
 
@@ -41,6 +45,10 @@ var testPid = 100
 
 var logger = log.NewMockLog()
 
+var noControlGroup = func(name string) (cgroup.ControlGroup, error) {
+	return nil, errors.New("control groups are not available")
+}
+
 func CreateTestCase() *TestCase {
 	contextMock := context.NewMockDefaultWithContext([]string{"MASTER"})
 	docStore := new(executermocks.MockDocumentStore)
@@ -111,6 +119,7 @@ func TestInitializeNewProcess(t *testing.T) {
 		assert.Equal(t, argv, []string{testDocumentID})
 		return testCase.processMock, nil
 	}
+	cgroupCreator = noControlGroup
 	exe := &OutOfProcExecuter{
 		ctx:        testCase.context,
 		docState:   &testCase.docState,
@@ -121,7 +130,7 @@ func TestInitializeNewProcess(t *testing.T) {
 
 	testCase.processMock.On("Wait").Return(nil)
 	testCase.processMock.On("Pid").Return(testPid)
-	testCase.processMock.On("StartTime").Return(testStartDateTime)
+	testCase.processMock.On("ProcInfo").Return(contracts.OSProcInfo{Pid: testPid, StartTime: testStartDateTime})
 	_, err := exe.initialize(stopTimer)
 	assert.NoError(t, err)
 	//Wait() returns immediately, block until zombie timeout
@@ -172,6 +181,7 @@ func TestInitializeProcessUnexpectedExited(t *testing.T) {
 		assert.Equal(t, argv, []string{testDocumentID})
 		return testCase.processMock, nil
 	}
+	cgroupCreator = noControlGroup
 	cancel := task.NewChanneledCancelFlag()
 	var err = errors.New("process exited with status 1")
 	exe := &OutOfProcExecuter{
@@ -181,7 +191,7 @@ func TestInitializeProcessUnexpectedExited(t *testing.T) {
 	}
 	testCase.processMock.On("Wait").Return(err)
 	testCase.processMock.On("Pid").Return(testPid)
-	testCase.processMock.On("StartTime").Return(testStartDateTime)
+	testCase.processMock.On("ProcInfo").Return(contracts.OSProcInfo{Pid: testPid, StartTime: testStartDateTime})
 	//assert Wait() syscall is called
 	stopTimer := make(chan bool)
 	_, err2 := exe.initialize(stopTimer)
@@ -195,6 +205,47 @@ func TestInitializeProcessUnexpectedExited(t *testing.T) {
 	testCase.processMock.AssertExpectations(t)
 }
 
+func TestInitializeLimitResourcesOOMKilled(t *testing.T) {
+	testCase := CreateTestCase()
+	testCase.docState.ResourceLimits = appconfig.ResourceLimits{MemoryLimitMB: 256}
+	channelMock := new(channelmock.MockedChannel)
+	channelCreator = func(log log.T, mode channel.Mode, documentID string) (channel.Channel, error, bool) {
+		return channelMock, nil, false
+	}
+	processCreator = func(name string, argv []string) (proc.OSProcess, error) {
+		return testCase.processMock, nil
+	}
+	groupMock := new(cgroupmock.MockedControlGroup)
+	cgroupCreator = func(name string) (cgroup.ControlGroup, error) {
+		assert.Equal(t, testDocumentID, name)
+		return groupMock, nil
+	}
+	cancel := task.NewChanneledCancelFlag()
+	exe := &OutOfProcExecuter{
+		ctx:        testCase.context,
+		docState:   &testCase.docState,
+		cancelFlag: cancel,
+	}
+	//the limits of the document override the ones of the agent config
+	groupMock.On("SetLimits", appconfig.ResourceLimits{MemoryLimitMB: 256}).Return(nil)
+	groupMock.On("AddProcess", testPid).Return(nil)
+	groupMock.On("Usage").Return(cgroup.Usage{OOMKills: 1}, nil)
+	groupMock.On("Remove").Return(nil)
+	testCase.processMock.On("Wait").Return(errors.New("signal: killed"))
+	testCase.processMock.On("Pid").Return(testPid)
+	testCase.processMock.On("ProcInfo").Return(contracts.OSProcInfo{Pid: testPid, StartTime: testStartDateTime})
+	stopTimer := make(chan bool)
+	_, err := exe.initialize(stopTimer)
+	assert.NoError(t, err)
+	<-stopTimer
+	cancel.Set(task.Completed)
+	groupMock.AssertExpectations(t)
+	//the worker killed by the OOM killer is reported as such
+	docResult := exe.generateUnexpectedFailResult("document worker timed out")
+	assert.Equal(t, contracts.FailureReasonOutOfMemory, docResult.PluginResults["plugin1"].FailureReason)
+	assert.Contains(t, docResult.PluginResults["plugin1"].Output, "OOM killer")
+}
+
 //TODO revisit this feature
 //func TestTerminateWaitWhenJobComplete(t *testing.T) {
 //	testCase := CreateTestCase()
@@ -234,28 +285,138 @@ func TestInitializeConnectOldOrphan(t *testing.T) {
 		isCreateCalled = true
 		return testCase.processMock, nil
 	}
-	//make sure the finder is called
-	isFinderCalled := false
-	processFinder = func(log log.T, procinfo contracts.OSProcInfo) bool {
-		isFinderCalled = true
-		return true
+	//make sure the attacher is called with the saved process info
+	testCase.docState.DocumentInformation.ProcInfo = contracts.OSProcInfo{Pid: testPid, StartTime: testStartDateTime, StartTicks: 4242, BootID: "boot-id"}
+	processAttacher = func(log log.T, procinfo contracts.OSProcInfo) (proc.OSProcess, error) {
+		assert.Equal(t, testCase.docState.DocumentInformation.ProcInfo, procinfo)
+		return testCase.processMock, nil
 	}
+	cgroupOpener = noControlGroup
 	cancel := task.NewChanneledCancelFlag()
 	exe := &OutOfProcExecuter{
 		ctx:        testCase.context,
 		docState:   &testCase.docState,
 		cancelFlag: cancel,
 	}
+	//the reattached process is waited for like a new process
+	testCase.processMock.On("Wait").Return(nil)
+	testCase.processMock.On("Pid").Return(testPid)
 	stopTimer := make(chan bool)
 	_, err := exe.initialize(stopTimer)
-	//make sure timeout returns when cancel is set
-	cancel.Set(task.Completed)
 	assert.NoError(t, err)
+	//Wait() returns immediately, block until zombie timeout
+	<-stopTimer
 	assert.False(t, isCreateCalled)
-	assert.True(t, isFinderCalled)
+	testCase.processMock.AssertExpectations(t)
 	channelMock.AssertExpectations(t)
 }
 
+func TestInitializeOldOrphanOOMKilled(t *testing.T) {
+	testCase := CreateTestCase()
+	channelMock := new(channelmock.MockedChannel)
+	channelCreator = func(log log.T, mode channel.Mode, documentID string) (channel.Channel, error, bool) {
+		return channelMock, nil, true
+	}
+	testCase.docState.DocumentInformation.ProcInfo = contracts.OSProcInfo{Pid: testPid, StartTime: testStartDateTime}
+	processAttacher = func(log log.T, procinfo contracts.OSProcInfo) (proc.OSProcess, error) {
+		return testCase.processMock, nil
+	}
+	groupMock := new(cgroupmock.MockedControlGroup)
+	cgroupOpener = func(name string) (cgroup.ControlGroup, error) {
+		assert.Equal(t, testDocumentID, name)
+		return groupMock, nil
+	}
+	cancel := task.NewChanneledCancelFlag()
+	exe := &OutOfProcExecuter{
+		ctx:        testCase.context,
+		docState:   &testCase.docState,
+		cancelFlag: cancel,
+	}
+	groupMock.On("Usage").Return(cgroup.Usage{OOMKills: 1}, nil)
+	groupMock.On("Remove").Return(nil)
+	//the exit status of a reattached worker is unknown, Wait returns nil
+	testCase.processMock.On("Wait").Return(nil)
+	testCase.processMock.On("Pid").Return(testPid)
+	stopTimer := make(chan bool)
+	_, err := exe.initialize(stopTimer)
+	assert.NoError(t, err)
+	<-stopTimer
+	groupMock.AssertExpectations(t)
+	//the reattached worker killed by the OOM killer is reported as such
+	docResult := exe.generateUnexpectedFailResult("document worker timed out")
+	assert.Equal(t, contracts.FailureReasonOutOfMemory, docResult.PluginResults["plugin1"].FailureReason)
+}
+
+func TestInitializeOldOrphanExited(t *testing.T) {
+	testCase := CreateTestCase()
+	channelMock := new(channelmock.MockedChannel)
+	channelCreator = func(log log.T, mode channel.Mode, documentID string) (channel.Channel, error, bool) {
+		return channelMock, nil, true
+	}
+	processAttacher = func(log log.T, procinfo contracts.OSProcInfo) (proc.OSProcess, error) {
+		return nil, errors.New("process not found")
+	}
+	cancel := task.NewChanneledCancelFlag()
+	exe := &OutOfProcExecuter{
+		ctx:        testCase.context,
+		docState:   &testCase.docState,
+		cancelFlag: cancel,
+	}
+	stopTimer := make(chan bool)
+	_, err := exe.initialize(stopTimer)
+	assert.NoError(t, err)
+	//the messaging is stopped after the zombie timeout
+	<-stopTimer
+	testCase.processMock.AssertExpectations(t)
+}
+
+func TestOutputStreamRepliesPartialResults(t *testing.T) {
+	testCase := CreateTestCase()
+	testCase.docState.InstancePluginsInformation[0].Result = *testCase.results["plugin1"]
+	var reply outputstream.ReplyFunc
+	outputStreamCreator = func(log log.T, config appconfig.OutputStreaming, instanceID string, documentID string, replyFunc outputstream.ReplyFunc) outputstream.Sink {
+		assert.Equal(t, testInstanceID, instanceID)
+		assert.Equal(t, testDocumentID, documentID)
+		reply = replyFunc
+		return new(outputstreammock.MockedSink)
+	}
+	exe := &OutOfProcExecuter{
+		ctx:      testCase.context,
+		docState: &testCase.docState,
+	}
+	resChan := make(chan contracts.DocumentResult, 1)
+	sink := exe.outputStream(logger, resChan)
+	assert.NotNil(t, sink)
+	assert.True(t, exe.docState.IOConfig.StreamOutput)
+
+	reply("plugin2", "partial output", "")
+	res := <-resChan
+	assert.Equal(t, contracts.ResultStatusInProgress, res.Status)
+	assert.Equal(t, "plugin2", res.LastPlugin)
+	assert.Equal(t, testMessageID, res.MessageID)
+	assert.Equal(t, 2, len(res.PluginResults))
+	assert.Equal(t, *testCase.results["plugin1"], *res.PluginResults["plugin1"])
+	assert.Equal(t, contracts.ResultStatusInProgress, res.PluginResults["plugin2"].Status)
+	assert.Equal(t, "aws:runPowershellScript", res.PluginResults["plugin2"].PluginName)
+	assert.Equal(t, "partial output", res.PluginResults["plugin2"].StandardOutput)
+	assert.Equal(t, "partial output", res.PluginResults["plugin2"].Output)
+}
+
+func TestOutputStreamNoReplyForAssociations(t *testing.T) {
+	testCase := CreateTestCase()
+	testCase.docState.DocumentType = contracts.Association
+	outputStreamCreator = func(log log.T, config appconfig.OutputStreaming, instanceID string, documentID string, reply outputstream.ReplyFunc) outputstream.Sink {
+		assert.Nil(t, reply)
+		return nil
+	}
+	exe := &OutOfProcExecuter{
+		ctx:      testCase.context,
+		docState: &testCase.docState,
+	}
+	assert.Nil(t, exe.outputStream(logger, make(chan contracts.DocumentResult)))
+	assert.False(t, exe.docState.IOConfig.StreamOutput)
+}
+
 //TODO add Run() unittest
 
 //this is needed, since after marshal-unmarshalling thru the data channel, the pointer value changed
@@ -41,9 +41,11 @@ type ExecuterBackend struct {
 	cancelFlag task.CancelFlag
 	output     chan contracts.DocumentResult
 	stopChan   chan int
+	//receives the output streamed by the running plugins, nil if the output is not streamed
+	stream contracts.OutputStream
 }
 
-func NewExecuterBackend(output chan contracts.DocumentResult, docState *contracts.DocumentState, cancelFlag task.CancelFlag) *ExecuterBackend {
+func NewExecuterBackend(output chan contracts.DocumentResult, docState *contracts.DocumentState, cancelFlag task.CancelFlag, stream contracts.OutputStream) *ExecuterBackend {
 	stopChan := make(chan int, defaultBackendChannelSize)
 	inputChan := make(chan string, defaultBackendChannelSize)
 	p := ExecuterBackend{
@@ -52,6 +54,7 @@ func NewExecuterBackend(output chan contracts.DocumentResult, docState *contract
 		input:      inputChan,
 		cancelFlag: cancelFlag,
 		stopChan:   stopChan,
+		stream:     stream,
 	}
 	go p.start(*docState)
 	return &p
@@ -93,6 +96,14 @@ func (p *ExecuterBackend) Process(datagram string) error {
 			//get document result, force termniate messaging worker
 			p.stopChan <- stopTypeTerminate
 		}
+	case MessageTypeOutput:
+		var chunk contracts.OutputChunk
+		if err := jsonutil.Unmarshal(content, &chunk); err != nil {
+			return err
+		}
+		if p.stream != nil {
+			p.stream(chunk)
+		}
 	default:
 		return errors.New("unsupported message type")
 	}
@@ -134,6 +145,9 @@ func (p *WorkerBackend) Process(datagram string) error {
 			//TODO request messaging to stop
 			return err
 		}
+		if docState.IOConfig.StreamOutput {
+			docState.IOConfig.OutputStream = p.streamOutput
+		}
 		p.once.Do(func() {
 			statusChan := make(chan contracts.PluginResult)
 			go p.runner(p.ctx, docState, statusChan, p.cancelFlag)
@@ -150,6 +164,12 @@ func (p *WorkerBackend) Process(datagram string) error {
 	return nil
 }
 
+//streamOutput forwards the output chunks of the running plugins to the master
+func (p *WorkerBackend) streamOutput(chunk contracts.OutputChunk) {
+	outputMessage, _ := CreateDatagram(MessageTypeOutput, chunk)
+	p.input <- outputMessage
+}
+
 func (p *WorkerBackend) pluginListener(statusChan chan contracts.PluginResult) {
 	log := p.ctx.Log()
 	results := make(map[string]*contracts.PluginResult)
@@ -188,6 +188,26 @@ func TestExecuterBackend_ProcessUnsupportedVersion(t *testing.T) {
 	logger.Info(err)
 }
 
+func TestBackendOutputStreaming(t *testing.T) {
+	testCase := CreateTestCase()
+	inputChan := make(chan string, 1)
+	worker := WorkerBackend{
+		ctx:   contextMock,
+		input: inputChan,
+	}
+	chunk := contracts.OutputChunk{PluginID: "plugin1", Stream: "stdout", Data: "partial output"}
+	worker.streamOutput(chunk)
+
+	var chunks []contracts.OutputChunk
+	executer := ExecuterBackend{
+		docState: &testCase.docState,
+		stream:   func(chunk contracts.OutputChunk) { chunks = append(chunks, chunk) },
+	}
+	err := executer.Process(<-inputChan)
+	assert.NoError(t, err)
+	assert.Equal(t, []contracts.OutputChunk{chunk}, chunks)
+}
+
 func TestWorkerBackend_ProcessCancelV1(t *testing.T) {
 	_ = CreateTestCase()
 	inputChan := make(chan string, 10)
@@ -21,6 +21,7 @@ const (
 	MessageTypeComplete     = "complete"
 	MessageTypeReply        = "reply"
 	MessageTypeCancel       = "cancel"
+	MessageTypeOutput       = "output"
 )
 
 var versions = []string{"1.0"}
@@ -3,6 +3,7 @@ package procmock
 import (
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -20,6 +21,11 @@ func (m *MockedOSProcess) StartTime() time.Time {
 	return args.Get(0).(time.Time)
 }
 
+func (m *MockedOSProcess) ProcInfo() contracts.OSProcInfo {
+	args := m.Called()
+	return args.Get(0).(contracts.OSProcInfo)
+}
+
 func (m *MockedOSProcess) Kill() error {
 	args := m.Called()
 	return args.Error(0)
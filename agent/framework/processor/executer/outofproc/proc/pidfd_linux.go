@@ -0,0 +1,66 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package proc
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// pollIn is POLLIN of poll(2), the numbers of the pidfd system calls depending on the architecture are in the
+// pidfd_sysnum files
+const pollIn = 0x1
+
+// pollFd is struct pollfd of poll(2)
+type pollFd struct {
+	fd      int32
+	events  int16
+	revents int16
+}
+
+// pidfdOpen returns a file descriptor referring to the process, which fails on kernels without pidfd
+func pidfdOpen(pid int) (int, error) {
+	fd, _, errno := syscall.Syscall(sysPidfdOpen, uintptr(pid), 0, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	syscall.CloseOnExec(int(fd))
+	return int(fd), nil
+}
+
+// pidfdSendSignal signals the process referred to by the pidfd
+func pidfdSendSignal(pidfd int, signal syscall.Signal) error {
+	_, _, errno := syscall.Syscall6(sysPidfdSendSignal, uintptr(pidfd), uintptr(signal), 0, 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// pidfdWait waits for the process referred to by the pidfd to exit, the pidfd becomes readable then
+func pidfdWait(pidfd int) error {
+	fds := []pollFd{{fd: int32(pidfd), events: pollIn}}
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_PPOLL, uintptr(unsafe.Pointer(&fds[0])), uintptr(len(fds)), 0, 0, 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+}
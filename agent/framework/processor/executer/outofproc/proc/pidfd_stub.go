@@ -0,0 +1,35 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd netbsd openbsd
+
+package proc
+
+import (
+	"errors"
+	"syscall"
+)
+
+var errPidfdNotSupported = errors.New("pidfd is only supported on Linux")
+
+func pidfdOpen(pid int) (int, error) {
+	return -1, errPidfdNotSupported
+}
+
+func pidfdSendSignal(pidfd int, signal syscall.Signal) error {
+	return errPidfdNotSupported
+}
+
+func pidfdWait(pidfd int) error {
+	return errPidfdNotSupported
+}
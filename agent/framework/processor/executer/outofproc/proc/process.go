@@ -22,6 +22,7 @@ import (
 	"os/exec"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 )
 
@@ -30,6 +31,8 @@ type OSProcess interface {
 	//generic ssm visible fields
 	Pid() int
 	StartTime() time.Time
+	//identity of the process, saved with the document state to reattach to the process after an agent restart
+	ProcInfo() contracts.OSProcInfo
 	//kill the attached child process
 	Kill() error
 	//wait for the child to finish, if parent dies halfway, the child process is detached and becomes orphan
@@ -43,6 +46,7 @@ type OSProcess interface {
 type WorkerProcess struct {
 	*exec.Cmd
 	startTime time.Time
+	procInfo  contracts.OSProcInfo
 }
 
 func (p *WorkerProcess) Pid() int {
@@ -53,6 +57,10 @@ func (p *WorkerProcess) StartTime() time.Time {
 	return p.startTime
 }
 
+func (p *WorkerProcess) ProcInfo() contracts.OSProcInfo {
+	return p.procInfo
+}
+
 //TODO use the kill functions provided in executes package
 func (p *WorkerProcess) Kill() error {
 	return p.Cmd.Process.Kill()
@@ -69,17 +77,40 @@ func StartProcess(name string, argv []string) (OSProcess, error) {
 	prepareProcess(cmd)
 	err := cmd.Start()
 	p := WorkerProcess{
-		cmd,
-		time.Now().UTC(),
+		Cmd:       cmd,
+		startTime: time.Now().UTC(),
+	}
+	if err == nil {
+		p.procInfo = identify(cmd.Process.Pid, p.startTime)
 	}
 
 	return &p, err
 }
 
+// AttachProcess returns a handle of a process started by a previous agent run, if the process identified by the info
+// is still running. The handle waits for and kills the process while the agent is not its parent.
+func AttachProcess(info contracts.OSProcInfo) (OSProcess, error) {
+	//pid 0 is reserved for kernel on both linux and windows, so the assumption is safe here
+	if info.Pid == 0 {
+		return nil, errors.New("process info is not initialized")
+	}
+	return attach(info)
+}
+
+// IdentifyProcess returns the identity of a running process, which tells it apart from a later process reusing its pid
+func IdentifyProcess(pid int, startTime time.Time) contracts.OSProcInfo {
+	return identify(pid, startTime)
+}
+
+// IsSameProcess returns whether the process identified by the info is still running
+func IsSameProcess(info contracts.OSProcInfo) (bool, error) {
+	return isSameProcess(info)
+}
+
 //os.FindProcess() doesn't work on Linux: https://groups.google.com/forum/#!topic/golang-nuts/hqrp0UHBK9k
 //what we can only do is check whether it exists
 func IsProcessExists(log log.T, pid int, createTime time.Time) bool {
-	found, err := find_process(pid, createTime)
+	found, err := isSameProcess(contracts.OSProcInfo{Pid: pid, StartTime: createTime})
 	if err != nil {
 		log.Errorf("encountered error when finding process: %v", err)
 	}
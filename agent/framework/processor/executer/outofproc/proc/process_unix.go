@@ -17,13 +17,32 @@
 package proc
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+)
+
+// the process table of Linux, ps is only used on the systems without it
+var (
+	procDir    = "/proc"
+	bootIDPath = "/proc/sys/kernel/random/boot_id"
 )
 
+// interval between the checks of an attached process without pidfd
+var attachedProcessPollInterval = time.Second
+
+// maximum difference between the start time recorded by the agent and the start time printed by ps
+const processStartTimeTolerance = 2 * time.Second
+
 //Unix man: http://www.skrenta.com/rt/man/ps.1.html , return the process table of the current user, in agent it'll be root
 //verified on RHEL, Amazon Linux, Ubuntu, Centos, FreeBSD and Darwin
 //TODO optimize this, do not print all processes; what we need is the process belongs to a specific user and no tty attached
@@ -36,7 +55,145 @@ func prepareProcess(command *exec.Cmd) {
 	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 }
 
+// attachedProcess is a process started by a previous agent run, the agent is not its parent and can't wait for it
+type attachedProcess struct {
+	info contracts.OSProcInfo
+	//pidfd refers to the process even after its pid is reused, -1 if pidfd is not available
+	pidfd int
+}
+
+func (p *attachedProcess) Pid() int {
+	return p.info.Pid
+}
+
+func (p *attachedProcess) StartTime() time.Time {
+	return p.info.StartTime
+}
+
+func (p *attachedProcess) ProcInfo() contracts.OSProcInfo {
+	return p.info
+}
+
+// Kill kills the process, unless the process already exited and its pid got reused
+func (p *attachedProcess) Kill() error {
+	if p.pidfd >= 0 {
+		return pidfdSendSignal(p.pidfd, syscall.SIGKILL)
+	}
+	if same, err := isSameProcess(p.info); err != nil || !same {
+		return fmt.Errorf("process %v not found: %v", p.info.Pid, err)
+	}
+	return syscall.Kill(p.info.Pid, syscall.SIGKILL)
+}
+
+// Wait waits for the process to exit, its exit status is only known to its parent so a nil error does not mean
+// the process succeeded
+func (p *attachedProcess) Wait() error {
+	if p.pidfd >= 0 {
+		defer syscall.Close(p.pidfd)
+		return pidfdWait(p.pidfd)
+	}
+	for {
+		same, err := isSameProcess(p.info)
+		if err != nil {
+			return err
+		}
+		if !same {
+			return nil
+		}
+		time.Sleep(attachedProcessPollInterval)
+	}
+}
+
+// identify returns the identity of the process, with its start ticks and boot id on the systems with a process table
+func identify(pid int, startTime time.Time) contracts.OSProcInfo {
+	info := contracts.OSProcInfo{Pid: pid, StartTime: startTime}
+	startTicks, _, err := readProcStat(pid)
+	if err != nil {
+		return info
+	}
+	if bootID, err := readBootID(); err == nil {
+		info.StartTicks = startTicks
+		info.BootID = bootID
+	}
+	return info
+}
+
+// attach returns a handle of the process if the process identified by the info is still running
+func attach(info contracts.OSProcInfo) (OSProcess, error) {
+	same, err := isSameProcess(info)
+	if err != nil {
+		return nil, err
+	}
+	if !same {
+		return nil, fmt.Errorf("process %v not found", info.Pid)
+	}
+	process := &attachedProcess{info: info, pidfd: -1}
+	if pidfd, err := pidfdOpen(info.Pid); err == nil {
+		//the pid might have been reused between the check and the opening, the pidfd now pins the process
+		if same, err = isSameProcess(info); err != nil || !same {
+			syscall.Close(pidfd)
+			return nil, fmt.Errorf("process %v exited", info.Pid)
+		}
+		process.pidfd = pidfd
+	}
+	return process, nil
+}
+
+// isSameProcess returns whether the process identified by the info is running. Processes saved without start ticks
+// are only matched by pid, and ps is used on the systems without process table.
+func isSameProcess(info contracts.OSProcInfo) (bool, error) {
+	currentBootID, err := readBootID()
+	if err != nil {
+		return find_process(info.Pid, info.StartTime)
+	}
+	if info.BootID != "" && info.BootID != currentBootID {
+		//the system rebooted since the process started
+		return false, nil
+	}
+	startTicks, state, err := readProcStat(info.Pid)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	//a zombie already exited, it's only waiting for its parent
+	if state == "Z" {
+		return false, nil
+	}
+	return info.BootID == "" || startTicks == info.StartTicks, nil
+}
+
+// readProcStat returns the start time in clock ticks after the boot and the state of the process from /proc/<pid>/stat
+func readProcStat(pid int) (startTicks uint64, state string, err error) {
+	content, err := ioutil.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return
+	}
+	//the command name in parentheses can contain spaces and parentheses, the other fields follow the last parenthesis
+	stat := string(content)
+	commEnd := strings.LastIndex(stat, ")")
+	if commEnd < 0 {
+		return 0, "", errors.New("invalid process stat format")
+	}
+	//the fields after the command name start with the state (3rd field), the start time is the 22nd field
+	fields := strings.Fields(stat[commEnd+1:])
+	if len(fields) < 20 {
+		return 0, "", errors.New("invalid process stat format")
+	}
+	startTicks, err = strconv.ParseUint(fields[19], 10, 64)
+	return startTicks, fields[0], err
+}
+
+func readBootID() (string, error) {
+	content, err := ioutil.ReadFile(bootIDPath)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
 //given the pid and the unix process startTime format string, return whether the process is still alive
+//the process must also have started around startTime, unless startTime is unknown or ps prints another time format
 func find_process(pid int, startTime time.Time) (bool, error) {
 	output, err := ps()
 	if err != nil {
@@ -54,16 +211,22 @@ func find_process(pid int, startTime time.Time) (bool, error) {
 			return false, err
 		}
 		if pid == int(_pid) {
-			return true, nil
+			return isStartedAt(startTime, strings.Join(parts[1:], " ")), nil
 		}
 	}
 	return false, nil
 }
 
-//TODO add time comparison
-//compare the 2 UTC date time, whether the startTime is within one sec
-func compareTimes(startTime time.Time, timeRaw string) bool {
-	startTime = startTime.UTC()
-	parsedTime, _ := time.Parse(time.ANSIC, timeRaw)
-	return startTime.Before(parsedTime.Add(time.Second)) && startTime.After(parsedTime.Add(-time.Second))
+//isStartedAt returns whether the process started at lstart, the local start time printed by ps, was started at
+//startTime; the agent records startTime right after it starts the process and lstart is truncated to the second
+func isStartedAt(startTime time.Time, lstart string) bool {
+	if startTime.IsZero() {
+		return true
+	}
+	parsedTime, err := time.ParseInLocation(time.ANSIC, lstart, time.Local)
+	if err != nil {
+		return true
+	}
+	diff := startTime.Sub(parsedTime)
+	return diff > -processStartTimeTolerance && diff < processStartTimeTolerance
 }
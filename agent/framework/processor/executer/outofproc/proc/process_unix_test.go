@@ -17,12 +17,17 @@
 package proc
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	"time"
 
 	"os/exec"
 
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/stretchr/testify/assert"
 )
@@ -57,8 +62,7 @@ func TestFindProcess(t *testing.T) {
 		return []byte(testInput), nil
 	}
 	testPidExist := 2598
-	//TODO add time to the overall result
-	testPidExistTime := time.Now()
+	testPidExistTime := time.Date(2017, 8, 4, 11, 39, 23, 10000, time.Local).UTC()
 	testPidNonExist := 10000
 	exists, err := find_process(testPidExist, testPidExistTime)
 	assert.NoError(t, err)
@@ -66,10 +70,113 @@ func TestFindProcess(t *testing.T) {
 	exists, err = find_process(testPidNonExist, testPidExistTime)
 	assert.NoError(t, err)
 	assert.False(t, exists)
+	//the pid was reused by a process started later
+	exists, err = find_process(testPidExist, testPidExistTime.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	//the start time of the processes saved by the previous agents is unknown
+	exists, err = find_process(testPidExist, time.Time{})
+	assert.NoError(t, err)
+	assert.True(t, exists)
 }
 
-func TestCompareTime(t *testing.T) {
+func TestIsStartedAt(t *testing.T) {
 	testInput := "Fri Aug  4 11:39:23 2017"
-	testTime := time.Date(2017, 8, 4, 11, 39, 23, 10000, time.UTC)
-	assert.True(t, compareTimes(testTime, testInput))
+	testTime := time.Date(2017, 8, 4, 11, 39, 23, 10000, time.Local)
+	assert.True(t, isStartedAt(testTime, testInput))
+	assert.True(t, isStartedAt(testTime.Add(time.Second), testInput))
+	assert.False(t, isStartedAt(testTime.Add(-time.Minute), testInput))
+	//ps of darwin prints another time format
+	assert.True(t, isStartedAt(testTime, "11:39AM"))
+}
+
+// setProcDir replaces the process table with a temporary folder and returns a function restoring it
+func setProcDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "proc")
+	assert.NoError(t, err)
+	originalProcDir, originalBootIDPath := procDir, bootIDPath
+	procDir, bootIDPath = dir, filepath.Join(dir, "boot_id")
+	assert.NoError(t, ioutil.WriteFile(bootIDPath, []byte("0f2c3c44-4a3b-4c1c-9a52-7d1e2e4e0b21\n"), 0644))
+	return dir, func() {
+		procDir, bootIDPath = originalProcDir, originalBootIDPath
+		os.RemoveAll(dir)
+	}
+}
+
+func writeProcStat(t *testing.T, dir string, pid string, stat string) {
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, pid), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, pid, "stat"), []byte(stat), 0644))
+}
+
+func TestIsSameProcess(t *testing.T) {
+	dir, restore := setProcDir(t)
+	defer restore()
+	//the command name can contain spaces and parentheses
+	writeProcStat(t, dir, "2598", "2598 (ssm-document (worker)) S 1 2598 2598 0 -1 4194560 1021 0 0 0 12 3 0 0 20 0 8 0 987654 730202112 3389 18446744073709551615 1 1 0 0 0 0 0 0 2143420159 0 0 0 17 0 0 0 0 0 0\n")
+	writeProcStat(t, dir, "2600", "2600 (sleep) Z 1 2600 2600 0 -1 4194560 1021 0 0 0 12 3 0 0 20 0 8 0 987700 730202112 3389\n")
+	bootID := "0f2c3c44-4a3b-4c1c-9a52-7d1e2e4e0b21"
+
+	for _, testCase := range []struct {
+		info     contracts.OSProcInfo
+		expected bool
+	}{
+		{contracts.OSProcInfo{Pid: 2598, StartTicks: 987654, BootID: bootID}, true},
+		//the pid was reused by another process
+		{contracts.OSProcInfo{Pid: 2598, StartTicks: 123, BootID: bootID}, false},
+		//the system rebooted
+		{contracts.OSProcInfo{Pid: 2598, StartTicks: 987654, BootID: "9e0a7f3a-1d0d-4d8b-8f3e-2c4b5a6d7e8f"}, false},
+		//processes saved by earlier agent versions are matched by pid
+		{contracts.OSProcInfo{Pid: 2598}, true},
+		//zombies already exited
+		{contracts.OSProcInfo{Pid: 2600, StartTicks: 987700, BootID: bootID}, false},
+		{contracts.OSProcInfo{Pid: 10000, StartTicks: 987654, BootID: bootID}, false},
+	} {
+		same, err := isSameProcess(testCase.info)
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.expected, same, "%v", testCase.info)
+	}
+}
+
+func TestAttachProcess(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process table is only available on Linux")
+	}
+	cmd := exec.Command("sleep", "10")
+	assert.NoError(t, cmd.Start())
+	info := identify(cmd.Process.Pid, time.Now())
+	assert.NotZero(t, info.StartTicks)
+	assert.NotEmpty(t, info.BootID)
+
+	_, err := AttachProcess(contracts.OSProcInfo{Pid: info.Pid, StartTicks: info.StartTicks + 1, BootID: info.BootID})
+	assert.Error(t, err)
+
+	process, err := AttachProcess(info)
+	assert.NoError(t, err)
+	assert.Equal(t, info, process.ProcInfo())
+	waitDone := make(chan error)
+	go func() {
+		waitDone <- process.Wait()
+	}()
+	assert.NoError(t, process.Kill())
+	//the parent reaps the process
+	cmd.Wait()
+	select {
+	case err = <-waitDone:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the attached process was not waited for")
+	}
+}
+
+func TestAttachedProcessWaitWithoutPidfd(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process table is only available on Linux")
+	}
+	attachedProcessPollInterval = 10 * time.Millisecond
+	cmd := exec.Command("sleep", "0.2")
+	assert.NoError(t, cmd.Start())
+	go cmd.Wait()
+	process := &attachedProcess{info: identify(cmd.Process.Pid, time.Now()), pidfd: -1}
+
+	assert.NoError(t, process.Wait())
 }
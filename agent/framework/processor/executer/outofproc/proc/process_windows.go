@@ -19,9 +19,12 @@ package proc
 import (
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"syscall"
 	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 )
 
 var (
@@ -33,6 +36,51 @@ func prepareProcess(command *exec.Cmd) {
 	// nothing to do on windows
 }
 
+// attachedProcess is a process started by a previous agent run, opened by its pid
+type attachedProcess struct {
+	*os.Process
+	info contracts.OSProcInfo
+}
+
+func (p *attachedProcess) Pid() int {
+	return p.info.Pid
+}
+
+func (p *attachedProcess) StartTime() time.Time {
+	return p.info.StartTime
+}
+
+func (p *attachedProcess) ProcInfo() contracts.OSProcInfo {
+	return p.info
+}
+
+// Wait waits for the process to exit, the handle of the process allows waiting for processes of other parents
+func (p *attachedProcess) Wait() error {
+	_, err := p.Process.Wait()
+	return err
+}
+
+// identify returns the identity of the process, the pid and start time on windows
+func identify(pid int, startTime time.Time) contracts.OSProcInfo {
+	return contracts.OSProcInfo{Pid: pid, StartTime: startTime}
+}
+
+// attach returns a handle of the process if the process identified by the info is still running
+func attach(info contracts.OSProcInfo) (OSProcess, error) {
+	if found, err := isSameProcess(info); err != nil || !found {
+		return nil, fmt.Errorf("process %v not found: %v", info.Pid, err)
+	}
+	process, err := os.FindProcess(info.Pid)
+	if err != nil {
+		return nil, err
+	}
+	return &attachedProcess{Process: process, info: info}, nil
+}
+
+func isSameProcess(info contracts.OSProcInfo) (bool, error) {
+	return find_process(info.Pid, info.StartTime)
+}
+
 //given the pid and the high order filetime, look up the process
 func find_process(pid int, startTime time.Time) (bool, error) {
 	const da = syscall.STANDARD_RIGHTS_READ |
@@ -7,6 +7,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/cgroup"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/channel"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/messaging"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/proc"
@@ -29,7 +30,17 @@ var pluginRunner = func(
 	resChan chan contracts.PluginResult,
 	cancelFlag task.CancelFlag,
 ) {
-	runpluginutil.RunPlugins(context, docState.InstancePluginsInformation, docState.IOConfig, runpluginutil.SSMPluginRegistry, resChan, cancelFlag)
+	stepResults := make(chan contracts.PluginResult)
+	go func() {
+		runpluginutil.RunPlugins(context, docState.InstancePluginsInformation, docState.IOConfig, runpluginutil.SSMPluginRegistry, stepResults, cancelFlag)
+		close(stepResults)
+	}()
+	//report the resources used by each plugin, measured in the control group of the worker
+	usageMeter := cgroup.NewUsageMeter(context.Log())
+	for res := range stepResults {
+		usageMeter.Measure(&res)
+		resChan <- res
+	}
 	//make sure to signal the client that job complete
 	close(resChan)
 }
@@ -66,7 +77,7 @@ func main() {
 	}
 	logger.Infof("document: %v worker started", channelName)
 	//create channel from the given handle identifier by master
-	ipc, err, _ := channel.CreateFileChannel(logger, channel.ModeWorker, channelName)
+	ipc, err, _ := channel.CreateChannel(logger, channel.ModeWorker, channelName)
 	if err != nil {
 		logger.Errorf("failed to create channel: %v", err)
 		logger.Close()
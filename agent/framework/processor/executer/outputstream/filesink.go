@@ -0,0 +1,211 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package outputstream
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// streamRetention is how long the output streamed by the documents is kept
+const streamRetention = 24 * time.Hour
+
+// unsafeFileNameChars matches the characters of the step ids not allowed in file names
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// fileSink writes the output of each step to a file per stream, the files are rotated once they exceed maxSize
+type fileSink struct {
+	log        log.T
+	dir        string
+	maxSize    int64
+	maxBackups int
+	files      map[string]*streamFile
+}
+
+// streamFile is a file opened by the File sink
+type streamFile struct {
+	file *os.File
+	size int64
+}
+
+func newFileSink(log log.T, dir string, maxSizeMB int, maxBackups int) *fileSink {
+	removeExpiredStreams(log, filepath.Dir(dir))
+	return &fileSink{
+		log:        log,
+		dir:        dir,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+		files:      make(map[string]*streamFile),
+	}
+}
+
+// StreamFile returns the path of the file the File sink writes a stream of a step to
+func StreamFile(dir string, pluginID string, stream string) string {
+	return filepath.Join(dir, unsafeFileNameChars.ReplaceAllString(pluginID, "_"), stream)
+}
+
+// backupFile returns the path of the nth rotated file of a stream, 1 being the most recent
+func backupFile(path string, n int) string {
+	return fmt.Sprintf("%v.%v", path, n)
+}
+
+// Write appends the chunk to the file of its stream, rotating the file first if it would exceed the maximum size
+func (s *fileSink) Write(chunk contracts.OutputChunk) {
+	path := StreamFile(s.dir, chunk.PluginID, chunk.Stream)
+	f, opened := s.files[path]
+	if opened && f.size > 0 && f.size+int64(len(chunk.Data)) > s.maxSize {
+		f.file.Close()
+		delete(s.files, path)
+		rotate(path, s.maxBackups)
+		opened = false
+	}
+	if !opened {
+		var err error
+		if f, err = openStreamFile(path); err != nil {
+			s.log.Errorf("Failed to open the output stream file %v: %v", path, err)
+			return
+		}
+		s.files[path] = f
+	}
+	n, err := f.file.WriteString(chunk.Data)
+	f.size += int64(n)
+	if err != nil {
+		s.log.Errorf("Failed to write to the output stream file %v: %v", path, err)
+	}
+}
+
+// Close closes the files of the streams
+func (s *fileSink) Close() {
+	for _, f := range s.files {
+		f.file.Close()
+	}
+	s.files = make(map[string]*streamFile)
+}
+
+func openStreamFile(path string) (*streamFile, error) {
+	if err := fileutil.MakeDirs(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, appconfig.FileFlagsCreateOrAppend, appconfig.ReadWriteAccess)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &streamFile{file: file, size: info.Size()}, nil
+}
+
+// rotate renames the file of a stream to its first backup, shifting the existing backups and dropping the oldest
+func rotate(path string, maxBackups int) {
+	os.Remove(backupFile(path, maxBackups))
+	for n := maxBackups - 1; n >= 1; n-- {
+		os.Rename(backupFile(path, n), backupFile(path, n+1))
+	}
+	if maxBackups > 0 {
+		os.Rename(path, backupFile(path, 1))
+	} else {
+		os.Remove(path)
+	}
+}
+
+// removeExpiredStreams deletes the output streamed by the documents that ended more than streamRetention ago
+func removeExpiredStreams(log log.T, rootDir string) {
+	documents, err := ioutil.ReadDir(rootDir)
+	if err != nil {
+		return
+	}
+	for _, document := range documents {
+		if !document.IsDir() {
+			continue
+		}
+		dir := filepath.Join(rootDir, document.Name())
+		if time.Since(lastWriteTime(dir, document.ModTime())) > streamRetention {
+			if err = os.RemoveAll(dir); err != nil {
+				log.Debugf("Failed to remove the expired output stream %v: %v", document.Name(), err)
+			}
+		}
+	}
+}
+
+// lastWriteTime returns when a stream of the document was last written, the directories are only modified when a
+// stream file is created or rotated, so a long running step would look expired. Without stream file, the directory
+// time is returned.
+func lastWriteTime(dir string, dirTime time.Time) time.Time {
+	var last time.Time
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() && info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+		return nil
+	})
+	if last.IsZero() {
+		return dirTime
+	}
+	return last
+}
+
+// ReadTail returns up to size bytes of the end of a stream of a step, including the rotated files if needed
+func ReadTail(dir string, pluginID string, stream string, size int64) (string, error) {
+	path := StreamFile(dir, pluginID, stream)
+	if !fileutil.Exists(path) {
+		return "", fmt.Errorf("No %v streamed by step %v", stream, pluginID)
+	}
+	var tail []byte
+	for n := 0; int64(len(tail)) < size; n++ {
+		name := path
+		if n > 0 {
+			name = backupFile(path, n)
+		}
+		content, err := ioutil.ReadFile(name)
+		if err != nil {
+			// no older rotated file
+			break
+		}
+		tail = append(content, tail...)
+	}
+	if int64(len(tail)) > size {
+		tail = tail[int64(len(tail))-size:]
+	}
+	return string(tail), nil
+}
+
+// LatestStep returns the id of the step of the document which streamed its output last
+func LatestStep(dir string) (string, error) {
+	steps, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var latest os.FileInfo
+	for _, step := range steps {
+		if step.IsDir() && (latest == nil || step.ModTime().After(latest.ModTime())) {
+			latest = step
+		}
+	}
+	if latest == nil {
+		return "", fmt.Errorf("No output streamed in %v", dir)
+	}
+	return latest.Name(), nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package outputstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger = log.NewMockLog()
+
+func TestFileSinkRotatesFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "outputstream")
+	defer os.RemoveAll(dir)
+	sink := &fileSink{log: logger, dir: dir, maxSize: 10, maxBackups: 2, files: make(map[string]*streamFile)}
+
+	for _, data := range []string{"aaaaaa", "bbbbbb", "cccccc", "dddddd"} {
+		sink.Write(contracts.OutputChunk{PluginID: "aws:runShellScript", Stream: "stdout", Data: data})
+	}
+	sink.Write(contracts.OutputChunk{PluginID: "aws:runShellScript", Stream: "stderr", Data: "error"})
+	sink.Close()
+
+	path := filepath.Join(dir, "aws_runShellScript", "stdout")
+	assert.Equal(t, path, StreamFile(dir, "aws:runShellScript", "stdout"))
+	for file, content := range map[string]string{path: "dddddd", path + ".1": "cccccc", path + ".2": "bbbbbb"} {
+		actual, err := ioutil.ReadFile(file)
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(actual))
+	}
+	_, err := os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	tail, err := ReadTail(dir, "aws:runShellScript", "stdout", 8)
+	assert.NoError(t, err)
+	assert.Equal(t, "ccdddddd", tail)
+	tail, err = ReadTail(dir, "aws:runShellScript", "stdout", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, "bbbbbbccccccdddddd", tail)
+	tail, err = ReadTail(dir, "aws:runShellScript", "stderr", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, "error", tail)
+	_, err = ReadTail(dir, "unknown", "stdout", 100)
+	assert.Error(t, err)
+}
+
+func TestFileSinkRemovesExpiredStreams(t *testing.T) {
+	rootDir, _ := ioutil.TempDir("", "outputstream")
+	defer os.RemoveAll(rootDir)
+	expired := filepath.Join(rootDir, "expired")
+	recent := filepath.Join(rootDir, "recent")
+	os.MkdirAll(expired, 0700)
+	os.MkdirAll(recent, 0700)
+	expiredTime := time.Now().Add(-2 * streamRetention)
+	os.Chtimes(expired, expiredTime, expiredTime)
+
+	newFileSink(logger, filepath.Join(rootDir, "document"), 1, 1)
+
+	_, err := os.Stat(expired)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(recent)
+	assert.NoError(t, err)
+}
+
+func TestFileSinkExpiresStreamsByTheirLastWrite(t *testing.T) {
+	rootDir, _ := ioutil.TempDir("", "outputstream")
+	defer os.RemoveAll(rootDir)
+	expiredTime := time.Now().Add(-2 * streamRetention)
+	// a step still streaming in a directory created long ago
+	running := filepath.Join(rootDir, "running")
+	os.MkdirAll(filepath.Join(running, "step1"), 0700)
+	ioutil.WriteFile(filepath.Join(running, "step1", "stdout"), []byte("output"), 0600)
+	os.Chtimes(filepath.Join(running, "step1"), expiredTime, expiredTime)
+	os.Chtimes(running, expiredTime, expiredTime)
+	// a document which ended long ago in a directory modified since
+	ended := filepath.Join(rootDir, "ended")
+	os.MkdirAll(filepath.Join(ended, "step1"), 0700)
+	ioutil.WriteFile(filepath.Join(ended, "step1", "stdout"), []byte("output"), 0600)
+	os.Chtimes(filepath.Join(ended, "step1", "stdout"), expiredTime, expiredTime)
+
+	newFileSink(logger, filepath.Join(rootDir, "document"), 1, 1)
+
+	_, err := os.Stat(running)
+	assert.NoError(t, err)
+	_, err = os.Stat(ended)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLatestStep(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "outputstream")
+	defer os.RemoveAll(dir)
+	_, err := LatestStep(dir)
+	assert.Error(t, err)
+
+	os.MkdirAll(filepath.Join(dir, "step1"), 0700)
+	os.MkdirAll(filepath.Join(dir, "step2"), 0700)
+	earlier := time.Now().Add(-time.Minute)
+	os.Chtimes(filepath.Join(dir, "step1"), earlier, earlier)
+
+	step, err := LatestStep(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "step2", step)
+}
@@ -0,0 +1,78 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package outputstream implements the sinks the output of the running steps is streamed to
+package outputstream
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Sink receives the output chunks of the running steps of a document
+type Sink interface {
+	Write(chunk contracts.OutputChunk)
+	Close()
+}
+
+// ReplyFunc sends the output written so far by a running step
+type ReplyFunc func(pluginID string, stdout string, stderr string)
+
+// multiSink duplicates the chunks to several sinks
+type multiSink []Sink
+
+// New creates the sinks of the configuration for a document, nil is returned when no sink is configured.
+// Partial results are only replied if reply is not nil.
+func New(log log.T, config appconfig.OutputStreaming, instanceID string, documentID string, reply ReplyFunc) Sink {
+	var sinks multiSink
+	for _, name := range config.Sinks {
+		switch {
+		case strings.EqualFold(name, appconfig.OutputStreamSinkFile):
+			sinks = append(sinks, newFileSink(log, StreamDir(instanceID, documentID), config.FileMaxSizeMB, config.FileMaxBackups))
+		case strings.EqualFold(name, appconfig.OutputStreamSinkReply):
+			if reply != nil {
+				sinks = append(sinks, newReplySink(reply, time.Duration(config.ReplyIntervalSeconds)*time.Second))
+			}
+		default:
+			log.Errorf("Unsupported output stream sink %v. Supported sinks are %v and %v", name, appconfig.OutputStreamSinkFile, appconfig.OutputStreamSinkReply)
+		}
+	}
+	if len(sinks) == 0 {
+		return nil
+	}
+	return sinks
+}
+
+// StreamDir returns the folder the File sink writes the output of a document to
+func StreamDir(instanceID string, documentID string) string {
+	return filepath.Join(appconfig.DefaultDataStorePath, instanceID, appconfig.OutputStreamRootDirName, documentID)
+}
+
+// Write writes the chunk to all the sinks
+func (sinks multiSink) Write(chunk contracts.OutputChunk) {
+	for _, sink := range sinks {
+		sink.Write(chunk)
+	}
+}
+
+// Close closes all the sinks
+func (sinks multiSink) Close() {
+	for _, sink := range sinks {
+		sink.Close()
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package outputstream
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	config := appconfig.OutputStreaming{Sinks: []string{"file", "Reply", "Unknown"}, FileMaxSizeMB: 1, ReplyIntervalSeconds: 30}
+	reply := func(pluginID string, stdout string, stderr string) {}
+
+	sinks := New(logger, config, "i-1234", "document", reply)
+	assert.Len(t, sinks, 2)
+	assert.IsType(t, &fileSink{}, sinks.(multiSink)[0])
+	assert.IsType(t, &replySink{}, sinks.(multiSink)[1])
+
+	//partial results are not replied without reply function
+	sinks = New(logger, config, "i-1234", "document", nil)
+	assert.Len(t, sinks, 1)
+
+	config.Sinks = []string{"Reply"}
+	assert.Nil(t, New(logger, config, "i-1234", "document", nil))
+	config.Sinks = nil
+	assert.Nil(t, New(logger, config, "i-1234", "document", reply))
+}
@@ -0,0 +1,70 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package outputstream
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+)
+
+// now returns the current time, overridden by the tests
+var now = time.Now
+
+// replySink replies the output of the running step at most once per interval. The output is capped the same way as
+// the output of the step results, and it's only replied when new output was streamed.
+type replySink struct {
+	reply     ReplyFunc
+	interval  time.Duration
+	pluginID  string
+	stdout    string
+	stderr    string
+	lastReply time.Time
+}
+
+func newReplySink(reply ReplyFunc, interval time.Duration) *replySink {
+	return &replySink{reply: reply, interval: interval}
+}
+
+// Write adds the chunk to the output of its step and replies the output if the interval elapsed since the last reply
+func (s *replySink) Write(chunk contracts.OutputChunk) {
+	if chunk.PluginID != s.pluginID {
+		s.pluginID, s.stdout, s.stderr = chunk.PluginID, "", ""
+		s.lastReply = now()
+	}
+	outputConfig := iohandler.DefaultOutputConfig()
+	switch chunk.Stream {
+	case outputConfig.StdoutFileName:
+		s.stdout = appendCapped(s.stdout, chunk.Data, outputConfig.MaxStdoutLength)
+	case outputConfig.StderrFileName:
+		s.stderr = appendCapped(s.stderr, chunk.Data, outputConfig.MaxStderrLength)
+	}
+	if now().Sub(s.lastReply) >= s.interval {
+		s.lastReply = now()
+		s.reply(s.pluginID, s.stdout, s.stderr)
+	}
+}
+
+// Close does nothing, the output of the step is replied with its result once the step completes
+func (s *replySink) Close() {}
+
+func appendCapped(output string, data string, capacity int) string {
+	if len(output) >= capacity {
+		return output
+	} else if len(output)+len(data) > capacity {
+		return output + data[:capacity-len(output)]
+	}
+	return output + data
+}
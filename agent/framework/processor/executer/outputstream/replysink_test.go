@@ -0,0 +1,59 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package outputstream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+type partialReply struct {
+	pluginID, stdout, stderr string
+}
+
+func TestReplySinkThrottlesReplies(t *testing.T) {
+	current := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+	var replies []partialReply
+	sink := newReplySink(func(pluginID string, stdout string, stderr string) {
+		replies = append(replies, partialReply{pluginID, stdout, stderr})
+	}, 30*time.Second)
+
+	sink.Write(contracts.OutputChunk{PluginID: "plugin1", Stream: "stdout", Data: "out1 "})
+	current = current.Add(10 * time.Second)
+	sink.Write(contracts.OutputChunk{PluginID: "plugin1", Stream: "stderr", Data: "err1"})
+	assert.Empty(t, replies)
+
+	current = current.Add(20 * time.Second)
+	sink.Write(contracts.OutputChunk{PluginID: "plugin1", Stream: "stdout", Data: "out2"})
+	assert.Equal(t, []partialReply{{"plugin1", "out1 out2", "err1"}}, replies)
+
+	//the output of the next plugin starts over
+	current = current.Add(time.Hour)
+	sink.Write(contracts.OutputChunk{PluginID: "plugin2", Stream: "stdout", Data: "out3"})
+	current = current.Add(30 * time.Second)
+	sink.Write(contracts.OutputChunk{PluginID: "plugin2", Stream: "stdout", Data: "out4"})
+	assert.Equal(t, []partialReply{{"plugin1", "out1 out2", "err1"}, {"plugin2", "out3out4", ""}}, replies)
+	sink.Close()
+}
+
+func TestAppendCapped(t *testing.T) {
+	assert.Equal(t, "abcd", appendCapped("ab", "cd", 5))
+	assert.Equal(t, "abcde", appendCapped("ab", "cdef", 5))
+	assert.Equal(t, "abcde", appendCapped("abcde", "f", 5))
+}
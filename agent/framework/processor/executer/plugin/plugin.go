@@ -81,6 +81,10 @@ func (f RunPowerShellFactory) Create(context context.T) (runpluginutil.T, error)
 	return runscript.NewRunPowerShellPlugin()
 }
 
+func (f RunPowerShellFactory) InputSchema() runpluginutil.InputSchema {
+	return runpluginutil.NewInputSchema(runscript.RunScriptPluginInput{})
+}
+
 type UpdateAgentFactory struct {
 }
 
@@ -88,6 +92,10 @@ func (f UpdateAgentFactory) Create(context context.T) (runpluginutil.T, error) {
 	return updatessmagent.NewPlugin(updatessmagent.GetUpdatePluginConfig(context))
 }
 
+func (f UpdateAgentFactory) InputSchema() runpluginutil.InputSchema {
+	return runpluginutil.NewInputSchema(updatessmagent.UpdatePluginInput{})
+}
+
 type ConfigureContainerFactory struct {
 }
 
@@ -95,6 +103,10 @@ func (f ConfigureContainerFactory) Create(context context.T) (runpluginutil.T, e
 	return configurecontainers.NewPlugin()
 }
 
+func (f ConfigureContainerFactory) InputSchema() runpluginutil.InputSchema {
+	return runpluginutil.NewInputSchema(configurecontainers.ConfigureContainerPluginInput{})
+}
+
 type RunDockerFactory struct {
 }
 
@@ -102,6 +114,10 @@ func (f RunDockerFactory) Create(context context.T) (runpluginutil.T, error) {
 	return dockercontainer.NewPlugin()
 }
 
+func (f RunDockerFactory) InputSchema() runpluginutil.InputSchema {
+	return runpluginutil.NewInputSchema(dockercontainer.DockerContainerPluginInput{})
+}
+
 type ConfigurePackageFactory struct {
 }
 
@@ -109,6 +125,10 @@ func (f ConfigurePackageFactory) Create(context context.T) (runpluginutil.T, err
 	return configurepackage.NewPlugin()
 }
 
+func (f ConfigurePackageFactory) InputSchema() runpluginutil.InputSchema {
+	return runpluginutil.NewInputSchema(configurepackage.ConfigurePackagePluginInput{})
+}
+
 type RefreshAssociationFactory struct {
 }
 
@@ -116,6 +136,10 @@ func (f RefreshAssociationFactory) Create(context context.T) (runpluginutil.T, e
 	return refreshassociation.NewPlugin()
 }
 
+func (f RefreshAssociationFactory) InputSchema() runpluginutil.InputSchema {
+	return runpluginutil.NewInputSchema(refreshassociation.RefreshAssociationPluginInput{})
+}
+
 type DownloadContentFactory struct {
 }
 
@@ -123,6 +147,10 @@ func (d DownloadContentFactory) Create(context context.T) (runpluginutil.T, erro
 	return downloadcontent.NewPlugin()
 }
 
+func (d DownloadContentFactory) InputSchema() runpluginutil.InputSchema {
+	return runpluginutil.NewInputSchema(downloadcontent.DownloadContentPlugin{}, "sourceType", "sourceInfo")
+}
+
 type RunDocumentFactory struct {
 }
 
@@ -130,6 +158,10 @@ func (r RunDocumentFactory) Create(context context.T) (runpluginutil.T, error) {
 	return rundocument.NewPlugin()
 }
 
+func (r RunDocumentFactory) InputSchema() runpluginutil.InputSchema {
+	return runpluginutil.NewInputSchema(rundocument.RunDocumentPluginInput{}, "documentType", "documentPath")
+}
+
 // RegisteredWorkerPlugins returns all registered core modules.
 func RegisteredWorkerPlugins(context context.T) runpluginutil.PluginRegistry {
 	once.Do(func() {
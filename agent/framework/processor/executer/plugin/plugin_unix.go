@@ -29,6 +29,10 @@ func (f RunShellScriptFactory) Create(context context.T) (runpluginutil.T, error
 	return runscript.NewRunShellPlugin(context.Log())
 }
 
+func (f RunShellScriptFactory) InputSchema() runpluginutil.InputSchema {
+	return runpluginutil.NewInputSchema(runscript.RunScriptPluginInput{})
+}
+
 // loadPlatformDependentPlugins registers platform dependent plugins
 func loadPlatformDependentPlugins(context context.T) runpluginutil.PluginRegistry {
 	var workerPlugins = runpluginutil.PluginRegistry{}
@@ -34,6 +34,10 @@ func (f PsModuleFactory) Create(context context.T) (runpluginutil.T, error) {
 	return psmodule.NewPlugin()
 }
 
+func (f PsModuleFactory) InputSchema() runpluginutil.InputSchema {
+	return runpluginutil.NewInputSchema(psmodule.PSModulePluginInput{})
+}
+
 type ApplicationFactory struct {
 }
 
@@ -41,6 +45,10 @@ func (f ApplicationFactory) Create(context context.T) (runpluginutil.T, error) {
 	return application.NewPlugin()
 }
 
+func (f ApplicationFactory) InputSchema() runpluginutil.InputSchema {
+	return runpluginutil.NewInputSchema(application.ApplicationPluginInput{})
+}
+
 type DomainJoinFactory struct {
 }
 
@@ -48,6 +56,10 @@ func (f DomainJoinFactory) Create(context context.T) (runpluginutil.T, error) {
 	return domainjoin.NewPlugin()
 }
 
+func (f DomainJoinFactory) InputSchema() runpluginutil.InputSchema {
+	return runpluginutil.NewInputSchema(domainjoin.DomainJoinPluginInput{}, "directoryId", "directoryName")
+}
+
 type UpdateEc2ConfigFactory struct {
 }
 
@@ -55,6 +67,10 @@ func (f UpdateEc2ConfigFactory) Create(context context.T) (runpluginutil.T, erro
 	return updateec2config.NewPlugin(updateec2config.GetUpdatePluginConfig(context))
 }
 
+func (f UpdateEc2ConfigFactory) InputSchema() runpluginutil.InputSchema {
+	return runpluginutil.NewInputSchema(updateec2config.UpdatePluginInput{})
+}
+
 // loadPlatformDependentPlugins registers platform dependent plugins
 func loadPlatformDependentPlugins(context context.T) runpluginutil.PluginRegistry {
 	var workerPlugins = runpluginutil.PluginRegistry{}
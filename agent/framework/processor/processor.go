@@ -16,8 +16,6 @@ package processor
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
 	"sync"
 	"time"
 
@@ -220,31 +218,21 @@ func (p *EngineProcessor) Stop(stopType contracts.StopType) {
 	close(p.resChan)
 }
 
-//TODO remove the direct file dependency once we encapsulate docmanager package
 func (p *EngineProcessor) processPendingDocuments(instanceID string) {
 	log := p.context.Log()
-	files := []os.FileInfo{}
-	var err error
 
 	//process older documents from PENDING folder
-	pendingDocsLocation := docmanager.DocumentStateDir(instanceID, appconfig.DefaultLocationOfPending)
-
-	if isDirectoryEmpty, _ := fileutil.IsDirEmpty(pendingDocsLocation); isDirectoryEmpty {
-		log.Debugf("No pending documents to process from %v", pendingDocsLocation)
-		return
-	}
-
-	//get all pending messages
-	if files, err = fileutil.ReadDir(pendingDocsLocation); err != nil {
-		log.Errorf("skipping reading pending documents from %v. unexpected error encountered - %v", pendingDocsLocation, err)
+	fileNames := p.documentMgr.ListDocumentStates(log, instanceID, appconfig.DefaultLocationOfPending)
+	if len(fileNames) == 0 {
+		log.Debugf("No pending documents to process from %v", appconfig.DefaultLocationOfPending)
 		return
 	}
 
 	//iterate through all pending messages
-	for _, f := range files {
-		log.Infof("Found pending document - %v", f.Name())
+	for _, fileName := range fileNames {
+		log.Infof("Found pending document - %v", fileName)
 		//inspect document state
-		docState := p.documentMgr.GetDocumentState(log, f.Name(), instanceID, appconfig.DefaultLocationOfPending)
+		docState := p.documentMgr.GetDocumentState(log, fileName, instanceID, appconfig.DefaultLocationOfPending)
 
 		if p.isSupportedDocumentType(docState.DocumentType) {
 			log.Infof("Processing pending document %v", docState.DocumentInformation.DocumentID)
@@ -258,32 +246,24 @@ func (p *EngineProcessor) processPendingDocuments(instanceID string) {
 func (p *EngineProcessor) processInProgressDocuments(instanceID string) {
 	log := p.context.Log()
 	config := p.context.AppConfig()
-	var err error
 
-	pendingDocsLocation := docmanager.DocumentStateDir(instanceID, appconfig.DefaultLocationOfCurrent)
-
-	if isDirectoryEmpty, _ := fileutil.IsDirEmpty(pendingDocsLocation); isDirectoryEmpty {
-		log.Debugf("No in-progress document to process from %v", pendingDocsLocation)
+	fileNames := p.documentMgr.ListDocumentStates(log, instanceID, appconfig.DefaultLocationOfCurrent)
+	if len(fileNames) == 0 {
+		log.Debugf("No in-progress document to process from %v", appconfig.DefaultLocationOfCurrent)
 		return
 
 	}
 
-	files := []os.FileInfo{}
-	if files, err = ioutil.ReadDir(pendingDocsLocation); err != nil {
-		log.Errorf("skipping reading inprogress document from %v. unexpected error encountered - %v", pendingDocsLocation, err)
-		return
-	}
-
 	//iterate through all InProgress docs
-	for _, f := range files {
-		log.Infof("Found in-progress document - %v", f.Name())
+	for _, fileName := range fileNames {
+		log.Infof("Found in-progress document - %v", fileName)
 
 		//inspect document state
-		docState := p.documentMgr.GetDocumentState(log, f.Name(), instanceID, appconfig.DefaultLocationOfCurrent)
+		docState := p.documentMgr.GetDocumentState(log, fileName, instanceID, appconfig.DefaultLocationOfCurrent)
 
 		retryLimit := config.Mds.CommandRetryLimit
 		if docState.DocumentInformation.RunCount >= retryLimit {
-			p.documentMgr.MoveDocumentState(log, f.Name(), instanceID, appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCorrupt)
+			p.documentMgr.MoveDocumentState(log, fileName, instanceID, appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCorrupt)
 			continue
 		}
 
@@ -297,7 +277,7 @@ func (p *EngineProcessor) processInProgressDocuments(instanceID string) {
 			//Submit the work to Job Pool so that we don't block for processing of new messages
 			if err := p.submit(&docState); err != nil {
 				log.Errorf("failed to submit in progress document %v : %v", docState.DocumentInformation.DocumentID, err)
-				p.documentMgr.MoveDocumentState(log, f.Name(), instanceID, appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCorrupt)
+				p.documentMgr.MoveDocumentState(log, fileName, instanceID, appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCorrupt)
 			}
 		}
 	}
@@ -191,6 +191,34 @@ func TestProcessCancelCommand_Success(t *testing.T) {
 
 }
 
+func TestProcessInProgressDocuments_RetryLimit(t *testing.T) {
+	ctx := context.NewMockDefault()
+	docMock := new(DocumentMgrMock)
+	processor := EngineProcessor{
+		context:     ctx,
+		documentMgr: docMock,
+	}
+	docState := contracts.DocumentState{}
+	docState.DocumentInformation.DocumentID = "documentID"
+	docState.DocumentInformation.RunCount = ctx.AppConfig().Mds.CommandRetryLimit
+	docMock.On("ListDocumentStates", mock.Anything, "instanceID", appconfig.DefaultLocationOfCurrent).Return([]string{"documentID"})
+	docMock.On("GetDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfCurrent).Return(docState)
+	docMock.On("MoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCorrupt)
+	processor.processInProgressDocuments("instanceID")
+	docMock.AssertExpectations(t)
+}
+
+func TestProcessPendingDocuments_Empty(t *testing.T) {
+	docMock := new(DocumentMgrMock)
+	processor := EngineProcessor{
+		context:     context.NewMockDefault(),
+		documentMgr: docMock,
+	}
+	docMock.On("ListDocumentStates", mock.Anything, "instanceID", appconfig.DefaultLocationOfPending).Return([]string{})
+	processor.processPendingDocuments("instanceID")
+	docMock.AssertExpectations(t)
+}
+
 type DocumentMgrMock struct {
 	mock.Mock
 }
@@ -214,3 +242,8 @@ func (m *DocumentMgrMock) RemoveDocumentState(log log.T, documentID, instanceID,
 	m.Called(log, documentID, instanceID, location)
 	return
 }
+
+func (m *DocumentMgrMock) ListDocumentStates(log log.T, instanceID, locationFolder string) []string {
+	args := m.Called(log, instanceID, locationFolder)
+	return args.Get(0).([]string)
+}
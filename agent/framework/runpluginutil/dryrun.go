@@ -0,0 +1,54 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"fmt"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+)
+
+// InputValidator is implemented by the plugins able to validate the input of a step without executing it
+type InputValidator interface {
+	ValidateInput(context context.T, config contracts.Configuration) error
+}
+
+// dryRunPlugin reports that a step would be executed once its input is validated against the input schema of the
+// plugin and by the plugin itself, without executing it. The plugins which can't validate their input are assumed
+// to accept it.
+func dryRunPlugin(context context.T, factory Factory, pluginID string, config contracts.Configuration) (res contracts.PluginResult) {
+	err := validateStepInput(factory, config)
+	var plugin T
+	if err == nil {
+		plugin, err = factory.Create(context)
+	}
+	if err == nil {
+		if validator, ok := plugin.(InputValidator); ok {
+			err = validator.ValidateInput(context, config)
+		}
+	}
+	if err != nil {
+		res.Status = contracts.ResultStatusFailed
+		res.Code = 1
+		res.Error = err
+		res.Output = fmt.Sprintf("Dry run: step execution would fail: %v. Step name: %s", err, pluginID)
+		context.Log().Error(res.Output)
+		return
+	}
+	res.Status = contracts.ResultStatusSuccess
+	res.Output = fmt.Sprintf("Dry run: step would be executed. Step name: %s", pluginID)
+	context.Log().Info(res.Output)
+	return
+}
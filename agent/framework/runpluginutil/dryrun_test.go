@@ -0,0 +1,111 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// validatingPluginMock is a mocked plugin validating its input
+type validatingPluginMock struct {
+	PluginMock
+	err error
+}
+
+func (m *validatingPluginMock) ValidateInput(context context.T, config contracts.Configuration) error {
+	return m.err
+}
+
+func newDryRunTestSteps(firstStep contracts.Configuration, plugins map[string]T) ([]contracts.PluginState, PluginRegistry) {
+	pluginRegistry := PluginRegistry{}
+	pluginStates := []contracts.PluginState{}
+	for _, name := range []string{testPlugin1, testPlugin2} {
+		config := contracts.Configuration{
+			PluginID:   name,
+			PluginName: name,
+			DryRun:     true,
+		}
+		if name == testPlugin1 {
+			config = firstStep
+		}
+		pluginFactory := new(PluginFactoryMock)
+		pluginFactory.On("Create", mock.Anything).Return(plugins[name], nil)
+		pluginRegistry[name] = pluginFactory
+		pluginStates = append(pluginStates, contracts.PluginState{
+			Name:          name,
+			Id:            name,
+			Configuration: config,
+		})
+	}
+	return pluginStates, pluginRegistry
+}
+
+func TestRunPluginsDryRun(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	firstStep := contracts.Configuration{
+		PluginID:   testPlugin1,
+		PluginName: testPlugin1,
+		DryRun:     true,
+	}
+	plugin1 := &validatingPluginMock{}
+	plugin2 := new(PluginMock)
+	pluginStates, pluginRegistry := newDryRunTestSteps(firstStep, map[string]T{testPlugin1: plugin1, testPlugin2: plugin2})
+
+	ch := make(chan contracts.PluginResult, 2)
+	outputs := RunPlugins(ctx, pluginStates, contracts.IOConfiguration{}, pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	plugin1.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	plugin2.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin1].Status)
+	assert.Equal(t, "Dry run: step would be executed. Step name: plugin1", outputs[testPlugin1].Output)
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin2].Status)
+}
+
+func TestRunPluginsDryRunInvalidInput(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	firstStep := contracts.Configuration{
+		PluginID:   testPlugin1,
+		PluginName: testPlugin1,
+		OnFailure:  contracts.OnFailureExit,
+		DryRun:     true,
+	}
+	plugin1 := &validatingPluginMock{err: errors.New("runCommand must be a list")}
+	plugin2 := new(PluginMock)
+	pluginStates, pluginRegistry := newDryRunTestSteps(firstStep, map[string]T{testPlugin1: plugin1, testPlugin2: plugin2})
+
+	ch := make(chan contracts.PluginResult, 2)
+	outputs := RunPlugins(ctx, pluginStates, contracts.IOConfiguration{}, pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Status)
+	assert.Equal(t, "Dry run: step execution would fail: runCommand must be a list. Step name: plugin1", outputs[testPlugin1].Output)
+	// the step exiting on failure skips the next steps during a dry run too
+	assert.Equal(t, contracts.ResultStatusSkipped, outputs[testPlugin2].Status)
+}
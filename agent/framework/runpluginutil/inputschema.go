@@ -0,0 +1,379 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+)
+
+// Types of the properties of the input of a plugin
+const (
+	InputTypeString  = "string"
+	InputTypeNumber  = "number"
+	InputTypeBoolean = "boolean"
+	InputTypeArray   = "array"
+	InputTypeObject  = "object"
+	// InputTypeAny is the type of the properties the plugin parses itself, i.e. a timeout given as a string or a number
+	InputTypeAny = "any"
+)
+
+// timeoutSecondsProperty is the property of the input of the plugins accepting an execution timeout
+const timeoutSecondsProperty = "timeoutSeconds"
+
+// runAsUserProperty is the property of the input of the plugins able to run their commands as another user
+const runAsUserProperty = "runAsUser"
+
+// maxSuggestionDistance is the number of edits allowed between an unknown property and the property it's suggested for
+const maxSuggestionDistance = 3
+
+// InputSchema describes the input of a plugin, the properties are named the way documents name them, i.e. runCommand
+type InputSchema struct {
+	Properties map[string]InputProperty `json:"properties"`
+	Required   []string                 `json:"required,omitempty"`
+}
+
+// InputProperty describes a property of the input of a plugin, Items being the type of the elements of an array
+type InputProperty struct {
+	Type  string `json:"type"`
+	Items string `json:"items,omitempty"`
+}
+
+// InputSchemaProvider is implemented by the plugin factories registering the input schema of their plugin
+type InputSchemaProvider interface {
+	InputSchema() InputSchema
+}
+
+// NewInputSchema returns the schema of the input parsed into the given struct, i.e. RunScriptPluginInput{}.
+// The properties are the ones the struct is remarshaled from, named after their json tag or their field.
+func NewInputSchema(input interface{}, required ...string) InputSchema {
+	schema := InputSchema{
+		Properties: make(map[string]InputProperty),
+		Required:   required,
+	}
+	addInputProperties(schema.Properties, reflect.TypeOf(input))
+	return schema
+}
+
+// addInputProperties adds the exported fields of the struct, including the fields of its embedded structs
+func addInputProperties(properties map[string]InputProperty, structType reflect.Type) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			addInputProperties(properties, field.Type)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = propertyName(field.Name)
+		}
+		property := InputProperty{Type: inputType(field.Type)}
+		if property.Type == InputTypeArray {
+			property.Items = inputType(field.Type.Elem())
+		}
+		properties[name] = property
+	}
+}
+
+// propertyName returns the name documents use for a field, i.e. runCommand for RunCommand and id for ID
+func propertyName(fieldName string) string {
+	runes := []rune(fieldName)
+	for i := 0; i < len(runes) && unicode.IsUpper(runes[i]); i++ {
+		// the last capital of an acronym followed by a word starts the word
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// inputType returns the type of the json values a value of the given type is unmarshaled from
+func inputType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return InputTypeString
+	case reflect.Bool:
+		return InputTypeBoolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return InputTypeNumber
+	case reflect.Slice, reflect.Array:
+		return InputTypeArray
+	case reflect.Map, reflect.Struct:
+		return InputTypeObject
+	case reflect.Ptr:
+		return inputType(t.Elem())
+	default:
+		return InputTypeAny
+	}
+}
+
+// Validate checks the input of a step against the schema. The properties are matched regardless of their case,
+// the way they are remarshaled into the input of the plugin.
+func (s InputSchema) Validate(stepName string, input interface{}) error {
+	var problems []string
+	switch input := input.(type) {
+	case nil:
+		problems = s.validateProperties(map[string]interface{}{})
+	case map[string]interface{}:
+		problems = s.validateProperties(input)
+	case []interface{}:
+		// 1.x documents list several inputs for the same plugin
+		for _, element := range input {
+			if err := s.Validate(stepName, element); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		// the input given as a struct is validated the way it's remarshaled
+		var properties map[string]interface{}
+		if err := jsonutil.Remarshal(input, &properties); err != nil {
+			problems = []string{fmt.Sprintf("the input must be an object, not %v", jsonType(input))}
+		} else {
+			problems = s.validateProperties(properties)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Invalid input of step %s: %s", stepName, strings.Join(problems, "; "))
+}
+
+// validateProperties returns the problems of the properties, sorted by property name
+func (s InputSchema) validateProperties(input map[string]interface{}) (problems []string) {
+	names := make([]string, 0, len(input))
+	for name := range input {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, found := s.lookup(name)
+		if !found {
+			problem := fmt.Sprintf("unknown property %s", name)
+			if suggestion := s.suggest(name); suggestion != "" {
+				problem += fmt.Sprintf(", did you mean %s?", suggestion)
+			}
+			problems = append(problems, problem)
+		} else if !property.accepts(input[name]) {
+			expected := property.Type
+			if property.Items != "" && property.Items != InputTypeAny {
+				expected = fmt.Sprintf("%s of %s", property.Type, property.Items)
+			}
+			problems = append(problems, fmt.Sprintf("property %s must be %s, not %s", name, article(expected), jsonType(input[name])))
+		}
+	}
+
+	for _, required := range s.Required {
+		if value, found := lookupValue(input, required); !found || value == nil || value == "" {
+			problems = append(problems, fmt.Sprintf("property %s is required", required))
+		}
+	}
+	return
+}
+
+// lookup returns the property of the schema with the given name regardless of its case
+func (s InputSchema) lookup(name string) (InputProperty, bool) {
+	if property, found := s.Properties[name]; found {
+		return property, true
+	}
+	for known, property := range s.Properties {
+		if strings.EqualFold(known, name) {
+			return property, true
+		}
+	}
+	return InputProperty{}, false
+}
+
+// lookupValue returns the value of the input with the given name regardless of its case
+func lookupValue(input map[string]interface{}, name string) (interface{}, bool) {
+	for key, value := range input {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// suggest returns the property of the schema closest to an unknown property, if any is close enough
+func (s InputSchema) suggest(name string) (suggestion string) {
+	best := maxSuggestionDistance + 1
+	for known := range s.Properties {
+		distance := editDistance(strings.ToLower(name), strings.ToLower(known))
+		if distance < best || (distance == best && known < suggestion) {
+			best = distance
+			suggestion = known
+		}
+	}
+	if best > maxSuggestionDistance || best >= len(name) {
+		return ""
+	}
+	return suggestion
+}
+
+// accepts returns true if the value can be remarshaled into the property
+func (p InputProperty) accepts(value interface{}) bool {
+	if value == nil || p.Type == InputTypeAny {
+		return true
+	}
+	if jsonType(value) != p.Type {
+		return false
+	}
+	if p.Type == InputTypeArray && p.Items != "" && p.Items != InputTypeAny {
+		items := reflect.ValueOf(value)
+		for i := 0; i < items.Len(); i++ {
+			if !(InputProperty{Type: p.Items}).accepts(items.Index(i).Interface()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// jsonType returns the type of a value of the input
+func jsonType(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	return inputType(reflect.TypeOf(value))
+}
+
+// article prefixes the type with the indefinite article
+func article(inputType string) string {
+	if strings.IndexAny(inputType[:1], "aeiou") == 0 {
+		return "an " + inputType
+	}
+	return "a " + inputType
+}
+
+// editDistance returns the number of insertions, deletions and substitutions turning a into b
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+	return result
+}
+
+// validateStepInput validates the input of the step against the input schema registered for its plugin, if any.
+// A step running as another user is rejected when its plugin doesn't accept runAsUser, rather than running as the
+// agent user.
+func validateStepInput(factory Factory, config contracts.Configuration) error {
+	provider, ok := factory.(InputSchemaProvider)
+	if !ok {
+		if config.RunAsUser != "" {
+			return runAsUserNotSupported(config)
+		}
+		return nil
+	}
+	schema := provider.InputSchema()
+	if _, accepted := schema.lookup(runAsUserProperty); config.RunAsUser != "" && !accepted {
+		return runAsUserNotSupported(config)
+	}
+	return schema.Validate(config.PluginID, config.Properties)
+}
+
+// runAsUserNotSupported returns the error of a step running as another user with a plugin that can't
+func runAsUserNotSupported(config contracts.Configuration) error {
+	return fmt.Errorf("Invalid input of step %v: runAsUser %v is not supported by %v, which runs as the agent user",
+		config.PluginID, config.RunAsUser, config.PluginName)
+}
+
+// stepRunAsUser returns the user the commands of the step run as, the runAsUser of its input when its plugin accepts
+// one and the runAsUser of the document otherwise
+func stepRunAsUser(factory Factory, config contracts.Configuration) string {
+	provider, ok := factory.(InputSchemaProvider)
+	if !ok {
+		return config.RunAsUser
+	}
+	if _, accepted := provider.InputSchema().lookup(runAsUserProperty); !accepted {
+		return config.RunAsUser
+	}
+	if input, isMap := config.Properties.(map[string]interface{}); isMap {
+		if value, isSet := lookupValue(input, runAsUserProperty); isSet {
+			if runAsUser, isString := value.(string); isString && runAsUser != "" {
+				return runAsUser
+			}
+		}
+	}
+	return config.RunAsUser
+}
+
+// applyDocumentTimeout returns the input of the step with the execution timeout of the document, which only applies to
+// the plugins whose schema accepts timeoutSeconds and to the inputs that don't set their own timeout
+func applyDocumentTimeout(factory Factory, config contracts.Configuration) interface{} {
+	if config.TimeoutSeconds == 0 {
+		return config.Properties
+	}
+	provider, ok := factory.(InputSchemaProvider)
+	if !ok {
+		return config.Properties
+	}
+	if _, accepted := provider.InputSchema().lookup(timeoutSecondsProperty); !accepted {
+		return config.Properties
+	}
+	return setDefaultTimeout(config.Properties, config.TimeoutSeconds)
+}
+
+// setDefaultTimeout sets the timeout of the input, or of each item of a list of inputs, when not set
+func setDefaultTimeout(input interface{}, timeoutSeconds int) interface{} {
+	switch input := input.(type) {
+	case map[string]interface{}:
+		if _, isSet := lookupValue(input, timeoutSecondsProperty); !isSet {
+			input[timeoutSecondsProperty] = timeoutSeconds
+		}
+	case []interface{}:
+		for i, item := range input {
+			input[i] = setDefaultTimeout(item, timeoutSeconds)
+		}
+	}
+	return input
+}
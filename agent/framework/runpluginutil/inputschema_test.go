@@ -0,0 +1,300 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testScriptInput is parsed the way the input of aws:runShellScript is
+type testScriptInput struct {
+	contracts.PluginInput
+	RunCommand       []string
+	ID               string
+	WorkingDirectory string
+	TimeoutSeconds   interface{}
+	RunAsUser        string
+	SourceInfo       string `json:"sourceInfo"`
+	UpdaterName      string `json:"-"`
+}
+
+// schemaPluginFactoryMock is a mocked plugin factory registering the input schema of its plugin
+type schemaPluginFactoryMock struct {
+	PluginFactoryMock
+}
+
+func (m *schemaPluginFactoryMock) InputSchema() InputSchema {
+	return NewInputSchema(testScriptInput{}, "runCommand")
+}
+
+// testDownloadInput is parsed the way the input of aws:downloadContent is, it doesn't accept a timeout
+type testDownloadInput struct {
+	contracts.PluginInput
+	SourceType      string `json:"sourceType"`
+	SourceInfo      string `json:"sourceInfo"`
+	DestinationPath string `json:"destinationPath"`
+}
+
+// downloadPluginFactoryMock is a mocked plugin factory registering an input schema without timeoutSeconds
+type downloadPluginFactoryMock struct {
+	PluginFactoryMock
+}
+
+func (m *downloadPluginFactoryMock) InputSchema() InputSchema {
+	return NewInputSchema(testDownloadInput{}, "sourceType", "sourceInfo")
+}
+
+func TestNewInputSchema(t *testing.T) {
+	schema := NewInputSchema(testScriptInput{}, "runCommand")
+
+	assert.Equal(t, map[string]InputProperty{
+		"runCommand":       {Type: InputTypeArray, Items: InputTypeString},
+		"id":               {Type: InputTypeString},
+		"workingDirectory": {Type: InputTypeString},
+		"timeoutSeconds":   {Type: InputTypeAny},
+		"runAsUser":        {Type: InputTypeString},
+		"sourceInfo":       {Type: InputTypeString},
+	}, schema.Properties)
+	assert.Equal(t, []string{"runCommand"}, schema.Required)
+}
+
+func TestInputSchemaValidate(t *testing.T) {
+	schema := NewInputSchema(testScriptInput{}, "runCommand")
+
+	testCases := []struct {
+		name  string
+		input interface{}
+		err   string
+	}{
+		{"valid input", map[string]interface{}{"runCommand": []interface{}{"date"}, "timeoutSeconds": "60"}, ""},
+		{"case insensitive properties", map[string]interface{}{"RunCommand": []interface{}{"date"}, "Id": "0.aws:runShellScript"}, ""},
+		{"null property", map[string]interface{}{"runCommand": []interface{}{"date"}, "workingDirectory": nil}, ""},
+		{"list of inputs", []interface{}{
+			map[string]interface{}{"runCommand": []interface{}{"date"}},
+			map[string]interface{}{"runCommand": []interface{}{"ls"}},
+		}, ""},
+		{"input struct", testScriptInput{RunCommand: []string{"date"}}, ""},
+		{"typo", map[string]interface{}{"runCommands": []interface{}{"date"}},
+			"Invalid input of step runShell: unknown property runCommands, did you mean runCommand?; property runCommand is required"},
+		{"unknown property", map[string]interface{}{"runCommand": []interface{}{"date"}, "shell": "bash"},
+			"Invalid input of step runShell: unknown property shell"},
+		{"wrong type", map[string]interface{}{"runCommand": "date"},
+			"Invalid input of step runShell: property runCommand must be an array of string, not string"},
+		{"wrong item type", map[string]interface{}{"runCommand": []interface{}{"date", 1.0}},
+			"Invalid input of step runShell: property runCommand must be an array of string, not array"},
+		{"missing input", nil, "Invalid input of step runShell: property runCommand is required"},
+		{"invalid list element", []interface{}{
+			map[string]interface{}{"runCommand": []interface{}{"date"}},
+			map[string]interface{}{"runCommand": []interface{}{"ls"}, "workingDirectry": "/tmp"},
+		}, "Invalid input of step runShell: unknown property workingDirectry, did you mean workingDirectory?"},
+		{"input not an object", "date", "Invalid input of step runShell: the input must be an object, not string"},
+	}
+
+	for _, testCase := range testCases {
+		err := schema.Validate("runShell", testCase.input)
+		if testCase.err == "" {
+			assert.NoError(t, err, testCase.name)
+		} else if assert.Error(t, err, testCase.name) {
+			assert.Equal(t, testCase.err, err.Error(), testCase.name)
+		}
+	}
+}
+
+func TestInputSchemaSuggest(t *testing.T) {
+	schema := NewInputSchema(testScriptInput{})
+
+	assert.Equal(t, "runCommand", schema.suggest("runcommands"))
+	assert.Equal(t, "timeoutSeconds", schema.suggest("timeoutSecond"))
+	assert.Equal(t, "", schema.suggest("commands"))
+	assert.Equal(t, "", schema.suggest("d"))
+}
+
+func TestPropertyName(t *testing.T) {
+	assert.Equal(t, "runCommand", propertyName("RunCommand"))
+	assert.Equal(t, "id", propertyName("ID"))
+	assert.Equal(t, "directoryOU", propertyName("DirectoryOU"))
+	assert.Equal(t, "dnsIpAddresses", propertyName("DnsIpAddresses"))
+	assert.Equal(t, "cpuShares", propertyName("CpuShares"))
+}
+
+func TestRunPluginsInvalidInput(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	plugin := new(PluginMock)
+	pluginFactory := new(schemaPluginFactoryMock)
+	pluginFactory.On("Create", mock.Anything).Return(plugin, nil)
+	pluginRegistry := PluginRegistry{testPlugin1: pluginFactory}
+	pluginStates := []contracts.PluginState{
+		{
+			Name: testPlugin1,
+			Id:   testPlugin1,
+			Configuration: contracts.Configuration{
+				PluginID:   testPlugin1,
+				PluginName: testPlugin1,
+				Properties: map[string]interface{}{"runCommands": []interface{}{"date"}},
+			},
+		},
+	}
+
+	ch := make(chan contracts.PluginResult, 1)
+	outputs := RunPlugins(ctx, pluginStates, contracts.IOConfiguration{}, pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	plugin.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Status)
+	assert.Equal(t, "Invalid input of step plugin1: unknown property runCommands, did you mean runCommand?; property runCommand is required",
+		outputs[testPlugin1].Output)
+}
+
+func TestApplyDocumentTimeout(t *testing.T) {
+	scriptFactory := new(schemaPluginFactoryMock)
+	config := contracts.Configuration{
+		TimeoutSeconds: 600,
+		Properties: []interface{}{
+			map[string]interface{}{"runCommand": []interface{}{"date"}},
+			map[string]interface{}{"runCommand": []interface{}{"date"}, "TimeoutSeconds": "60"},
+		},
+	}
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"runCommand": []interface{}{"date"}, "timeoutSeconds": 600},
+		map[string]interface{}{"runCommand": []interface{}{"date"}, "TimeoutSeconds": "60"},
+	}, applyDocumentTimeout(scriptFactory, config))
+
+	// the plugins that don't accept a timeout keep their input, which passes the validation of their schema
+	downloadFactory := new(downloadPluginFactoryMock)
+	config = contracts.Configuration{
+		PluginID:       "download",
+		TimeoutSeconds: 600,
+		Properties:     map[string]interface{}{"sourceType": "S3", "sourceInfo": "{}"},
+	}
+	config.Properties = applyDocumentTimeout(downloadFactory, config)
+	assert.Equal(t, map[string]interface{}{"sourceType": "S3", "sourceInfo": "{}"}, config.Properties)
+	assert.NoError(t, validateStepInput(downloadFactory, config))
+
+	// without a document timeout the input is unchanged
+	config = contracts.Configuration{Properties: map[string]interface{}{"runCommand": []interface{}{"date"}}}
+	assert.Equal(t, map[string]interface{}{"runCommand": []interface{}{"date"}}, applyDocumentTimeout(scriptFactory, config))
+}
+
+func TestRunPluginsDocumentTimeout(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	scriptPlugin := new(PluginMock)
+	scriptPlugin.On("Execute", ctx, mock.Anything, cancelFlag, mock.Anything).Return()
+	scriptFactory := new(schemaPluginFactoryMock)
+	scriptFactory.On("Create", mock.Anything).Return(scriptPlugin, nil)
+	downloadPlugin := new(PluginMock)
+	downloadPlugin.On("Execute", ctx, mock.Anything, cancelFlag, mock.Anything).Return()
+	downloadFactory := new(downloadPluginFactoryMock)
+	downloadFactory.On("Create", mock.Anything).Return(downloadPlugin, nil)
+	pluginRegistry := PluginRegistry{testPlugin1: scriptFactory, testPlugin2: downloadFactory}
+	pluginStates := []contracts.PluginState{
+		{
+			Name: testPlugin1,
+			Id:   testPlugin1,
+			Configuration: contracts.Configuration{
+				PluginID:       testPlugin1,
+				PluginName:     testPlugin1,
+				TimeoutSeconds: 600,
+				Properties:     map[string]interface{}{"runCommand": []interface{}{"date"}},
+			},
+		},
+		{
+			Name: testPlugin2,
+			Id:   testPlugin2,
+			Configuration: contracts.Configuration{
+				PluginID:       testPlugin2,
+				PluginName:     testPlugin2,
+				TimeoutSeconds: 600,
+				Properties:     map[string]interface{}{"sourceType": "S3", "sourceInfo": "{}"},
+			},
+		},
+	}
+
+	ch := make(chan contracts.PluginResult, 2)
+	outputs := RunPlugins(ctx, pluginStates, contracts.IOConfiguration{}, pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	// both steps pass the validation of their input, only the step whose plugin accepts a timeout gets it
+	scriptPlugin.AssertNumberOfCalls(t, "Execute", 1)
+	downloadPlugin.AssertNumberOfCalls(t, "Execute", 1)
+	scriptConfig := scriptPlugin.Calls[0].Arguments.Get(1).(contracts.Configuration)
+	assert.Equal(t, 600, scriptConfig.Properties.(map[string]interface{})["timeoutSeconds"])
+	downloadConfig := downloadPlugin.Calls[0].Arguments.Get(1).(contracts.Configuration)
+	_, isSet := downloadConfig.Properties.(map[string]interface{})["timeoutSeconds"]
+	assert.False(t, isSet)
+	assert.NotEqual(t, contracts.ResultStatusFailed, outputs[testPlugin2].Status)
+}
+
+func TestRunPluginsRunAsUserNotSupported(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	scriptPlugin := new(PluginMock)
+	scriptPlugin.On("Execute", ctx, mock.Anything, cancelFlag, mock.Anything).Return()
+	scriptFactory := new(schemaPluginFactoryMock)
+	scriptFactory.On("Create", mock.Anything).Return(scriptPlugin, nil)
+	downloadPlugin := new(PluginMock)
+	downloadFactory := new(downloadPluginFactoryMock)
+	downloadFactory.On("Create", mock.Anything).Return(downloadPlugin, nil)
+	pluginRegistry := PluginRegistry{testPlugin1: scriptFactory, testPlugin2: downloadFactory}
+	pluginStates := []contracts.PluginState{
+		{
+			Name: testPlugin1,
+			Id:   testPlugin1,
+			Configuration: contracts.Configuration{
+				PluginID:   testPlugin1,
+				PluginName: testPlugin1,
+				RunAsUser:  "deploy",
+				Properties: map[string]interface{}{"runCommand": []interface{}{"date"}},
+			},
+		},
+		{
+			Name: testPlugin2,
+			Id:   testPlugin2,
+			Configuration: contracts.Configuration{
+				PluginID:   testPlugin2,
+				PluginName: testPlugin2,
+				RunAsUser:  "deploy",
+				Properties: map[string]interface{}{"sourceType": "S3", "sourceInfo": "{}"},
+			},
+		},
+	}
+
+	ch := make(chan contracts.PluginResult, 2)
+	outputs := RunPlugins(ctx, pluginStates, contracts.IOConfiguration{}, pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	// the step whose plugin can't run as the user fails instead of running as the agent user
+	scriptPlugin.AssertNumberOfCalls(t, "Execute", 1)
+	downloadPlugin.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.NotEqual(t, contracts.ResultStatusFailed, outputs[testPlugin1].Status)
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin2].Status)
+	assert.Equal(t, "Invalid input of step plugin2: runAsUser deploy is not supported by plugin2, which runs as the agent user",
+		outputs[testPlugin2].Output)
+}
@@ -0,0 +1,262 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/updateutil"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+const (
+	platformTypeVariable = "platformType"
+
+	// envVariablePrefix prefixes the precondition operands that refer to an environment variable, e.g. env:HOME
+	envVariablePrefix = "env:"
+)
+
+// Kinds of precondition operands, a comparison needs exactly one operand of a higher kind than the other one
+const (
+	literalOperand = iota
+	parameterOperand
+	variableOperand
+)
+
+// preconditionVariables resolves the instance properties that can be used as precondition operands.
+// Created as a variable to allow for better testability.
+var preconditionVariables = map[string]func(log log.T) (string, error){
+	platformTypeVariable: platform.PlatformType,
+	"platformName":       platform.PlatformName,
+	"platformVersion":    platform.PlatformVersion,
+	"instanceType": func(log log.T) (string, error) {
+		return platform.InstanceType()
+	},
+	"region": func(log log.T) (string, error) {
+		return platform.Region()
+	},
+	"agentVersion": func(log log.T) (string, error) {
+		return version.Version, nil
+	},
+}
+
+// stepOutputRegex matches the reference to the output of an earlier step, i.e. {{ stepName.outputName }}
+var stepOutputRegex = regexp.MustCompile(`^{{\s*([\w.-]+\.\w+)\s*}}$`)
+
+// preconditionOperand is a precondition argument along with its kind and value
+type preconditionOperand struct {
+	kind  int
+	value string
+	name  string
+}
+
+// unevaluatedPreconditions are the preconditions that can't be evaluated, either because they aren't recognized
+// or because they reference step outputs that no earlier step produced
+type unevaluatedPreconditions struct {
+	unrecognized   []string
+	missingOutputs []string
+}
+
+// isEmpty returns true if all the preconditions could be evaluated
+func (u unevaluatedPreconditions) isEmpty() bool {
+	return len(u.unrecognized) == 0 && len(u.missingOutputs) == 0
+}
+
+// add appends the preconditions that couldn't be evaluated in another precondition
+func (u *unevaluatedPreconditions) add(other unevaluatedPreconditions) {
+	u.unrecognized = append(u.unrecognized, other.unrecognized...)
+	u.missingOutputs = append(u.missingOutputs, other.missingOutputs...)
+}
+
+// Evaluate precondition and return precondition result and the preconditions that can't be evaluated (if any).
+// Unevaluated preconditions don't prevent the step from being skipped when the rest of the preconditions don't hold.
+func evaluatePreconditions(
+	log log.T,
+	preconditions []contracts.Precondition,
+	stepOutputs map[string]string,
+) (bool, unevaluatedPreconditions) {
+
+	// preconditions at the top level of a step must all hold
+	isAllowed, unevaluated := evaluateAll(log, preconditions, stepOutputs)
+	return isAllowed || !unevaluated.isEmpty(), unevaluated
+}
+
+// evaluatePrecondition evaluates a single precondition. The result is meaningless when unevaluated preconditions
+// are returned, in which case the precondition is neither known to hold nor known not to hold.
+func evaluatePrecondition(log log.T, precondition contracts.Precondition, stepOutputs map[string]string) (bool, unevaluatedPreconditions) {
+	switch precondition.Operator {
+	case contracts.PreconditionAnd, contracts.PreconditionOr, contracts.PreconditionNot:
+		if len(precondition.Arguments) > 0 || len(precondition.Conditions) == 0 ||
+			(precondition.Operator == contracts.PreconditionNot && len(precondition.Conditions) != 1) {
+			return false, unrecognizedPrecondition(precondition)
+		}
+		switch precondition.Operator {
+		case contracts.PreconditionAnd:
+			return evaluateAll(log, precondition.Conditions, stepOutputs)
+		case contracts.PreconditionOr:
+			return evaluateAny(log, precondition.Conditions, stepOutputs)
+		default:
+			result, unevaluated := evaluatePrecondition(log, precondition.Conditions[0], stepOutputs)
+			return !result, unevaluated
+		}
+
+	case contracts.PreconditionStringEquals,
+		contracts.PreconditionStringNotEquals,
+		contracts.PreconditionStringLike,
+		contracts.PreconditionVersionGreaterThanOrEqual:
+		return evaluateComparison(log, precondition, stepOutputs)
+
+	default:
+		return false, unrecognizedPrecondition(precondition)
+	}
+}
+
+// evaluateAll returns false if any precondition doesn't hold, even if other preconditions can't be evaluated
+func evaluateAll(log log.T, preconditions []contracts.Precondition, stepOutputs map[string]string) (bool, unevaluatedPreconditions) {
+	var allUnevaluated unevaluatedPreconditions
+	for _, precondition := range preconditions {
+		result, unevaluated := evaluatePrecondition(log, precondition, stepOutputs)
+		if unevaluated.isEmpty() && !result {
+			return false, unevaluatedPreconditions{}
+		}
+		allUnevaluated.add(unevaluated)
+	}
+	return true, allUnevaluated
+}
+
+// evaluateAny returns true if any precondition holds, even if other preconditions can't be evaluated
+func evaluateAny(log log.T, preconditions []contracts.Precondition, stepOutputs map[string]string) (bool, unevaluatedPreconditions) {
+	var allUnevaluated unevaluatedPreconditions
+	for _, precondition := range preconditions {
+		result, unevaluated := evaluatePrecondition(log, precondition, stepOutputs)
+		if unevaluated.isEmpty() && result {
+			return true, unevaluatedPreconditions{}
+		}
+		allUnevaluated.add(unevaluated)
+	}
+	return false, allUnevaluated
+}
+
+// evaluateComparison compares the variable operand of the precondition with its other operand.
+// Variable and value can be in any order, i.e. both "StringEquals": ["platformType", "Windows"]
+// and "StringEquals": ["Windows", "platformType"] are valid
+func evaluateComparison(log log.T, precondition contracts.Precondition, stepOutputs map[string]string) (bool, unevaluatedPreconditions) {
+	unrecognized := unrecognizedPrecondition(precondition)
+	if len(precondition.Arguments) != 2 || len(precondition.Conditions) > 0 {
+		return false, unrecognized
+	}
+
+	variable, isKnown := getPreconditionOperand(log, precondition.Arguments[0], stepOutputs)
+	if !isKnown {
+		return false, unevaluatedPreconditions{missingOutputs: []string{variable.name}}
+	}
+	value, isKnown := getPreconditionOperand(log, precondition.Arguments[1], stepOutputs)
+	if !isKnown {
+		return false, unevaluatedPreconditions{missingOutputs: []string{value.name}}
+	}
+	if variable.kind == value.kind {
+		// either none or both operands are variables
+		return false, unrecognized
+	} else if variable.kind < value.kind {
+		variable, value = value, variable
+	}
+
+	expected := value.value
+	if variable.name == platformTypeVariable {
+		// platform type of the instance is lower case
+		expected = strings.ToLower(expected)
+	}
+	log.Debugf("Precondition operand %s = %s", variable.name, variable.value)
+
+	switch precondition.Operator {
+	case contracts.PreconditionStringEquals:
+		return variable.value == expected, unevaluatedPreconditions{}
+	case contracts.PreconditionStringNotEquals:
+		return variable.value != expected, unevaluatedPreconditions{}
+	case contracts.PreconditionStringLike:
+		return globMatch(expected, variable.value), unevaluatedPreconditions{}
+	default:
+		if _, err := updateutil.VersionCompare(expected, expected); err != nil {
+			return false, unrecognized
+		}
+		compare, err := updateutil.VersionCompare(variable.value, expected)
+		if err != nil {
+			log.Errorf("%s is not a version: %v", variable.name, err)
+			return false, unevaluatedPreconditions{}
+		}
+		return compare >= 0, unevaluatedPreconditions{}
+	}
+}
+
+// getPreconditionOperand returns the kind and value of a precondition argument, false is returned when the argument
+// references a step output that isn't known, in which case the name of the operand is the referenced output
+func getPreconditionOperand(log log.T, argument contracts.PreconditionArgument, stepOutputs map[string]string) (preconditionOperand, bool) {
+	name := argument.InitialArgumentValue
+	if resolve, isVariable := preconditionVariables[name]; isVariable {
+		value, err := resolve(log)
+		if err != nil {
+			log.Errorf("Failed to get %s for precondition: %v", name, err)
+		}
+		return preconditionOperand{kind: variableOperand, value: value, name: name}, true
+	}
+
+	if strings.HasPrefix(name, envVariablePrefix) && len(name) > len(envVariablePrefix) {
+		value := os.Getenv(strings.TrimPrefix(name, envVariablePrefix))
+		return preconditionOperand{kind: variableOperand, value: value, name: name}, true
+	}
+
+	if match := stepOutputRegex.FindStringSubmatch(argument.ResolvedArgumentValue); match != nil {
+		value, isKnown := stepOutputs[match[1]]
+		return preconditionOperand{kind: variableOperand, value: value, name: match[1]}, isKnown
+	}
+
+	if argument.ResolvedArgumentValue != name {
+		// document parameters have been replaced with their value
+		return preconditionOperand{kind: parameterOperand, value: argument.ResolvedArgumentValue, name: name}, true
+	}
+	return preconditionOperand{kind: literalOperand, value: name, name: name}, true
+}
+
+// globMatch returns true if the value matches the pattern, where * matches any sequence of characters
+// and ? matches any single character
+func globMatch(pattern string, value string) bool {
+	expression := regexp.QuoteMeta(pattern)
+	expression = strings.Replace(expression, `\*`, `.*`, -1)
+	expression = strings.Replace(expression, `\?`, `.`, -1)
+	matched, _ := regexp.MatchString("^"+expression+"$", value)
+	return matched
+}
+
+// unrecognizedPrecondition returns the precondition as the only unrecognized one
+func unrecognizedPrecondition(precondition contracts.Precondition) unevaluatedPreconditions {
+	return unevaluatedPreconditions{unrecognized: []string{formatPrecondition(precondition)}}
+}
+
+// formatPrecondition returns the precondition as written in the document, e.g. "StringEquals": [platformType Linux]
+func formatPrecondition(precondition contracts.Precondition) string {
+	var operands []string
+	for _, argument := range precondition.Arguments {
+		operands = append(operands, argument.InitialArgumentValue)
+	}
+	for _, condition := range precondition.Conditions {
+		operands = append(operands, "{"+formatPrecondition(condition)+"}")
+	}
+	return fmt.Sprintf("\"%s\": %v", precondition.Operator, operands)
+}
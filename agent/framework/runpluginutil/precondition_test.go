@@ -0,0 +1,199 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// newPrecondition creates a comparison precondition whose arguments don't reference document parameters
+func newPrecondition(operator string, arguments ...string) contracts.Precondition {
+	precondition := contracts.Precondition{Operator: operator}
+	for _, argument := range arguments {
+		precondition.Arguments = append(precondition.Arguments, contracts.PreconditionArgument{
+			InitialArgumentValue:  argument,
+			ResolvedArgumentValue: argument,
+		})
+	}
+	return precondition
+}
+
+// newCompositePrecondition creates an And, Or or Not precondition
+func newCompositePrecondition(operator string, conditions ...contracts.Precondition) contracts.Precondition {
+	return contracts.Precondition{Operator: operator, Conditions: conditions}
+}
+
+// mockPreconditionVariables replaces the instance properties with fixed values and returns a function restoring them
+func mockPreconditionVariables() func() {
+	original := preconditionVariables
+	preconditionVariables = map[string]func(log log.T) (string, error){}
+	values := map[string]string{
+		"platformType":    "linux",
+		"platformName":    "Ubuntu",
+		"platformVersion": "18.04",
+		"instanceType":    "t2.micro",
+		"region":          "us-east-1",
+		"agentVersion":    "2.2.0.0",
+	}
+	for name, value := range values {
+		value := value
+		preconditionVariables[name] = func(log log.T) (string, error) { return value, nil }
+	}
+	return func() { preconditionVariables = original }
+}
+
+func TestEvaluatePreconditions_Operators(t *testing.T) {
+	defer mockPreconditionVariables()()
+	logger := log.NewMockLog()
+
+	testCases := []struct {
+		precondition contracts.Precondition
+		isAllowed    bool
+	}{
+		{newPrecondition("StringEquals", "platformType", "Linux"), true},
+		{newPrecondition("StringEquals", "platformName", "Ubuntu"), true},
+		{newPrecondition("StringEquals", "platformName", "ubuntu"), false},
+		{newPrecondition("StringEquals", "us-east-1", "region"), true},
+		{newPrecondition("StringNotEquals", "instanceType", "t2.micro"), false},
+		{newPrecondition("StringNotEquals", "instanceType", "t2.large"), true},
+		{newPrecondition("StringLike", "instanceType", "t2.*"), true},
+		{newPrecondition("StringLike", "instanceType", "t?.micro"), true},
+		{newPrecondition("StringLike", "instanceType", "m*"), false},
+		{newPrecondition("VersionGreaterThanOrEqual", "platformVersion", "16.04"), true},
+		{newPrecondition("VersionGreaterThanOrEqual", "platformVersion", "18.04"), true},
+		{newPrecondition("VersionGreaterThanOrEqual", "platformVersion", "18.10"), false},
+		{newPrecondition("VersionGreaterThanOrEqual", "2.10", "agentVersion"), false},
+	}
+
+	for _, testCase := range testCases {
+		isAllowed, unevaluated := evaluatePreconditions(logger, []contracts.Precondition{testCase.precondition}, nil)
+		assert.True(t, unevaluated.isEmpty(), formatPrecondition(testCase.precondition))
+		assert.Equal(t, testCase.isAllowed, isAllowed, formatPrecondition(testCase.precondition))
+	}
+}
+
+func TestEvaluatePreconditions_Composition(t *testing.T) {
+	defer mockPreconditionVariables()()
+	logger := log.NewMockLog()
+
+	ubuntu := newPrecondition("StringEquals", "platformName", "Ubuntu")
+	bionic := newPrecondition("VersionGreaterThanOrEqual", "platformVersion", "18.04")
+	amazon := newPrecondition("StringEquals", "platformName", "Amazon Linux")
+
+	testCases := []struct {
+		precondition contracts.Precondition
+		isAllowed    bool
+	}{
+		{newCompositePrecondition("And", ubuntu, bionic), true},
+		{newCompositePrecondition("And", ubuntu, amazon), false},
+		{newCompositePrecondition("Or", amazon, ubuntu), true},
+		{newCompositePrecondition("Or", amazon), false},
+		{newCompositePrecondition("Not", amazon), true},
+		{newCompositePrecondition("Not", newCompositePrecondition("Or", ubuntu, amazon)), false},
+	}
+
+	for _, testCase := range testCases {
+		isAllowed, unevaluated := evaluatePreconditions(logger, []contracts.Precondition{testCase.precondition}, nil)
+		assert.True(t, unevaluated.isEmpty(), formatPrecondition(testCase.precondition))
+		assert.Equal(t, testCase.isAllowed, isAllowed, formatPrecondition(testCase.precondition))
+	}
+}
+
+func TestEvaluatePreconditions_ParametersAndStepOutputs(t *testing.T) {
+	defer mockPreconditionVariables()()
+	logger := log.NewMockLog()
+	stepOutputs := map[string]string{"step1.status": "Success", "step1.output": "enabled"}
+
+	parameter := contracts.PreconditionArgument{InitialArgumentValue: "{{ distribution }}", ResolvedArgumentValue: "Ubuntu"}
+	parameterEqualsLiteral := contracts.Precondition{
+		Operator:  "StringEquals",
+		Arguments: []contracts.PreconditionArgument{newPrecondition("", "Ubuntu").Arguments[0], parameter},
+	}
+	variableEqualsParameter := contracts.Precondition{
+		Operator:  "StringEquals",
+		Arguments: []contracts.PreconditionArgument{parameter, newPrecondition("", "platformName").Arguments[0]},
+	}
+
+	testCases := []struct {
+		precondition contracts.Precondition
+		isAllowed    bool
+	}{
+		{parameterEqualsLiteral, true},
+		{variableEqualsParameter, true},
+		{newPrecondition("StringEquals", "{{ step1.status }}", "Success"), true},
+		{newPrecondition("StringLike", "{{step1.output}}", "disabled"), false},
+	}
+
+	for _, testCase := range testCases {
+		isAllowed, unevaluated := evaluatePreconditions(logger, []contracts.Precondition{testCase.precondition}, stepOutputs)
+		assert.True(t, unevaluated.isEmpty(), formatPrecondition(testCase.precondition))
+		assert.Equal(t, testCase.isAllowed, isAllowed, formatPrecondition(testCase.precondition))
+	}
+}
+
+func TestEvaluatePreconditions_EnvironmentVariable(t *testing.T) {
+	logger := log.NewMockLog()
+	os.Setenv("SSM_PRECONDITION_TEST", "value")
+	defer os.Unsetenv("SSM_PRECONDITION_TEST")
+
+	isAllowed, unevaluated := evaluatePreconditions(logger,
+		[]contracts.Precondition{newPrecondition("StringEquals", "env:SSM_PRECONDITION_TEST", "value")}, nil)
+	assert.True(t, unevaluated.isEmpty())
+	assert.True(t, isAllowed)
+
+	isAllowed, unevaluated = evaluatePreconditions(logger,
+		[]contracts.Precondition{newPrecondition("StringEquals", "env:SSM_PRECONDITION_UNSET", "value")}, nil)
+	assert.True(t, unevaluated.isEmpty())
+	assert.False(t, isAllowed)
+}
+
+func TestEvaluatePreconditions_Unrecognized(t *testing.T) {
+	defer mockPreconditionVariables()()
+	logger := log.NewMockLog()
+
+	testCases := []struct {
+		precondition contracts.Precondition
+		unrecognized string
+	}{
+		{newPrecondition("VersionGreaterThanOrEqual", "platformVersion", "latest"), `"VersionGreaterThanOrEqual": [platformVersion latest]`},
+		{newCompositePrecondition("Not"), `"Not": []`},
+		{
+			newCompositePrecondition("Not", newPrecondition("foo", "platformName")),
+			`"foo": [platformName]`,
+		},
+		{
+			newCompositePrecondition("Not", newPrecondition("StringEquals", "platformName", "Ubuntu"), newPrecondition("StringEquals", "region", "us-east-1")),
+			`"Not": [{"StringEquals": [platformName Ubuntu]} {"StringEquals": [region us-east-1]}]`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		isAllowed, unevaluated := evaluatePreconditions(logger, []contracts.Precondition{testCase.precondition}, nil)
+		assert.True(t, isAllowed)
+		assert.Equal(t, unevaluatedPreconditions{unrecognized: []string{testCase.unrecognized}}, unevaluated)
+	}
+
+	// a precondition that doesn't hold takes precedence over unrecognized ones
+	isAllowed, unevaluated := evaluatePreconditions(logger, []contracts.Precondition{
+		newCompositePrecondition("Or", newPrecondition("foo"), newPrecondition("StringEquals", "platformName", "Ubuntu")),
+		newPrecondition("StringEquals", "region", "eu-west-1"),
+	}, nil)
+	assert.False(t, isAllowed)
+	assert.True(t, unevaluated.isEmpty())
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"os"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// decoupling the file ownership for easy testability
+var giveDirToRunAsUser = executers.GiveToRunAsUser
+
+// runAsDirectories returns the orchestration directory of the step and the directory of its output files. Both are the
+// same directory for the steps of a v1 document, whose orchestration directory is shared by the steps of the plugin.
+func runAsDirectories(config contracts.Configuration, ioConfig contracts.IOConfiguration, pluginName string, propID string) (dirs []string) {
+	if ioConfig.OrchestrationDirectory != "" {
+		dirs = append(dirs, fileutil.BuildPath(ioConfig.OrchestrationDirectory, pluginName, propID))
+	}
+	if config.PluginName != config.PluginID && config.OrchestrationDirectory != "" {
+		dirs = append(dirs, config.OrchestrationDirectory)
+	}
+	return dirs
+}
+
+// reclaimFromRunAsUser removes the directories a previous attempt of the step gave to the user, so that the agent
+// doesn't write through the entries the user left in them
+func reclaimFromRunAsUser(log log.T, dirs []string) {
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Warnf("failed to remove %v: %v", dir, err)
+		}
+	}
+}
+
+// giveToRunAsUser gives the directories of the step to the user the step ran as, the agent no longer writing to them
+func giveToRunAsUser(log log.T, runAsUser string, dirs []string) {
+	for _, dir := range dirs {
+		if !fileutil.Exists(dir) {
+			continue
+		}
+		if err := giveDirToRunAsUser(dir, runAsUser); err != nil {
+			log.Errorf("failed to give %v to %v: %v", dir, runAsUser, err)
+		}
+	}
+}
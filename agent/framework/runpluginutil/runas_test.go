@@ -0,0 +1,128 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStepRunAsUser(t *testing.T) {
+	scriptFactory := new(schemaPluginFactoryMock)
+	downloadFactory := new(downloadPluginFactoryMock)
+
+	// the user of the step overrides the user of the document
+	config := contracts.Configuration{RunAsUser: "deploy", Properties: map[string]interface{}{"RunAsUser": "backup"}}
+	assert.Equal(t, "backup", stepRunAsUser(scriptFactory, config))
+	config.Properties = map[string]interface{}{"runCommand": []interface{}{"date"}}
+	assert.Equal(t, "deploy", stepRunAsUser(scriptFactory, config))
+
+	// a plugin that doesn't accept runAsUser ignores it
+	config.Properties = map[string]interface{}{"runAsUser": "backup"}
+	assert.Equal(t, "deploy", stepRunAsUser(downloadFactory, config))
+	assert.Equal(t, "deploy", stepRunAsUser(new(PluginFactoryMock), config))
+}
+
+func TestRunAsDirectories(t *testing.T) {
+	ioConfig := contracts.IOConfiguration{OrchestrationDirectory: "orchestration"}
+
+	// the orchestration directory of a v1 step is its output directory
+	config := contracts.Configuration{PluginName: "aws:runShellScript", PluginID: "aws:runShellScript", OrchestrationDirectory: "orchestration/awsrunShellScript"}
+	assert.Equal(t, []string{filepath.Join("orchestration", "awsrunShellScript", "0.awsrunShellScript")},
+		runAsDirectories(config, ioConfig, "aws:runShellScript", "0.aws:runShellScript"))
+
+	config = contracts.Configuration{PluginName: "aws:runShellScript", PluginID: "deploy", OrchestrationDirectory: "orchestration/deploy"}
+	assert.Equal(t, []string{filepath.Join("orchestration", "awsrunShellScript", "deploy"), "orchestration/deploy"},
+		runAsDirectories(config, ioConfig, "aws:runShellScript", "deploy"))
+
+	// nothing is given away without an orchestration directory
+	assert.Empty(t, runAsDirectories(contracts.Configuration{PluginName: "aws:runShellScript", PluginID: "deploy"}, contracts.IOConfiguration{}, "aws:runShellScript", "deploy"))
+}
+
+func TestRunPluginsGivesDirectoriesToRunAsUser(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+
+	orchestrationDir, err := ioutil.TempDir("", "runpluginutil")
+	assert.NoError(t, err)
+	defer os.RemoveAll(orchestrationDir)
+	stepDir := filepath.Join(orchestrationDir, "step1")
+	outputDir := filepath.Join(orchestrationDir, testPlugin1, "step1")
+	// a previous attempt left an entry of the user in the directory of the step
+	assert.NoError(t, fileutil.MakeDirs(stepDir))
+	target := filepath.Join(orchestrationDir, "target")
+	assert.NoError(t, ioutil.WriteFile(target, []byte("target"), 0600))
+	assert.NoError(t, os.Symlink(target, filepath.Join(stepDir, "_script.sh")))
+
+	var givenDirs []string
+	var givenUser string
+	outputWritten := true
+	giveDirToRunAsUser = func(dir string, runAsUser string) error {
+		givenDirs = append(givenDirs, dir)
+		givenUser = runAsUser
+		outputWritten = outputWritten && fileutil.Exists(filepath.Join(outputDir, "stdout"))
+		return nil
+	}
+	defer func() { giveDirToRunAsUser = executers.GiveToRunAsUser }()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	scriptPlugin := new(PluginMock)
+	scriptPlugin.On("Execute", ctx, mock.Anything, cancelFlag, mock.Anything).Return().Run(func(mock.Arguments) {
+		// the plugin writes its script to the orchestration directory of the step
+		assert.NoError(t, fileutil.MakeDirs(stepDir))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(stepDir, "_script.sh"), []byte("date"), 0600))
+	})
+	scriptFactory := new(schemaPluginFactoryMock)
+	scriptFactory.On("Create", mock.Anything).Return(scriptPlugin, nil)
+	pluginStates := []contracts.PluginState{
+		{
+			Name: testPlugin1,
+			Id:   "step1",
+			Configuration: contracts.Configuration{
+				PluginID:               "step1",
+				PluginName:             testPlugin1,
+				OrchestrationDirectory: stepDir,
+				RunAsUser:              "deploy",
+				Properties:             map[string]interface{}{"runCommand": []interface{}{"date"}, "runAsUser": "backup"},
+			},
+		},
+	}
+
+	ch := make(chan contracts.PluginResult, 1)
+	RunPlugins(ctx, pluginStates, contracts.IOConfiguration{OrchestrationDirectory: orchestrationDir}, PluginRegistry{testPlugin1: scriptFactory}, ch, cancelFlag)
+	close(ch)
+
+	// the directories are reclaimed before the step runs and given to the user of the step once its output is closed
+	scriptPlugin.AssertNumberOfCalls(t, "Execute", 1)
+	assert.Equal(t, "backup", scriptPlugin.Calls[0].Arguments.Get(1).(contracts.Configuration).RunAsUser)
+	assert.Equal(t, []string{outputDir, stepDir}, givenDirs)
+	assert.Equal(t, "backup", givenUser)
+	assert.True(t, outputWritten)
+	info, err := os.Lstat(filepath.Join(stepDir, "_script.sh"))
+	assert.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	content, _ := ioutil.ReadFile(target)
+	assert.Equal(t, "target", string(content))
+}
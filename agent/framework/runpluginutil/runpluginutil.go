@@ -26,7 +26,6 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
-	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
@@ -37,6 +36,16 @@ const (
 	failStep    string = "fail"
 )
 
+const (
+	// retryMaxBackoff caps the delay between two attempts of a step
+	retryMaxBackoff = 5 * time.Minute
+	// retryPollInterval is how often the cancel flag is checked while waiting for the next attempt
+	retryPollInterval = 100 * time.Millisecond
+)
+
+// retryBaseBackoff is the delay before the second attempt of a step, it doubles for every following attempt
+var retryBaseBackoff = 2 * time.Second
+
 type T interface {
 	Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler)
 }
@@ -90,6 +99,9 @@ func RunPlugins(
 
 	pluginOutputs = make(map[string]*contracts.PluginResult)
 
+	// exitStepID is set to the step that stopped the document through its onFailure action
+	var exitStepID string
+
 	for _, pluginState := range plugins {
 		pluginID := pluginState.Id     // the identifier of the plugin
 		pluginName := pluginState.Name // the name of the plugin
@@ -119,6 +131,21 @@ func RunPlugins(
 		default:
 			context.Log().Debugf("plugin - %v already executed, skipping...",
 				pluginName)
+			// a resumed document must not run the steps following a step that exited on failure
+			if exitStepID == "" && (pluginOutput.ExitedOnFailure || isExitOnFailure(pluginState.Configuration, pluginOutput.Status)) {
+				exitStepID = pluginID
+			}
+			continue
+		}
+
+		if exitStepID != "" {
+			message := fmt.Sprintf("Step execution skipped due to onFailure of step %s. Step name: %s", exitStepID, pluginID)
+			context.Log().Info(message)
+			pluginOutputs[pluginID].Status = contracts.ResultStatusSkipped
+			pluginOutputs[pluginID].Code = 0
+			pluginOutputs[pluginID].Output = message
+			pluginOutputs[pluginID].EndDateTime = time.Now()
+			resChan <- *pluginOutputs[pluginID]
 			continue
 		}
 
@@ -141,6 +168,7 @@ func RunPlugins(
 		p, pluginHandlerFound := pluginRegistry[pluginName]
 
 		isKnown, isSupported, _ := isSupportedPlugin(context.Log(), pluginName)
+		stepOutputs := getStepOutputs(pluginOutputs, pluginID)
 		operation, logMessage := getStepExecutionOperation(
 			context.Log(),
 			pluginName,
@@ -149,18 +177,31 @@ func RunPlugins(
 			isSupported,
 			pluginHandlerFound,
 			configuration.IsPreconditionEnabled,
-			configuration.Preconditions)
+			configuration.Preconditions,
+			stepOutputs)
 
 		switch operation {
 		case executeStep:
-			context.Log().Infof("Running plugin %s", pluginName)
-			r = runPlugin(context, p, pluginName, configuration, cancelFlag, ioConfig)
+			configuration.Properties = replaceStepOutputs(context.Log(), configuration.Properties, stepOutputs)
+			configuration.Properties = applyDocumentTimeout(p, configuration)
+			if configuration.DryRun {
+				r = dryRunPlugin(context, p, pluginID, configuration)
+			} else if err := validateStepInput(p, configuration); err != nil {
+				context.Log().Error(err)
+				r = contracts.PluginResult{Status: contracts.ResultStatusFailed, Code: 1, Error: err, Output: err.Error()}
+			} else {
+				context.Log().Infof("Running plugin %s", pluginName)
+				r = runPluginWithAttempts(context, p, pluginName, configuration, cancelFlag, streamPluginOutput(ioConfig, pluginID), pluginOutputs[pluginID])
+			}
 			pluginOutputs[pluginID].Code = r.Code
 			pluginOutputs[pluginID].Status = r.Status
 			pluginOutputs[pluginID].Error = r.Error
 			pluginOutputs[pluginID].Output = r.Output
 			pluginOutputs[pluginID].StandardOutput = r.StandardOutput
 			pluginOutputs[pluginID].StandardError = r.StandardError
+			if !configuration.DryRun {
+				pluginOutputs[pluginID].StepOutputs = captureStepOutputs(context.Log(), pluginID, configuration.Outputs, r.StandardOutput)
+			}
 
 		case skipStep:
 			context.Log().Info(logMessage)
@@ -179,6 +220,15 @@ func RunPlugins(
 			context.Log().Error(err)
 		}
 
+		if isExitOnFailure(configuration, pluginOutputs[pluginID].Status) {
+			context.Log().Infof("Step %s failed with onFailure %s, remaining steps will not be executed", pluginID, configuration.OnFailure)
+			exitStepID = pluginID
+			pluginOutputs[pluginID].ExitedOnFailure = true
+			if configuration.OnFailure == contracts.OnFailureSuccessAndExit {
+				pluginOutputs[pluginID].Status = contracts.ResultStatusSuccess
+			}
+		}
+
 		// set end time.
 		pluginOutputs[pluginID].EndDateTime = time.Now()
 		context.Log().Infof("Sending plugin %v completion message", pluginID)
@@ -195,6 +245,116 @@ func RunPlugins(
 	return
 }
 
+// streamPluginOutput returns the io configuration of the plugin, with the streamed output chunks identifying the plugin
+func streamPluginOutput(ioConfig contracts.IOConfiguration, pluginID string) contracts.IOConfiguration {
+	if stream := ioConfig.OutputStream; stream != nil {
+		ioConfig.OutputStream = func(chunk contracts.OutputChunk) {
+			chunk.PluginID = pluginID
+			stream(chunk)
+		}
+	}
+	return ioConfig
+}
+
+// runPluginWithAttempts runs the plugin until it does not fail or the maxAttempts of the step is reached,
+// backing off exponentially between two attempts. Attempts are recorded in the step result only when the step
+// allows more than one attempt. Failed attempts persisted before a reboot count towards maxAttempts.
+func runPluginWithAttempts(
+	context context.T,
+	pluginFactory Factory,
+	pluginName string,
+	config contracts.Configuration,
+	cancelFlag task.CancelFlag,
+	ioConfig contracts.IOConfiguration,
+	stepResult *contracts.PluginResult) (res contracts.PluginResult) {
+
+	log := context.Log()
+	failedAttempts := 0
+	for _, attempt := range stepResult.Attempts {
+		if isFailedStatus(attempt.Status) {
+			failedAttempts++
+		}
+	}
+
+	for {
+		res = runPlugin(context, pluginFactory, pluginName, config, cancelFlag, ioConfig)
+		if config.MaxAttempts <= 1 {
+			return
+		}
+
+		attempt := contracts.PluginAttempt{
+			Attempt:       len(stepResult.Attempts) + 1,
+			Status:        res.Status,
+			Code:          res.Code,
+			StartDateTime: res.StartDateTime,
+			EndDateTime:   res.EndDateTime,
+		}
+		if res.Error != nil {
+			attempt.Error = res.Error.Error()
+		}
+		stepResult.Attempts = append(stepResult.Attempts, attempt)
+
+		if !isFailedStatus(res.Status) {
+			return
+		}
+		failedAttempts++
+		if failedAttempts >= config.MaxAttempts {
+			log.Infof("Step %s failed after %d attempts", config.PluginID, failedAttempts)
+			return
+		}
+
+		delay := retryBackoff(failedAttempts)
+		log.Infof("Step %s failed on attempt %d of %d, retrying in %v", config.PluginID, failedAttempts, config.MaxAttempts, delay)
+		if !waitForRetry(cancelFlag, delay) {
+			log.Infof("Step %s will not be retried, the document is being cancelled or shut down", config.PluginID)
+			return
+		}
+	}
+}
+
+// retryBackoff returns the delay before the attempt following the given number of failed attempts
+func retryBackoff(failedAttempts int) time.Duration {
+	delay := retryBaseBackoff
+	for i := 1; i < failedAttempts && delay < retryMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxBackoff {
+		delay = retryMaxBackoff
+	}
+	return delay
+}
+
+// waitForRetry sleeps for the given delay and returns false if the cancel flag is set in the meantime
+func waitForRetry(cancelFlag task.CancelFlag, delay time.Duration) bool {
+	deadline := time.Now().Add(delay)
+	for {
+		if cancelFlag.Canceled() || cancelFlag.ShutDown() {
+			return false
+		}
+		remaining := deadline.Sub(time.Now())
+		if remaining <= 0 {
+			return true
+		}
+		if remaining > retryPollInterval {
+			remaining = retryPollInterval
+		}
+		time.Sleep(remaining)
+	}
+}
+
+// isFailedStatus returns true for the statuses that make a step eligible for another attempt or its onFailure action
+func isFailedStatus(status contracts.ResultStatus) bool {
+	return status == contracts.ResultStatusFailed || status == contracts.ResultStatusTimedOut
+}
+
+// isExitOnFailure returns true if the step result stops the execution of the remaining steps of the document
+func isExitOnFailure(config contracts.Configuration, status contracts.ResultStatus) bool {
+	if !isFailedStatus(status) {
+		return false
+	}
+	return config.OnFailure == contracts.OnFailureExit || config.OnFailure == contracts.OnFailureSuccessAndExit
+}
+
 func runPlugin(
 	context context.T,
 	pluginFactory Factory,
@@ -230,6 +390,8 @@ func runPlugin(
 	res.StartDateTime = time.Now()
 	defer func() { res.EndDateTime = time.Now() }()
 
+	// the limits of the step override the limits of the agent
+	ioConfig.OutputLimits = appconfig.ValidateOutputLimits(config.OutputLimits, context.AppConfig().Agent.OutputLimits)
 	output := iohandler.NewDefaultIOHandler(log, ioConfig)
 	//check if properties is a list. If true, then unroll
 	switch config.Properties.(type) {
@@ -241,21 +403,22 @@ func runPlugin(
 		}
 		for _, prop := range properties {
 			config.Properties = prop
+			propConfig := config
+			propConfig.RunAsUser = stepRunAsUser(pluginFactory, config)
 			propOutput := iohandler.NewDefaultIOHandler(log, ioConfig)
-			executePlugin(context, p, pluginName, config, cancelFlag, propOutput)
+			executePlugin(context, p, pluginName, propConfig, cancelFlag, propOutput)
 			output.Merge(log, propOutput)
 		}
 
 	default:
+		config.RunAsUser = stepRunAsUser(pluginFactory, config)
 		executePlugin(context, p, pluginName, config, cancelFlag, output)
 	}
-	pluginConfig := iohandler.DefaultOutputConfig()
-
 	res.Code = output.GetExitCode()
 	res.Status = output.GetStatus()
 	res.Output = output.GetOutput()
-	res.StandardOutput = pluginutil.StringPrefix(output.GetStdout(), pluginConfig.MaxStdoutLength, pluginConfig.OutputTruncatedSuffix)
-	res.StandardError = pluginutil.StringPrefix(output.GetStderr(), pluginConfig.MaxStderrLength, pluginConfig.OutputTruncatedSuffix)
+	res.StandardOutput, res.StandardOutputTruncation = output.GetTruncatedStdout()
+	res.StandardError, res.StandardErrorTruncation = output.GetTruncatedStderr()
 	return
 }
 
@@ -283,6 +446,12 @@ func executePlugin(context context.T,
 		errorString := fmt.Errorf("Invalid format in plugin properties %v;\nerror %v", config.Properties, err)
 		output.MarkAsFailed(errorString)
 	} else {
+		// the directories of a step running as another user are given to the user once its output is closed
+		if config.RunAsUser != "" {
+			runAsDirs := runAsDirectories(config, output.GetIOConfig(), pluginName, propID)
+			reclaimFromRunAsUser(log, runAsDirs)
+			defer giveToRunAsUser(log, config.RunAsUser, runAsDirs)
+		}
 		// Create the output object and execute the plugin
 		defer output.Close(log)
 		output.Init(log, pluginName, propID)
@@ -306,7 +475,8 @@ func getStepExecutionOperation(
 	isSupported bool,
 	isPluginHandlerFound bool,
 	isPreconditionEnabled bool,
-	preconditions map[string][]string,
+	preconditions []contracts.Precondition,
+	stepOutputs map[string]string,
 ) (string, string) {
 	log.Debugf("isSupported flag = %t", isSupported)
 	log.Debugf("isPluginHandlerFound flag = %t", isPluginHandlerFound)
@@ -358,7 +528,7 @@ func getStepExecutionOperation(
 		} else {
 			log.Debugf("Cross-platform Precondition is present, precondition = %v", preconditions)
 
-			isAllowed, unrecognizedPreconditionList := evaluatePreconditions(log, preconditions)
+			isAllowed, unevaluated := evaluatePreconditions(log, preconditions, stepOutputs)
 
 			if isAllowed && !isKnown {
 				return failStep, fmt.Sprintf(
@@ -369,10 +539,15 @@ func getStepExecutionOperation(
 				return skipStep, fmt.Sprintf(
 					"Step execution skipped due to incompatible platform. Step name: %s",
 					pluginId)
-			} else if len(unrecognizedPreconditionList) > 0 {
+			} else if len(unevaluated.missingOutputs) > 0 {
+				return failStep, fmt.Sprintf(
+					"Precondition(s) reference step output(s) that no earlier step produced: '%s'. Step name: %s",
+					strings.Join(unevaluated.missingOutputs, ", "),
+					pluginId)
+			} else if len(unevaluated.unrecognized) > 0 {
 				return failStep, fmt.Sprintf(
 					"Unrecognized precondition(s): '%s', please update agent to latest version. Step name: %s",
-					strings.Join(unrecognizedPreconditionList, ", "),
+					strings.Join(unevaluated.unrecognized, ", "),
 					pluginId)
 			} else {
 				return executeStep, ""
@@ -380,50 +555,3 @@ func getStepExecutionOperation(
 		}
 	}
 }
-
-// Evaluate precondition and return precondition result and unrecognized preconditions (if any)
-func evaluatePreconditions(
-	log log.T,
-	preconditions map[string][]string,
-) (bool, []string) {
-
-	var isAllowed = true
-	var unrecognizedPreconditionList []string
-
-	// For current release, we only support "StringEquals" operator and "platformType"
-	// operand, so explicitly checking for those and number of operands must be 2
-	for key, value := range preconditions {
-		switch key {
-		case "StringEquals":
-			// Platform type of OS on the instance
-			instancePlatformType, _ := platform.PlatformType(log)
-			log.Debugf("OS platform type of this instance = %s", instancePlatformType)
-
-			if len(value) != 2 ||
-				(strings.Compare(value[0], "platformType") == 0 && strings.Compare(value[1], "platformType") == 0) ||
-				(strings.Compare(value[0], "platformType") != 0 && strings.Compare(value[1], "platformType") != 0) {
-
-				unrecognizedPreconditionList = append(unrecognizedPreconditionList, fmt.Sprintf("\"%s\": %v", key, value))
-			} else {
-				// Variable and value can be in any order, i.e. both "StringEquals": ["platformType", "Windows"]
-				// and "StringEquals": ["Windows", "platformType"] are valid
-				var platformTypeValue string
-				if strings.Compare(value[0], "platformType") == 0 {
-					platformTypeValue = value[1]
-				} else {
-					platformTypeValue = value[0]
-				}
-
-				if strings.Compare(instancePlatformType, strings.ToLower(platformTypeValue)) != 0 {
-					// if precondition doesn't match for platformType, mark step for skip
-					isAllowed = false
-				}
-			}
-		default:
-			// mark for unrecognizedPrecondition (which is a form of failure)
-			unrecognizedPreconditionList = append(unrecognizedPreconditionList, fmt.Sprintf("\"%s\": %v", key, value))
-		}
-	}
-
-	return isAllowed, unrecognizedPreconditionList
-}
@@ -15,12 +15,15 @@
 package runpluginutil
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"testing"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 	"github.com/stretchr/testify/assert"
@@ -499,7 +502,7 @@ func TestRunPluginsWithCompatiblePrecondition(t *testing.T) {
 	defaultTime := time.Now()
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"platformType", "Linux"}}
+	preconditions := []contracts.Precondition{newPrecondition("StringEquals", "platformType", "Linux")}
 
 	for index, name := range pluginNames {
 
@@ -589,7 +592,7 @@ func TestRunPluginsWithCompatiblePreconditionWithValueFirst(t *testing.T) {
 	defaultTime := time.Now()
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"Linux", "platformType"}}
+	preconditions := []contracts.Precondition{newPrecondition("StringEquals", "Linux", "platformType")}
 
 	for index, name := range pluginNames {
 
@@ -678,7 +681,7 @@ func TestRunPluginsWithIncompatiblePrecondition(t *testing.T) {
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"platformType", "Windows"}}
+	preconditions := []contracts.Precondition{newPrecondition("StringEquals", "platformType", "Windows")}
 
 	for index, name := range pluginNames {
 
@@ -767,7 +770,7 @@ func TestRunPluginsWithCompatiblePreconditionButMissingPluginHandler(t *testing.
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"platformType", "Linux"}}
+	preconditions := []contracts.Precondition{newPrecondition("StringEquals", "platformType", "Linux")}
 
 	for index, name := range pluginNames {
 
@@ -854,9 +857,9 @@ func TestRunPluginsWithMoreThanOnePrecondition(t *testing.T) {
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{
-		"StringEquals": []string{"platformType", "Linux"},
-		"foo":          []string{"operand1", "operand2"},
+	preconditions := []contracts.Precondition{
+		newPrecondition("StringEquals", "platformType", "Linux"),
+		newPrecondition("foo", "operand1", "operand2"),
 	}
 
 	for index, name := range pluginNames {
@@ -952,7 +955,7 @@ func TestRunPluginsWithUnrecognizedPreconditionOperator(t *testing.T) {
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"foo": []string{"platformType", "Linux"}}
+	preconditions := []contracts.Precondition{newPrecondition("foo", "platformType", "Linux")}
 
 	for index, name := range pluginNames {
 
@@ -1048,7 +1051,7 @@ func TestRunPluginsWithUnrecognizedPreconditionOperand(t *testing.T) {
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"foo", "Linux"}}
+	preconditions := []contracts.Precondition{newPrecondition("StringEquals", "foo", "Linux")}
 
 	for index, name := range pluginNames {
 
@@ -1145,7 +1148,7 @@ func TestRunPluginsWithUnrecognizedPreconditionDuplicateVariable(t *testing.T) {
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"platformType", "platformType"}}
+	preconditions := []contracts.Precondition{newPrecondition("StringEquals", "platformType", "platformType")}
 
 	for index, name := range pluginNames {
 
@@ -1242,7 +1245,7 @@ func TestRunPluginsWithMoreThanTwoPreconditionOperands(t *testing.T) {
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"platformType", "Linux", "foo"}}
+	preconditions := []contracts.Precondition{newPrecondition("StringEquals", "platformType", "Linux", "foo")}
 
 	for index, name := range pluginNames {
 
@@ -1339,7 +1342,7 @@ func TestRunPluginsWithUnknownPlugin(t *testing.T) {
 	defaultOutput := ""
 	pluginConfigs2 := make([]contracts.PluginState, len(pluginNames))
 
-	preconditions := map[string][]string{"StringEquals": []string{"platformType", "Linux"}}
+	preconditions := []contracts.Precondition{newPrecondition("StringEquals", "platformType", "Linux")}
 
 	for index, name := range pluginNames {
 
@@ -1427,3 +1430,301 @@ func TestRunPluginsWithUnknownPlugin(t *testing.T) {
 
 	assert.Equal(t, pluginResults, outputs)
 }
+
+// newOnFailureTestSteps creates two steps where the first one fails on its first failures executions
+func newOnFailureTestSteps(ctx context.T, cancelFlag task.CancelFlag, firstStep contracts.Configuration, failures int) ([]contracts.PluginState, PluginRegistry, map[string]*PluginMock) {
+	pluginRegistry := PluginRegistry{}
+	plugins := make(map[string]*PluginMock)
+	pluginStates := []contracts.PluginState{}
+	for _, name := range []string{testPlugin1, testPlugin2} {
+		config := contracts.Configuration{
+			PluginID:   name,
+			PluginName: name,
+		}
+		if name == testPlugin1 {
+			config = firstStep
+		}
+		plugins[name] = new(PluginMock)
+		if name == testPlugin1 && failures > 0 {
+			plugins[name].On("Execute", ctx, config, cancelFlag, mock.Anything).Run(func(args mock.Arguments) {
+				args.Get(3).(iohandler.IOHandler).MarkAsFailed(fmt.Errorf("step failed"))
+			}).Return().Times(failures)
+		}
+		plugins[name].On("Execute", ctx, config, cancelFlag, mock.Anything).Run(func(args mock.Arguments) {
+			args.Get(3).(iohandler.IOHandler).MarkAsSucceeded()
+		}).Return()
+		pluginFactory := new(PluginFactoryMock)
+		pluginFactory.On("Create", mock.Anything).Return(plugins[name], nil)
+		pluginRegistry[name] = pluginFactory
+		pluginStates = append(pluginStates, contracts.PluginState{
+			Name:          name,
+			Id:            name,
+			Configuration: config,
+		})
+	}
+	return pluginStates, pluginRegistry, plugins
+}
+
+// newOnFailureTestIOConfig keeps the output of the failing steps away from the other tests
+func newOnFailureTestIOConfig(t *testing.T) contracts.IOConfiguration {
+	orchestrationDir, err := ioutil.TempDir("", "runpluginutil")
+	assert.NoError(t, err)
+	return contracts.IOConfiguration{OrchestrationDirectory: orchestrationDir}
+}
+
+func TestRunPluginsRetriesFailedStep(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	origBackoff := retryBaseBackoff
+	retryBaseBackoff = time.Millisecond
+	defer func() { retryBaseBackoff = origBackoff }()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	firstStep := contracts.Configuration{
+		PluginID:    testPlugin1,
+		PluginName:  testPlugin1,
+		MaxAttempts: 3,
+	}
+	pluginStates, pluginRegistry, plugins := newOnFailureTestSteps(ctx, cancelFlag, firstStep, 2)
+
+	ch := make(chan contracts.PluginResult, 2)
+	outputs := RunPlugins(ctx, pluginStates, newOnFailureTestIOConfig(t), pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	plugins[testPlugin1].AssertNumberOfCalls(t, "Execute", 3)
+	plugins[testPlugin2].AssertNumberOfCalls(t, "Execute", 1)
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin1].Status)
+	assert.Equal(t, 3, len(outputs[testPlugin1].Attempts))
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Attempts[0].Status)
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Attempts[1].Status)
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin1].Attempts[2].Status)
+	assert.Equal(t, 3, outputs[testPlugin1].Attempts[2].Attempt)
+	assert.Nil(t, outputs[testPlugin2].Attempts)
+}
+
+func TestRunPluginsStopsRetryingAtMaxAttempts(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	origBackoff := retryBaseBackoff
+	retryBaseBackoff = time.Millisecond
+	defer func() { retryBaseBackoff = origBackoff }()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	firstStep := contracts.Configuration{
+		PluginID:    testPlugin1,
+		PluginName:  testPlugin1,
+		MaxAttempts: 2,
+	}
+	pluginStates, pluginRegistry, plugins := newOnFailureTestSteps(ctx, cancelFlag, firstStep, 5)
+
+	ch := make(chan contracts.PluginResult, 2)
+	outputs := RunPlugins(ctx, pluginStates, newOnFailureTestIOConfig(t), pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	plugins[testPlugin1].AssertNumberOfCalls(t, "Execute", 2)
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Status)
+	assert.Equal(t, 2, len(outputs[testPlugin1].Attempts))
+	// onFailure defaults to continue
+	plugins[testPlugin2].AssertNumberOfCalls(t, "Execute", 1)
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin2].Status)
+}
+
+func TestRunPluginsResumedStepCountsPersistedAttempts(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	origBackoff := retryBaseBackoff
+	retryBaseBackoff = time.Millisecond
+	defer func() { retryBaseBackoff = origBackoff }()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	firstStep := contracts.Configuration{
+		PluginID:    testPlugin1,
+		PluginName:  testPlugin1,
+		MaxAttempts: 3,
+	}
+	pluginStates, pluginRegistry, plugins := newOnFailureTestSteps(ctx, cancelFlag, firstStep, 5)
+	// the document rebooted during the third attempt after two failed attempts
+	pluginStates[0].Result = contracts.PluginResult{
+		Status: contracts.ResultStatusSuccessAndReboot,
+		Attempts: []contracts.PluginAttempt{
+			{Attempt: 1, Status: contracts.ResultStatusFailed},
+			{Attempt: 2, Status: contracts.ResultStatusFailed},
+			{Attempt: 3, Status: contracts.ResultStatusSuccessAndReboot},
+		},
+	}
+
+	ch := make(chan contracts.PluginResult, 2)
+	outputs := RunPlugins(ctx, pluginStates, newOnFailureTestIOConfig(t), pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	plugins[testPlugin1].AssertNumberOfCalls(t, "Execute", 1)
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Status)
+	assert.Equal(t, 4, len(outputs[testPlugin1].Attempts))
+}
+
+func TestRunPluginsOnFailureExit(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	firstStep := contracts.Configuration{
+		PluginID:   testPlugin1,
+		PluginName: testPlugin1,
+		OnFailure:  contracts.OnFailureExit,
+	}
+	pluginStates, pluginRegistry, plugins := newOnFailureTestSteps(ctx, cancelFlag, firstStep, 1)
+
+	ch := make(chan contracts.PluginResult, 2)
+	outputs := RunPlugins(ctx, pluginStates, newOnFailureTestIOConfig(t), pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	assert.Equal(t, 2, len(ch))
+	plugins[testPlugin1].AssertNumberOfCalls(t, "Execute", 1)
+	plugins[testPlugin2].AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Status)
+	assert.Equal(t, contracts.ResultStatusSkipped, outputs[testPlugin2].Status)
+
+	status, _, _ := contracts.DocumentResultAggregator(ctx.Log(), "", outputs)
+	assert.Equal(t, contracts.ResultStatusFailed, status)
+}
+
+func TestRunPluginsOnFailureSuccessAndExit(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	firstStep := contracts.Configuration{
+		PluginID:   testPlugin1,
+		PluginName: testPlugin1,
+		OnFailure:  contracts.OnFailureSuccessAndExit,
+	}
+	pluginStates, pluginRegistry, plugins := newOnFailureTestSteps(ctx, cancelFlag, firstStep, 1)
+
+	ch := make(chan contracts.PluginResult, 2)
+	outputs := RunPlugins(ctx, pluginStates, newOnFailureTestIOConfig(t), pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	plugins[testPlugin2].AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin1].Status)
+	assert.True(t, outputs[testPlugin1].ExitedOnFailure)
+	assert.Equal(t, contracts.ResultStatusSkipped, outputs[testPlugin2].Status)
+
+	status, _, _ := contracts.DocumentResultAggregator(ctx.Log(), "", outputs)
+	assert.Equal(t, contracts.ResultStatusSuccess, status)
+}
+
+func TestRunPluginsOnFailureContinue(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	firstStep := contracts.Configuration{
+		PluginID:   testPlugin1,
+		PluginName: testPlugin1,
+		OnFailure:  contracts.OnFailureContinue,
+	}
+	pluginStates, pluginRegistry, plugins := newOnFailureTestSteps(ctx, cancelFlag, firstStep, 1)
+
+	ch := make(chan contracts.PluginResult, 2)
+	outputs := RunPlugins(ctx, pluginStates, newOnFailureTestIOConfig(t), pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	plugins[testPlugin2].AssertNumberOfCalls(t, "Execute", 1)
+	assert.Equal(t, contracts.ResultStatusFailed, outputs[testPlugin1].Status)
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin2].Status)
+}
+
+func TestRunPluginsResumedDocumentHonorsOnFailureExit(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	firstStep := contracts.Configuration{
+		PluginID:   testPlugin1,
+		PluginName: testPlugin1,
+		OnFailure:  contracts.OnFailureExit,
+	}
+	pluginStates, pluginRegistry, plugins := newOnFailureTestSteps(ctx, cancelFlag, firstStep, 0)
+	pluginStates[0].Result.Status = contracts.ResultStatusFailed
+	pluginStates[1].Result.Status = contracts.ResultStatusNotStarted
+
+	ch := make(chan contracts.PluginResult, 2)
+	outputs := RunPlugins(ctx, pluginStates, newOnFailureTestIOConfig(t), pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	plugins[testPlugin1].AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	plugins[testPlugin2].AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(t, contracts.ResultStatusSkipped, outputs[testPlugin2].Status)
+}
+
+func TestRunPluginsResumedDocumentHonorsOnFailureSuccessAndExit(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	firstStep := contracts.Configuration{
+		PluginID:   testPlugin1,
+		PluginName: testPlugin1,
+		OnFailure:  contracts.OnFailureSuccessAndExit,
+	}
+	pluginStates, pluginRegistry, plugins := newOnFailureTestSteps(ctx, cancelFlag, firstStep, 1)
+
+	// the document is interrupted after the first step, whose result is persisted in the document state
+	ch := make(chan contracts.PluginResult, 2)
+	outputs := RunPlugins(ctx, pluginStates[:1], newOnFailureTestIOConfig(t), pluginRegistry, ch, cancelFlag)
+	close(ch)
+	content, err := json.Marshal(outputs[testPlugin1])
+	assert.NoError(t, err)
+	var persisted contracts.PluginResult
+	assert.NoError(t, json.Unmarshal(content, &persisted))
+	pluginStates[0].Result = persisted
+	pluginStates[1].Result.Status = contracts.ResultStatusNotStarted
+
+	// the resumed document doesn't run the steps following the step that exited
+	ch = make(chan contracts.PluginResult, 2)
+	outputs = RunPlugins(ctx, pluginStates, newOnFailureTestIOConfig(t), pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	plugins[testPlugin1].AssertNumberOfCalls(t, "Execute", 1)
+	plugins[testPlugin2].AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(t, contracts.ResultStatusSuccess, outputs[testPlugin1].Status)
+	assert.Equal(t, contracts.ResultStatusSkipped, outputs[testPlugin2].Status)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	assert.Equal(t, retryBaseBackoff, retryBackoff(1))
+	assert.Equal(t, 2*retryBaseBackoff, retryBackoff(2))
+	assert.Equal(t, 4*retryBaseBackoff, retryBackoff(3))
+	assert.Equal(t, retryMaxBackoff, retryBackoff(100))
+}
+
+func TestRunPluginsStreamsOutput(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	firstStep := contracts.Configuration{
+		PluginID:   testPlugin1,
+		PluginName: testPlugin1,
+	}
+	pluginStates, pluginRegistry, _ := newOnFailureTestSteps(ctx, cancelFlag, firstStep, 1)
+	var chunks []contracts.OutputChunk
+	ioConfig := newOnFailureTestIOConfig(t)
+	ioConfig.OutputStream = func(chunk contracts.OutputChunk) { chunks = append(chunks, chunk) }
+
+	ch := make(chan contracts.PluginResult, 2)
+	RunPlugins(ctx, pluginStates, ioConfig, pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	// the chunks identify the step that wrote them
+	assert.Equal(t, []contracts.OutputChunk{{PluginID: testPlugin1, Stream: "stderr", Data: "step failed"}}, chunks)
+}
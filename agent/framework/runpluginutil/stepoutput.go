@@ -0,0 +1,144 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/parameters"
+	"github.com/gabs"
+)
+
+// getStepOutputs returns the outputs of the steps executed before the given step, indexed by "stepName.outputName"
+func getStepOutputs(pluginOutputs map[string]*contracts.PluginResult, currentPluginID string) map[string]string {
+	stepOutputs := make(map[string]string)
+	for pluginID, result := range pluginOutputs {
+		if pluginID == currentPluginID {
+			continue
+		}
+		stepOutputs[pluginID+"."+contracts.StepOutputStatus] = string(result.Status)
+		stepOutputs[pluginID+"."+contracts.StepOutputExitCode] = strconv.Itoa(result.Code)
+		stepOutputs[pluginID+"."+contracts.StepOutputStandardOutput] = strings.TrimSpace(result.StandardOutput)
+		for name, value := range result.StepOutputs {
+			stepOutputs[pluginID+"."+name] = value
+		}
+	}
+	return stepOutputs
+}
+
+// replaceStepOutputs replaces the references to the outputs of earlier steps within the plugin Properties.
+// References to unknown outputs are left as is.
+func replaceStepOutputs(log log.T, properties interface{}, stepOutputs map[string]string) interface{} {
+	values := make(map[string]interface{}, len(stepOutputs))
+	for name, value := range stepOutputs {
+		values[name] = value
+	}
+	return parameters.ReplaceParameters(properties, values, log)
+}
+
+// captureStepOutputs extracts the outputs declared by the step from its standard output
+func captureStepOutputs(log log.T, pluginID string, outputs []contracts.StepOutput, stdout string) map[string]string {
+	if len(outputs) == 0 {
+		return nil
+	}
+	values := make(map[string]string)
+	for _, output := range outputs {
+		value, err := captureStepOutput(output, stdout)
+		if err != nil {
+			log.Errorf("Output %s of step %s was not captured: %v", output.Name, pluginID, err)
+			continue
+		}
+		log.Debugf("Output %s of step %s = %s", output.Name, pluginID, value)
+		values[output.Name] = value
+	}
+	return values
+}
+
+// captureStepOutput extracts a single output from the standard output with the selector of the output
+func captureStepOutput(output contracts.StepOutput, stdout string) (string, error) {
+	switch {
+	case output.Regex != "":
+		regex, err := regexp.Compile(output.Regex)
+		if err != nil {
+			return "", err
+		}
+		match := regex.FindStringSubmatch(stdout)
+		if match == nil {
+			return "", fmt.Errorf("regex %s doesn't match the output", output.Regex)
+		}
+		if len(match) > 1 {
+			return match[1], nil
+		}
+		return match[0], nil
+
+	case output.JSONPath != "":
+		return captureJSONPath(output.JSONPath, stdout)
+
+	case output.Key != "":
+		// the last line setting the key wins, like it would in a shell
+		var value string
+		found := false
+		for _, line := range strings.Split(stdout, "\n") {
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) == 2 && strings.TrimSpace(parts[0]) == output.Key {
+				value = strings.TrimSpace(parts[1])
+				found = true
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("no line sets %s", output.Key)
+		}
+		return value, nil
+
+	default:
+		return "", fmt.Errorf("no selector")
+	}
+}
+
+// captureJSONPath returns the value at the given path, i.e. $.instance.tags.0.value, of the json printed by the step.
+// Values that aren't strings are returned as json.
+func captureJSONPath(path string, stdout string) (string, error) {
+	container, err := gabs.ParseJSON([]byte(strings.TrimSpace(stdout)))
+	if err != nil {
+		return "", fmt.Errorf("output is not json: %v", err)
+	}
+
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path != "" {
+		for _, segment := range strings.Split(path, ".") {
+			if _, isArray := container.Data().([]interface{}); isArray {
+				index, err := strconv.Atoi(segment)
+				if err != nil || index < 0 {
+					return "", fmt.Errorf("%s is not an array index", segment)
+				}
+				container = container.Index(index)
+			} else {
+				container = container.S(segment)
+			}
+			if container.Data() == nil {
+				return "", fmt.Errorf("path %s not found in the output", path)
+			}
+		}
+	}
+
+	if value, isString := container.Data().(string); isString {
+		return value, nil
+	}
+	return container.String(), nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testStepOutput = `{"instance": {"id": "i-1234", "tags": [{"key": "env", "value": "prod"}], "count": 2}}`
+
+func TestCaptureStepOutput(t *testing.T) {
+	testCases := []struct {
+		output contracts.StepOutput
+		stdout string
+		value  string
+	}{
+		{contracts.StepOutput{Name: "version", Regex: `version (\d+\.\d+)`}, "agent version 2.2 installed", "2.2"},
+		{contracts.StepOutput{Name: "match", Regex: `\d+\.\d+`}, "agent version 2.2 installed", "2.2"},
+		{contracts.StepOutput{Name: "id", JSONPath: "$.instance.id"}, testStepOutput, "i-1234"},
+		{contracts.StepOutput{Name: "tag", JSONPath: "instance.tags.0.value"}, testStepOutput, "prod"},
+		{contracts.StepOutput{Name: "count", JSONPath: "$.instance.count"}, testStepOutput, "2"},
+		{contracts.StepOutput{Name: "tags", JSONPath: "$.instance.tags"}, testStepOutput, `[{"key":"env","value":"prod"}]`},
+		{contracts.StepOutput{Name: "status", Key: "STATUS"}, "STATUS=starting\nlog line\n STATUS = ready \n", "ready"},
+	}
+
+	for _, testCase := range testCases {
+		value, err := captureStepOutput(testCase.output, testCase.stdout)
+		assert.NoError(t, err, testCase.output.Name)
+		assert.Equal(t, testCase.value, value, testCase.output.Name)
+	}
+}
+
+func TestCaptureStepOutput_NotFound(t *testing.T) {
+	testCases := []struct {
+		output contracts.StepOutput
+		stdout string
+	}{
+		{contracts.StepOutput{Name: "version", Regex: `version (\d+)`}, "no version"},
+		{contracts.StepOutput{Name: "id", JSONPath: "$.instance.name"}, testStepOutput},
+		{contracts.StepOutput{Name: "tag", JSONPath: "$.instance.tags.1"}, testStepOutput},
+		{contracts.StepOutput{Name: "tag", JSONPath: "$.instance.tags.key"}, testStepOutput},
+		{contracts.StepOutput{Name: "id", JSONPath: "$.id"}, "not json"},
+		{contracts.StepOutput{Name: "status", Key: "STATUS"}, "STATE=ready"},
+	}
+
+	for _, testCase := range testCases {
+		_, err := captureStepOutput(testCase.output, testCase.stdout)
+		assert.Error(t, err, testCase.output.Name)
+	}
+}
+
+func TestCaptureStepOutputs_SkipsMissingOutputs(t *testing.T) {
+	outputs := []contracts.StepOutput{
+		{Name: "version", Key: "VERSION"},
+		{Name: "missing", Key: "MISSING"},
+	}
+
+	values := captureStepOutputs(log.NewMockLog(), "step1", outputs, "VERSION=1.0")
+	assert.Equal(t, map[string]string{"version": "1.0"}, values)
+	assert.Nil(t, captureStepOutputs(log.NewMockLog(), "step1", nil, "VERSION=1.0"))
+}
+
+func TestGetStepOutputs(t *testing.T) {
+	pluginOutputs := map[string]*contracts.PluginResult{
+		"step1": {Status: contracts.ResultStatusFailed, Code: 2, StandardOutput: "output\n", StepOutputs: map[string]string{"version": "1.0"}},
+		"step2": {Status: contracts.ResultStatusNotStarted},
+	}
+
+	stepOutputs := getStepOutputs(pluginOutputs, "step2")
+	assert.Equal(t, map[string]string{
+		"step1.status":   "Failed",
+		"step1.exitCode": "2",
+		"step1.output":   "output",
+		"step1.version":  "1.0",
+	}, stepOutputs)
+}
+
+func TestRunPluginsPassesStepOutputs(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+
+	var cancelFlag task.CancelFlag = task.NewChanneledCancelFlag()
+	ctx := context.NewMockDefault()
+	firstStep := contracts.Configuration{
+		PluginID:   testPlugin1,
+		PluginName: testPlugin1,
+		Outputs:    []contracts.StepOutput{{Name: "version", Key: "VERSION"}},
+	}
+	secondStep := contracts.Configuration{
+		PluginID:   testPlugin2,
+		PluginName: testPlugin2,
+		Properties: map[string]interface{}{
+			"runCommand": []interface{}{"install {{ plugin1.version }}", "{{ plugin1.unknown }}"},
+		},
+	}
+	resolvedSecondStep := secondStep
+	resolvedSecondStep.Properties = map[string]interface{}{
+		"runCommand": []interface{}{"install 1.2.3", "{{ plugin1.unknown }}"},
+	}
+
+	pluginRegistry := PluginRegistry{}
+	plugins := map[string]*PluginMock{testPlugin1: new(PluginMock), testPlugin2: new(PluginMock)}
+	plugins[testPlugin1].On("Execute", ctx, firstStep, cancelFlag, mock.Anything).Run(func(args mock.Arguments) {
+		out := args.Get(3).(iohandler.IOHandler)
+		out.GetStdoutWriter().WriteString("VERSION=1.2.3\n")
+		out.MarkAsSucceeded()
+	}).Return()
+	plugins[testPlugin2].On("Execute", ctx, resolvedSecondStep, cancelFlag, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(3).(iohandler.IOHandler).MarkAsSucceeded()
+	}).Return()
+	var pluginStates []contracts.PluginState
+	for _, config := range []contracts.Configuration{firstStep, secondStep} {
+		pluginFactory := new(PluginFactoryMock)
+		pluginFactory.On("Create", mock.Anything).Return(plugins[config.PluginID], nil)
+		pluginRegistry[config.PluginID] = pluginFactory
+		pluginStates = append(pluginStates, contracts.PluginState{Name: config.PluginName, Id: config.PluginID, Configuration: config})
+	}
+
+	ch := make(chan contracts.PluginResult, 2)
+	outputs := RunPlugins(ctx, pluginStates, newOnFailureTestIOConfig(t), pluginRegistry, ch, cancelFlag)
+	close(ch)
+
+	plugins[testPlugin1].AssertExpectations(t)
+	plugins[testPlugin2].AssertExpectations(t)
+	assert.Equal(t, map[string]string{"version": "1.2.3"}, outputs[testPlugin1].StepOutputs)
+	assert.Nil(t, outputs[testPlugin2].StepOutputs)
+}
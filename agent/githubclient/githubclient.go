@@ -17,6 +17,7 @@ package githubclient
 
 import (
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/go-github/github"
 	gitcontext "golang.org/x/net/context"
 
@@ -38,6 +39,9 @@ const (
 
 // NewClient is a constructor for GitClient
 func NewClient(httpClient *http.Client) IGitClient {
+	if httpClient == nil {
+		httpClient = &http.Client{Transport: proxyconfig.SharedTransport()}
+	}
 
 	return &GitClient{
 		github.NewClient(httpClient),
@@ -22,6 +22,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	"github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/amazon-ssm-agent/agent/updateutil"
 	"github.com/aws/amazon-ssm-agent/agent/version"
 	"github.com/carlescere/scheduler"
 )
@@ -71,7 +72,10 @@ func (h *HealthCheck) updateHealth() {
 	// If both ssm config and command is inactive => agent is inactive.
 	if _, err = h.service.UpdateInstanceInformation(log, version.Version, "Active", AgentName); err != nil {
 		sdkutil.HandleAwsError(log, err, h.healthCheckStopPolicy)
+		return
 	}
+	// the updater verifies the health of the agent it installed with the successful health checks
+	updateutil.RecordAgentHealthCheck(log, version.Version)
 	return
 }
 
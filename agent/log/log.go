@@ -57,6 +57,12 @@ func withContext(logger seelog.LoggerInterface, context ...string) (contextLogge
 	return contextLogger
 }
 
+// SilentLogger returns a logger discarding every message, for the programs whose output must not be mixed with logs,
+// e.g. the ssm-cli commands printing JSON
+func SilentLogger() T {
+	return &Wrapper{Format: &ContextFormatFilter{}, M: new(sync.Mutex), Delegate: &DelegateLogger{BaseLoggerInstance: seelog.Disabled}}
+}
+
 // ContextFormatFilter is a filter that can add a context to the parameters of a log message.
 type ContextFormatFilter struct {
 	Context []string
@@ -38,6 +38,7 @@ func NewMockLog() *Mock {
 	log.On("Errorf", mock.Anything, mock.Anything).Return(nil)
 	log.On("Tracef", mock.Anything, mock.Anything).Return()
 	log.On("Infof", mock.Anything, mock.Anything).Return()
+	log.On("Warnf", mock.Anything, mock.Anything).Return(nil)
 	return log
 }
 
@@ -54,6 +55,7 @@ func NewMockLogWithContext(ctx string) *Mock {
 	log.On("Errorf", mock.Anything, mock.Anything).Return(nil)
 	log.On("Tracef", mock.Anything, mock.Anything).Return()
 	log.On("Infof", mock.Anything, mock.Anything).Return()
+	log.On("Warnf", mock.Anything, mock.Anything).Return(nil)
 	return log
 }
 
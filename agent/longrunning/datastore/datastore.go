@@ -23,7 +23,9 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	logger "github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin"
+	"github.com/aws/amazon-ssm-agent/agent/statestore"
 )
 
 // DataStore is the interface to provide utilities to read & write from a data store
@@ -103,3 +105,40 @@ func (fs *FsStore) load(fileName string) (map[string]plugin.PluginInfo, error) {
 func (fs *FsStore) dataStoreFileExist(fileName string) bool {
 	return fileutil.Exists(fileName)
 }
+
+// StateStoreBucket is the bucket of the local state store holding the long running plugins data
+const StateStoreBucket = "longrunningplugins"
+
+// StateStore reads & writes long running plugins data from the local state store, the data of an instance being the
+// value of its instance id. The data store file written by the previous versions of the agent is migrated on read.
+type StateStore struct {
+	Log        logger.T
+	InstanceID string
+}
+
+// Write overwrites long running plugins specific data in the local state store
+func (ss *StateStore) Write(data map[string]plugin.PluginInfo, location, fileName string) error {
+	store, err := statestore.OpenDefault(ss.Log)
+	if err != nil {
+		return err
+	}
+	return store.Update(func(tx statestore.Tx) error {
+		return statestore.PutJSON(tx, StateStoreBucket, ss.InstanceID, data)
+	})
+}
+
+// Read reads long running plugins data from the local state store, after migrating the data store file if it exists
+func (ss *StateStore) Read(fileName string) (data map[string]plugin.PluginInfo, err error) {
+	store, err := statestore.OpenDefault(ss.Log)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = statestore.MigrateFile(ss.Log, store, StateStoreBucket, ss.InstanceID, fileName); err != nil {
+		ss.Log.Warnf("Failed to migrate long running plugins datastore file %v: %v", fileName, err)
+	}
+	err = store.View(func(tx statestore.Tx) error {
+		_, err := statestore.GetJSON(tx, StateStoreBucket, ss.InstanceID, &data)
+		return err
+	})
+	return data, err
+}
@@ -217,6 +217,10 @@ func (m *Manager) ModuleExecute(context context.T) (err error) {
 				OutputS3BucketName:     "",
 				OutputS3KeyPrefix:      "",
 			}
+			// let the plugin resume supervising its process if it survived the agent restart
+			if tracker, ok := p.Handler.(managerContracts.ProcessTracker); ok {
+				tracker.SetProcessInfo(pluginInfo.Process)
+			}
 			out := iohandler.NewDefaultIOHandler(log, ioConfig)
 			defer out.Close(log)
 			out.Init(log, p.Info.Name)
@@ -224,6 +228,7 @@ func (m *Manager) ModuleExecute(context context.T) (err error) {
 			out.Close(log)
 			m.registeredPlugins[pluginName] = p
 		}
+		m.refreshProcessInfo()
 	} else {
 		log.Infof("there aren't any long running plugin to execute")
 
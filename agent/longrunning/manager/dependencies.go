@@ -30,10 +30,8 @@ type dataStoreT interface {
 	Read() (map[string]plugin.PluginInfo, error)
 }
 
-// ds contains the implementation of long running plugin manager's dataStore
-type ds struct {
-	dsImpl datastore.FsStore
-}
+// ds contains the implementation of long running plugin manager's dataStore, the local state store of the instance
+type ds struct{}
 
 // Write writes new data in the data-store
 func (d ds) Write(data map[string]plugin.PluginInfo) error {
@@ -41,7 +39,11 @@ func (d ds) Write(data map[string]plugin.PluginInfo) error {
 	if err != nil {
 		return err
 	}
-	return d.dsImpl.Write(data, location, fileName)
+	dsImpl, err := d.impl()
+	if err != nil {
+		return err
+	}
+	return dsImpl.Write(data, location, fileName)
 }
 
 // Read reads data from the data-store
@@ -50,13 +52,24 @@ func (d ds) Read() (map[string]plugin.PluginInfo, error) {
 	if err != nil {
 		return nil, err
 	}
-	return d.dsImpl.Read(fileName)
+	dsImpl, err := d.impl()
+	if err != nil {
+		return nil, err
+	}
+	return dsImpl.Read(fileName)
 }
 
-var dataStore dataStoreT = ds{
-	dsImpl: datastore.FsStore{},
+// impl returns the data-store of the instance
+func (d ds) impl() (datastore.DataStore, error) {
+	instanceId, err := platform.InstanceID()
+	if err != nil {
+		return nil, err
+	}
+	return &datastore.StateStore{Log: log.Logger(), InstanceID: instanceId}, nil
 }
 
+var dataStore dataStoreT = ds{}
+
 // getDataStoreLocation returns the absolute path where long running plugins data-store is saved.
 func getDataStoreLocation() (location, fileName string, err error) {
 	var instanceId string
@@ -98,6 +98,7 @@ func (m *Manager) StartPlugin(name, configuration string, orchestrationDir strin
 		LastConfigurationModifiedTime: time.Now(),
 		IsEnabled:                     true,
 	}
+	p.Info.Process = processInfo(p.Handler)
 
 	// TODO move persisting out of executing logic
 	m.runningPlugins[name] = p.Info
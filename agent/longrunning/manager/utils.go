@@ -38,6 +38,8 @@ func (m *Manager) ensurePluginsAreRunning() {
 
 	log := m.context.Log()
 
+	m.refreshProcessInfo()
+
 	lock.RLock()
 	defer lock.RUnlock()
 
@@ -74,6 +76,41 @@ func (m *Manager) ensurePluginsAreRunning() {
 	}
 }
 
+// refreshProcessInfo persists the process information of running plugins that restarted their process since it was last saved
+func (m *Manager) refreshProcessInfo() {
+	log := m.context.Log()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	modified := false
+	for name, info := range m.runningPlugins {
+		p, isRegistered := m.registeredPlugins[name]
+		if !isRegistered {
+			continue
+		}
+		if current := processInfo(p.Handler); current != info.Process {
+			info.Process = current
+			m.runningPlugins[name] = info
+			modified = true
+		}
+	}
+
+	if modified {
+		if err := dataStore.Write(m.runningPlugins); err != nil {
+			log.Errorf("Failed to update datastore - because of %s", err)
+		}
+	}
+}
+
+// processInfo returns the os process supervised by the plugin, if the plugin tracks one
+func processInfo(handler plugin.LongRunningPlugin) contracts.OSProcInfo {
+	if tracker, ok := handler.(plugin.ProcessTracker); ok {
+		return tracker.GetProcessInfo()
+	}
+	return contracts.OSProcInfo{}
+}
+
 // stopLifeCycleManagementJob stops periodic health checks of long running plugins
 func (m *Manager) stopLifeCycleManagementJob() {
 	if m.managingLifeCycleJob != nil {
@@ -20,6 +20,7 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
@@ -39,6 +40,7 @@ type PluginInfo struct {
 	Name          string
 	Configuration string
 	State         PluginState
+	Process       contracts.OSProcInfo
 }
 
 // Plugin reflects a long running plugin
@@ -54,6 +56,13 @@ type LongRunningPlugin interface {
 	Stop(context context.T, cancelFlag task.CancelFlag) error
 }
 
+// ProcessTracker is implemented by long running plugins that supervise an os process. The manager persists the process
+// information in its data store so that the plugin can resume supervising that process after the agent restarts.
+type ProcessTracker interface {
+	GetProcessInfo() contracts.OSProcInfo
+	SetProcessInfo(info contracts.OSProcInfo)
+}
+
 //PluginSettings reflects settings that can be applied to long running plugins like aws:cloudWatch
 type PluginSettings struct {
 	StartType string
@@ -17,11 +17,50 @@
 package rundaemon
 
 import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
 	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/proc"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
 
+// RequestedDaemonStateType represents whether the user has explicitly requested to start/stop the daemon
+type RequestedDaemonStateType uint
+
+const (
+	RequestedDisabled RequestedDaemonStateType = iota
+	RequestedEnabled
+)
+
+// CurrentDaemonStateType represents whether the daemon is currently running or not.
+type CurrentDaemonStateType uint
+
+const (
+	CurrentStopped CurrentDaemonStateType = iota
+	CurrentRunning
+)
+
+// MaxRetryCountDuringFailures is the number of consecutive crashes after which the daemon is no longer restarted
+const MaxRetryCountDuringFailures = 10
+
+// Created as variables to allow for better testability
+var (
+	// RestartBackoffBase is the delay before the daemon is restarted after its first crash
+	RestartBackoffBase = 5 * time.Second
+	// RestartBackoffMax caps the delay between two restarts, a daemon that runs longer than this has its retry count reset
+	RestartBackoffMax = 5 * time.Minute
+	// StopTimeout is how long the daemon is given to exit after SIGTERM before it is sent SIGKILL
+	StopTimeout = 10 * time.Second
+	// livenessPollInterval is how often a daemon that is not a child of this agent is checked for liveness
+	livenessPollInterval = time.Second
+)
+
 // Plugin is the type for the configureDaemon plugin.
 type Plugin struct {
 	iohandler.PluginConfig
@@ -31,19 +70,74 @@ type Plugin struct {
 	Name string
 	// CommandLine is the command line to launch the daemon (On Windows, ame of executable or a powershell script)
 	CommandLine string
+	// ProcessInfo identifies the daemon process, it is persisted in the long running plugin datastore
+	ProcessInfo contracts.OSProcInfo
+	// ProcessStateLock lock is used to Protect access to daemon state updates
+	ProcessStateLock sync.Mutex
+	// RequestedDaemonState represents whether the user has explicitly requested to start/stop the daemon
+	RequestedDaemonState RequestedDaemonStateType
+	// CurrentDaemonState represents whether the daemon is currently running or not.
+	CurrentDaemonState CurrentDaemonStateType
+
+	// exited is closed once the current daemon process has exited
+	exited chan struct{}
+	// stopped is closed when a stop of the daemon is requested
+	stopped chan struct{}
+	// supervising is true while a goroutine is managing the daemon lifecycle
+	supervising bool
 }
 
 // IsRunning checks if the daemon is alive
 func (p *Plugin) IsRunning(context context.T) bool {
-	log := context.Log()
-	log.Infof("IsRunning check for daemon %v", p.Name)
-	return false // TODO:DAEMON check to see if process is alive (false for now to force regular restarts and see the logs
+	p.ProcessStateLock.Lock()
+	defer p.ProcessStateLock.Unlock()
+	return p.CurrentDaemonState == CurrentRunning && isProcessAlive(p.ProcessInfo.Pid)
 }
 
-// Start starts the daemon
+// GetProcessInfo returns the information of the daemon process
+func (p *Plugin) GetProcessInfo() contracts.OSProcInfo {
+	p.ProcessStateLock.Lock()
+	defer p.ProcessStateLock.Unlock()
+	return p.ProcessInfo
+}
+
+// SetProcessInfo restores the information of a daemon process started by a previous run of the agent
+func (p *Plugin) SetProcessInfo(info contracts.OSProcInfo) {
+	p.ProcessStateLock.Lock()
+	defer p.ProcessStateLock.Unlock()
+	if p.CurrentDaemonState == CurrentStopped {
+		p.ProcessInfo = info
+	}
+}
+
+// Start starts the daemon, or adopts the daemon process left running by a previous run of the agent,
+// and supervises it until Stop is called
 func (p *Plugin) Start(context context.T, configuration string, orchestrationDir string, cancelFlag task.CancelFlag, out iohandler.IOHandler) error {
 	log := context.Log()
-	log.Infof("Starting %v Command: %v Config: %v", p.Name, p.CommandLine, configuration)
+	if configuration == "" {
+		configuration = p.CommandLine
+	}
+	log.Infof("Starting %v Command: %v Package location: %v", p.Name, configuration, p.ExeLocation)
+
+	p.ProcessStateLock.Lock()
+	defer p.ProcessStateLock.Unlock()
+	p.RequestedDaemonState = RequestedEnabled
+	if p.supervising {
+		log.Infof("Daemon %v is already managed", p.Name)
+		return nil
+	}
+
+	if p.ProcessInfo.Pid != 0 && isDaemonProcess(p.ProcessInfo) {
+		log.Infof("Daemon %v is still running with pid %v, resuming its supervision", p.Name, p.ProcessInfo.Pid)
+		p.exited = watchProcess(p.ProcessInfo.Pid)
+	} else if err := p.launch(context, configuration); err != nil {
+		return err
+	}
+
+	p.CurrentDaemonState = CurrentRunning
+	p.stopped = make(chan struct{})
+	p.supervising = true
+	go p.supervise(context, configuration, p.exited, p.stopped)
 	return nil
 }
 
@@ -51,5 +145,198 @@ func (p *Plugin) Start(context context.T, configuration string, orchestrationDir
 func (p *Plugin) Stop(context context.T, cancelFlag task.CancelFlag) error {
 	log := context.Log()
 	log.Infof("Stopping %v", p.Name)
+
+	p.ProcessStateLock.Lock()
+	p.RequestedDaemonState = RequestedDisabled
+	if p.supervising {
+		close(p.stopped)
+		p.supervising = false
+	}
+	pid := p.ProcessInfo.Pid
+	exited := p.exited
+	running := p.CurrentDaemonState == CurrentRunning
+	p.ProcessStateLock.Unlock()
+
+	if running && pid != 0 {
+		if err := stopProcessGroup(context, pid, exited); err != nil {
+			return err
+		}
+	}
+
+	p.ProcessStateLock.Lock()
+	defer p.ProcessStateLock.Unlock()
+	p.CurrentDaemonState = CurrentStopped
+	p.ProcessInfo = contracts.OSProcInfo{}
+	return nil
+}
+
+// launch starts the daemon command line in its own process group, the caller must hold ProcessStateLock
+func (p *Plugin) launch(context context.T, configuration string) error {
+	log := context.Log()
+	daemonInvoke := exec.Command("sh", "-c", configuration)
+	daemonInvoke.Dir = p.ExeLocation
+	// the daemon gets its own process group so it can be signalled together with its children
+	daemonInvoke.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := daemonInvoke.Start(); err != nil {
+		log.Errorf("Error starting Daemon %v: %v", p.Name, err)
+		return err
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		daemonInvoke.Wait()
+		close(exited)
+	}()
+
+	p.exited = exited
+	p.ProcessInfo = proc.IdentifyProcess(daemonInvoke.Process.Pid, time.Now().UTC())
+	log.Infof("Started Daemon %v with pid %v", p.Name, p.ProcessInfo.Pid)
 	return nil
 }
+
+// supervise restarts the daemon with an exponential backoff whenever it exits until the stopped channel
+// of this supervision is closed
+func (p *Plugin) supervise(context context.T, configuration string, exited, stopped chan struct{}) {
+	log := context.Log()
+	retryCount := 0
+	for {
+		startTime := time.Now()
+		select {
+		case <-stopped:
+			return
+		case <-exited:
+		}
+
+		p.ProcessStateLock.Lock()
+		if isClosed(stopped) {
+			p.ProcessStateLock.Unlock()
+			return
+		}
+		p.CurrentDaemonState = CurrentStopped
+		p.ProcessStateLock.Unlock()
+
+		if time.Since(startTime) > RestartBackoffMax {
+			retryCount = 0
+		}
+		retryCount++
+		if retryCount > MaxRetryCountDuringFailures {
+			log.Errorf("Daemon %v exited %v times in a row. Bailing out.", p.Name, retryCount-1)
+			p.ProcessStateLock.Lock()
+			if !isClosed(stopped) {
+				p.supervising = false
+			}
+			p.ProcessStateLock.Unlock()
+			return
+		}
+
+		delay := restartBackoff(retryCount)
+		log.Infof("Daemon %v exited, waiting %v to start it again", p.Name, delay)
+		select {
+		case <-stopped:
+			return
+		case <-time.After(delay):
+		}
+
+		p.ProcessStateLock.Lock()
+		if isClosed(stopped) {
+			p.ProcessStateLock.Unlock()
+			return
+		}
+		if err := p.launch(context, configuration); err != nil {
+			// a daemon that cannot be launched is treated like a daemon that exited right away
+			exited = make(chan struct{})
+			close(exited)
+		} else {
+			exited = p.exited
+			p.CurrentDaemonState = CurrentRunning
+		}
+		p.ProcessStateLock.Unlock()
+	}
+}
+
+// isClosed returns true if the channel has been closed, the channels it is used with are never written to
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// restartBackoff returns the delay before restarting a daemon that crashed retryCount times in a row
+func restartBackoff(retryCount int) time.Duration {
+	delay := RestartBackoffBase
+	for i := 1; i < retryCount && delay < RestartBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > RestartBackoffMax {
+		delay = RestartBackoffMax
+	}
+	return delay
+}
+
+// stopProcessGroup sends SIGTERM to the daemon process group and SIGKILL if it is still alive after StopTimeout
+func stopProcessGroup(context context.T, pid int, exited chan struct{}) error {
+	log := context.Log()
+	if exited == nil {
+		exited = watchProcess(pid)
+	}
+
+	log.Infof("Sending SIGTERM to daemon process group %v", pid)
+	if err := syscall.Kill(-pid, syscall.SIGTERM); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("failed to send SIGTERM to daemon process group %v: %v", pid, err)
+	}
+	select {
+	case <-exited:
+		// make sure no child of the daemon survives its leader
+		syscall.Kill(-pid, syscall.SIGKILL)
+		return nil
+	case <-time.After(StopTimeout):
+	}
+
+	log.Infof("Daemon process group %v did not exit within %v, sending SIGKILL", pid, StopTimeout)
+	if err := syscall.Kill(-pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("failed to send SIGKILL to daemon process group %v: %v", pid, err)
+	}
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(StopTimeout):
+		return fmt.Errorf("daemon process %v is still running after SIGKILL", pid)
+	}
+}
+
+// watchProcess returns a channel that is closed once the process, which is not a child of the agent, is gone
+func watchProcess(pid int) chan struct{} {
+	exited := make(chan struct{})
+	go func() {
+		for isProcessAlive(pid) {
+			time.Sleep(livenessPollInterval)
+		}
+		close(exited)
+	}()
+	return exited
+}
+
+// isProcessAlive probes the process with the null signal
+func isProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
+
+// isDaemonProcess returns true if the daemon process is alive, was started at the recorded time and still leads the
+// process group it was started in, which guards against adopting an unrelated process that reused the pid
+func isDaemonProcess(info contracts.OSProcInfo) bool {
+	if !isProcessAlive(info.Pid) {
+		return false
+	}
+	if same, err := proc.IsSameProcess(info); err != nil || !same {
+		return false
+	}
+	pgid, err := syscall.Getpgid(info.Pid)
+	return err == nil && pgid == info.Pid
+}
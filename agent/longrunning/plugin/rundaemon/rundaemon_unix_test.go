@@ -0,0 +1,184 @@
+// +build darwin freebsd linux netbsd openbsd
+
+// Copyright 2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package rundaemon implements rundaemon plugin and its configuration
+package rundaemon
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/proc"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPlugin(name string) *Plugin {
+	return &Plugin{
+		Name:        name,
+		ExeLocation: os.TempDir(),
+	}
+}
+
+// waitFor polls the condition until it is true or the timeout elapses
+func waitFor(timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return condition()
+}
+
+func TestStartStop(t *testing.T) {
+	ctx := context.NewMockDefault()
+	p := newTestPlugin("TestStartStop")
+
+	err := p.Start(ctx, "sleep 60", "", task.NewMockDefault(), nil)
+	assert.NoError(t, err)
+
+	pid := p.GetProcessInfo().Pid
+	assert.NotEqual(t, 0, pid)
+	assert.True(t, p.IsRunning(ctx))
+	pgid, err := syscall.Getpgid(pid)
+	assert.NoError(t, err)
+	assert.Equal(t, pid, pgid, "daemon must lead its own process group")
+
+	err = p.Stop(ctx, task.NewMockDefault())
+	assert.NoError(t, err)
+	assert.False(t, p.IsRunning(ctx))
+	assert.False(t, isProcessAlive(pid))
+	assert.Equal(t, 0, p.GetProcessInfo().Pid)
+}
+
+func TestStartIsIdempotent(t *testing.T) {
+	ctx := context.NewMockDefault()
+	p := newTestPlugin("TestStartIsIdempotent")
+	defer p.Stop(ctx, task.NewMockDefault())
+
+	assert.NoError(t, p.Start(ctx, "sleep 60", "", task.NewMockDefault(), nil))
+	pid := p.GetProcessInfo().Pid
+	assert.NoError(t, p.Start(ctx, "sleep 60", "", task.NewMockDefault(), nil))
+	assert.Equal(t, pid, p.GetProcessInfo().Pid)
+}
+
+func TestRestartAfterCrash(t *testing.T) {
+	origBackoff := RestartBackoffBase
+	RestartBackoffBase = 10 * time.Millisecond
+	defer func() { RestartBackoffBase = origBackoff }()
+
+	ctx := context.NewMockDefault()
+	p := newTestPlugin("TestRestartAfterCrash")
+	defer p.Stop(ctx, task.NewMockDefault())
+
+	assert.NoError(t, p.Start(ctx, "sleep 60", "", task.NewMockDefault(), nil))
+	pid := p.GetProcessInfo().Pid
+
+	syscall.Kill(-pid, syscall.SIGKILL)
+
+	restarted := waitFor(5*time.Second, func() bool {
+		newPid := p.GetProcessInfo().Pid
+		return newPid != 0 && newPid != pid && p.IsRunning(ctx)
+	})
+	assert.True(t, restarted, "daemon should be restarted after it crashed")
+}
+
+func TestStopEscalatesToSigkill(t *testing.T) {
+	origTimeout := StopTimeout
+	StopTimeout = 200 * time.Millisecond
+	defer func() { StopTimeout = origTimeout }()
+
+	ctx := context.NewMockDefault()
+	p := newTestPlugin("TestStopEscalatesToSigkill")
+
+	assert.NoError(t, p.Start(ctx, "trap '' TERM; while true; do sleep 1; done", "", task.NewMockDefault(), nil))
+	pid := p.GetProcessInfo().Pid
+	// give the shell time to install its trap
+	time.Sleep(100 * time.Millisecond)
+
+	assert.NoError(t, p.Stop(ctx, task.NewMockDefault()))
+	assert.False(t, isProcessAlive(pid))
+}
+
+func TestStartAdoptsRunningDaemon(t *testing.T) {
+	ctx := context.NewMockDefault()
+	// a daemon left running by a previous run of the agent
+	cmd := exec.Command("sleep", "60")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	assert.NoError(t, cmd.Start())
+	go cmd.Wait()
+
+	p := newTestPlugin("TestStartAdoptsRunningDaemon")
+	info := proc.IdentifyProcess(cmd.Process.Pid, time.Now())
+	p.SetProcessInfo(info)
+
+	assert.NoError(t, p.Start(ctx, "sleep 60", "", task.NewMockDefault(), nil))
+	assert.Equal(t, info, p.GetProcessInfo())
+	assert.True(t, p.IsRunning(ctx))
+
+	assert.NoError(t, p.Stop(ctx, task.NewMockDefault()))
+	assert.True(t, waitFor(5*time.Second, func() bool { return !isProcessAlive(cmd.Process.Pid) }))
+}
+
+func TestStartDoesNotAdoptUnrelatedProcess(t *testing.T) {
+	ctx := context.NewMockDefault()
+	p := newTestPlugin("TestStartDoesNotAdoptUnrelatedProcess")
+	defer p.Stop(ctx, task.NewMockDefault())
+
+	// the test process is not the leader of its process group
+	if pgid, _ := syscall.Getpgid(os.Getpid()); pgid == os.Getpid() {
+		t.Skip("test process leads its own process group")
+	}
+	p.SetProcessInfo(contracts.OSProcInfo{Pid: os.Getpid()})
+
+	assert.NoError(t, p.Start(ctx, "sleep 60", "", task.NewMockDefault(), nil))
+	assert.NotEqual(t, os.Getpid(), p.GetProcessInfo().Pid)
+}
+
+func TestStartDoesNotAdoptProcessReusingPid(t *testing.T) {
+	ctx := context.NewMockDefault()
+	// a process leading its process group that reused the pid of the daemon started by a previous run of the agent
+	cmd := exec.Command("sleep", "60")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	assert.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+	go cmd.Wait()
+	info := proc.IdentifyProcess(cmd.Process.Pid, time.Now())
+	if info.BootID == "" {
+		t.Skip("the start ticks of the processes are not available")
+	}
+	info.StartTicks--
+
+	p := newTestPlugin("TestStartDoesNotAdoptProcessReusingPid")
+	defer p.Stop(ctx, task.NewMockDefault())
+	p.SetProcessInfo(info)
+
+	assert.NoError(t, p.Start(ctx, "sleep 60", "", task.NewMockDefault(), nil))
+	assert.NotEqual(t, cmd.Process.Pid, p.GetProcessInfo().Pid)
+	assert.True(t, isProcessAlive(cmd.Process.Pid))
+}
+
+func TestRestartBackoff(t *testing.T) {
+	assert.Equal(t, RestartBackoffBase, restartBackoff(1))
+	assert.Equal(t, 2*RestartBackoffBase, restartBackoff(2))
+	assert.Equal(t, RestartBackoffMax, restartBackoff(MaxRetryCountDuringFailures))
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package parameterprovider
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/statestore"
+)
+
+const (
+	// cacheBucket is the bucket of the local state store holding the cache, keyed by the names used to resolve the
+	// parameters. The state store serializes the updates of the agent and of the document workers.
+	cacheBucket = "ssmparameters"
+
+	// maxCacheAge is the age of the entries removed from the cache, the expired entries being kept until then in
+	// case Parameter Store can't be called
+	maxCacheAge = 7 * 24 * time.Hour
+)
+
+// cacheEntry is a parameter of the cache
+type cacheEntry struct {
+	Parameter
+	CachedAt time.Time
+}
+
+// cache is the parameters cached in the local state store. The secure strings are never cached, so that their values
+// aren't kept at rest.
+type cache map[string]cacheEntry
+
+// loadCache returns the cached parameters with the given names, an empty cache if the cache can't be read
+func loadCache(log log.T, names []string) cache {
+	c := make(cache)
+	store, err := openStore(log)
+	if err == nil {
+		err = store.View(func(tx statestore.Tx) error {
+			for _, name := range names {
+				var entry cacheEntry
+				if found, err := statestore.GetJSON(tx, cacheBucket, name, &entry); err != nil {
+					return err
+				} else if found && entry.Type != secureStringType {
+					c[name] = entry
+				}
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		log.Warnf("Failed to read the cached values of the SSM parameters: %v", err)
+		return make(cache)
+	}
+	return c
+}
+
+// updateCache caches the parameters retrieved from Parameter Store, removes the parameters which don't exist anymore
+// and the entries older than maxCacheAge
+func updateCache(log log.T, parameters []Parameter, names []string, invalidNames []string) {
+	store, err := openStore(log)
+	if err == nil {
+		err = store.Update(func(tx statestore.Tx) error {
+			for i, parameter := range parameters {
+				if parameter.Type == secureStringType {
+					continue
+				}
+				if err := statestore.PutJSON(tx, cacheBucket, names[i], cacheEntry{Parameter: parameter, CachedAt: now()}); err != nil {
+					return err
+				}
+			}
+			for _, name := range invalidNames {
+				if err := tx.Delete(cacheBucket, name); err != nil {
+					return err
+				}
+			}
+			return removeExpiredEntries(tx)
+		})
+	}
+	if err != nil {
+		log.Warnf("Failed to cache the values of the SSM parameters: %v", err)
+	}
+}
+
+// removeExpiredEntries removes the entries older than maxCacheAge, and the ones that can't be read
+func removeExpiredEntries(tx statestore.Tx) error {
+	for _, name := range tx.Keys(cacheBucket) {
+		var entry cacheEntry
+		if _, err := statestore.GetJSON(tx, cacheBucket, name, &entry); err == nil && now().Sub(entry.CachedAt) <= maxCacheAge {
+			continue
+		}
+		if err := tx.Delete(cacheBucket, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package parameterprovider
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/statestore"
+)
+
+// dependency for the cache
+var openStore = statestore.OpenDefault
+
+// dependencies for the local parameter file
+var fileExists = fileutil.Exists
+var unmarshalFile = jsonutil.UnmarshalFile
+
+var now = time.Now
+
+var loadConfig = func() appconfig.SsmagentConfig {
+	config, err := appconfig.Config(false)
+	if err != nil {
+		return appconfig.DefaultConfig()
+	}
+	return config
+}
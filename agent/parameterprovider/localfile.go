@@ -0,0 +1,34 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package parameterprovider
+
+import "fmt"
+
+// localParameterFile is the local file of parameter values resolved before Parameter Store, for example
+// {"Parameters": [{"Name": "/app/endpoint", "Type": "String", "Value": "https://example.com", "Version": 1}]}
+type localParameterFile struct {
+	Parameters []Parameter
+}
+
+// loadLocalParameters returns the parameters of the local file, none if the file doesn't exist
+func loadLocalParameters(path string) ([]Parameter, error) {
+	if path == "" || !fileExists(path) {
+		return nil, nil
+	}
+	var file localParameterFile
+	if err := unmarshalFile(path, &file); err != nil {
+		return nil, fmt.Errorf("Failed to read the local parameter file %v: %v", path, err)
+	}
+	return file.Parameters, nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package parameterprovider resolves the SSM parameters referenced by the documents from the local parameter file,
+// the cache of the values previously retrieved and Parameter Store, logging the source of each value.
+package parameterprovider
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// Sources of the values of the parameters
+const (
+	SourceLocalFile      = "local parameter file"
+	SourceCache          = "cache"
+	SourceExpiredCache   = "expired cache"
+	SourceParameterStore = "Parameter Store"
+)
+
+const secureStringType = "SecureString"
+
+// Parameter is the value of an SSM parameter
+type Parameter struct {
+	Name    string
+	Type    string
+	Value   string
+	Version int64
+}
+
+// FetchFunc retrieves the parameters from Parameter Store, returning the names of the parameters which don't exist
+type FetchFunc func(log log.T, names []string) (parameters []Parameter, invalidNames []string, err error)
+
+// Resolve returns the parameters with the given names, keyed by name. A name can be followed by the version of the
+// parameter, i.e. name:3. The parameters are looked up in the local parameter file, then in the cache, and the
+// others are retrieved from Parameter Store. The cache is only used when ParameterCacheTTLSeconds is set in appconfig,
+// and the secure strings are never cached. The expired values of the cache are
+// used when Parameter Store can't be called because of a network, throttling or server error, the error being returned
+// only if a parameter can't be resolved without Parameter Store. Any other error, i.e. an access denied, is returned.
+func Resolve(log log.T, names []string, fetch FetchFunc) (parameters map[string]Parameter, invalidNames []string, err error) {
+	config := loadConfig()
+	parameters = make(map[string]Parameter)
+	sources := make(map[string]string)
+
+	var local []Parameter
+	if local, err = loadLocalParameters(config.Ssm.ParameterFile); err != nil {
+		return nil, nil, err
+	}
+
+	ttl := time.Duration(config.Ssm.ParameterCacheTTLSeconds) * time.Second
+	cached := make(cache)
+	if ttl > 0 {
+		cached = loadCache(log, names)
+	}
+	expired := make(map[string]Parameter)
+	var toFetch []string
+	for _, name := range names {
+		if parameter, found := match(name, local); found {
+			parameters[name], sources[name] = parameter, SourceLocalFile
+			continue
+		}
+		if entry, found := cached[name]; found {
+			if now().Sub(entry.CachedAt) < ttl {
+				parameters[name], sources[name] = entry.Parameter, SourceCache
+				continue
+			}
+			expired[name] = entry.Parameter
+		}
+		toFetch = append(toFetch, name)
+	}
+
+	if len(toFetch) > 0 {
+		var fetched []Parameter
+		if fetched, invalidNames, err = fetch(log, toFetch); err != nil {
+			if !isUnavailable(err) {
+				return nil, nil, err
+			}
+			for _, name := range toFetch {
+				if _, found := expired[name]; !found {
+					return nil, nil, err
+				}
+			}
+			log.Warnf("Failed to retrieve the SSM parameters from %v, using the expired values of the cache: %v", SourceParameterStore, err)
+			for _, name := range toFetch {
+				parameters[name], sources[name] = expired[name], SourceExpiredCache
+			}
+			err = nil
+		} else {
+			var toCache []Parameter
+			var cachedNames []string
+			for _, name := range toFetch {
+				if parameter, found := match(name, fetched); found {
+					parameters[name], sources[name] = parameter, SourceParameterStore
+					toCache, cachedNames = append(toCache, parameter), append(cachedNames, name)
+				}
+			}
+			if ttl > 0 {
+				updateCache(log, toCache, cachedNames, invalidNames)
+			}
+		}
+	}
+
+	for _, name := range names {
+		if source, found := sources[name]; found {
+			log.Infof("SSM parameter %v resolved from %v", name, source)
+		}
+	}
+	return parameters, invalidNames, nil
+}
+
+// match returns the parameter with the given name, the latest version when the name isn't followed by a version
+func match(name string, parameters []Parameter) (result Parameter, found bool) {
+	parameterName, version := name, int64(0)
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		if v, err := strconv.ParseInt(name[i+1:], 10, 64); err == nil {
+			parameterName, version = name[:i], v
+		}
+	}
+	for _, parameter := range parameters {
+		if parameter.Name != parameterName {
+			continue
+		}
+		if version != 0 && parameter.Version == version {
+			return parameter, true
+		}
+		if version == 0 && (!found || parameter.Version > result.Version) {
+			result, found = parameter, true
+		}
+	}
+	return
+}
+
+// isUnavailable returns whether the error tells that Parameter Store can't be called, i.e. a network, throttling or
+// server error, rather than that the instance isn't allowed to retrieve the parameters
+func isUnavailable(err error) bool {
+	if request.IsErrorRetryable(err) || request.IsErrorThrottle(err) {
+		return true
+	}
+	if failure, ok := err.(awserr.RequestFailure); ok {
+		return failure.StatusCode() >= http.StatusInternalServerError
+	}
+	return false
+}
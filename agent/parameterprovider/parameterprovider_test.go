@@ -0,0 +1,304 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package parameterprovider
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/statestore"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testParameterFile = "/etc/amazon/ssm/parameters.json"
+
+// testCacheTTLSeconds enables the cache, which is disabled by default
+const testCacheTTLSeconds = 300
+
+var testTime = time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// fetchMock is a mocked Parameter Store
+type fetchMock struct {
+	parameters []Parameter
+	err        error
+	fetched    [][]string
+}
+
+func (f *fetchMock) fetch(log log.T, names []string) ([]Parameter, []string, error) {
+	f.fetched = append(f.fetched, names)
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	var parameters []Parameter
+	var invalidNames []string
+	for _, name := range names {
+		if parameter, found := match(name, f.parameters); found {
+			parameters = append(parameters, parameter)
+		} else {
+			invalidNames = append(invalidNames, name)
+		}
+	}
+	return parameters, invalidNames, nil
+}
+
+// setUp resolves the parameters with a state store in a temporary folder, the returned function removing it
+func setUp(t *testing.T, local []Parameter) (store *statestore.FileStore, tearDown func()) {
+	dir, err := ioutil.TempDir("", "parameterprovider")
+	assert.NoError(t, err)
+	store, _, err = statestore.Open(dir)
+	assert.NoError(t, err)
+	openStore = func(log.T) (statestore.Store, error) { return store, nil }
+	now = func() time.Time { return testTime }
+	loadConfig = func() appconfig.SsmagentConfig {
+		config := appconfig.DefaultConfig()
+		config.Ssm.ParameterFile = testParameterFile
+		config.Ssm.ParameterCacheTTLSeconds = testCacheTTLSeconds
+		return config
+	}
+	fileExists = func(path string) bool { return local != nil && path == testParameterFile }
+	unmarshalFile = func(path string, dest interface{}) error {
+		dest.(*localParameterFile).Parameters = local
+		return nil
+	}
+	return store, func() {
+		store.Close()
+		os.RemoveAll(dir)
+		openStore = statestore.OpenDefault
+	}
+}
+
+func newLog() *log.Mock {
+	logMock := log.NewMockLog()
+	logMock.On("Warnf", mock.Anything, mock.Anything).Return(nil)
+	return logMock
+}
+
+func assertSource(t *testing.T, logMock *log.Mock, name string, source string) {
+	logMock.AssertCalled(t, "Infof", "SSM parameter %v resolved from %v", []interface{}{name, source})
+}
+
+func TestResolveFromParameterStore(t *testing.T) {
+	_, tearDown := setUp(t, nil)
+	defer tearDown()
+	logMock := newLog()
+	service := &fetchMock{parameters: []Parameter{{Name: "/app/endpoint", Type: "String", Value: "https://example.com", Version: 2}}}
+
+	parameters, invalidNames, err := Resolve(logMock, []string{"/app/endpoint", "/app/missing"}, service.fetch)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/app/missing"}, invalidNames)
+	assert.Equal(t, map[string]Parameter{"/app/endpoint": service.parameters[0]}, parameters)
+	assertSource(t, logMock, "/app/endpoint", SourceParameterStore)
+
+	// the value is cached until it expires
+	now = func() time.Time { return testTime.Add(time.Minute) }
+	parameters, _, err = Resolve(logMock, []string{"/app/endpoint"}, service.fetch)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com", parameters["/app/endpoint"].Value)
+	assert.Len(t, service.fetched, 1)
+	assertSource(t, logMock, "/app/endpoint", SourceCache)
+
+	now = func() time.Time { return testTime.Add(time.Hour) }
+	service.parameters[0].Value = "https://example.org"
+	parameters, _, err = Resolve(logMock, []string{"/app/endpoint"}, service.fetch)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.org", parameters["/app/endpoint"].Value)
+	assert.Len(t, service.fetched, 2)
+}
+
+func TestResolveCacheDisabled(t *testing.T) {
+	store, tearDown := setUp(t, nil)
+	defer tearDown()
+	loadConfig = func() appconfig.SsmagentConfig { return appconfig.DefaultConfig() }
+	logMock := newLog()
+	service := &fetchMock{parameters: []Parameter{{Name: "/app/endpoint", Type: "String", Value: "https://example.com"}}}
+
+	// every resolution calls Parameter Store, nothing being cached
+	for i := 0; i < 2; i++ {
+		parameters, _, err := Resolve(logMock, []string{"/app/endpoint"}, service.fetch)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com", parameters["/app/endpoint"].Value)
+	}
+	assert.Len(t, service.fetched, 2)
+	logMock.AssertNotCalled(t, "Infof", "SSM parameter %v resolved from %v", []interface{}{"/app/endpoint", SourceCache})
+	assert.NoError(t, store.View(func(tx statestore.Tx) error {
+		assert.Empty(t, tx.Keys(cacheBucket))
+		return nil
+	}))
+
+	// without a cache, Parameter Store being unavailable is an error
+	service.err = awserr.New("RequestError", "send request failed", errors.New("connection refused"))
+	_, _, err := Resolve(logMock, []string{"/app/endpoint"}, service.fetch)
+	assert.Error(t, err)
+}
+
+func TestResolveFromLocalFile(t *testing.T) {
+	_, tearDown := setUp(t, []Parameter{{Name: "/app/endpoint", Type: "String", Value: "http://localhost"}})
+	defer tearDown()
+	logMock := newLog()
+	service := &fetchMock{parameters: []Parameter{{Name: "/app/port", Type: "String", Value: "80"}}}
+
+	parameters, _, err := Resolve(logMock, []string{"/app/endpoint", "/app/port"}, service.fetch)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost", parameters["/app/endpoint"].Value)
+	assert.Equal(t, "80", parameters["/app/port"].Value)
+	assert.Equal(t, [][]string{{"/app/port"}}, service.fetched)
+	assertSource(t, logMock, "/app/endpoint", SourceLocalFile)
+	assertSource(t, logMock, "/app/port", SourceParameterStore)
+}
+
+func TestResolveInvalidLocalFile(t *testing.T) {
+	_, tearDown := setUp(t, []Parameter{})
+	defer tearDown()
+	unmarshalFile = func(path string, dest interface{}) error { return errors.New("invalid character") }
+	service := &fetchMock{}
+
+	_, _, err := Resolve(newLog(), []string{"/app/endpoint"}, service.fetch)
+
+	assert.EqualError(t, err, "Failed to read the local parameter file /etc/amazon/ssm/parameters.json: invalid character")
+	assert.Empty(t, service.fetched)
+}
+
+func TestResolveParameterStoreUnavailable(t *testing.T) {
+	_, tearDown := setUp(t, nil)
+	defer tearDown()
+	logMock := newLog()
+	service := &fetchMock{parameters: []Parameter{{Name: "/app/endpoint", Type: "String", Value: "https://example.com"}}}
+	Resolve(logMock, []string{"/app/endpoint"}, service.fetch)
+
+	// the expired values are used when Parameter Store can't be called
+	now = func() time.Time { return testTime.Add(time.Hour) }
+	unavailableErrors := []error{
+		awserr.New("RequestError", "send request failed", nil),
+		awserr.New("ThrottlingException", "Rate exceeded", nil),
+		awserr.NewRequestFailure(awserr.New("InternalServerError", "internal error", nil), 500, "request-id"),
+	}
+	for _, unavailableErr := range unavailableErrors {
+		service.err = unavailableErr
+		parameters, _, err := Resolve(logMock, []string{"/app/endpoint"}, service.fetch)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com", parameters["/app/endpoint"].Value)
+		assertSource(t, logMock, "/app/endpoint", SourceExpiredCache)
+	}
+
+	// unless some parameters aren't cached
+	_, _, err := Resolve(logMock, []string{"/app/endpoint", "/app/port"}, service.fetch)
+
+	assert.Equal(t, unavailableErrors[2], err)
+}
+
+func TestResolveAccessDenied(t *testing.T) {
+	_, tearDown := setUp(t, nil)
+	defer tearDown()
+	service := &fetchMock{parameters: []Parameter{{Name: "/app/endpoint", Type: "String", Value: "https://example.com"}}}
+	Resolve(newLog(), []string{"/app/endpoint"}, service.fetch)
+
+	// the expired values aren't used once the instance isn't allowed to retrieve the parameters
+	now = func() time.Time { return testTime.Add(time.Hour) }
+	service.err = awserr.NewRequestFailure(awserr.New("AccessDeniedException", "not authorized", nil), 400, "request-id")
+	_, _, err := Resolve(newLog(), []string{"/app/endpoint"}, service.fetch)
+
+	assert.Equal(t, service.err, err)
+}
+
+func TestResolveSecureStringIsNotCached(t *testing.T) {
+	store, tearDown := setUp(t, nil)
+	defer tearDown()
+	service := &fetchMock{parameters: []Parameter{
+		{Name: "/app/password", Type: secureStringType, Value: "secret"},
+		{Name: "/app/endpoint", Type: "String", Value: "https://example.com"},
+	}}
+
+	parameters, _, err := Resolve(newLog(), []string{"/app/password", "/app/endpoint"}, service.fetch)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", parameters["/app/password"].Value)
+	assert.NoError(t, store.View(func(tx statestore.Tx) error {
+		assert.Equal(t, []string{"/app/endpoint"}, tx.Keys(cacheBucket))
+		return nil
+	}))
+
+	// the secure strings are retrieved from Parameter Store every time
+	Resolve(newLog(), []string{"/app/password", "/app/endpoint"}, service.fetch)
+
+	assert.Equal(t, [][]string{{"/app/password", "/app/endpoint"}, {"/app/password"}}, service.fetched)
+}
+
+func TestResolveRemovesExpiredEntries(t *testing.T) {
+	store, tearDown := setUp(t, nil)
+	defer tearDown()
+	service := &fetchMock{parameters: []Parameter{
+		{Name: "/app/endpoint", Type: "String", Value: "https://example.com"},
+		{Name: "/app/port", Type: "String", Value: "80"},
+	}}
+	Resolve(newLog(), []string{"/app/endpoint"}, service.fetch)
+
+	now = func() time.Time { return testTime.Add(maxCacheAge + time.Hour) }
+	Resolve(newLog(), []string{"/app/port"}, service.fetch)
+
+	assert.NoError(t, store.View(func(tx statestore.Tx) error {
+		assert.Equal(t, []string{"/app/port"}, tx.Keys(cacheBucket))
+		return nil
+	}))
+}
+
+func TestResolveRemovesInvalidParameters(t *testing.T) {
+	_, tearDown := setUp(t, nil)
+	defer tearDown()
+	service := &fetchMock{parameters: []Parameter{{Name: "/app/endpoint", Type: "String", Value: "https://example.com"}}}
+	Resolve(newLog(), []string{"/app/endpoint"}, service.fetch)
+
+	now = func() time.Time { return testTime.Add(time.Hour) }
+	service.parameters = nil
+	_, invalidNames, err := Resolve(newLog(), []string{"/app/endpoint"}, service.fetch)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/app/endpoint"}, invalidNames)
+	assert.NotContains(t, loadCache(newLog(), []string{"/app/endpoint"}), "/app/endpoint")
+}
+
+func TestMatch(t *testing.T) {
+	parameters := []Parameter{
+		{Name: "/app/endpoint", Version: 1, Value: "v1"},
+		{Name: "/app/endpoint", Version: 3, Value: "v3"},
+		{Name: "/app/endpoint", Version: 2, Value: "v2"},
+	}
+
+	testCases := []struct {
+		name  string
+		found bool
+		value string
+	}{
+		{"/app/endpoint", true, "v3"},
+		{"/app/endpoint:2", true, "v2"},
+		{"/app/endpoint:4", false, ""},
+		{"/app/port", false, ""},
+	}
+	for _, testCase := range testCases {
+		parameter, found := match(testCase.name, parameters)
+		assert.Equal(t, testCase.found, found, testCase.name)
+		assert.Equal(t, testCase.value, parameter.Value, testCase.name)
+	}
+}
@@ -25,6 +25,10 @@ import (
 
 const paramNameRegex = "^[a-zA-Z0-9]+$"
 
+// referenceNameRegex matches the names that can be referenced as {{ name }}, the parameters of the document
+// and the outputs of earlier steps, i.e. {{ stepName.outputName }}
+const referenceNameRegex = "^[a-zA-Z0-9_.-]+$"
+
 // ReplaceParameters traverses an arbitrarily complex input object (maps/slices/strings/etc.)
 // and tries to replace parameters given as {{parameter}} with their values from the parameters map.
 //
@@ -99,14 +103,14 @@ func ReplaceParameters(input interface{}, parameters map[string]interface{}, log
 	}
 }
 
-var singleParamRegex = regexp.MustCompile(paramNameRegex)
+var singleParamRegex = regexp.MustCompile(referenceNameRegex)
 
 // isSingleParameterString returns true if the given string has the form "{{ paramName }}" with
 // some spaces but nothing else.
 func isSingleParameterString(input string, paramName string) bool {
 	if singleParamRegex.MatchString(paramName) {
 		// this method should be called only on parameter names that have been validated first
-		r := regexp.MustCompile(fmt.Sprintf(`^{{\s*%v\s*}}$`, paramName))
+		r := regexp.MustCompile(fmt.Sprintf(`^{{\s*%v\s*}}$`, regexp.QuoteMeta(paramName)))
 		return r.MatchString(input)
 	}
 	return false
@@ -115,8 +119,8 @@ func isSingleParameterString(input string, paramName string) bool {
 // ReplaceParameter replaces all occurrences of "{{ paramName }}" in the input by paramValue.
 func ReplaceParameter(input string, paramName string, paramValue string) string {
 	// this method should be called only on parameter names that have been validated first
-	r := regexp.MustCompile(fmt.Sprintf(`{{\s*%v\s*}}`, paramName))
-	return r.ReplaceAllString(input, paramValue)
+	r := regexp.MustCompile(fmt.Sprintf(`{{\s*%v\s*}}`, regexp.QuoteMeta(paramName)))
+	return r.ReplaceAllLiteralString(input, paramValue)
 }
 
 // ValidParameters checks if parameter names are valid. Returns valid parameters only.
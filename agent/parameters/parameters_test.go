@@ -29,6 +29,11 @@ func ExampleReplaceParameter() {
 	// Output: A name is a name.
 }
 
+func ExampleReplaceParameter_stepOutput() {
+	fmt.Println(ReplaceParameter("echo {{ step1.price }}", "step1.price", "$1"))
+	// Output: echo $1
+}
+
 type IsSingleParameterStringTest struct {
 	Input     string
 	ParamName string
@@ -43,6 +48,8 @@ func TestIsSingleParameterString(t *testing.T) {
 		{"a {{ command}}", "command", false},
 		{"{{ command }} {{ command }}", "command", false},
 		{"{{ co!mmand}}", "co!mmand", false},
+		{"{{ step1.version }}", "step1.version", true},
+		{"{{ step1xversion }}", "step1.version", false},
 	}
 
 	for _, test := range isSingleParameterStringTests {
@@ -22,6 +22,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/parameterprovider"
 	"github.com/aws/amazon-ssm-agent/agent/ssm"
 )
 
@@ -50,6 +51,21 @@ const (
 
 var callParameterService = callGetParameters
 
+// ServiceError is returned when the values of the parameters couldn't be retrieved from the service
+type ServiceError struct {
+	Err error
+}
+
+func (e ServiceError) Error() string {
+	return e.Err.Error()
+}
+
+// IsServiceError returns true if the parameters couldn't be resolved because the service couldn't be called
+func IsServiceError(err error) bool {
+	_, isServiceError := err.(ServiceError)
+	return isServiceError
+}
+
 // Resolve resolves ssm parameters of the format {{ssm:*}}
 func Resolve(log log.T, input interface{}) (interface{}, error) {
 	validSSMParam, err := getValidSSMParamRegexCompiler(log, defaultParamName)
@@ -189,7 +205,7 @@ func getSSMParameterValues(log log.T, ssmParams []string) (map[string]Parameter,
 	}
 
 	if result, err = callParameterService(log, paramNames); err != nil {
-		return nil, err
+		return nil, ServiceError{Err: err}
 	}
 
 	if len(paramNames) != len(result.Parameters) {
@@ -263,8 +279,36 @@ func getSSMParameterValues(log log.T, ssmParams []string) (map[string]Parameter,
 	return resolvedParamMap, nil
 }
 
-// callGetParameters makes a GetParameters API call to the service
+// callGetParameters resolves the parameters from the local parameter file, the cache or the GetParameters API
 func callGetParameters(log log.T, paramNames []string) (*GetParametersResponse, error) {
+	parameters, invalidNames, err := parameterprovider.Resolve(log, paramNames, fetchParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	result := GetParametersResponse{InvalidParameters: invalidNames}
+	for _, paramName := range paramNames {
+		if parameter, found := parameters[paramName]; found {
+			result.Parameters = append(result.Parameters, Parameter(parameter))
+		}
+	}
+	return &result, nil
+}
+
+// fetchParameters retrieves the parameters from Parameter Store
+func fetchParameters(log log.T, paramNames []string) (parameters []parameterprovider.Parameter, invalidNames []string, err error) {
+	var result *GetParametersResponse
+	if result, err = getParameters(log, paramNames); err != nil {
+		return
+	}
+	for _, parameter := range result.Parameters {
+		parameters = append(parameters, parameterprovider.Parameter(parameter))
+	}
+	return parameters, result.InvalidParameters, nil
+}
+
+// getParameters makes a GetParameters API call to the service
+func getParameters(log log.T, paramNames []string) (*GetParametersResponse, error) {
 	finalResult := GetParametersResponse{}
 
 	ssmSvc := ssm.NewService()
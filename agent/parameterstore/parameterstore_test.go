@@ -15,6 +15,7 @@
 package parameterstore
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
@@ -166,6 +167,20 @@ func testResolveMethodWithInvalidCase(t *testing.T, testCase StringTestCase) {
 	assert.NotNil(t, err)
 }
 
+func TestResolveServiceError(t *testing.T) {
+	callParameterService = func(
+		log log.T,
+		paramNames []string) (*GetParametersResponse, error) {
+		return nil, errors.New("RequestError: send request failed")
+	}
+
+	result, err := Resolve(logger, "{{ssm:test}}")
+
+	assert.Equal(t, "{{ssm:test}}", result)
+	assert.True(t, IsServiceError(err))
+	assert.Equal(t, "RequestError: send request failed", err.Error())
+}
+
 func testGetValidSSMParamRegexCompiler(t *testing.T) {
 	validSSMParam, _ := getValidSSMParamRegexCompiler(logger, "test.p1")
 	assert.True(t, validSSMParam.MatchString("test.p1"), "test.p1 should not match test.p1")
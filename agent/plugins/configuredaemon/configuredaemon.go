@@ -132,7 +132,7 @@ func runConfigureDaemon(
 		p.lrpm.EnsurePluginRegistered(input.Name, plugin)
 		p.lrpm.StopPlugin(input.Name, cancelFlag)
 		if errStart := p.lrpm.StartPlugin(input.Name, input.Command, orchestrationDir, cancelFlag, output); errStart != nil {
-			output.AppendErrorf("\nFailed to start ssm daemon %v: %v", input.Name, err.Error())
+			output.AppendErrorf("\nFailed to start ssm daemon %v: %v", input.Name, errStart.Error())
 			output.SetStatus(contracts.ResultStatusFailed)
 			return
 		}
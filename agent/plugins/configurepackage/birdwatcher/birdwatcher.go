@@ -265,6 +265,7 @@ func downloadFile(tracer trace.Tracer, file *File) (string, error) {
 		SourceURL: file.DownloadLocation,
 		// TODO don't hardcode sha256 - use multiple checksums
 		SourceChecksums: file.Checksums,
+		VerifySignature: true,
 	}
 
 	log := tracer.CurrentTrace().Logger
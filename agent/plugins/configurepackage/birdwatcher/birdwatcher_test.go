@@ -692,6 +692,7 @@ func TestDownloadFile(t *testing.T) {
 				input := artifact.DownloadInput{
 					SourceURL:       testdata.file.DownloadLocation,
 					SourceChecksums: map[string]string{"sha256": "asdf"},
+					VerifySignature: true,
 				}
 				assert.Equal(t, input, testdata.network.downloadInput)
 			}
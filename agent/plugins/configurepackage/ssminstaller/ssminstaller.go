@@ -138,7 +138,7 @@ func (inst *Installer) readScriptAction(action *Action, workingDir string, plugi
 		BookKeepingFileName:     inst.config.BookKeepingFileName,
 		PluginName:              pluginFullName,
 		PluginID:                inst.version,
-		Preconditions:           make(map[string][]string),
+		Preconditions:           []contracts.Precondition{},
 		IsPreconditionEnabled:   false,
 		DefaultWorkingDirectory: workingDir,
 	}
@@ -154,7 +154,8 @@ func (*PackageService) ReportResult(tracer trace.Tracer, result packageservice.P
 func downloadPackageFromS3(tracer trace.Tracer, packageS3Source string) (string, error) {
 	// TODO: deduplicate with birdwatcher download
 	downloadInput := artifact.DownloadInput{
-		SourceURL: packageS3Source,
+		SourceURL:       packageS3Source,
+		VerifySignature: true,
 	}
 
 	logger := tracer.CurrentTrace().Logger
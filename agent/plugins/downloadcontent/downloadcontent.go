@@ -113,6 +113,12 @@ func (p *Plugin) execute(context context.T, config contracts.Configuration, canc
 	}
 }
 
+// ValidateInput checks the input of the step without downloading the content
+func (p *Plugin) ValidateInput(context context.T, config contracts.Configuration) error {
+	_, err := parseAndValidateInput(config.Properties)
+	return err
+}
+
 // runCopyContent figures out the type of source, downloads the resource, saves it on disk and returns information required for it
 func (p *Plugin) runCopyContent(log log.T, input *DownloadContentPlugin, config contracts.Configuration, output iohandler.IOHandler) {
 
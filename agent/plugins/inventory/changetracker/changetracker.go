@@ -0,0 +1,375 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package changetracker computes the inventory items added, removed or modified between two collections
+package changetracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/datauploader"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/application"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/awscomponent"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/file"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/instancedetailedinformation"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/network"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/registry"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/role"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/service"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/windowsUpdate"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+const (
+	snapshotDirName  = "snapshot"
+	changeLogDirName = "changelog"
+
+	// changeLogFileExtension is the extension of the change log files, one file of newline delimited JSON per day
+	changeLogFileExtension = ".ndjson"
+	changeLogDateFormat    = "2006-01-02"
+
+	// changeLogRetention is how long the change log files are kept
+	changeLogRetention = 30 * 24 * time.Hour
+)
+
+// keyAttributes are the attributes identifying an item within its inventory type. Items of types without key
+// attributes, such as custom types, are identified by their whole content and can only be added or removed.
+var keyAttributes = map[string][]string{
+	application.GathererName:   {"Name", "Architecture"},
+	awscomponent.GathererName:  {"Name", "Architecture"},
+	file.GathererName:          {"InstalledDir", "Name"},
+	network.GathererName:       {"Name"},
+	registry.GathererName:      {"KeyPath", "ValueName"},
+	role.GathererName:          {"Name"},
+	service.GathererName:       {"Name"},
+	windowsUpdate.GathererName: {"HotFixId"},
+	// instance information is a single item
+	instancedetailedinformation.GathererName: {},
+}
+
+// decoupling time.Now for easy testability
+var now = time.Now
+
+// snapshot is the content of an inventory type as of its last collection
+type snapshot struct {
+	CaptureTime string
+	Content     []map[string]string
+}
+
+// Tracker keeps the last snapshot of each inventory type and logs the changes detected at each collection
+type Tracker struct {
+	log          log.T
+	snapshotDir  string
+	changeLogDir string
+}
+
+// NewTracker creates a tracker keeping its data in the inventory folder of the instance
+func NewTracker(log log.T, machineID string) *Tracker {
+	rootDir := filepath.Join(appconfig.DefaultDataStorePath, machineID, appconfig.InventoryRootDirName)
+	return New(log, filepath.Join(rootDir, snapshotDirName), filepath.Join(rootDir, changeLogDirName))
+}
+
+// New creates a tracker keeping the snapshots and the change log in the given folders
+func New(log log.T, snapshotDir, changeLogDir string) *Tracker {
+	return &Tracker{
+		log:          log,
+		snapshotDir:  snapshotDir,
+		changeLogDir: changeLogDir,
+	}
+}
+
+// TrackChanges compares the collected items with the snapshots of their inventory types, appends the changes to
+// the change log and replaces the snapshots. Nothing is reported for the first collection of an inventory type,
+// nor for the types that weren't collected this time.
+func (t *Tracker) TrackChanges(items []model.Item) (changes []model.ChangeData, err error) {
+	for _, item := range items {
+		current, err := newSnapshot(item)
+		if err != nil {
+			return nil, err
+		}
+
+		previous, found := t.loadSnapshot(item.Name)
+		if found {
+			changes = append(changes, compare(item.Name, previous, current)...)
+		} else {
+			t.log.Infof("No previous snapshot of %v, its changes will be tracked from now on", item.Name)
+		}
+
+		if err = t.saveSnapshot(item.Name, current); err != nil {
+			return nil, err
+		}
+	}
+
+	t.log.Infof("Detected %v changes of the inventory data", len(changes))
+	if err = t.appendToChangeLog(changes); err != nil {
+		return changes, err
+	}
+	t.cleanupChangeLog()
+	return changes, nil
+}
+
+// ChangeItem returns the changes as an item of the custom inventory type the changes are uploaded as
+func ChangeItem(changes []model.ChangeData) model.Item {
+	content := changes
+	if content == nil {
+		// the changes previously uploaded are cleared when nothing changed
+		content = []model.ChangeData{}
+	}
+	return model.Item{
+		Name:          model.InventoryChangeTypeName,
+		SchemaVersion: model.InventoryChangeSchemaVersion,
+		Content:       content,
+		CaptureTime:   now().UTC().Format(time.RFC3339),
+	}
+}
+
+// newSnapshot converts the content of the item to attribute maps, the same way it is sent to SSM
+func newSnapshot(item model.Item) (snapshot, error) {
+	inventoryItem, err := datauploader.ConvertToSSMInventoryItem(item)
+	if err != nil {
+		return snapshot{}, fmt.Errorf("Unable to convert the content of %v - %v", item.Name, err)
+	}
+	result := snapshot{CaptureTime: item.CaptureTime, Content: make([]map[string]string, 0, len(inventoryItem.Content))}
+	for _, entry := range inventoryItem.Content {
+		values := make(map[string]string, len(entry))
+		for attribute, value := range entry {
+			if value != nil {
+				values[attribute] = *value
+			}
+		}
+		result.Content = append(result.Content, values)
+	}
+	return result, nil
+}
+
+// compare returns the changes between two snapshots of an inventory type. Entries are matched by their key attributes;
+// when several entries share the same key, i.e. multiple versions of a package, they are matched by their whole content.
+func compare(typeName string, previous, current snapshot) (changes []model.ChangeData) {
+	keys, hasKey := keyAttributes[typeName]
+	previousByKey := groupByKey(previous.Content, keys, hasKey)
+	currentByKey := groupByKey(current.Content, keys, hasKey)
+
+	newChange := func(changeType, key string) model.ChangeData {
+		return model.ChangeData{
+			TypeName:            typeName,
+			ChangeType:          changeType,
+			Key:                 key,
+			PreviousCaptureTime: previous.CaptureTime,
+			CaptureTime:         current.CaptureTime,
+		}
+	}
+
+	for _, key := range sortedKeys(previousByKey, currentByKey) {
+		previousEntries, currentEntries := previousByKey[key], currentByKey[key]
+
+		if hasKey && len(previousEntries) == 1 && len(currentEntries) == 1 {
+			if attributes := modifiedAttributes(previousEntries[0], currentEntries[0]); len(attributes) > 0 {
+				change := newChange(model.ChangeModified, key)
+				change.Attributes = strings.Join(attributes, ",")
+				change.PreviousValue = toJSON(subset(previousEntries[0], attributes))
+				change.NewValue = toJSON(subset(currentEntries[0], attributes))
+				changes = append(changes, change)
+			}
+			continue
+		}
+
+		removed, added := unmatched(previousEntries, currentEntries)
+		for _, entry := range removed {
+			change := newChange(model.ChangeRemoved, key)
+			change.PreviousValue = toJSON(entry)
+			changes = append(changes, change)
+		}
+		for _, entry := range added {
+			change := newChange(model.ChangeAdded, key)
+			change.NewValue = toJSON(entry)
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// groupByKey groups the entries by the values of their key attributes, i.e. Name=nginx,Architecture=x86_64
+func groupByKey(entries []map[string]string, keys []string, hasKey bool) map[string][]map[string]string {
+	groups := make(map[string][]map[string]string)
+	for _, entry := range entries {
+		var key string
+		if hasKey {
+			var parts []string
+			for _, attribute := range keys {
+				parts = append(parts, attribute+"="+entry[attribute])
+			}
+			key = strings.Join(parts, ",")
+		}
+		groups[key] = append(groups[key], entry)
+	}
+	return groups
+}
+
+// unmatched returns the entries of each list which have no identical entry in the other list
+func unmatched(previous, current []map[string]string) (removed, added []map[string]string) {
+	matched := make([]bool, len(current))
+	for _, previousEntry := range previous {
+		found := false
+		for i, currentEntry := range current {
+			if !matched[i] && reflect.DeepEqual(previousEntry, currentEntry) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			removed = append(removed, previousEntry)
+		}
+	}
+	for i, currentEntry := range current {
+		if !matched[i] {
+			added = append(added, currentEntry)
+		}
+	}
+	return
+}
+
+// modifiedAttributes returns the sorted names of the attributes whose value differs between the entries
+func modifiedAttributes(previous, current map[string]string) (attributes []string) {
+	for attribute, value := range previous {
+		if currentValue, found := current[attribute]; !found || currentValue != value {
+			attributes = append(attributes, attribute)
+		}
+	}
+	for attribute := range current {
+		if _, found := previous[attribute]; !found {
+			attributes = append(attributes, attribute)
+		}
+	}
+	sort.Strings(attributes)
+	return
+}
+
+func subset(entry map[string]string, attributes []string) map[string]string {
+	result := make(map[string]string, len(attributes))
+	for _, attribute := range attributes {
+		if value, found := entry[attribute]; found {
+			result[attribute] = value
+		}
+	}
+	return result
+}
+
+func sortedKeys(groups ...map[string][]map[string]string) (keys []string) {
+	seen := make(map[string]bool)
+	for _, group := range groups {
+		for key := range group {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return
+}
+
+func toJSON(value interface{}) string {
+	dataB, _ := json.Marshal(value)
+	return string(dataB)
+}
+
+// snapshotPath returns the path of the snapshot of an inventory type, the colon of the type name isn't allowed in
+// file names on Windows
+func (t *Tracker) snapshotPath(typeName string) string {
+	return filepath.Join(t.snapshotDir, strings.Replace(typeName, ":", "_", -1)+".json")
+}
+
+func (t *Tracker) loadSnapshot(typeName string) (result snapshot, found bool) {
+	path := t.snapshotPath(typeName)
+	if !fileutil.Exists(path) {
+		return result, false
+	}
+	content, err := fileutil.ReadAllText(path)
+	if err == nil {
+		err = json.Unmarshal([]byte(content), &result)
+	}
+	if err != nil {
+		t.log.Errorf("Unable to read the snapshot of %v, its changes will be tracked from now on - %v", typeName, err)
+		return result, false
+	}
+	return result, true
+}
+
+func (t *Tracker) saveSnapshot(typeName string, current snapshot) error {
+	if err := fileutil.MakeDirs(t.snapshotDir); err != nil {
+		return fmt.Errorf("Unable to create the snapshot folder %v - %v", t.snapshotDir, err)
+	}
+	dataB, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	path := t.snapshotPath(typeName)
+	if _, err = fileutil.WriteIntoFileWithPermissions(path, string(dataB), appconfig.ReadWriteAccess); err != nil {
+		return fmt.Errorf("Unable to save the snapshot of %v - %v", typeName, err)
+	}
+	return nil
+}
+
+// appendToChangeLog appends the changes to the change log of the day
+func (t *Tracker) appendToChangeLog(changes []model.ChangeData) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	if err := fileutil.MakeDirs(t.changeLogDir); err != nil {
+		return fmt.Errorf("Unable to create the change log folder %v - %v", t.changeLogDir, err)
+	}
+	path := filepath.Join(t.changeLogDir, now().UTC().Format(changeLogDateFormat)+changeLogFileExtension)
+	changeLog, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, appconfig.ReadWriteAccess)
+	if err != nil {
+		return fmt.Errorf("Unable to open the change log %v - %v", path, err)
+	}
+	defer changeLog.Close()
+
+	encoder := json.NewEncoder(changeLog)
+	for _, change := range changes {
+		if err = encoder.Encode(change); err != nil {
+			return fmt.Errorf("Unable to write the change log %v - %v", path, err)
+		}
+	}
+	return nil
+}
+
+// cleanupChangeLog deletes the change log files older than the retention
+func (t *Tracker) cleanupChangeLog() {
+	files, err := fileutil.GetFileNames(t.changeLogDir)
+	if err != nil {
+		return
+	}
+	oldest := now().UTC().Add(-changeLogRetention).Format(changeLogDateFormat)
+	for _, name := range files {
+		if !strings.HasSuffix(name, changeLogFileExtension) {
+			continue
+		}
+		// dates in the file names sort in chronological order
+		if strings.TrimSuffix(name, changeLogFileExtension) < oldest {
+			if err = os.Remove(filepath.Join(t.changeLogDir, name)); err != nil {
+				t.log.Errorf("Unable to delete the change log %v - %v", name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package changetracker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger = log.NewMockLog()
+
+// newTestTracker creates a tracker in a temporary folder and returns a function deleting it
+func newTestTracker(t *testing.T) (*Tracker, func()) {
+	dir, err := ioutil.TempDir("", "changetracker")
+	assert.NoError(t, err)
+	return New(logger, filepath.Join(dir, snapshotDirName), filepath.Join(dir, changeLogDirName)), func() { os.RemoveAll(dir) }
+}
+
+// setNow sets the current time of the tracker and returns a function restoring it
+func setNow(current time.Time) func() {
+	original := now
+	now = func() time.Time { return current }
+	return func() { now = original }
+}
+
+func applications(captureTime string, apps ...model.ApplicationData) model.Item {
+	return model.Item{Name: "AWS:Application", SchemaVersion: "1.1", CaptureTime: captureTime, Content: apps}
+}
+
+func TestTrackChanges(t *testing.T) {
+	tracker, cleanup := newTestTracker(t)
+	defer cleanup()
+	defer setNow(time.Date(2018, 3, 2, 10, 0, 0, 0, time.UTC))()
+
+	// nothing is reported for the first collection
+	changes, err := tracker.TrackChanges([]model.Item{applications("2018-03-01T10:00:00Z",
+		model.ApplicationData{Name: "nginx", Version: "1.12.1", Architecture: "x86_64"},
+		model.ApplicationData{Name: "kernel", Version: "4.9.75", Architecture: "x86_64"},
+		model.ApplicationData{Name: "telnet", Version: "0.17", Architecture: "x86_64"},
+	)})
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	changes, err = tracker.TrackChanges([]model.Item{applications("2018-03-02T10:00:00Z",
+		model.ApplicationData{Name: "nginx", Version: "1.12.2", Architecture: "x86_64"},
+		model.ApplicationData{Name: "kernel", Version: "4.9.75", Architecture: "x86_64"},
+		model.ApplicationData{Name: "kernel", Version: "4.9.81", Architecture: "x86_64"},
+		model.ApplicationData{Name: "curl", Version: "7.53.1", Architecture: "x86_64"},
+	)})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ChangeData{
+		{
+			TypeName:            "AWS:Application",
+			ChangeType:          model.ChangeAdded,
+			Key:                 "Name=curl,Architecture=x86_64",
+			NewValue:            `{"Architecture":"x86_64","Name":"curl","Publisher":"","Version":"7.53.1"}`,
+			PreviousCaptureTime: "2018-03-01T10:00:00Z",
+			CaptureTime:         "2018-03-02T10:00:00Z",
+		},
+		{
+			TypeName:            "AWS:Application",
+			ChangeType:          model.ChangeAdded,
+			Key:                 "Name=kernel,Architecture=x86_64",
+			NewValue:            `{"Architecture":"x86_64","Name":"kernel","Publisher":"","Version":"4.9.81"}`,
+			PreviousCaptureTime: "2018-03-01T10:00:00Z",
+			CaptureTime:         "2018-03-02T10:00:00Z",
+		},
+		{
+			TypeName:            "AWS:Application",
+			ChangeType:          model.ChangeModified,
+			Key:                 "Name=nginx,Architecture=x86_64",
+			Attributes:          "Version",
+			PreviousValue:       `{"Version":"1.12.1"}`,
+			NewValue:            `{"Version":"1.12.2"}`,
+			PreviousCaptureTime: "2018-03-01T10:00:00Z",
+			CaptureTime:         "2018-03-02T10:00:00Z",
+		},
+		{
+			TypeName:            "AWS:Application",
+			ChangeType:          model.ChangeRemoved,
+			Key:                 "Name=telnet,Architecture=x86_64",
+			PreviousValue:       `{"Architecture":"x86_64","Name":"telnet","Publisher":"","Version":"0.17"}`,
+			PreviousCaptureTime: "2018-03-01T10:00:00Z",
+			CaptureTime:         "2018-03-02T10:00:00Z",
+		},
+	}, changes)
+
+	// the changes are logged locally
+	content, err := fileutil.ReadAllText(filepath.Join(tracker.changeLogDir, "2018-03-02.ndjson"))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	assert.Equal(t, 4, len(lines))
+	var logged model.ChangeData
+	assert.NoError(t, json.Unmarshal([]byte(lines[2]), &logged))
+	assert.Equal(t, changes[2], logged)
+}
+
+func TestTrackChanges_TypesWithoutKey(t *testing.T) {
+	tracker, cleanup := newTestTracker(t)
+	defer cleanup()
+
+	custom := func(captureTime, value string) model.Item {
+		return model.Item{Name: "Custom:RackInfo", CaptureTime: captureTime, Content: []map[string]string{{"RackLocation": value}}}
+	}
+	_, err := tracker.TrackChanges([]model.Item{custom("2018-03-01T10:00:00Z", "Bay B/Row C/Rack D")})
+	assert.NoError(t, err)
+
+	changes, err := tracker.TrackChanges([]model.Item{custom("2018-03-02T10:00:00Z", "Bay A/Row C/Rack D")})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(changes))
+	assert.Equal(t, model.ChangeRemoved, changes[0].ChangeType)
+	assert.Equal(t, model.ChangeAdded, changes[1].ChangeType)
+	assert.Equal(t, `{"RackLocation":"Bay A/Row C/Rack D"}`, changes[1].NewValue)
+}
+
+func TestCleanupChangeLog(t *testing.T) {
+	tracker, cleanup := newTestTracker(t)
+	defer cleanup()
+	defer setNow(time.Date(2018, 3, 31, 10, 0, 0, 0, time.UTC))()
+
+	assert.NoError(t, fileutil.MakeDirs(tracker.changeLogDir))
+	for _, name := range []string{"2018-02-27.ndjson", "2018-03-01.ndjson", "2018-03-30.ndjson", "notes.txt"} {
+		assert.NoError(t, fileutil.WriteAllText(filepath.Join(tracker.changeLogDir, name), "{}"))
+	}
+
+	tracker.cleanupChangeLog()
+
+	files, err := fileutil.GetFileNames(tracker.changeLogDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2018-03-01.ndjson", "2018-03-30.ndjson", "notes.txt"}, files)
+}
+
+func TestChangeItem(t *testing.T) {
+	defer setNow(time.Date(2018, 3, 2, 10, 0, 0, 0, time.UTC))()
+
+	item := ChangeItem(nil)
+
+	assert.Equal(t, model.InventoryChangeTypeName, item.Name)
+	assert.Equal(t, "2018-03-02T10:00:00Z", item.CaptureTime)
+	// an empty list clears the changes previously uploaded
+	assert.Equal(t, []model.ChangeData{}, item.Content)
+}
@@ -0,0 +1,246 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package datauploader contains routines upload inventory data to SSM - Inventory service
+package datauploader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// Sinks the inventory data can be sent to, besides SSM the data is exported as newline delimited JSON
+// with one line per inventory type.
+const (
+	SinkSSM    = "SSM"
+	SinkFile   = "File"
+	SinkHTTP   = "HTTP"
+	SinkStdout = "Stdout"
+
+	// exportFileName is the file the data is appended to by the File sink when no destination is set
+	exportFileName = "inventory.ndjson"
+
+	ndjsonContentType = "application/x-ndjson"
+	httpSinkTimeout   = 30 * time.Second
+)
+
+// exportedItem is an inventory type as exported by the sinks other than SSM. Content is omitted when it didn't
+// change since the last export, the content hash identifying the content exported earlier.
+type exportedItem struct {
+	InstanceId    string
+	TypeName      string
+	SchemaVersion string
+	CaptureTime   string
+	ContentHash   string
+	Content       []map[string]*string `json:",omitempty"`
+}
+
+// httpPoster decouples http.Client.Post for easy testability
+type httpPoster interface {
+	Post(url string, contentType string, body io.Reader) (*http.Response, error)
+}
+
+// writerSink writes the inventory data to the given writer
+type writerSink struct {
+	lock   sync.Mutex
+	writer io.Writer
+}
+
+// fileSink appends the inventory data to a file
+type fileSink struct {
+	path string
+}
+
+// httpSink posts the inventory data to an HTTP endpoint
+type httpSink struct {
+	endpoint string
+	client   httpPoster
+}
+
+// NormalizeSinkName returns the name of the sink in its canonical case, or an error if the sink is not supported
+func NormalizeSinkName(name string) (string, error) {
+	for _, sink := range []string{SinkSSM, SinkFile, SinkHTTP, SinkStdout} {
+		if strings.EqualFold(name, sink) {
+			return sink, nil
+		}
+	}
+	return "", fmt.Errorf("Unsupported inventory sink - %v. Supported sinks are %v, %v, %v and %v", name, SinkSSM, SinkFile, SinkHTTP, SinkStdout)
+}
+
+// newExportSink creates a sink other than SSM, the destination is the path of the file for the File sink and the url
+// of the endpoint for the HTTP sink. As the destination may come from a document, the File sink only writes under the
+// export folder of the agent configuration and the HTTP sink only posts to the endpoints it allows.
+func newExportSink(sink, destination, instanceID string, cfg appconfig.SsmCfg) (SSMCaller, error) {
+	switch sink {
+	case SinkFile:
+		path, err := exportFilePath(destination, instanceID, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &fileSink{path: path}, nil
+	case SinkHTTP:
+		endpoint, err := url.Parse(destination)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return nil, fmt.Errorf("%v sink requires an http or https url as destination, got %v", SinkHTTP, destination)
+		}
+		if !isAllowedEndpoint(endpoint, append([]string{cfg.InventorySinkDestination}, cfg.InventoryHTTPEndpoints...)) {
+			return nil, fmt.Errorf("%v sink can't post to %v, which is not one of the InventoryHTTPEndpoints of the agent configuration", SinkHTTP, destination)
+		}
+		transport := proxyconfig.NewTransport()
+		return &httpSink{endpoint: destination, client: &http.Client{Timeout: httpSinkTimeout, Transport: transport}}, nil
+	case SinkStdout:
+		return &writerSink{writer: os.Stdout}, nil
+	default:
+		return nil, fmt.Errorf("%v is not an export sink", sink)
+	}
+}
+
+// exportFilePath returns the path of the file of the File sink, a relative destination being relative to the export
+// folder. Destinations outside the export folder are rejected, except the destination of the agent configuration.
+func exportFilePath(destination, instanceID string, cfg appconfig.SsmCfg) (string, error) {
+	if destination != "" && filepath.IsAbs(destination) && destination == cfg.InventorySinkDestination {
+		return filepath.Clean(destination), nil
+	}
+	exportDir := cfg.InventoryExportDirectory
+	if exportDir == "" {
+		exportDir = filepath.Join(appconfig.DefaultDataStorePath, instanceID, appconfig.InventoryRootDirName)
+	}
+	exportDir = filepath.Clean(exportDir)
+	if destination == "" {
+		return filepath.Join(exportDir, exportFileName), nil
+	}
+	path := destination
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(exportDir, path)
+	}
+	path = filepath.Clean(path)
+	if relative, err := filepath.Rel(exportDir, path); err != nil || relative == "." || relative == ".." ||
+		strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%v sink can't write to %v, which is not in the InventoryExportDirectory %v", SinkFile, destination, exportDir)
+	}
+	return path, nil
+}
+
+// isAllowedEndpoint returns true if the endpoint has the scheme and host of one of the allowed urls, and its path is
+// the path of the allowed url or under it
+func isAllowedEndpoint(endpoint *url.URL, allowed []string) bool {
+	for _, allowedURL := range allowed {
+		if allowedURL == "" {
+			continue
+		}
+		allowedEndpoint, err := url.Parse(allowedURL)
+		if err != nil || !strings.EqualFold(allowedEndpoint.Scheme, endpoint.Scheme) || !strings.EqualFold(allowedEndpoint.Host, endpoint.Host) {
+			continue
+		}
+		allowedPath := strings.TrimSuffix(allowedEndpoint.Path, "/")
+		if endpoint.Path == allowedPath || strings.HasPrefix(endpoint.Path, allowedPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// marshalNDJSON returns the inventory items as newline delimited JSON
+func marshalNDJSON(input *ssm.PutInventoryInput) ([]byte, error) {
+	var buffer bytes.Buffer
+	for _, item := range input.Items {
+		line, err := json.Marshal(exportedItem{
+			InstanceId:    stringValue(input.InstanceId),
+			TypeName:      stringValue(item.TypeName),
+			SchemaVersion: stringValue(item.SchemaVersion),
+			CaptureTime:   stringValue(item.CaptureTime),
+			ContentHash:   stringValue(item.ContentHash),
+			Content:       item.Content,
+		})
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(line)
+		buffer.WriteByte('\n')
+	}
+	return buffer.Bytes(), nil
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// PutInventory writes the inventory data to the writer
+func (s *writerSink) PutInventory(input *ssm.PutInventoryInput) (*ssm.PutInventoryOutput, error) {
+	data, err := marshalNDJSON(input)
+	if err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, err = s.writer.Write(data); err != nil {
+		return nil, err
+	}
+	return &ssm.PutInventoryOutput{}, nil
+}
+
+// PutInventory appends the inventory data to the file, creating it if needed
+func (s *fileSink) PutInventory(input *ssm.PutInventoryInput) (*ssm.PutInventoryOutput, error) {
+	data, err := marshalNDJSON(input)
+	if err != nil {
+		return nil, err
+	}
+	if err = fileutil.MakeDirs(filepath.Dir(s.path)); err != nil {
+		return nil, fmt.Errorf("Unable to create the folder of %v - %v", s.path, err)
+	}
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, appconfig.ReadWriteAccess)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err = file.Write(data); err != nil {
+		return nil, err
+	}
+	return &ssm.PutInventoryOutput{}, nil
+}
+
+// PutInventory posts the inventory data to the endpoint, any status other than 2xx is an error
+func (s *httpSink) PutInventory(input *ssm.PutInventoryInput) (*ssm.PutInventoryOutput, error) {
+	data, err := marshalNDJSON(input)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Post(s.endpoint, ndjsonContentType, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%v responded with %v - %v", s.endpoint, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return &ssm.PutInventoryOutput{}, nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package datauploader contains routines upload inventory data to SSM - Inventory service
+package datauploader
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func samplePutInventoryInput() *ssm.PutInventoryInput {
+	instanceID, typeName, schemaVersion, captureTime := "i-12345678", "AWS:Application", "1.0", "2018-03-01T10:00:00Z"
+	changedHash, unchangedHash, name := "changed", "unchanged", "Test1"
+	return &ssm.PutInventoryInput{
+		InstanceId: &instanceID,
+		Items: []*ssm.InventoryItem{
+			{TypeName: &typeName, SchemaVersion: &schemaVersion, CaptureTime: &captureTime, ContentHash: &changedHash, Content: []map[string]*string{{"Name": &name}}},
+			{TypeName: &typeName, SchemaVersion: &schemaVersion, CaptureTime: &captureTime, ContentHash: &unchangedHash},
+		},
+	}
+}
+
+const sampleNDJSON = `{"InstanceId":"i-12345678","TypeName":"AWS:Application","SchemaVersion":"1.0","CaptureTime":"2018-03-01T10:00:00Z","ContentHash":"changed","Content":[{"Name":"Test1"}]}
+{"InstanceId":"i-12345678","TypeName":"AWS:Application","SchemaVersion":"1.0","CaptureTime":"2018-03-01T10:00:00Z","ContentHash":"unchanged"}
+`
+
+func TestNormalizeSinkName(t *testing.T) {
+	for name, expected := range map[string]string{"ssm": SinkSSM, "FILE": SinkFile, "Http": SinkHTTP, "stdout": SinkStdout} {
+		sink, err := NormalizeSinkName(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, sink)
+	}
+
+	_, err := NormalizeSinkName("kafka")
+	assert.Error(t, err)
+}
+
+func TestNewExportSink(t *testing.T) {
+	sink, err := newExportSink(SinkFile, "", "i-12345678", appconfig.SsmCfg{})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(sink.(*fileSink).path, filepath.Join("i-12345678", "inventory", exportFileName)))
+
+	for _, destination := range []string{"", "ftp://cmdb.example.com", "http://", "not a url"} {
+		_, err = newExportSink(SinkHTTP, destination, "i-12345678", appconfig.SsmCfg{})
+		assert.Error(t, err, destination)
+	}
+
+	_, err = newExportSink(SinkSSM, "", "i-12345678", appconfig.SsmCfg{})
+	assert.Error(t, err)
+}
+
+func TestExportFilePath(t *testing.T) {
+	cfg := appconfig.SsmCfg{InventoryExportDirectory: filepath.Join("/var", "lib", "inventory")}
+
+	for destination, expected := range map[string]string{
+		"":                                    filepath.Join("/var", "lib", "inventory", exportFileName),
+		"cmdb.ndjson":                         filepath.Join("/var", "lib", "inventory", "cmdb.ndjson"),
+		"export/cmdb.ndjson":                  filepath.Join("/var", "lib", "inventory", "export", "cmdb.ndjson"),
+		"/var/lib/inventory/cmdb.ndjson":      filepath.Join("/var", "lib", "inventory", "cmdb.ndjson"),
+		"/var/lib/inventory/../inventory/a.j": filepath.Join("/var", "lib", "inventory", "a.j"),
+	} {
+		path, err := exportFilePath(destination, "i-12345678", cfg)
+		assert.NoError(t, err, destination)
+		assert.Equal(t, expected, path, destination)
+	}
+
+	// the destinations escaping the export folder are rejected
+	for _, destination := range []string{"/etc/cron.d/inventory", "../cmdb.ndjson", "/var/lib/inventory", "/var/lib/inventory2/a", "."} {
+		_, err := exportFilePath(destination, "i-12345678", cfg)
+		assert.Error(t, err, destination)
+	}
+
+	// except the destination of the agent configuration
+	cfg.InventorySinkDestination = "/var/log/inventory.ndjson"
+	path, err := exportFilePath("/var/log/inventory.ndjson", "i-12345678", cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/log/inventory.ndjson", path)
+}
+
+func TestIsAllowedEndpoint(t *testing.T) {
+	allowed := []string{"https://cmdb.example.com/inventory/", "http://localhost:8080"}
+
+	for _, endpoint := range []string{
+		"https://cmdb.example.com/inventory",
+		"https://CMDB.example.com/inventory/linux",
+		"http://localhost:8080/",
+		"http://localhost:8080/any/path",
+	} {
+		parsed, _ := url.Parse(endpoint)
+		assert.True(t, isAllowedEndpoint(parsed, allowed), endpoint)
+	}
+
+	for _, endpoint := range []string{
+		"http://cmdb.example.com/inventory",
+		"https://cmdb.example.com/inventory2",
+		"https://cmdb.example.com/",
+		"https://attacker.example.com/inventory",
+		"http://localhost:8081/",
+	} {
+		parsed, _ := url.Parse(endpoint)
+		assert.False(t, isAllowedEndpoint(parsed, allowed), endpoint)
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	var buffer bytes.Buffer
+	sink := &writerSink{writer: &buffer}
+
+	_, err := sink.PutInventory(samplePutInventoryInput())
+
+	assert.NoError(t, err)
+	assert.Equal(t, sampleNDJSON, buffer.String())
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inventory")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sink := &fileSink{path: filepath.Join(dir, "export", exportFileName)}
+
+	// the data is appended at each call
+	for i := 0; i < 2; i++ {
+		_, err = sink.PutInventory(samplePutInventoryInput())
+		assert.NoError(t, err)
+	}
+
+	content, err := ioutil.ReadFile(sink.path)
+	assert.NoError(t, err)
+	assert.Equal(t, sampleNDJSON+sampleNDJSON, string(content))
+}
+
+func TestHTTPSink(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, ndjsonContentType, r.Header.Get("Content-Type"))
+		body, _ := ioutil.ReadAll(r.Body)
+		received = string(body)
+		if strings.HasSuffix(r.URL.Path, "/fail") {
+			http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	cfg := appconfig.SsmCfg{InventoryHTTPEndpoints: []string{server.URL}}
+	_, err := newExportSink(SinkHTTP, "https://cmdb.example.com/inventory", "i-12345678", cfg)
+	assert.Error(t, err)
+
+	sink, err := newExportSink(SinkHTTP, server.URL+"/inventory", "i-12345678", cfg)
+	assert.NoError(t, err)
+	_, err = sink.PutInventory(samplePutInventoryInput())
+	assert.NoError(t, err)
+	assert.Equal(t, sampleNDJSON, received)
+
+	sink, err = newExportSink(SinkHTTP, server.URL+"/fail", "i-12345678", cfg)
+	assert.NoError(t, err)
+	_, err = sink.PutInventory(samplePutInventoryInput())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "quota exceeded")
+}
+
+func TestSendDataToSink(t *testing.T) {
+	var buffer bytes.Buffer
+	machineIDProvider = func() (string, error) { return "i-12345678", nil }
+	mockOptimizer := NewMockDefault()
+	mockOptimizer.On("UpdateContentHash", "AWS:Application", mock.AnythingOfType("string")).Return(nil)
+	u := &InventoryUploader{
+		ssm:       &writerSink{writer: &buffer},
+		optimizer: mockOptimizer,
+	}
+
+	err := u.SendDataToSSM(context.NewMockDefault(), samplePutInventoryInput().Items)
+
+	// content hashes are updated once exported, the same way as once uploaded to SSM
+	assert.NoError(t, err)
+	mockOptimizer.AssertNumberOfCalls(t, "UpdateContentHash", 2)
+	var item exportedItem
+	assert.NoError(t, json.Unmarshal([]byte(strings.Split(buffer.String(), "\n")[0]), &item))
+	assert.Equal(t, "i-12345678", item.InstanceId)
+}
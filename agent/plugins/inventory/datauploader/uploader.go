@@ -19,6 +19,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
@@ -40,6 +41,7 @@ type T interface {
 	ConvertToSsmInventoryItems(context context.T, items []model.Item) (optimizedInventoryItems, nonOptimizedInventoryItems []*ssm.InventoryItem, err error)
 }
 
+// SSMCaller receives the PutInventory calls, either SSM or one of the sinks the inventory data can be exported to
 type SSMCaller interface {
 	PutInventory(input *ssm.PutInventoryInput) (output *ssm.PutInventoryOutput, err error)
 }
@@ -50,8 +52,18 @@ type InventoryUploader struct {
 	optimizer Optimizer //helps inventory plugin to optimize PutInventory calls
 }
 
-// NewInventoryUploader creates a new InventoryUploader (which sends data to SSM Inventory)
+// NewInventoryUploader creates a new InventoryUploader which sends data to the sink configured in appconfig,
+// SSM Inventory by default
 func NewInventoryUploader(context context.T) (*InventoryUploader, error) {
+	sink, destination := SinkSSM, ""
+	if appCfg, err := appconfig.Config(false); err == nil && appCfg.Ssm.InventorySink != "" {
+		sink, destination = appCfg.Ssm.InventorySink, appCfg.Ssm.InventorySinkDestination
+	}
+	return NewInventoryUploaderWithSink(context, sink, destination)
+}
+
+// NewInventoryUploaderWithSink creates a new InventoryUploader which sends data to the given sink
+func NewInventoryUploaderWithSink(context context.T, sink, destination string) (*InventoryUploader, error) {
 	var uploader = InventoryUploader{}
 	var appCfg appconfig.SsmagentConfig
 	var err error
@@ -59,6 +71,32 @@ func NewInventoryUploader(context context.T) (*InventoryUploader, error) {
 	c := context.With("[" + Name + "]")
 	log := c.Log()
 
+	if sink, err = NormalizeSinkName(sink); err != nil {
+		return &uploader, err
+	}
+
+	if sink != SinkSSM {
+		var instanceID string
+		if instanceID, err = machineIDProvider(); err != nil {
+			return &uploader, fmt.Errorf("Unable to detect machineID because of %v", err.Error())
+		}
+		// the destinations the sinks may export to are restricted by the agent configuration, the default
+		// configuration being returned when the configuration can't be loaded
+		appCfg, _ = appconfig.Config(false)
+		if uploader.ssm, err = newExportSink(sink, destination, instanceID, appCfg.Ssm); err != nil {
+			return &uploader, err
+		}
+		log.Infof("Inventory data will be sent to the %v sink %v", sink, destination)
+
+		// content hashes are kept per sink so that each sink gets the full data at least once
+		contentHashFileName := appconfig.InventoryContentHashFileName + "-" + strings.ToLower(sink)
+		if uploader.optimizer, err = NewOptimizerImplWithLocation(log, appconfig.InventoryRootDirName, contentHashFileName); err != nil {
+			log.Errorf("Unable to load optimizer for inventory uploader because - %v", err.Error())
+			return &uploader, err
+		}
+		return &uploader, nil
+	}
+
 	// setting ssm client config
 	cfg := sdkutil.AwsConfig()
 
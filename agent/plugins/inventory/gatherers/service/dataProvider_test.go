@@ -14,87 +14,9 @@
 
 package service
 
-import (
-	"errors"
-	"testing"
-
-	"github.com/aws/amazon-ssm-agent/agent/context"
-	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
-	"github.com/stretchr/testify/assert"
-)
-
-var testServiceOutput = "[{\"Name\": \"AJRouter\", \"DisplayName\": \"AllJoyn Router Service\", \"Status\": \"Stopped\", \"DependentServices\": \"\", \"ServicesDependedOn\": \"\", \"ServiceType\": \"Win32ShareProcess\", \"StartType\": \"\"},{\"Name\": \"ALG\", \"DisplayName\": \"Application Layer Gateway Service\", \"Status\": \"Stopped\", \"DependentServices\": \"\", \"ServicesDependedOn\": \"BrokerInfrastructure\", \"ServiceType\": \"Win32OwnProcess\", \"StartType\": \"\"}]"
-var testServiceOutputIncorrect = "[{\"Name\": \"<start123>AJRouter\", \"DisplayName\": \"AllJoyn Router Service\", \"Status\": \"Stopped\", \"DependentServices\": \"\", \"ServicesDependedOn\": \"\", \"ServiceType\": \"Win32ShareProcess\", \"StartType\": \"\"},{\"Name\": \"ALG\", \"DisplayName\": \"Application Layer Gateway Service\", \"Status\": \"Stopped\", \"DependentServices\": \"\", \"ServicesDependedOn\": \"BrokerInfrastructure\", \"ServiceType\": \"Win32OwnProcess\", \"StartType\": \"\"}]"
-
-var testServiceOutputData = []model.ServiceData{
-	{
-		Name:               "AJRouter",
-		DisplayName:        "AllJoyn Router Service",
-		Status:             "Stopped",
-		DependentServices:  "",
-		ServicesDependedOn: "",
-		ServiceType:        "Win32ShareProcess",
-		StartType:          "",
-	},
-	{
-		Name:               "ALG",
-		DisplayName:        "Application Layer Gateway Service",
-		Status:             "Stopped",
-		DependentServices:  "",
-		ServicesDependedOn: "BrokerInfrastructure",
-		ServiceType:        "Win32OwnProcess",
-		StartType:          "",
-	},
-}
-
 func createMockTestExecuteCommand(output string, err error) func(string, ...string) ([]byte, error) {
 
 	return func(string, ...string) ([]byte, error) {
 		return []byte(output), err
 	}
 }
-
-func TestServiceData(t *testing.T) {
-
-	contextMock := context.NewMockDefault()
-	cmdExecutor = createMockTestExecuteCommand(testServiceOutput, nil)
-
-	data, err := collectServiceData(contextMock, model.Config{})
-
-	assert.Nil(t, err)
-	assert.Equal(t, data, testServiceOutputData)
-}
-
-func TestServiceDataCmdErr(t *testing.T) {
-
-	contextMock := context.NewMockDefault()
-	cmdExecutor = createMockTestExecuteCommand("", errors.New("error"))
-
-	data, err := collectServiceData(contextMock, model.Config{})
-
-	assert.NotNil(t, err)
-	assert.Nil(t, data)
-}
-
-func TestServiceDataInvalidOutput(t *testing.T) {
-
-	contextMock := context.NewMockDefault()
-	cmdExecutor = createMockTestExecuteCommand("Invalid", nil)
-
-	data, err := collectServiceData(contextMock, model.Config{})
-
-	assert.NotNil(t, err)
-	assert.Nil(t, data)
-}
-
-func TestServiceDataInvalidMarker(t *testing.T) {
-	startMarker = "<start123>"
-	endMarker = "<test>"
-	contextMock := context.NewMockDefault()
-	cmdExecutor = createMockTestExecuteCommand(testServiceOutputIncorrect, nil)
-
-	data, err := collectServiceData(contextMock, model.Config{})
-
-	assert.NotNil(t, err)
-	assert.Nil(t, data)
-}
@@ -0,0 +1,435 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+// Service status and start types reported with the values used by Windows so that inventory can be queried the same way on all platforms
+const (
+	statusRunning      = "Running"
+	statusStopped      = "Stopped"
+	statusStartPending = "StartPending"
+	statusStopPending  = "StopPending"
+
+	startTypeAutomatic = "Automatic"
+	startTypeManual    = "Manual"
+	startTypeDisabled  = "Disabled"
+)
+
+const (
+	systemctlCmd       = "systemctl"
+	systemdServiceType = ".service"
+	// systemdRuntimeDir exists when systemd is the init system
+	systemdRuntimeDir = "/run/systemd/system"
+
+	initctlCmd     = "initctl"
+	upstartJobsDir = "/etc/init"
+
+	runlevelCmd      = "runlevel"
+	sysVInitDir      = "/etc/init.d"
+	defaultRunlevel  = "3"
+	lsbFacilityStart = "$"
+	// sysVLockDir holds the lock files the init scripts of Red Hat based systems create once their service is started
+	sysVLockDir = "/var/lock/subsys"
+	// chkconfigPidFile is the header of the init scripts of Red Hat based systems naming the pid file of their service
+	chkconfigPidFile = "# pidfile:"
+)
+
+// systemdProperties are the unit properties reported by systemctl show
+var systemdProperties = []string{"Id", "Description", "ActiveState", "UnitFileState", "Type", "Requires", "Wants", "RequiredBy", "WantedBy"}
+
+// sysVRunlevelDirs are the folders holding the start links of a runlevel, %v is the runlevel
+var sysVRunlevelDirs = []string{"/etc/rc%v.d", "/etc/rc.d/rc%v.d"}
+
+// sysVPidFiles are the usual pid files of a SysV service, %[1]v is the name of the service
+var sysVPidFiles = []string{"/var/run/%[1]v.pid", "/var/run/%[1]v/%[1]v.pid", "/run/%[1]v.pid", "/run/%[1]v/%[1]v.pid"}
+
+// upstartJobRegex matches the jobs listed by initctl list, i.e. "ssh start/running, process 1234"
+// or "network-interface (eth0) start/running" for the instances of a job
+var upstartJobRegex = regexp.MustCompile(`^(\S+)(\s+\(.*\))?\s+(start|stop)/([^,\s]+)`)
+
+// decoupling filesystem access for easy testability
+var (
+	readFile   = ioutil.ReadFile
+	readDir    = ioutil.ReadDir
+	glob       = filepath.Glob
+	isDirExist = func(path string) bool {
+		fileInfo, err := os.Stat(path)
+		return err == nil && fileInfo.IsDir()
+	}
+	// isProcessAlive probes the process with the null signal
+	isProcessAlive = func(pid int) bool {
+		err := syscall.Kill(pid, syscall.Signal(0))
+		return err == nil || err == syscall.EPERM
+	}
+)
+
+// collectServiceData collects the services managed by systemd, or by upstart and SysV init on systems without systemd
+func collectServiceData(context context.T, config model.Config) (data []model.ServiceData, err error) {
+	log := context.Log()
+	log.Infof("collectServiceData called")
+
+	if isDirExist(systemdRuntimeDir) {
+		return collectSystemdServices(log)
+	}
+
+	upstartServices := collectUpstartServices(log)
+	known := make(map[string]bool)
+	for _, service := range upstartServices {
+		known[service.Name] = true
+	}
+	data = append(data, upstartServices...)
+	for _, service := range collectSysVServices(log) {
+		// upstart jobs may provide a compatibility script in /etc/init.d
+		if !known[service.Name] {
+			data = append(data, service)
+		}
+	}
+	return data, nil
+}
+
+// collectSystemdServices lists all the service units, loaded or not, and gets their properties in a single systemctl call
+func collectSystemdServices(log log.T) (data []model.ServiceData, err error) {
+	units := make(map[string]bool)
+	listCommands := [][]string{
+		{"list-unit-files", "--type=service", "--no-legend", "--no-pager"},
+		{"list-units", "--type=service", "--all", "--no-legend", "--no-pager", "--plain"},
+	}
+	for _, args := range listCommands {
+		output, err := cmdExecutor(systemctlCmd, args...)
+		if err != nil {
+			log.Errorf("Failed to list services with %v %v: %v %v", systemctlCmd, args, err, string(output))
+			continue
+		}
+		for _, unit := range parseSystemdUnitList(string(output)) {
+			units[unit] = true
+		}
+	}
+	if len(units) == 0 {
+		return nil, fmt.Errorf("no systemd service found")
+	}
+
+	args := []string{"show", "--no-pager", "--property=" + strings.Join(systemdProperties, ",")}
+	for unit := range units {
+		args = append(args, unit)
+	}
+	sort.Strings(args[3:])
+	output, err := cmdExecutor(systemctlCmd, args...)
+	if err != nil {
+		log.Errorf("Failed to get the properties of the services: %v %v", err, string(output))
+		return nil, err
+	}
+	return parseSystemdServices(string(output)), nil
+}
+
+// parseSystemdUnitList returns the service units in the first column of systemctl list-units or list-unit-files.
+// Templates such as getty@.service aren't services and are skipped.
+func parseSystemdUnitList(output string) (units []string) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		// failed units are marked with a bullet on recent versions of systemd
+		if len(fields) > 0 && !strings.HasSuffix(fields[0], systemdServiceType) {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 || !strings.HasSuffix(fields[0], systemdServiceType) || strings.HasSuffix(fields[0], "@"+systemdServiceType) {
+			continue
+		}
+		units = append(units, fields[0])
+	}
+	return
+}
+
+// parseSystemdServices converts the output of systemctl show, where the properties of each unit are separated
+// by an empty line, to service data
+func parseSystemdServices(output string) (data []model.ServiceData) {
+	for _, block := range strings.Split(strings.Replace(output, "\r\n", "\n", -1), "\n\n") {
+		properties := make(map[string]string)
+		for _, line := range strings.Split(block, "\n") {
+			if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
+				properties[parts[0]] = strings.TrimSpace(parts[1])
+			}
+		}
+		if properties["Id"] == "" {
+			continue
+		}
+		data = append(data, model.ServiceData{
+			Name:               strings.TrimSuffix(properties["Id"], systemdServiceType),
+			DisplayName:        properties["Description"],
+			Status:             systemdStatus(properties["ActiveState"]),
+			DependentServices:  systemdServiceList(properties["RequiredBy"], properties["WantedBy"]),
+			ServicesDependedOn: systemdServiceList(properties["Requires"], properties["Wants"]),
+			ServiceType:        properties["Type"],
+			StartType:          systemdStartType(properties["UnitFileState"]),
+		})
+	}
+	return
+}
+
+// systemdStatus maps the active state of a unit to a service status
+func systemdStatus(activeState string) string {
+	switch activeState {
+	case "active", "reloading":
+		return statusRunning
+	case "activating":
+		return statusStartPending
+	case "deactivating":
+		return statusStopPending
+	default:
+		// inactive and failed
+		return statusStopped
+	}
+}
+
+// systemdStartType maps the unit file state of a unit to a service start type
+func systemdStartType(unitFileState string) string {
+	switch unitFileState {
+	case "enabled", "enabled-runtime", "generated", "linked", "linked-runtime":
+		return startTypeAutomatic
+	case "masked", "masked-runtime":
+		return startTypeDisabled
+	case "":
+		return ""
+	default:
+		// disabled, static and indirect units only start when requested or as a dependency
+		return startTypeManual
+	}
+}
+
+// systemdServiceList returns the service units among the given lists of units, space separated and without the unit type
+func systemdServiceList(unitLists ...string) string {
+	var services []string
+	seen := make(map[string]bool)
+	for _, units := range unitLists {
+		for _, unit := range strings.Fields(units) {
+			if !strings.HasSuffix(unit, systemdServiceType) || seen[unit] {
+				continue
+			}
+			seen[unit] = true
+			services = append(services, strings.TrimSuffix(unit, systemdServiceType))
+		}
+	}
+	sort.Strings(services)
+	return strings.Join(services, " ")
+}
+
+// collectUpstartServices lists the upstart jobs, start type and description come from the job configuration
+func collectUpstartServices(log log.T) (data []model.ServiceData) {
+	output, err := cmdExecutor(initctlCmd, "list")
+	if err != nil {
+		log.Debugf("Upstart is not available: %v", err)
+		return
+	}
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		match := upstartJobRegex.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		// jobs with multiple instances are listed once per instance
+		if match == nil || seen[match[1]] {
+			continue
+		}
+		name := match[1]
+		seen[name] = true
+
+		service := model.ServiceData{Name: name, Status: upstartStatus(match[3], match[4]), StartType: startTypeManual}
+		if conf, err := readFile(filepath.Join(upstartJobsDir, name+".conf")); err == nil {
+			service.DisplayName = upstartStanza(string(conf), "description")
+			if upstartStanza(string(conf), "start on") != "" {
+				service.StartType = startTypeAutomatic
+			}
+		}
+		if override, err := readFile(filepath.Join(upstartJobsDir, name+".override")); err == nil && hasLine(string(override), "manual") {
+			service.StartType = startTypeManual
+		}
+		data = append(data, service)
+	}
+	return
+}
+
+// upstartStatus maps the goal and state of an upstart job to a service status
+func upstartStatus(goal string, state string) string {
+	switch {
+	case goal == "start" && state == "running":
+		return statusRunning
+	case goal == "start":
+		return statusStartPending
+	case state == "waiting":
+		return statusStopped
+	default:
+		return statusStopPending
+	}
+}
+
+// upstartStanza returns the value of a stanza of an upstart job configuration, without quotes
+func upstartStanza(conf string, stanza string) string {
+	for _, line := range strings.Split(conf, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, stanza) && len(line) > len(stanza) && strings.ContainsAny(line[len(stanza):len(stanza)+1], " \t") {
+			return strings.Trim(strings.TrimSpace(line[len(stanza):]), `"`)
+		}
+	}
+	return ""
+}
+
+// hasLine returns true if the content has a line with the given value, comments aside
+func hasLine(content string, value string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(strings.SplitN(line, "#", 2)[0]) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// collectSysVServices lists the init scripts, description and dependencies come from their LSB header. The init scripts
+// aren't run to get the status of their service, which is read from the pid and lock files the scripts maintain.
+func collectSysVServices(log log.T) (data []model.ServiceData) {
+	files, err := readDir(sysVInitDir)
+	if err != nil {
+		log.Debugf("No SysV init scripts: %v", err)
+		return
+	}
+
+	runlevel := currentRunlevel()
+	dependents := make(map[string][]string)
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".dpkg-old") || name == "README" || name == "functions" {
+			continue
+		}
+
+		service := model.ServiceData{Name: name, Status: statusStopped, StartType: startTypeManual}
+		var script []byte
+		if script, err = readFile(filepath.Join(sysVInitDir, name)); err == nil {
+			header := parseLSBHeader(string(script))
+			service.DisplayName = header["Short-Description"]
+			var dependencies []string
+			for _, dependency := range strings.Fields(header["Required-Start"]) {
+				// facilities such as $network aren't services
+				if !strings.HasPrefix(dependency, lsbFacilityStart) {
+					dependencies = append(dependencies, dependency)
+					dependents[dependency] = append(dependents[dependency], name)
+				}
+			}
+			service.ServicesDependedOn = strings.Join(dependencies, " ")
+		}
+		if isSysVServiceEnabled(name, runlevel) {
+			service.StartType = startTypeAutomatic
+		}
+		if isSysVServiceRunning(name, string(script)) {
+			service.Status = statusRunning
+		}
+		data = append(data, service)
+	}
+
+	for i := range data {
+		sort.Strings(dependents[data[i].Name])
+		data[i].DependentServices = strings.Join(dependents[data[i].Name], " ")
+	}
+	return
+}
+
+// currentRunlevel returns the current runlevel, runlevel prints the previous and the current runlevel, i.e. "N 3"
+func currentRunlevel() string {
+	if output, err := cmdExecutor(runlevelCmd); err == nil {
+		if fields := strings.Fields(string(output)); len(fields) == 2 {
+			return fields[1]
+		}
+	}
+	return defaultRunlevel
+}
+
+// isSysVServiceEnabled returns true if the service has a start link in the folder of the runlevel, i.e. /etc/rc3.d/S55sshd
+func isSysVServiceEnabled(name string, runlevel string) bool {
+	for _, dir := range sysVRunlevelDirs {
+		if links, _ := glob(filepath.Join(fmt.Sprintf(dir, runlevel), "S[0-9][0-9]"+name)); len(links) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isSysVServiceRunning returns true if a pid file of the service names a running process, or for the services without
+// pid file, if the init script left its lock file, i.e. /var/lock/subsys/network
+func isSysVServiceRunning(name string, script string) bool {
+	pidFiles := make([]string, 0, len(sysVPidFiles)+1)
+	for _, line := range strings.Split(script, "\n") {
+		if strings.HasPrefix(line, chkconfigPidFile) {
+			pidFiles = append(pidFiles, strings.TrimSpace(strings.TrimPrefix(line, chkconfigPidFile)))
+		}
+	}
+	for _, pidFile := range sysVPidFiles {
+		pidFiles = append(pidFiles, fmt.Sprintf(pidFile, name))
+	}
+
+	for _, pidFile := range pidFiles {
+		content, err := readFile(pidFile)
+		if err != nil {
+			continue
+		}
+		// a pid file that is left once the process exited doesn't make the service running
+		fields := strings.Fields(string(content))
+		if len(fields) == 0 {
+			continue
+		}
+		if pid, err := strconv.Atoi(fields[0]); err == nil && pid > 0 && isProcessAlive(pid) {
+			return true
+		}
+		return false
+	}
+	_, err := readFile(filepath.Join(sysVLockDir, name))
+	return err == nil
+}
+
+// parseLSBHeader returns the fields of the LSB header of an init script, i.e.
+// ### BEGIN INIT INFO
+// # Required-Start:    $remote_fs $syslog
+// # Short-Description: OpenBSD Secure Shell server
+// ### END INIT INFO
+func parseLSBHeader(script string) map[string]string {
+	header := make(map[string]string)
+	inHeader := false
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "### BEGIN INIT INFO"):
+			inHeader = true
+		case strings.HasPrefix(line, "### END INIT INFO"):
+			return header
+		case inHeader:
+			if parts := strings.SplitN(strings.TrimLeft(line, "# "), ":", 2); len(parts) == 2 {
+				header[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+			}
+		}
+	}
+	return header
+}
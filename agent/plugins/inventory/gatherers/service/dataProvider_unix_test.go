@@ -0,0 +1,264 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	sampleSystemdUnitFiles = `sshd.service                    enabled
+getty@.service                  enabled
+rescue.service                  static
+postfix.service                 masked
+`
+	sampleSystemdUnits = `sshd.service          loaded    active   running OpenSSH server daemon
+● network.service     loaded    failed   failed  LSB: Bring up/down networking
+rescue.service        loaded    inactive dead    Rescue Shell
+`
+	sampleSystemdShow = `Id=network.service
+Type=forking
+Description=LSB: Bring up/down networking
+ActiveState=failed
+UnitFileState=
+Requires=system.slice
+Wants=network.target
+RequiredBy=
+WantedBy=multi-user.target sshd.service
+
+Id=postfix.service
+Type=forking
+Description=Postfix Mail Transport Agent
+ActiveState=inactive
+UnitFileState=masked
+Requires=
+Wants=
+RequiredBy=
+WantedBy=
+
+Id=rescue.service
+Type=idle
+Description=Rescue Shell
+ActiveState=inactive
+UnitFileState=static
+Requires=sysinit.target
+Wants=
+RequiredBy=
+WantedBy=
+
+Id=sshd.service
+Type=notify
+Description=OpenSSH server daemon
+ActiveState=active
+UnitFileState=enabled
+Requires=basic.target
+Wants=sshd-keygen.service network.service sshd-keygen.service
+RequiredBy=
+WantedBy=multi-user.target
+`
+	sampleInitctlList = `rc stop/waiting
+tty1 start/running, process 1122
+network-interface (eth0) start/running
+network-interface (lo) start/running
+ssh start/running, process 953
+ureadahead stop/waiting
+`
+	sampleUpstartSsh = `# ssh - OpenBSD Secure Shell server
+description	"OpenSSH server"
+
+start on runlevel [2345]
+stop on runlevel [!2345]
+`
+	sampleSysVSshd = `#!/bin/sh
+### BEGIN INIT INFO
+# Provides:          sshd
+# Required-Start:    $remote_fs $syslog network
+# Required-Stop:     $remote_fs $syslog
+# Short-Description: OpenBSD Secure Shell server
+### END INIT INFO
+`
+	sampleSysVNetwork = `#!/bin/sh
+### BEGIN INIT INFO
+# Provides:          network
+# Required-Start:    $local_fs
+# Short-Description: Bring up/down networking
+### END INIT INFO
+`
+)
+
+type fakeFileInfo struct {
+	name  string
+	isDir bool
+}
+
+func (f fakeFileInfo) Name() string       { return f.name }
+func (f fakeFileInfo) Size() int64        { return 0 }
+func (f fakeFileInfo) Mode() os.FileMode  { return 0755 }
+func (f fakeFileInfo) ModTime() time.Time { return time.Time{} }
+func (f fakeFileInfo) IsDir() bool        { return f.isDir }
+func (f fakeFileInfo) Sys() interface{}   { return nil }
+
+// mockFileSystem replaces the filesystem access with the given files and returns a function restoring it
+func mockFileSystem(systemd bool, files map[string]string, initScripts []os.FileInfo, startLinks []string) func() {
+	originalIsDirExist, originalReadFile, originalReadDir, originalGlob := isDirExist, readFile, readDir, glob
+	isDirExist = func(path string) bool {
+		return systemd && path == systemdRuntimeDir
+	}
+	readFile = func(path string) ([]byte, error) {
+		if content, found := files[path]; found {
+			return []byte(content), nil
+		}
+		return nil, fmt.Errorf("%v not found", path)
+	}
+	readDir = func(path string) ([]os.FileInfo, error) {
+		if initScripts == nil {
+			return nil, fmt.Errorf("%v not found", path)
+		}
+		return initScripts, nil
+	}
+	glob = func(pattern string) ([]string, error) {
+		var matches []string
+		for _, link := range startLinks {
+			if isMatch, _ := filepath.Match(pattern, link); isMatch {
+				matches = append(matches, link)
+			}
+		}
+		return matches, nil
+	}
+	return func() {
+		isDirExist, readFile, readDir, glob = originalIsDirExist, originalReadFile, originalReadDir, originalGlob
+	}
+}
+
+// mockCommands replaces the command executor with the given outputs, keyed by command line, and returns a function restoring it
+func mockCommands(outputs map[string]string) func() {
+	original := cmdExecutor
+	cmdExecutor = func(command string, args ...string) ([]byte, error) {
+		for commandLine, output := range outputs {
+			if strings.HasPrefix(strings.Join(append([]string{command}, args...), " "), commandLine) {
+				return []byte(output), nil
+			}
+		}
+		return []byte("not found"), fmt.Errorf("exit status 1")
+	}
+	return func() { cmdExecutor = original }
+}
+
+// mockRunningProcesses replaces the process probe with the given running processes and returns a function restoring it
+func mockRunningProcesses(pids ...int) func() {
+	original := isProcessAlive
+	isProcessAlive = func(pid int) bool {
+		for _, running := range pids {
+			if pid == running {
+				return true
+			}
+		}
+		return false
+	}
+	return func() { isProcessAlive = original }
+}
+
+func TestCollectServiceData_Systemd(t *testing.T) {
+	contextMock := context.NewMockDefault()
+	defer mockFileSystem(true, nil, nil, nil)()
+	defer mockCommands(map[string]string{
+		"systemctl list-unit-files": sampleSystemdUnitFiles,
+		"systemctl list-units":      sampleSystemdUnits,
+		"systemctl show --no-pager --property=Id,Description,ActiveState,UnitFileState,Type,Requires,Wants,RequiredBy,WantedBy network.service postfix.service rescue.service sshd.service": sampleSystemdShow,
+	})()
+
+	data, err := collectServiceData(contextMock, model.Config{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ServiceData{
+		{Name: "network", DisplayName: "LSB: Bring up/down networking", Status: statusStopped, DependentServices: "sshd", ServiceType: "forking"},
+		{Name: "postfix", DisplayName: "Postfix Mail Transport Agent", Status: statusStopped, ServiceType: "forking", StartType: startTypeDisabled},
+		{Name: "rescue", DisplayName: "Rescue Shell", Status: statusStopped, ServiceType: "idle", StartType: startTypeManual},
+		{Name: "sshd", DisplayName: "OpenSSH server daemon", Status: statusRunning, ServicesDependedOn: "network sshd-keygen", ServiceType: "notify", StartType: startTypeAutomatic},
+	}, data)
+}
+
+func TestCollectServiceData_SystemdError(t *testing.T) {
+	contextMock := context.NewMockDefault()
+	defer mockFileSystem(true, nil, nil, nil)()
+	defer mockCommands(map[string]string{})()
+
+	_, err := collectServiceData(contextMock, model.Config{})
+
+	assert.Error(t, err)
+}
+
+func TestCollectServiceData_UpstartAndSysV(t *testing.T) {
+	contextMock := context.NewMockDefault()
+	defer mockFileSystem(false, map[string]string{
+		"/etc/init/ssh.conf":      sampleUpstartSsh,
+		"/etc/init/tty1.conf":     "start on stopped rc RUNLEVEL=[2345]",
+		"/etc/init/tty1.override": "# disabled\nmanual\n",
+		"/etc/init.d/sshd":        sampleSysVSshd,
+		"/etc/init.d/network":     sampleSysVNetwork,
+		"/var/run/sshd.pid":       "953\n",
+		"/var/run/network.pid":    "4242\n",
+	}, []os.FileInfo{
+		fakeFileInfo{name: "network"},
+		fakeFileInfo{name: "ssh"},
+		fakeFileInfo{name: "sshd"},
+		fakeFileInfo{name: "README"},
+		fakeFileInfo{name: "subdir", isDir: true},
+	}, []string{"/etc/rc.d/rc5.d/S55sshd"})()
+	defer mockCommands(map[string]string{
+		"initctl list": sampleInitctlList,
+		"runlevel":     "N 5",
+	})()
+	defer mockRunningProcesses(953)()
+
+	data, err := collectServiceData(contextMock, model.Config{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ServiceData{
+		{Name: "rc", Status: statusStopped, StartType: startTypeManual},
+		{Name: "tty1", Status: statusRunning, StartType: startTypeManual},
+		{Name: "network-interface", Status: statusRunning, StartType: startTypeManual},
+		{Name: "ssh", DisplayName: "OpenSSH server", Status: statusRunning, StartType: startTypeAutomatic},
+		{Name: "ureadahead", Status: statusStopped, StartType: startTypeManual},
+		{Name: "network", DisplayName: "Bring up/down networking", Status: statusStopped, DependentServices: "sshd", StartType: startTypeManual},
+		{Name: "sshd", DisplayName: "OpenBSD Secure Shell server", Status: statusRunning, ServicesDependedOn: "network", StartType: startTypeAutomatic},
+	}, data)
+}
+
+func TestIsSysVServiceRunning(t *testing.T) {
+	defer mockFileSystem(false, map[string]string{
+		"/var/run/httpd/httpd.pid": "1200",
+		"/var/run/crond.pid":       "1300",
+		"/var/run/stale.pid":       "1400",
+		"/var/lock/subsys/network": "",
+	}, nil, nil)()
+	defer mockRunningProcesses(1200, 1300)()
+
+	assert.True(t, isSysVServiceRunning("httpd", ""))
+	assert.True(t, isSysVServiceRunning("cron", "#!/bin/sh\n# chkconfig: 2345 90 60\n# pidfile: /var/run/crond.pid\n"))
+	assert.False(t, isSysVServiceRunning("stale", ""))
+	assert.True(t, isSysVServiceRunning("network", ""))
+	assert.False(t, isSysVServiceRunning("postfix", ""))
+}
@@ -17,17 +17,17 @@ package inventory
 import (
 	"encoding/json"
 	"fmt"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
-	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/changetracker"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/datauploader"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/application"
@@ -73,6 +73,17 @@ type PluginInput struct {
 	InstanceDetailedInformation string
 	CustomInventory             string
 	CustomInventoryDirectory    string
+	// Sink overrides the sink configured in appconfig - SSM, File, HTTP or Stdout. SinkDestination is a file of the
+	// InventoryExportDirectory or a url of the InventoryHTTPEndpoints of appconfig.
+	Sink            string
+	SinkDestination string
+	// ChangeHistory uploads the changes detected since the previous collection as a custom inventory type when Enabled
+	ChangeHistory string
+}
+
+// decoupling datauploader.NewInventoryUploaderWithSink for easy testability
+var uploaderWithSink = func(context context.T, sink, destination string) (datauploader.T, error) {
+	return datauploader.NewInventoryUploaderWithSink(context, sink, destination)
 }
 
 // decoupling platform.InstanceID for easy testability
@@ -97,6 +108,9 @@ type Plugin struct {
 	//uploader handles uploading inventory data to SSM.
 	uploader datauploader.T
 
+	//changeTracker keeps the previous snapshot of each inventory type to detect the changes of the inventory data
+	changeTracker *changetracker.Tracker
+
 	// machineID of the machine where agent is running - useful during command detection
 	machineID string
 }
@@ -126,6 +140,7 @@ func NewPlugin(context context.T) (*Plugin, error) {
 
 	//loads all registered gatherers (for now only a dummy application gatherer is loaded in memory)
 	p.supportedGatherers, p.installedGatherers = gatherers.InitializeGatherers(p.context)
+	p.changeTracker = changetracker.NewTracker(log, p.machineID)
 	//initializes SSM Inventory uploader
 	if p.uploader, err = datauploader.NewInventoryUploader(c); err != nil {
 		err = log.Errorf("Unable to configure SSM Inventory uploader - %v", err.Error())
@@ -145,6 +160,16 @@ func (p *Plugin) ApplyInventoryPolicy(context context.T, inventoryInput PluginIn
 	//map of all valid gatherers & respective configs to run
 	var gatherers map[gatherers.T]model.Config
 
+	//the sink given as input overrides the sink configured in appconfig
+	if inventoryInput.Sink != "" {
+		if p.uploader, err = uploaderWithSink(p.context, inventoryInput.Sink, inventoryInput.SinkDestination); err != nil {
+			log.Info(err.Error())
+			output.SetExitCode(1)
+			output.AppendError(err.Error())
+			return
+		}
+	}
+
 	//validate all gatherers
 	if gatherers, err = p.ValidateInventoryInput(context, inventoryInput); err != nil {
 		log.Info(err.Error())
@@ -161,6 +186,20 @@ func (p *Plugin) ApplyInventoryPolicy(context context.T, inventoryInput PluginIn
 		return
 	}
 
+	//track the changes since the previous collection - a failure doesn't prevent uploading the inventory data
+	if p.changeTracker != nil {
+		if changes, trackErr := p.changeTracker.TrackChanges(items); trackErr != nil {
+			log.Errorf("Unable to track the changes of the inventory data - %v", trackErr.Error())
+		} else if inventoryInput.ChangeHistory == model.Enabled {
+			changeItem := changetracker.ChangeItem(changes)
+			if p.VerifyInventoryDataSize(changeItem, append(items, changeItem)) {
+				items = append(items, changeItem)
+			} else {
+				log.Errorf("Size limit exceeded for %v, the changes are only kept locally", changeItem.Name)
+			}
+		}
+	}
+
 	//check if there is data to send to SSM
 	if len(items) == 0 {
 		//no data to send to ssm - no need to call PutInventory API
@@ -458,34 +497,19 @@ func (p *Plugin) IsMulitpleAssociationPresent(currentAssociationID string, confi
 // IsInventoryBeingInvokedAsAssociation returns true if inventory plugin is invoked via ssm-associate or else it returns false.
 // It throws error if the detection itself fails
 func (p *Plugin) IsInventoryBeingInvokedAsAssociation(fileName string) (status bool, err error) {
-	var content string
-	var docState contracts.DocumentState
 	log := p.context.Log()
 
 	//since the document is still getting executed - it must be in Current folder
-	path := filepath.Join(appconfig.DefaultDataStorePath,
-		p.machineID,
-		appconfig.DefaultDocumentRootDirName,
-		appconfig.DefaultLocationOfState,
-		appconfig.DefaultLocationOfCurrent)
-
-	absPathOfDoc := filepath.Join(path, fileName)
-
-	//read file & then determine if document is of association type
-	if fileutil.Exists(absPathOfDoc) {
-		log.Debugf("Found the document that's executing inventory plugin - %v", absPathOfDoc)
-
-		//read file
-		if content, err = fileutil.ReadAllText(absPathOfDoc); err == nil {
-			if err = json.Unmarshal([]byte(content), &docState); err == nil {
-				status = docState.IsAssociation()
-			}
+	docMgr := docmanager.NewDocumentFileMgr(appconfig.DefaultDataStorePath, appconfig.DefaultDocumentRootDirName, appconfig.DefaultLocationOfState)
+	for _, documentID := range docMgr.ListDocumentStates(log, p.machineID, appconfig.DefaultLocationOfCurrent) {
+		if documentID == fileName {
+			log.Debugf("Found the document that's executing inventory plugin - %v", fileName)
+			docState := docMgr.GetDocumentState(log, fileName, p.machineID, appconfig.DefaultLocationOfCurrent)
+			return docState.IsAssociation(), nil
 		}
-
-	} else {
-		err = fmt.Errorf("Inventory plugin can't locate the execution document which invoked it. The doc should have been in the location - %v", absPathOfDoc)
 	}
 
+	err = fmt.Errorf("Inventory plugin can't locate the execution document which invoked it. The doc should have been in the %v documents of %v", appconfig.DefaultLocationOfCurrent, p.machineID)
 	return
 }
 
@@ -21,6 +21,7 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
 	"github.com/stretchr/testify/assert"
@@ -199,3 +200,13 @@ func TestPlugin_IsMulitpleAssociationPresent(t *testing.T) {
 	assert.True(t, status)
 	assert.Equal(t, "testAssociationID2", other)
 }
+
+func TestApplyInventoryPolicy_InvalidSink(t *testing.T) {
+	p, _ := MockInventoryPlugin([]string{"RandomGatherer"}, []string{"RandomGatherer"})
+	output := iohandler.DefaultIOHandler{}
+
+	p.ApplyInventoryPolicy(p.context, PluginInput{Sink: "Kafka"}, &output)
+
+	assert.Equal(t, 1, output.GetExitCode())
+	assert.Contains(t, output.GetStderr(), "Unsupported inventory sink")
+}
@@ -39,6 +39,17 @@ const (
 	Arch64Bit = "x86_64"
 	// Standard name for 32-bit architecture
 	Arch32Bit = "i386"
+	// InventoryChangeTypeName is the custom inventory type the changes detected between collections are uploaded as
+	InventoryChangeTypeName = "Custom:InventoryChange"
+	// InventoryChangeSchemaVersion is the schema version of the changes uploaded to SSM
+	InventoryChangeSchemaVersion = "1.0"
+)
+
+// Types of the changes detected between two collections of an inventory type
+const (
+	ChangeAdded    = "Added"
+	ChangeRemoved  = "Removed"
+	ChangeModified = "Modified"
 )
 
 // Item encapsulates an inventory item
@@ -167,6 +178,21 @@ type InstanceDetailedInformation struct {
 	OSServicePack         string
 }
 
+// ChangeData captures an inventory item added, removed or modified since the previous collection of its inventory type
+type ChangeData struct {
+	TypeName   string
+	ChangeType string
+	// Key identifies the item within its inventory type, i.e. Name=nginx,Architecture=x86_64
+	Key string
+	// Attributes lists the modified attributes, comma separated
+	Attributes string `json:",omitempty"`
+	// PreviousValue and NewValue are the item as JSON, or only the modified attributes of a modified item
+	PreviousValue       string `json:",omitempty"`
+	NewValue            string `json:",omitempty"`
+	PreviousCaptureTime string `json:",omitempty"`
+	CaptureTime         string
+}
+
 // Config captures all various properties (including optional) that can be supplied to a gatherer.
 // NOTE: Not all properties will be applicable to all gatherers.
 // E.g: Applications gatherer uses Collection, Files use Filters, Custom uses Collection & Location.
@@ -16,6 +16,8 @@ package runscript
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 
 	"strings"
@@ -32,6 +34,9 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
 
+// decoupling the file ownership changes for easy testability
+var chownToRunAsUser = executers.ChownToRunAsUser
+
 const (
 	downloadsDir = "downloads" //Directory under the orchestration directory where the downloaded resource resides
 )
@@ -55,6 +60,7 @@ type RunScriptPluginInput struct {
 	ID               string
 	WorkingDirectory string
 	TimeoutSeconds   interface{}
+	RunAsUser        string
 }
 
 // Execute runs multiple sets of commands and returns their outputs.
@@ -69,13 +75,27 @@ func (p *Plugin) Execute(context context.T, config contracts.Configuration, canc
 	} else if cancelFlag.Canceled() {
 		output.MarkAsCancelled()
 	} else {
-		p.runCommandsRawInput(log, config.PluginID, config.Properties, config.OrchestrationDirectory, config.DefaultWorkingDirectory, cancelFlag, output)
+		p.runCommandsRawInput(log, config.PluginID, config.Properties, config.OrchestrationDirectory, config.DefaultWorkingDirectory, config.RunAsUser, cancelFlag, output)
+	}
+}
+
+// ValidateInput checks the input of the step, including the user running the commands, without running them.
+func (p *Plugin) ValidateInput(context context.T, config contracts.Configuration) error {
+	var pluginInput RunScriptPluginInput
+	if err := jsonutil.Remarshal(config.Properties, &pluginInput); err != nil {
+		return fmt.Errorf("Invalid format in plugin properties %v;\nerror %v", config.Properties, err)
+	}
+	if pluginInput.RunAsUser == "" {
+		pluginInput.RunAsUser = config.RunAsUser
 	}
+	_, err := executers.WithRunAsUser(p.CommandExecuter, pluginInput.RunAsUser)
+	return err
 }
 
 // runCommandsRawInput executes one set of commands and returns their output.
 // The input is in the default json unmarshal format (e.g. map[string]interface{}).
-func (p *Plugin) runCommandsRawInput(log log.T, pluginID string, rawPluginInput interface{}, orchestrationDirectory string, defaultWorkingDirectory string, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+// The commands run as the user set in the input, or as the user set for the document when the input sets none.
+func (p *Plugin) runCommandsRawInput(log log.T, pluginID string, rawPluginInput interface{}, orchestrationDirectory string, defaultWorkingDirectory string, defaultRunAsUser string, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
 	var pluginInput RunScriptPluginInput
 	err := jsonutil.Remarshal(rawPluginInput, &pluginInput)
 	if err != nil {
@@ -83,16 +103,51 @@ func (p *Plugin) runCommandsRawInput(log log.T, pluginID string, rawPluginInput
 		output.MarkAsFailed(errorString)
 		return
 	}
+	if pluginInput.RunAsUser == "" {
+		pluginInput.RunAsUser = defaultRunAsUser
+	}
 	p.runCommands(log, pluginID, pluginInput, orchestrationDirectory, defaultWorkingDirectory, cancelFlag, output)
 }
 
+// createRunAsScript writes the script the user runs to a temporary directory. The directory belongs to the agent and
+// is only opened to the user once the script is written, so that the user can't plant a link at the path of the script.
+func createRunAsScript(log log.T, scriptName string, runCommand []string, byteOrderMark fileutil.ByteOrderMark, runAsUser string) (scriptPath string, err error) {
+	var dir string
+	if dir, err = ioutil.TempDir("", "ssm-runscript"); err != nil {
+		return
+	}
+	scriptPath = filepath.Join(dir, scriptName)
+	if err = pluginutil.CreateScriptFile(log, scriptPath, runCommand, byteOrderMark); err == nil {
+		if err = chownToRunAsUser(scriptPath, runAsUser); err == nil {
+			// the user can run the script but can't add files to the directory
+			err = os.Chmod(dir, 0711)
+		}
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return
+}
+
 // runCommands executes one set of commands and returns their output.
 func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPluginInput, orchestrationDirectory string, defaultWorkingDirectory string, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
 	var err error
 	var workingDir string
 
+	// commands must not run as the agent user when another user is requested
+	commandExecuter, err := executers.WithRunAsUser(p.CommandExecuter, pluginInput.RunAsUser)
+	if err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+
 	if filepath.IsAbs(pluginInput.WorkingDirectory) {
 		workingDir = pluginInput.WorkingDirectory
+	} else if pluginInput.RunAsUser != "" {
+		// the orchestration directory is only given to the user once the step ran, a relative working directory is
+		// relative to the home directory of the user
+		workingDir = pluginInput.WorkingDirectory
 	} else {
 		orchestrationDir := strings.TrimSuffix(orchestrationDirectory, pluginID)
 		// The Document path is expected to have the name of the document
@@ -122,6 +177,16 @@ func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPl
 		return
 	}
 
+	// the user runs a copy of the script in a private directory, the orchestration directory being given to the user
+	// only once the step ran
+	if pluginInput.RunAsUser != "" {
+		if scriptPath, err = createRunAsScript(log, p.ScriptName, pluginInput.RunCommand, p.ByteOrderMark, pluginInput.RunAsUser); err != nil {
+			output.MarkAsFailed(fmt.Errorf("failed to create script file for %v. %v", pluginInput.RunAsUser, err))
+			return
+		}
+		defer os.RemoveAll(filepath.Dir(scriptPath))
+	}
+
 	// Set execution time
 	executionTimeout := pluginutil.ValidateExecutionTimeout(log, pluginInput.TimeoutSeconds)
 
@@ -130,7 +195,7 @@ func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPl
 	commandArguments := append(p.ShellArguments, scriptPath, appconfig.ExitCodeTrap)
 
 	// Execute Command
-	exitCode, err := p.CommandExecuter.NewExecute(log, workingDir, output.GetStdoutWriter(), output.GetStderrWriter(), cancelFlag, executionTimeout, commandName, commandArguments)
+	exitCode, err := commandExecuter.NewExecute(log, workingDir, output.GetStdoutWriter(), output.GetStderrWriter(), cancelFlag, executionTimeout, commandName, commandArguments)
 
 	// Set output status
 	output.SetExitCode(exitCode)
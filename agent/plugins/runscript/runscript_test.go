@@ -15,11 +15,16 @@ package runscript
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/mock"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/multiwriter/mock"
@@ -140,7 +145,7 @@ func testRunScripts(t *testing.T, testCase TestCase, rawInput bool) {
 			err := jsonutil.Remarshal(testCase.Input, &rawPluginInput)
 			assert.Nil(t, err)
 
-			p.runCommandsRawInput(logger, pluginID, rawPluginInput, orchestrationDirectory, defaultWorkingDirectory, mockCancelFlag, mockIOHandler)
+			p.runCommandsRawInput(logger, pluginID, rawPluginInput, orchestrationDirectory, defaultWorkingDirectory, "", mockCancelFlag, mockIOHandler)
 		} else {
 			p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, mockCancelFlag, mockIOHandler)
 		}
@@ -149,6 +154,21 @@ func testRunScripts(t *testing.T, testCase TestCase, rawInput bool) {
 	testExecution(t, runScriptTester)
 }
 
+// TestRunScripts_RunAsUser tests the commands don't run when the executer can't run them as the user of the document.
+func TestRunScripts_RunAsUser(t *testing.T) {
+	runScriptTester := func(p *Plugin, mockCancelFlag *task.MockCancelFlag, mockExecuter *executers.MockCommandExecuter, mockIOHandler *iohandlermocks.MockIOHandler) {
+		mockIOHandler.On("MarkAsFailed", mock.Anything).Return()
+
+		var rawPluginInput interface{}
+		err := jsonutil.Remarshal(generateTestCaseOk("0").Input, &rawPluginInput)
+		assert.Nil(t, err)
+
+		p.runCommandsRawInput(logger, pluginID, rawPluginInput, orchestrationDirectory, defaultWorkingDirectory, "deploy", mockCancelFlag, mockIOHandler)
+	}
+
+	testExecution(t, runScriptTester)
+}
+
 // TestBucketsInDifferentRegions tests runScripts when S3Buckets are present in IAD and PDX region.
 func TestBucketsInDifferentRegions(t *testing.T) {
 	for _, testCase := range TestCases {
@@ -343,3 +363,28 @@ func setCancelFlagExpectations(mockCancelFlag *task.MockCancelFlag, times int) {
 	mockCancelFlag.On("Canceled").Return(false).Times(times)
 	mockCancelFlag.On("ShutDown").Return(false).Times(times)
 }
+
+// TestCreateRunAsScript tests only the script is given to the user, the directory keeps belonging to the agent.
+func TestCreateRunAsScript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("running commands as another user is not supported on Windows")
+	}
+	var chowned []string
+	chownToRunAsUser = func(path string, runAsUser string) error {
+		chowned = append(chowned, path)
+		return nil
+	}
+	defer func() { chownToRunAsUser = executers.ChownToRunAsUser }()
+
+	scriptPath, err := createRunAsScript(logger, "_script.sh", []string{"date"}, fileutil.ByteOrderMarkSkip, "deploy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(filepath.Dir(scriptPath))
+
+	assert.Equal(t, []string{scriptPath}, chowned)
+	content, err := ioutil.ReadFile(scriptPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "date\n", string(content))
+	info, err := os.Stat(filepath.Dir(scriptPath))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0711), info.Mode().Perm())
+}
@@ -50,6 +50,9 @@ type UpdatePluginInput struct {
 	TargetVersion  string `json:"targetVersion"`
 	Source         string `json:"source"`
 	UpdaterName    string `json:"-"`
+	// UpdateSource is the repository of the manifest and packages, at the source or at the update source of the agent
+	// configuration when the source isn't set
+	UpdateSource updateutil.UpdateSource `json:"-"`
 }
 
 // UpdatePluginConfig is used for initializing update agent plugin with default values
@@ -104,6 +107,21 @@ func NewPlugin(updatePluginConfig UpdatePluginConfig) (*Plugin, error) {
 	return &plugin, nil
 }
 
+// isDefaultManifestSource returns true if the source is empty or is the default S3 manifest of the region, which the
+// AWS-UpdateSSMAgent document passes when the document doesn't override it
+func isDefaultManifestSource(source string, defaultManifestURL string, region string) bool {
+	if source == "" {
+		return true
+	}
+	source = strings.Replace(source, updateutil.RegionHolder, region, -1)
+	for _, manifestURL := range []string{defaultManifestURL, CommonManifestURL, ChinaManifestURL} {
+		if manifestURL != "" && strings.EqualFold(source, strings.Replace(manifestURL, updateutil.RegionHolder, region, -1)) {
+			return true
+		}
+	}
+	return false
+}
+
 // updateAgent downloads the installation packages and update the agent
 func runUpdateAgent(
 	p *Plugin,
@@ -126,17 +144,31 @@ func runUpdateAgent(
 		return
 	}
 
+	//Refuse the update during the blackout windows of the agent configuration
+	if appConfig, configErr := getAppConfig(false); configErr == nil {
+		if err = updateutil.CheckUpdateBlackout(appConfig.Agent.UpdateBlackoutWindows, time.Now()); err != nil {
+			output.MarkAsFailed(err)
+			return
+		}
+	}
+
 	if context, err = util.CreateInstanceContext(log); err != nil {
 		output.MarkAsFailed(err)
 		return
 	}
 
-	//Use default manifest location is the override is not present
-	if len(pluginInput.Source) == 0 {
-		pluginInput.Source = p.ManifestLocation
+	//Use the update source of the agent configuration unless the document overrides the default manifest location
+	if isDefaultManifestSource(pluginInput.Source, p.ManifestLocation, context.Region) {
+		if appConfig, configErr := getAppConfig(false); configErr == nil && appConfig.Agent.UpdateSource.Location != "" {
+			pluginInput.Source = appConfig.Agent.UpdateSource.Location
+		}
 	}
-	//Calculate manifest location base on current instance's region
-	pluginInput.Source = strings.Replace(pluginInput.Source, updateutil.RegionHolder, context.Region, -1)
+	//Calculate manifest location base on the update source and current instance's region
+	if pluginInput.UpdateSource, err = updateutil.NewUpdateSource(log, pluginInput.Source, p.ManifestLocation, context.Region); err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	pluginInput.Source = pluginInput.UpdateSource.ManifestLocation()
 	//Calculate updater package name base on agent name
 	pluginInput.UpdaterName = pluginInput.AgentName + updateutil.UpdaterPackageNamePrefix
 	//Generate update output
@@ -275,7 +307,7 @@ func (m *updateManager) generateUpdateCmd(log log.T,
 	return
 }
 
-//downloadManifest downloads manifest file from s3 bucket
+//downloadManifest downloads manifest file from the update source
 func (m *updateManager) downloadManifest(log log.T,
 	util updateutil.T,
 	pluginInput *UpdatePluginInput,
@@ -288,10 +320,7 @@ func (m *updateManager) downloadManifest(log log.T,
 		return nil, err
 	}
 
-	downloadInput := artifact.DownloadInput{
-		SourceURL:            pluginInput.Source,
-		DestinationDirectory: updateDownload,
-	}
+	downloadInput := updateutil.NewDownloadInput(pluginInput.Source, "", updateDownload)
 
 	downloadOutput, downloadErr := fileDownload(log, downloadInput)
 	if downloadErr != nil ||
@@ -300,10 +329,17 @@ func (m *updateManager) downloadManifest(log log.T,
 		return nil, downloadErr
 	}
 	out.AppendInfof("Successfully downloaded %v", downloadInput.SourceURL)
-	return ParseManifest(log, downloadOutput.LocalFilePath, context, pluginInput.AgentName)
+	if manifest, err = ParseManifest(log, downloadOutput.LocalFilePath, context, pluginInput.AgentName); err != nil {
+		return nil, err
+	}
+	//The packages of the mirrors and local repositories are next to their manifest
+	if pluginInput.UpdateSource != nil {
+		manifest.URIFormat = pluginInput.UpdateSource.URIFormat(manifest.URIFormat)
+	}
+	return manifest, nil
 }
 
-//downloadUpdater downloads updater from the s3 bucket
+//downloadUpdater downloads updater from the update source
 func (m *updateManager) downloadUpdater(log log.T,
 	util updateutil.T,
 	updaterPackageName string,
@@ -324,13 +360,7 @@ func (m *updateManager) downloadUpdater(log log.T,
 		return
 	}
 
-	downloadInput := artifact.DownloadInput{
-		SourceURL: source,
-		SourceChecksums: map[string]string{
-			updateutil.HashType: hash,
-		},
-		DestinationDirectory: updateDownloadFolder,
-	}
+	downloadInput := updateutil.NewDownloadInput(source, hash, updateDownloadFolder)
 	downloadOutput, downloadErr := fileDownload(log, downloadInput)
 	if downloadErr != nil ||
 		downloadOutput.IsHashMatched == false ||
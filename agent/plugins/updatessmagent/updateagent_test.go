@@ -19,6 +19,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
@@ -71,6 +72,36 @@ func TestDownloadManifest(t *testing.T) {
 	assert.NotNil(t, manifest)
 }
 
+func TestDownloadManifestFromMirror(t *testing.T) {
+	plugin := createStubPluginInput()
+	context := createStubInstanceContext()
+	plugin.UpdateSource, _ = updateutil.NewUpdateSource(logger, "https://mirror.example.com/ssm/", CommonManifestURL, "us-east-1")
+	plugin.Source = plugin.UpdateSource.ManifestLocation()
+
+	manager := updateManager{}
+	util := fakeUtility{}
+	out := iohandler.DefaultIOHandler{}
+
+	var downloadInput artifact.DownloadInput
+	fileDownload = func(log log.T, input artifact.DownloadInput) (output artifact.DownloadOutput, err error) {
+		downloadInput = input
+		result := artifact.DownloadOutput{}
+		result.IsHashMatched = true
+		result.LocalFilePath = "testdata/sampleManifest.json"
+		return result, nil
+	}
+
+	manifest, err := manager.downloadManifest(logger, &util, plugin, context, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://mirror.example.com/ssm/ssm-agent-manifest.json", downloadInput.SourceURL)
+	assert.True(t, downloadInput.VerifySignature)
+
+	// the packages are downloaded from the mirror rather than from the UriFormat of the manifest
+	source, _, err := manifest.DownloadURLAndHash(context, plugin.AgentName, "1.0.178.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://mirror.example.com/ssm/amazon-ssm-agent/1.0.178.0/amazon-ssm-agent-linux-amd64.tar.gz", source)
+}
+
 func TestDownloadUpdater(t *testing.T) {
 	plugin := createStubPluginInput()
 	context := createStubInstanceContext()
@@ -242,7 +273,7 @@ func TestValidateUpdate_UnsupportedCurrentVersion(t *testing.T) {
 
 func TestUpdateAgent_InvalidPluginRaw(t *testing.T) {
 	config := contracts.Configuration{}
-	plugin := &Plugin{}
+	plugin := &Plugin{ManifestLocation: CommonManifestURL}
 
 	mockCancelFlag := new(task.MockCancelFlag)
 
@@ -261,7 +292,7 @@ func TestUpdateAgent(t *testing.T) {
 	context := createStubInstanceContext()
 	manifest := createStubManifest(pluginInput, context, true, true)
 	config := contracts.Configuration{}
-	plugin := &Plugin{}
+	plugin := &Plugin{ManifestLocation: CommonManifestURL}
 
 	testCases := []fakeUpdateManager{
 		{
@@ -287,13 +318,70 @@ func TestUpdateAgent(t *testing.T) {
 	}
 }
 
+func TestUpdateAgent_Blackout(t *testing.T) {
+	getAppConfig = func(reload bool) (appconfig.SsmagentConfig, error) {
+		config := appconfig.DefaultConfig()
+		config.Agent.UpdateBlackoutWindows = []appconfig.BlackoutWindow{{Start: "00:00", End: "00:00"}}
+		return config, nil
+	}
+	defer func() { getAppConfig = appconfig.Config }()
+	pluginInput := createStubPluginInput()
+	plugin := &Plugin{ManifestLocation: CommonManifestURL}
+	mockCancelFlag := new(task.MockCancelFlag)
+	manager := &fakeUpdateManager{}
+	util := fakeUtility{}
+
+	out := iohandler.DefaultIOHandler{}
+	updateAgent(plugin, contracts.Configuration{}, logger, manager, &util, pluginInput, mockCancelFlag, &out, time.Now())
+
+	assert.Contains(t, out.GetStderr(), "update refused during the blackout window 00:00-00:00 UTC")
+}
+
+func TestUpdateAgent_ConfiguredUpdateSource(t *testing.T) {
+	getAppConfig = func(reload bool) (appconfig.SsmagentConfig, error) {
+		config := appconfig.DefaultConfig()
+		config.Agent.UpdateSource.Location = "https://mirror.example.com/ssm/"
+		return config, nil
+	}
+	defer func() { getAppConfig = appconfig.Config }()
+	plugin := &Plugin{ManifestLocation: CommonManifestURL}
+	mockCancelFlag := new(task.MockCancelFlag)
+	util := fakeUtility{}
+
+	for source, expected := range map[string]string{
+		// the source of the AWS-UpdateSSMAgent document is the default manifest, the configured mirror overrides it
+		"https://s3.{Region}.amazonaws.com/amazon-ssm-{Region}/ssm-agent-manifest.json": "https://mirror.example.com/ssm/ssm-agent-manifest.json",
+		"": "https://mirror.example.com/ssm/ssm-agent-manifest.json",
+		// an explicit source of the document wins over the configuration
+		"https://s3.amazonaws.com/my-bucket/ssm-agent-manifest.json": "https://s3.amazonaws.com/my-bucket/ssm-agent-manifest.json",
+	} {
+		pluginInput := createStubPluginInput()
+		pluginInput.Source = source
+		manager := &fakeUpdateManager{downloadManifestError: fmt.Errorf("stop after the manifest location")}
+		out := iohandler.DefaultIOHandler{}
+
+		updateAgent(plugin, contracts.Configuration{}, logger, manager, &util, pluginInput, mockCancelFlag, &out, time.Now())
+
+		assert.Equal(t, expected, manager.downloadManifestInput.Source, source)
+	}
+}
+
+func TestIsDefaultManifestSource(t *testing.T) {
+	assert.True(t, isDefaultManifestSource("", CommonManifestURL, "us-east-1"))
+	assert.True(t, isDefaultManifestSource(CommonManifestURL, CommonManifestURL, "us-east-1"))
+	assert.True(t, isDefaultManifestSource("https://s3.us-east-1.amazonaws.com/amazon-ssm-us-east-1/ssm-agent-manifest.json", CommonManifestURL, "us-east-1"))
+	assert.True(t, isDefaultManifestSource(ChinaManifestURL, ChinaManifestURL, "cn-north-1"))
+	assert.False(t, isDefaultManifestSource("https://s3.us-west-2.amazonaws.com/amazon-ssm-us-west-2/ssm-agent-manifest.json", CommonManifestURL, "us-east-1"))
+	assert.False(t, isDefaultManifestSource("https://mirror.example.com/ssm/", CommonManifestURL, "us-east-1"))
+}
+
 func TestUpdateAgent_NegativeTestCases(t *testing.T) {
 	pluginInput := createStubPluginInput()
 	pluginInput.TargetVersion = ""
 	context := createStubInstanceContext()
 	manifest := createStubManifest(pluginInput, context, true, true)
 	config := contracts.Configuration{}
-	plugin := &Plugin{}
+	plugin := &Plugin{ManifestLocation: CommonManifestURL}
 
 	testCases := []fakeUpdateManager{
 		{
@@ -344,7 +432,7 @@ func TestExecute(t *testing.T) {
 	p := make([]interface{}, 1)
 	p[0] = pluginInput
 	config.Properties = p
-	plugin := &Plugin{}
+	plugin := &Plugin{ManifestLocation: CommonManifestURL}
 
 	pluginInput.TargetVersion = ""
 	mockCancelFlag := new(task.MockCancelFlag)
@@ -464,6 +552,8 @@ type fakeUpdateManager struct {
 	downloadUpdaterError    error
 	validateUpdateResult    bool
 	validateUpdateError     error
+	// downloadManifestInput is the input of the plugin the manifest is downloaded for
+	downloadManifestInput *UpdatePluginInput
 }
 
 func (u *fakeUpdateManager) generateUpdateCmd(log log.T,
@@ -485,6 +575,7 @@ func (u *fakeUpdateManager) downloadManifest(log log.T,
 	pluginInput *UpdatePluginInput,
 	context *updateutil.InstanceContext,
 	out iohandler.IOHandler) (manifest *Manifest, err error) {
+	u.downloadManifestInput = pluginInput
 	return u.downloadManifestResult, u.downloadManifestError
 }
 
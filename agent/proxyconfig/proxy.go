@@ -0,0 +1,180 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package proxyconfig manages the proxy settings of the agent. SetProxySettings applies them to the http_proxy,
+// https_proxy and no_proxy environment variables the http requests of the agent and its child processes use.
+package proxyconfig
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// HTTP Proxy environment variables possible values
+var ProxyEnvVariables = [3]string{
+	"https_proxy",
+	"http_proxy",
+	"no_proxy",
+}
+
+// ProxySettings represents the proxy settings for https_proxy and http_proxy
+type ProxySettings struct {
+	https_proxy *url.URL
+	http_proxy  *url.URL
+}
+
+// ParseProxySettings parses the proxy-list string
+// The Windows proxy server list contains one or more of the following strings
+// ([<scheme>=][<scheme>"://"]<server>[":"<port>])
+// Internet Explorer and WinHTTP support 4 proxy types for [<scheme>=]:
+// http=, https=, ftp=, or socks=
+func ParseProxySettings(log log.T, proxy string) ProxySettings {
+	// Parse http and https proxy settings allowing only valid URL or host[:port] values
+	var http, https, other *url.URL
+	var err error = nil
+
+	for _, f := range strings.Fields(proxy) {
+		for _, s := range strings.Split(f, ";") {
+			if len(s) == 0 {
+				continue
+			}
+
+			split := strings.SplitN(s, "=", 2)
+			if len(split) > 1 {
+				switch split[0] {
+				case "https":
+					https, err = ValidateHost(split[1])
+				case "http":
+					http, err = ValidateHost(split[1])
+				default:
+					continue
+				}
+			} else {
+				other, err = ValidateHost(split[0])
+			}
+
+			if err != nil {
+				log.Warnf("ParseProxySettings, invalid URL or host for proxy: %v", err.Error())
+			}
+		}
+	}
+
+	result := ProxySettings{
+		http_proxy:  http,
+		https_proxy: https,
+	}
+
+	// If no [<scheme>=] is provided http is the default option
+	if https == nil && http == nil {
+		result.http_proxy = other
+	} else if https != nil && http == nil {
+		result.http_proxy = other
+	} else if https == nil && http != nil {
+		result.https_proxy = other
+	}
+
+	log.Debugf("ParseProxySettings result: http_proxy:%v,https_proxy:%v",
+		result.http_proxy,
+		result.https_proxy,
+	)
+
+	return result
+}
+
+// ValidateHost tries to parse the http_proxy and https_proxy addresses
+func ValidateHost(s string) (*url.URL, error) {
+
+	if s == "<-loopback>" || s == "<local>" {
+		return nil, errors.New(s + " host not supported, skipped")
+	}
+
+	// Helps url.Parse to validate an IP for example 127.0.0.1
+	if strings.Index(s, "//") == 0 {
+		s = "http:" + s
+	}
+
+	// Forces http when the schema is missing
+	if strings.Index(s, "://") == -1 {
+		s = "http://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, errors.New(err.Error() + s + ", skipped")
+	}
+
+	return u, nil
+}
+
+// ParseProxyBypass parses the hosts bypassing the proxy, allowing only valid URL, host[:port] or CIDR values.
+// The hosts are separated by blank spaces, semicolons or commas.
+func ParseProxyBypass(log log.T, bypass string) []string {
+	var bypassList = []string{}
+	for _, f := range strings.Fields(bypass) {
+		for _, s := range strings.FieldsFunc(f, func(r rune) bool { return r == ';' || r == ',' }) {
+			if _, _, err := net.ParseCIDR(s); err == nil {
+				bypassList = append(bypassList, s)
+				continue
+			}
+			url, err := ValidateHost(s)
+			if err == nil {
+				bypassList = append(bypassList, url.Host)
+			} else {
+				log.Warnf("ParseProxyBypass invalid URL or host for no_proxy: %v", err.Error())
+			}
+		}
+	}
+	return bypassList
+}
+
+// Proxy returns the proxy of the request, from the environment variables set by SetProxySettings when the agent starts
+func Proxy(request *http.Request) (*url.URL, error) {
+	return http.ProxyFromEnvironment(request)
+}
+
+var (
+	sharedTransport     *http.Transport
+	sharedTransportOnce sync.Once
+)
+
+// SharedTransport returns the transport shared by the http clients of the agent, which reuse its idle connections
+// the way they reused those of http.DefaultTransport. Clients that change the settings of their transport must
+// use NewTransport instead.
+func SharedTransport() *http.Transport {
+	sharedTransportOnce.Do(func() {
+		sharedTransport = NewTransport()
+	})
+	return sharedTransport
+}
+
+// NewTransport returns the transport of the http requests of the agent, with the settings of the default transport
+func NewTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: Proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build darwin freebsd linux netbsd openbsd
+
+package proxyconfig
+
+import (
+	"bufio"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Sources of the proxy settings, in order of precedence
+const (
+	sourceAppConfig   = "amazon-ssm-agent.json"
+	sourceEnvFile     = "proxy.env"
+	sourceEnvironment = "environment"
+)
+
+// proxyValues are the values of the proxy environment variables read from a source, keyed by lower case name
+type proxyValues map[string]string
+
+var loadConfig = func() (appconfig.SsmagentConfig, error) {
+	return appconfig.Config(false)
+}
+
+var readEnvFile = func() (string, error) {
+	if !fileutil.Exists(appconfig.ProxyEnvFilePath) {
+		return "", nil
+	}
+	return fileutil.ReadAllText(appconfig.ProxyEnvFilePath)
+}
+
+// For HTTP requests the agent gets the proxy address from the
+// environment variables http_proxy, https_proxy and no_proxy
+// https_proxy takes precedence over http_proxy for https requests.
+// SetProxySettings() overwrites the lower and upper case environment
+// variables with the value of each variable read, in order of precedence, from the Proxy settings of the Agent
+// section of amazon-ssm-agent.json, from the drop-in file /etc/amazon/ssm/proxy.env of lines such as
+// https_proxy=http://proxy:3128, and from the environment of the agent set by systemd or upstart, the lower case
+// variable before the upper case one.
+// The invalid proxies and hosts of no_proxy are removed, the variables being unset when no valid value remains.
+func SetProxySettings(log log.T) {
+	sources := []struct {
+		name   string
+		values proxyValues
+	}{
+		{sourceAppConfig, appConfigProxyValues(log)},
+		{sourceEnvFile, envFileProxyValues(log)},
+		{sourceEnvironment, environmentProxyValues()},
+	}
+
+	for _, name := range ProxyEnvVariables {
+		var value, source string
+		for _, s := range sources {
+			if v := strings.TrimSpace(s.values[name]); v != "" {
+				value, source = v, s.name
+				break
+			}
+		}
+
+		switch name {
+		case "https_proxy", "http_proxy":
+			if value != "" {
+				if u, err := ValidateHost(value); err == nil {
+					value = u.String()
+				} else {
+					log.Warnf("SetProxySettings invalid URL or host for %v: %v", name, err.Error())
+					value = ""
+				}
+			}
+		case "no_proxy":
+			value = strings.Join(ParseProxyBypass(log, value), ",")
+		}
+
+		setProxyEnvVariable(name, value)
+		if value != "" {
+			log.Infof("Proxy environment variable %v from %v: %v", name, source, redactProxy(name, value))
+		}
+	}
+}
+
+// appConfigProxyValues returns the proxy settings of the agent configuration
+func appConfigProxyValues(log log.T) proxyValues {
+	config, err := loadConfig()
+	if err != nil {
+		log.Warnf("Failed to load the proxy settings of the agent configuration: %v", err)
+		return proxyValues{}
+	}
+	return proxyValues{
+		"http_proxy":  config.Agent.Proxy.HttpProxy,
+		"https_proxy": config.Agent.Proxy.HttpsProxy,
+		"no_proxy":    config.Agent.Proxy.NoProxy,
+	}
+}
+
+// envFileProxyValues returns the proxy environment variables of the drop-in file, in the format of the EnvironmentFile
+// of systemd. Lines starting with export, as sourced by shell scripts, are accepted too.
+func envFileProxyValues(log log.T) proxyValues {
+	values := proxyValues{}
+	content, err := readEnvFile()
+	if err != nil {
+		log.Warnf("Failed to read the proxy settings of %v: %v", appconfig.ProxyEnvFilePath, err)
+		return values
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		split := strings.SplitN(line, "=", 2)
+		if len(split) != 2 {
+			log.Warnf("Invalid line in %v: %v", appconfig.ProxyEnvFilePath, line)
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(split[0]))
+		if !isProxyEnvVariable(name) {
+			continue
+		}
+		if _, found := values[name]; !found || name == strings.TrimSpace(split[0]) {
+			// the lower case variable takes precedence over the upper case one
+			values[name] = unquote(strings.TrimSpace(split[1]))
+		}
+	}
+	return values
+}
+
+// environmentProxyValues returns the proxy environment variables of the agent process
+func environmentProxyValues() proxyValues {
+	values := proxyValues{}
+	for _, name := range ProxyEnvVariables {
+		if value := os.Getenv(name); value != "" {
+			values[name] = value
+		} else {
+			values[name] = os.Getenv(strings.ToUpper(name))
+		}
+	}
+	return values
+}
+
+// setProxyEnvVariable sets the lower and upper case variables, the http requests of the agent using the upper case
+// variables before the lower case ones
+func setProxyEnvVariable(name string, value string) {
+	for _, variable := range []string{name, strings.ToUpper(name)} {
+		if value == "" {
+			os.Unsetenv(variable)
+		} else {
+			os.Setenv(variable, value)
+		}
+	}
+}
+
+func isProxyEnvVariable(name string) bool {
+	for _, variable := range ProxyEnvVariables {
+		if name == variable {
+			return true
+		}
+	}
+	return false
+}
+
+// unquote removes the single or double quotes around the value
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// redactProxy hides the password of the proxy in the logs
+func redactProxy(name string, value string) string {
+	if name == "no_proxy" {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
@@ -11,12 +11,9 @@
 // either express or implied. See the License for the specific language governing
 // permissions and limitations under the License.
 
-// Package proxy config to handle set/get functions of the Windows proxy settings
 package proxyconfig
 
 import (
-	"errors"
-	"net/url"
 	"os"
 	"strings"
 	"syscall"
@@ -68,19 +65,6 @@ type HttpDefaultProxyConfig struct {
 	bypass string
 }
 
-// HTTP Proxy environment variables possible values
-var ProxyEnvVariables = [3]string{
-	"https_proxy",
-	"http_proxy",
-	"no_proxy",
-}
-
-// ProxySettings represents the proxy settings for https_proxy and http_proxy
-type ProxySettings struct {
-	https_proxy *url.URL
-	http_proxy  *url.URL
-}
-
 // StringFromUTF16Ptr converts a *uint16 C string to a Go String
 // https://github.com/mattn/go-ieproxy/blob/master/utils.go
 func StringFromUTF16Ptr(s *uint16) string {
@@ -164,20 +148,7 @@ func SetProxySettings(log log.T) {
 	// Parse no_proxy settings allowing only valid URL or host[:port] values
 	// The proxy bypass string contains multiple addresses and host names, separate
 	// with blank spaces or semicolons
-	var bypassList = []string{}
-	for _, f := range strings.Fields(bypass) {
-		for _, s := range strings.Split(f, ";") {
-			if len(s) == 0 {
-				continue
-			}
-			url, err := ValidateHost(s)
-			if err == nil {
-				bypassList = append(bypassList, url.Host)
-			} else {
-				log.Warnf("SetProxySettings invalid URL or host for no_proxy: %v", err.Error())
-			}
-		}
-	}
+	bypassList := ParseProxyBypass(log, bypass)
 
 	if len(bypassList) > 0 {
 		os.Setenv("no_proxy", strings.Join(bypassList, ","))
@@ -278,85 +249,3 @@ func GetIEProxySettings(log log.T) (p HttpIEProxyConfig, err error) {
 	return result, nil
 }
 
-// ParseProxySettings parses the proxy-list string
-// The Windows proxy server list contains one or more of the following strings
-// ([<scheme>=][<scheme>"://"]<server>[":"<port>])
-// Internet Explorer and WinHTTP support 4 proxy types for [<scheme>=]:
-// http=, https=, ftp=, or socks=
-func ParseProxySettings(log log.T, proxy string) ProxySettings {
-	// Parse http and https proxy settings allowing only valid URL or host[:port] values
-	var http, https, other *url.URL
-	var err error = nil
-
-	for _, f := range strings.Fields(proxy) {
-		for _, s := range strings.Split(f, ";") {
-			if len(s) == 0 {
-				continue
-			}
-
-			split := strings.SplitN(s, "=", 2)
-			if len(split) > 1 {
-				switch split[0] {
-				case "https":
-					https, err = ValidateHost(split[1])
-				case "http":
-					http, err = ValidateHost(split[1])
-				default:
-					continue
-				}
-			} else {
-				other, err = ValidateHost(split[0])
-			}
-
-			if err != nil {
-				log.Warnf("ParseProxySettings, invalid URL or host for proxy: %v", err.Error())
-			}
-		}
-	}
-
-	result := ProxySettings{
-		http_proxy:  http,
-		https_proxy: https,
-	}
-
-	// If no [<scheme>=] is provided http is the default option
-	if https == nil && http == nil {
-		result.http_proxy = other
-	} else if https != nil && http == nil {
-		result.http_proxy = other
-	} else if https == nil && http != nil {
-		result.https_proxy = other
-	}
-
-	log.Debugf("ParseProxySettings result: http_proxy:%v,https_proxy:%v",
-		result.http_proxy,
-		result.https_proxy,
-	)
-
-	return result
-}
-
-// ValidateHost tries to parse the http_proxy and https_proxy addresses
-func ValidateHost(s string) (*url.URL, error) {
-
-	if s == "<-loopback>" || s == "<local>" {
-		return nil, errors.New(s + " host not supported, skipped")
-	}
-
-	// Helps url.Parse to validate an IP for example 127.0.0.1
-	if strings.Index(s, "//") == 0 {
-		s = "http:" + s
-	}
-
-	// Forces http when the schema is missing
-	if strings.Index(s, "://") == -1 {
-		s = "http://" + s
-	}
-
-	u, err := url.Parse(s)
-	if err != nil {
-		return nil, errors.New(err.Error() + s + ", skipped")
-	}
-
-	return u, nil
-}
@@ -0,0 +1,186 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package service is a wrapper for the SSM Message Delivery Service and Offline Command Service
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/docparser"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/go-yaml/yaml"
+)
+
+const (
+	// envelopeDocumentKey identifies an envelope, documents have no such top level key
+	envelopeDocumentKey = "documentContent"
+
+	// bounds of the execution timeout of the plugins
+	minTimeoutSeconds = 5
+	maxTimeoutSeconds = 172800
+)
+
+// offlineCommand is a locally submitted command, either a document or an envelope carrying the document along with
+// the values it runs with, i.e.
+//
+//	{
+//	  "documentContent": {"schemaVersion": "2.0", "parameters": {"commands": {"type": "StringList"}}, "mainSteps": [...]},
+//	  "parameters": {"commands": ["echo foo"]},
+//	  "outputS3BucketName": "bucket",
+//	  "outputS3KeyPrefix": "prefix",
+//	  "timeoutSeconds": 600,
+//	  "comment": "nightly cleanup"
+//	}
+//
+// Both can be written in JSON or YAML.
+type offlineCommand struct {
+	DocumentContent    contracts.DocumentContent `json:"documentContent"`
+	Parameters         map[string]interface{}    `json:"parameters"`
+	OutputS3BucketName string                    `json:"outputS3BucketName"`
+	OutputS3KeyPrefix  string                    `json:"outputS3KeyPrefix"`
+	TimeoutSeconds     int                       `json:"timeoutSeconds"`
+	Comment            string                    `json:"comment"`
+
+	// parsedParameters are the parameter values in the format of the parameters sent by MDS
+	parsedParameters map[string]interface{}
+}
+
+// parseOfflineCommand parses and validates a locally submitted document or envelope
+func parseOfflineCommand(log log.T, content []byte) (command offlineCommand, err error) {
+	var raw map[string]interface{}
+	if errJSON := json.Unmarshal(content, &raw); errJSON != nil {
+		var rawYAML interface{}
+		if errYAML := yaml.Unmarshal(content, &rawYAML); errYAML != nil {
+			return command, fmt.Errorf("document is neither valid JSON nor valid YAML. JSON format error - %v, YAML format error - %v", errJSON, errYAML)
+		}
+		var isMap bool
+		if raw, isMap = normalizeYAML(rawYAML).(map[string]interface{}); !isMap {
+			return command, fmt.Errorf("document must be a JSON or YAML object")
+		}
+	}
+
+	if _, isEnvelope := raw[envelopeDocumentKey]; isEnvelope {
+		err = jsonutil.Remarshal(raw, &command)
+	} else {
+		err = jsonutil.Remarshal(raw, &command.DocumentContent)
+	}
+	if err != nil {
+		return command, err
+	}
+
+	if err = validateOfflineDocument(command.DocumentContent); err != nil {
+		return command, err
+	}
+	if command.TimeoutSeconds != 0 && (command.TimeoutSeconds < minTimeoutSeconds || command.TimeoutSeconds > maxTimeoutSeconds) {
+		return command, fmt.Errorf("timeoutSeconds must be between %v and %v", minTimeoutSeconds, maxTimeoutSeconds)
+	}
+	parameterValues, err := getParameterValues(command.Parameters, command.DocumentContent.Parameters)
+	if err != nil {
+		return command, err
+	}
+	command.parsedParameters = docparser.ParseParameters(log, parameterValues, command.DocumentContent.Parameters)
+	// the timeout is applied by the processor to the steps whose plugin accepts a timeout
+	command.DocumentContent.TimeoutSeconds = command.TimeoutSeconds
+	return command, nil
+}
+
+// validateOfflineDocument checks that the document has at least one runtimeConfig for 1.2 or mainSteps for 2.0 and later
+func validateOfflineDocument(content contracts.DocumentContent) error {
+	switch {
+	case content.SchemaVersion == "":
+		return fmt.Errorf("schemaVersion is required")
+	case content.SchemaVersion == "1.2":
+		if len(content.RuntimeConfig) == 0 {
+			return fmt.Errorf("runtimeConfig cannot be empty")
+		}
+	case len(content.MainSteps) == 0:
+		return fmt.Errorf("mainSteps cannot be empty")
+	}
+	return nil
+}
+
+// getParameterValues converts the parameter values of the envelope to the format of the parameters sent by MDS.
+// Unlike MDS, which validates the parameters when the command is sent, values of undeclared parameters are rejected.
+func getParameterValues(values map[string]interface{}, definitions map[string]*contracts.Parameter) (map[string][]*string, error) {
+	result := make(map[string][]*string)
+	var errs []string
+	for name, value := range values {
+		definition, isDeclared := definitions[name]
+		if !isDeclared {
+			errs = append(errs, fmt.Sprintf("parameter %v is not declared by the document", name))
+			continue
+		}
+
+		var list []*string
+		switch value := value.(type) {
+		case string:
+			list = []*string{&value}
+		case []interface{}:
+			for _, item := range value {
+				itemString, isString := item.(string)
+				if !isString {
+					errs = append(errs, fmt.Sprintf("parameter %v must be a list of strings", name))
+					break
+				}
+				list = append(list, &itemString)
+			}
+		case map[string]interface{}:
+			// StringMap parameters are sent by MDS as json
+			mapJSON, err := jsonutil.Marshal(value)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("parameter %v is invalid: %v", name, err))
+				continue
+			}
+			list = []*string{&mapJSON}
+		default:
+			errs = append(errs, fmt.Sprintf("parameter %v must be a string or a list of strings", name))
+			continue
+		}
+
+		if definition.ParamType != contracts.ParamTypeStringList && len(list) != 1 {
+			errs = append(errs, fmt.Sprintf("parameter %v of type %v expects a single value", name, definition.ParamType))
+			continue
+		}
+		result[name] = list
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, fmt.Errorf("%v", strings.Join(errs, "\n"))
+	}
+	return result, nil
+}
+
+// normalizeYAML converts the maps decoded from YAML to maps with string keys so that the content can be marshaled to JSON
+func normalizeYAML(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			out[fmt.Sprintf("%v", k)] = normalizeYAML(v)
+		}
+		return out
+	case []interface{}:
+		for i, v := range value {
+			value[i] = normalizeYAML(v)
+		}
+		return value
+	default:
+		return value
+	}
+}
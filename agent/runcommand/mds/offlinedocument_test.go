@@ -0,0 +1,105 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package service is a wrapper for the SSM Message Delivery Service and Offline Command Service
+package service
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func loadOfflineCommand(t *testing.T, name string) (offlineCommand, error) {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	assert.NoError(t, err)
+	return parseOfflineCommand(logger, content)
+}
+
+func TestParseOfflineCommand_Document(t *testing.T) {
+	for _, name := range []string{"validcommand12.json", "validcommand20.json", "validcommand22.yaml"} {
+		command, err := loadOfflineCommand(t, name)
+		assert.NoError(t, err, name)
+		assert.NotEmpty(t, command.DocumentContent.SchemaVersion, name)
+		assert.Empty(t, command.parsedParameters, name)
+		assert.Equal(t, 0, command.TimeoutSeconds, name)
+	}
+
+	command, _ := loadOfflineCommand(t, "validcommand22.yaml")
+	assert.Equal(t, "{{ commands }}", command.DocumentContent.MainSteps[0].Inputs.(map[string]interface{})["runCommand"])
+	// the content is sent to the processor as json
+	_, err := jsonutil.Marshal(command.DocumentContent)
+	assert.NoError(t, err)
+}
+
+func TestParseOfflineCommand_Envelope(t *testing.T) {
+	command, err := loadOfflineCommand(t, "envelope.yaml")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "2.2", command.DocumentContent.SchemaVersion)
+	assert.Equal(t, map[string]interface{}{
+		"commands":         []string{"echo foo", "echo bar"},
+		"workingDirectory": "/tmp",
+	}, command.parsedParameters)
+	assert.Equal(t, "bucket", command.OutputS3BucketName)
+	assert.Equal(t, "prefix", command.OutputS3KeyPrefix)
+	assert.Equal(t, "nightly cleanup", command.Comment)
+	assert.Equal(t, 600, command.DocumentContent.TimeoutSeconds)
+	// the inputs of the steps are left to the validation against the input schema of their plugin
+	_, isSet := command.DocumentContent.MainSteps[0].Inputs.(map[string]interface{})["timeoutSeconds"]
+	assert.False(t, isSet)
+}
+
+func TestParseOfflineCommand_Timeout(t *testing.T) {
+	command, err := parseOfflineCommand(logger, []byte(`{
+		"documentContent": {"schemaVersion": "1.2", "runtimeConfig": {"aws:runShellScript": {"properties": [
+			{"id": "0.aws:runShellScript", "runCommand": ["echo foo"]},
+			{"id": "1.aws:runShellScript", "runCommand": ["echo bar"], "timeoutSeconds": 60}
+		]}}},
+		"timeoutSeconds": 600
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 600, command.DocumentContent.TimeoutSeconds)
+
+	// a document can't set the timeout of the envelope
+	command, err = parseOfflineCommand(logger, []byte(`{"schemaVersion": "2.2", "mainSteps": [{"action": "aws:runShellScript", "name": "test"}], "timeoutSeconds": 1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, command.DocumentContent.TimeoutSeconds)
+}
+
+func TestParseOfflineCommand_Invalid(t *testing.T) {
+	testCases := map[string]string{
+		"not an object":        `- echo foo`,
+		"no schema version":    `{"mainSteps": [{"action": "aws:runShellScript", "name": "test"}]}`,
+		"no steps":             `{"schemaVersion": "2.2"}`,
+		"no runtime config":    `{"documentContent": {"schemaVersion": "1.2"}}`,
+		"undeclared parameter": `{"documentContent": {"schemaVersion": "2.2", "mainSteps": [{}]}, "parameters": {"foo": "bar"}}`,
+		"too many values": `{
+			"documentContent": {"schemaVersion": "2.2", "parameters": {"foo": {"type": "String"}}, "mainSteps": [{}]},
+			"parameters": {"foo": ["bar", "baz"]}
+		}`,
+		"not a string": `{
+			"documentContent": {"schemaVersion": "2.2", "parameters": {"foo": {"type": "StringList"}}, "mainSteps": [{}]},
+			"parameters": {"foo": [1]}
+		}`,
+		"timeout out of range": `{"documentContent": {"schemaVersion": "2.2", "mainSteps": [{}]}, "timeoutSeconds": 1}`,
+	}
+
+	for name, content := range testCases {
+		_, err := parseOfflineCommand(logger, []byte(content))
+		assert.Error(t, err, name)
+	}
+}
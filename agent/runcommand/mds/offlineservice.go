@@ -16,33 +16,67 @@ package service
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"errors"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
-	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	messageContracts "github.com/aws/amazon-ssm-agent/agent/runcommand/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/runcommand/resultstore"
 	"github.com/aws/amazon-ssm-agent/agent/times"
 	"github.com/aws/aws-sdk-go/service/ssmmds"
+	"github.com/fsnotify/fsnotify"
 	"github.com/twinj/uuid"
 )
 
+const (
+	// partialDocumentSuffix marks a command document that is still being written, tools submitting
+	// large documents write them with this suffix and rename them once complete
+	partialDocumentSuffix = ".tmp"
+)
+
+// Created as variables to allow for better testability
+var (
+	// offlineLongPollTimeout is how long GetMessages waits for a new command document, like the MDS long poll
+	offlineLongPollTimeout = 20 * time.Second
+	// documentSettleTime is how long a command document must remain unmodified before it is parsed
+	documentSettleTime = 2 * time.Second
+)
+
 type offlineService struct {
 	TopicPrefix         string
 	newCommandDir       string
 	submittedCommandDir string
 	commandResultDir    string
 	invalidCommandDir   string
+
+	// results indexes the replies of the commands, documents are deleted along with the results
+	results *resultstore.ResultStore
+	// documentRetention is how long invalid documents are kept
+	documentRetention time.Duration
+
+	// watcher notifies changes of the new command folder, the folder is scanned on every poll when it is nil
+	watcher *fsnotify.Watcher
+	// changed is signaled when a command document may be ready in the new command folder
+	changed chan struct{}
+	// stopped is closed when the service stops
+	stopped  chan struct{}
+	stopOnce sync.Once
+	// settleTime is how long a document must remain unmodified before it is parsed
+	settleTime time.Duration
+	// rescanAfter is set when the last scan skipped documents that are still being written
+	rescanAfter time.Duration
 }
 
 // NewOfflineService initializes a service that looks for work in a local command folder
-func NewOfflineService(log log.T, topicPrefix string) (Service, error) {
+func NewOfflineService(log log.T, topicPrefix string, config appconfig.SsmagentConfig) (Service, error) {
 	uuid.SwitchFormat(uuid.CleanHyphen)
 	// Create and harden local document folder if needed
 	err := fileutil.MakeDirs(appconfig.LocalCommandRoot)
@@ -51,29 +85,124 @@ func NewOfflineService(log log.T, topicPrefix string) (Service, error) {
 		return nil, err
 	}
 	err = fileutil.MakeDirs(appconfig.LocalCommandRootCompleted)
-	return &offlineService{
+	ols := &offlineService{
 		TopicPrefix:         topicPrefix,
 		newCommandDir:       appconfig.LocalCommandRoot,
 		submittedCommandDir: appconfig.LocalCommandRootSubmitted,
 		invalidCommandDir:   appconfig.LocalCommandRootInvalid,
 		commandResultDir:    appconfig.LocalCommandRootCompleted,
-	}, err
+		results:             resultstore.NewResultStore(config),
+		documentRetention:   time.Duration(config.Ssm.OfflineCommandResultsRetentionDurationHours) * time.Hour,
+		settleTime:          documentSettleTime,
+	}
+	ols.cleanup(log)
+	ols.startWatcher(log)
+	return ols, err
+}
+
+// startWatcher watches the new command folder for documents, the service falls back to polling
+// the folder when filesystem notifications are unavailable
+func (ols *offlineService) startWatcher(log log.T) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Errorf("Failed to create watcher for local command directory, falling back to polling: %v", err)
+		return
+	}
+	if err = watcher.Add(ols.newCommandDir); err != nil {
+		log.Errorf("Failed to watch local command directory %v, falling back to polling: %v", ols.newCommandDir, err)
+		watcher.Close()
+		return
+	}
+
+	ols.watcher = watcher
+	ols.changed = make(chan struct{}, 1)
+	ols.stopped = make(chan struct{})
+	// documents submitted while the agent was not running are processed by the first poll
+	ols.changed <- struct{}{}
+	go ols.watch(log)
+}
+
+// watch signals the changes of the new command folder that can make a document ready to be processed
+func (ols *offlineService) watch(log log.T) {
+	for {
+		select {
+		case event, ok := <-ols.watcher.Events:
+			if !ok {
+				return
+			}
+			if strings.HasSuffix(event.Name, partialDocumentSuffix) || !isCommandDocument(event.Name) {
+				// partial documents trigger an event once renamed, documents moved out of the folder are done
+				continue
+			}
+			log.Debugf("Local command directory event %v", event)
+			select {
+			case ols.changed <- struct{}{}:
+			default:
+			}
+		case err, ok := <-ols.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Errorf("Local command directory watcher error: %v", err)
+		}
+	}
+}
+
+// isCommandDocument returns true if the path is a file
+func isCommandDocument(path string) bool {
+	fileInfo, err := os.Stat(path)
+	return err == nil && !fileInfo.IsDir()
+}
+
+// waitForCommandDocuments blocks until the new command folder changes, documents that are still
+// being written settle, the long poll timeout elapses or the service stops
+func (ols *offlineService) waitForCommandDocuments(log log.T) {
+	if ols.watcher == nil {
+		// without filesystem notifications the folder is scanned on every poll
+		return
+	}
+	timeout := offlineLongPollTimeout
+	if ols.rescanAfter > 0 {
+		timeout = ols.rescanAfter
+	}
+	select {
+	case <-ols.changed:
+	case <-time.After(timeout):
+	case <-ols.stopped:
+	}
 }
 
 // GetMessages looks for new local command documents on the filesystem and parses them into messages
 func (ols *offlineService) GetMessages(log log.T, instanceID string) (messages *ssmmds.GetMessagesOutput, err error) {
 	messages = &ssmmds.GetMessagesOutput{}
+	ols.waitForCommandDocuments(log)
 
 	// Look for unprocessed locally submitted documents
 	var docName, docPath string
-	var filenames []string
-	if filenames, err = fileutil.GetFileNames(ols.newCommandDir); err != nil {
+	var files []os.FileInfo
+	if files, err = fileutil.ReadDir(ols.newCommandDir); err != nil {
 		log.Debugf("offlineservice: error: %v", err.Error())
 		return messages, err
 	}
-	messages.Messages = make([]*ssmmds.Message, 0, len(filenames))
-	for _, filename := range filenames {
-		docName = filename
+	ols.rescanAfter = 0
+	messages.Messages = make([]*ssmmds.Message, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(file.Name(), partialDocumentSuffix) {
+			log.Debugf("Local command document %v is still being written", file.Name())
+			continue
+		}
+		if unmodified := time.Since(file.ModTime()); unmodified < ols.settleTime {
+			// the document may still be being written, parse it once it is no longer modified
+			log.Debugf("Local command document %v was modified %v ago, waiting for it to settle", file.Name(), unmodified)
+			if wait := ols.settleTime - unmodified; ols.rescanAfter == 0 || wait < ols.rescanAfter {
+				ols.rescanAfter = wait
+			}
+			continue
+		}
+		docName = file.Name()
 		docPath = filepath.Join(ols.newCommandDir, docName)
 		log.Debugf("Found local command document %v | %v", docName, docPath)
 
@@ -84,19 +213,30 @@ func (ols *offlineService) GetMessages(log log.T, instanceID string) (messages *
 		messageID := fmt.Sprintf("aws.ssm.%v.%v", commandID, instanceID)
 
 		// Parse file
-		var content contracts.DocumentContent
-		if errContent := jsonutil.UnmarshalFile(docPath, &content); errContent != nil {
+		var command offlineCommand
+		rawContent, errContent := fileutil.ReadAllText(docPath)
+		if errContent == nil {
+			command, errContent = parseOfflineCommand(log, []byte(rawContent))
+		}
+		if errContent != nil {
 			log.Errorf("Error parsing command document %v:\n%v", docName, errContent)
 			if errMove := moveCommandDocument(ols.newCommandDir, ols.invalidCommandDir, docName, commandID); errMove != nil {
 				log.Errorf("Command %v was invalid but failed to move to invalid folder: %v", commandID, errMove.Error())
 			}
 			continue
 		}
-		debugContent, _ := jsonutil.Marshal(content)
+		debugContent, _ := jsonutil.Marshal(command.DocumentContent)
 		log.Debugf("Local command content:\n%v", debugContent)
 
 		// Turn it into a message
-		payload := &messageContracts.SendCommandPayload{DocumentContent: content, CommandID: commandID, DocumentName: docName}
+		payload := &messageContracts.SendCommandPayload{
+			DocumentContent:    command.DocumentContent,
+			Parameters:         command.parsedParameters,
+			CommandID:          commandID,
+			DocumentName:       docName,
+			OutputS3BucketName: command.OutputS3BucketName,
+			OutputS3KeyPrefix:  command.OutputS3KeyPrefix,
+		}
 		var payloadstr string
 		if payloadstr, err = jsonutil.Marshal(payload); err != nil {
 			log.Errorf("Error marshalling message for command document %v with message ID %v:\n%v", docName, messageID, err)
@@ -119,6 +259,9 @@ func (ols *offlineService) GetMessages(log log.T, instanceID string) (messages *
 			log.Errorf("Command %v was valid but failed to move to submitted folder: %v", commandID, errMove.Error())
 			continue // If doc failed to move, we will not return this message - we don't want to reprocess it or make it impossible to know which command ID it was given
 		}
+		if errSave := ols.results.SaveSubmitted(log, commandID, docName, command.Comment); errSave != nil {
+			log.Errorf("Failed to index submitted command %v: %v", commandID, errSave)
+		}
 
 		messages.Messages = append(messages.Messages, message)
 	}
@@ -128,7 +271,6 @@ func (ols *offlineService) GetMessages(log log.T, instanceID string) (messages *
 	return messages, nil
 }
 
-// TODO:MF: clean up old documents in dstDir?  Or maybe do that in SendReply?  Maybe both
 // moveCommandDocument moves a command into its final destination and attaches the command ID file extension
 func moveCommandDocument(srcDir string, dstDir string, docName string, commandID string) error {
 	// Make directory with appropriate ACL
@@ -161,12 +303,41 @@ func (ols *offlineService) SendReply(log log.T, messageID string, payload string
 		log.Errorf("failed to parse messageID: %v", err)
 		return nil
 	}
-	if err := fileutil.WriteAllText(filepath.Join(ols.commandResultDir, commandID), payload); err != nil {
-		log.Errorf("failed to write command %v result: %v", commandID, err)
+	if err := ols.results.SaveReply(log, commandID, payload); err != nil {
+		log.Errorf("failed to index command %v result: %v", commandID, err)
+		// keep the raw result so that it is not lost
+		if err := fileutil.WriteAllText(filepath.Join(ols.commandResultDir, commandID), payload); err != nil {
+			log.Errorf("failed to write command %v result: %v", commandID, err)
+		}
 	}
+	ols.cleanup(log)
 	return nil
 }
 
+// cleanup deletes the results exceeding the retention policy along with the documents of these commands,
+// invalid documents have no result and are deleted once older than the retention
+func (ols *offlineService) cleanup(log log.T) {
+	for _, invocation := range ols.results.Cleanup(log) {
+		submittedName := strings.Join([]string{invocation.DocumentName, invocation.CommandID}, ".")
+		if err := fileutil.DeleteFile(filepath.Join(ols.submittedCommandDir, submittedName)); err != nil && !os.IsNotExist(err) {
+			log.Errorf("Failed to delete submitted document %v: %v", submittedName, err)
+		}
+	}
+
+	files, err := fileutil.ReadDir(ols.invalidCommandDir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		if file.IsDir() || time.Since(file.ModTime()) <= ols.documentRetention {
+			continue
+		}
+		if err := fileutil.DeleteFile(filepath.Join(ols.invalidCommandDir, file.Name())); err != nil {
+			log.Errorf("Failed to delete invalid document %v: %v", file.Name(), err)
+		}
+	}
+}
+
 func (ols *offlineService) FailMessage(log log.T, messageID string, failureType FailureType) error {
 	return nil
 }
@@ -175,4 +346,14 @@ func (ols *offlineService) DeleteMessage(log log.T, messageID string) error {
 	return nil
 }
 
-func (ols *offlineService) Stop() {}
+// Stop stops watching the local command folder and releases the pending GetMessages call
+func (ols *offlineService) Stop() {
+	ols.stopOnce.Do(func() {
+		if ols.watcher == nil {
+			return
+		}
+		close(ols.stopped)
+		// closing the watcher also ends the watch go routine, fsnotify Close can block so it is offloaded
+		go ols.watcher.Close()
+	})
+}
@@ -15,21 +15,31 @@
 package service
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	messageContracts "github.com/aws/amazon-ssm-agent/agent/runcommand/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/runcommand/resultstore"
 	"github.com/stretchr/testify/assert"
 )
 
 var logger = log.NewMockLog()
 
-const (
-	newCommands       = "testdata/new"
-	submittedCommands = "testdata/new/submitted"
-	invalidCommands   = "testdata/new/invalid"
-	completeDir       = "testdata/new/completed"
+// directories of the test service, created in a temporary directory by GetTestService so that the cleanup of the
+// service never deletes files of the source tree
+var (
+	testRootDir       string
+	newCommands       string
+	submittedCommands string
+	invalidCommands   string
+	completeDir       string
 )
 
 func TestValid(t *testing.T) {
@@ -80,6 +90,117 @@ func TestBothVersions(t *testing.T) {
 	assert.Equal(t, 2, FileCount(submittedCommands))
 }
 
+func TestEnvelope(t *testing.T) {
+	service := GetTestService().(*offlineService)
+
+	defer CleanTestDirs()
+	err := SubmitTestDoc("envelope.yaml")
+	assert.Nil(t, err)
+
+	messages, err := service.GetMessages(logger, "i-bar")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(messages.Messages))
+	assert.Equal(t, 1, FileCount(submittedCommands))
+
+	var payload messageContracts.SendCommandPayload
+	assert.Nil(t, jsonutil.Unmarshal(*messages.Messages[0].Payload, &payload))
+	assert.Equal(t, []interface{}{"echo foo", "echo bar"}, payload.Parameters["commands"])
+	assert.Equal(t, "bucket", payload.OutputS3BucketName)
+	assert.Equal(t, "prefix", payload.OutputS3KeyPrefix)
+	assert.Equal(t, "envelope.yaml", payload.DocumentName)
+
+	invocation, err := service.results.Get(logger, payload.CommandID)
+	assert.Nil(t, err)
+	assert.Equal(t, "nightly cleanup", invocation.Comment)
+}
+
+func TestPartialDocumentIsNotProcessed(t *testing.T) {
+	service := GetTestService()
+
+	defer CleanTestDirs()
+	err := SubmitTestDocAs("validcommand20.json", "validcommand20.json"+partialDocumentSuffix)
+	assert.Nil(t, err)
+
+	messages, err := service.GetMessages(logger, "i-bar")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(messages.Messages))
+	assert.Equal(t, 1, FileCount(newCommands))
+}
+
+func TestDocumentIsProcessedOnceSettled(t *testing.T) {
+	service := GetTestService().(*offlineService)
+	service.settleTime = time.Hour
+
+	defer CleanTestDirs()
+	err := SubmitTestDoc("validcommand20.json")
+	assert.Nil(t, err)
+
+	messages, err := service.GetMessages(logger, "i-bar")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(messages.Messages))
+	assert.Equal(t, 1, FileCount(newCommands))
+	assert.True(t, service.rescanAfter > 0 && service.rescanAfter <= time.Hour)
+
+	// the document is processed once it hasn't been modified for the settle time
+	service.settleTime = 0
+	messages, err = service.GetMessages(logger, "i-bar")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(messages.Messages))
+	assert.Equal(t, 0, FileCount(newCommands))
+	assert.Equal(t, time.Duration(0), service.rescanAfter)
+}
+
+func TestWatcherPicksUpRenamedDocument(t *testing.T) {
+	service := GetTestService().(*offlineService)
+	service.startWatcher(logger)
+	defer service.Stop()
+	defer CleanTestDirs()
+	assert.NotNil(t, service.watcher)
+
+	// the first poll processes the documents submitted before the service started
+	messages, err := service.GetMessages(logger, "i-bar")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(messages.Messages))
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		partialName := "validcommand20.json" + partialDocumentSuffix
+		SubmitTestDocAs("validcommand20.json", partialName)
+		os.Rename(filepath.Join(newCommands, partialName), filepath.Join(newCommands, "validcommand20.json"))
+	}()
+
+	start := time.Now()
+	messages, err = service.GetMessages(logger, "i-bar")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(messages.Messages))
+	assert.True(t, time.Since(start) < offlineLongPollTimeout, "document should be picked up before the long poll timeout")
+	assert.Equal(t, 1, FileCount(submittedCommands))
+}
+
+func TestStopReleasesGetMessages(t *testing.T) {
+	service := GetTestService().(*offlineService)
+	service.startWatcher(logger)
+	defer CleanTestDirs()
+	service.GetMessages(logger, "i-bar")
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		service.Stop()
+	}()
+
+	start := time.Now()
+	messages, err := service.GetMessages(logger, "i-bar")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(messages.Messages))
+	assert.True(t, time.Since(start) < offlineLongPollTimeout)
+}
+
 func TestOfflineService_SendReply(t *testing.T) {
 	service := GetTestService()
 	defer CleanTestDirs()
@@ -87,47 +208,99 @@ func TestOfflineService_SendReply(t *testing.T) {
 	assert.Equal(t, 1, FileCount(completeDir))
 }
 
+func TestOfflineService_SendReplyIndexesResult(t *testing.T) {
+	service := GetTestService().(*offlineService)
+	defer CleanTestDirs()
+	assert.Nil(t, SubmitTestDoc("validcommand20.json"))
+	messages, _ := service.GetMessages(logger, "i-bar")
+	assert.Equal(t, 1, len(messages.Messages))
+
+	messageID := *messages.Messages[0].MessageId
+	commandID, _ := messageContracts.GetCommandID(messageID)
+	invocation, err := service.results.Get(logger, commandID)
+	assert.Nil(t, err)
+	assert.Equal(t, "validcommand20.json", invocation.DocumentName)
+	assert.Equal(t, contracts.ResultStatusNotStarted, invocation.Status)
+
+	service.SendReply(logger, messageID, `{"documentStatus": "Success", "runtimeStatus": {"aws:runScript": {"status": "Success", "standardOutput": "done"}}}`)
+	invocation, err = service.results.Get(logger, commandID)
+	assert.Nil(t, err)
+	assert.Equal(t, contracts.ResultStatusSuccess, invocation.Status)
+	assert.NotEmpty(t, invocation.EndDateTime)
+	assert.Equal(t, "done", invocation.RuntimeStatus["aws:runScript"].StandardOutput)
+}
+
+func TestOfflineService_CleanupDeletesExpiredDocuments(t *testing.T) {
+	service := GetTestService().(*offlineService)
+	service.results = resultstore.New(completeDir, submittedCommands, time.Hour, 1)
+	defer CleanTestDirs()
+
+	var messageIDs []string
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, SubmitTestDoc("validcommand20.json"))
+		messages, _ := service.GetMessages(logger, "i-bar")
+		assert.Equal(t, 1, len(messages.Messages))
+		messageIDs = append(messageIDs, *messages.Messages[0].MessageId)
+		// start times are recorded with a millisecond precision
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 2, FileCount(submittedCommands))
+
+	service.SendReply(logger, messageIDs[0], `{"documentStatus": "Success"}`)
+	assert.Equal(t, 2, FileCount(submittedCommands))
+	service.SendReply(logger, messageIDs[1], `{"documentStatus": "Failed"}`)
+
+	// only the most recent completed command is kept
+	assert.Equal(t, 1, FileCount(submittedCommands))
+	firstCommandID, _ := messageContracts.GetCommandID(messageIDs[0])
+	_, err := service.results.Get(logger, firstCommandID)
+	assert.NotNil(t, err)
+	assert.False(t, fileutil.Exists(filepath.Join(completeDir, firstCommandID)))
+}
+
 func GetTestService() Service {
 	CleanTestDirs()
+	testRootDir, _ = ioutil.TempDir("", "offlineservice")
+	newCommands = filepath.Join(testRootDir, "new")
+	submittedCommands = filepath.Join(newCommands, "submitted")
+	invalidCommands = filepath.Join(newCommands, "invalid")
+	completeDir = filepath.Join(newCommands, "completed")
+	for _, dir := range []string{submittedCommands, invalidCommands, completeDir} {
+		fileutil.MakeDirs(dir)
+	}
 	return &offlineService{
 		TopicPrefix:         "foo",
 		newCommandDir:       newCommands,
 		submittedCommandDir: submittedCommands,
 		invalidCommandDir:   invalidCommands,
 		commandResultDir:    completeDir,
+		results:             resultstore.New(completeDir, submittedCommands, time.Hour, 10),
+		documentRetention:   time.Hour,
 	}
 }
 
 func SubmitTestDoc(name string) error {
+	return SubmitTestDocAs(name, name)
+}
+
+func SubmitTestDocAs(name string, submittedName string) error {
 	if doc, err := fileutil.ReadAllText(filepath.Join("testdata", name)); err != nil {
 		return err
 	} else {
-		return fileutil.WriteAllText(filepath.Join(newCommands, name), doc)
+		return fileutil.WriteAllText(filepath.Join(newCommands, submittedName), doc)
 	}
 }
 
+// CleanTestDirs deletes the directories of the test service
 func CleanTestDirs() {
-	var files []string
-	files, _ = fileutil.GetFileNames(submittedCommands)
-	for _, file := range files {
-		fileutil.DeleteFile(filepath.Join(submittedCommands, file))
-	}
-	files, _ = fileutil.GetFileNames(invalidCommands)
-	for _, file := range files {
-		fileutil.DeleteFile(filepath.Join(invalidCommands, file))
-	}
-	files, _ = fileutil.GetFileNames(newCommands)
-	for _, file := range files {
-		fileutil.DeleteFile(filepath.Join(newCommands, file))
-	}
-	files, _ = fileutil.GetFileNames(completeDir)
-	for _, file := range files {
-		fileutil.DeleteFile(filepath.Join(completeDir, file))
+	if testRootDir != "" {
+		os.RemoveAll(testRootDir)
+		testRootDir = ""
 	}
 }
 
+// FileCount returns the number of test commands in the directory
 func FileCount(path string) int {
-	var files []string
-	files, _ = fileutil.GetFileNames(path)
+	files, _ := fileutil.GetFileNames(path)
 	return len(files)
 }
@@ -0,0 +1,345 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package resultstore keeps the results of the commands submitted locally with ssm-cli along with an index to query them
+package resultstore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	messageContracts "github.com/aws/amazon-ssm-agent/agent/runcommand/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/times"
+)
+
+const (
+	// indexFileName is the name of the index within the result folder, it can't be mistaken for a command ID
+	indexFileName = "index.json"
+	// partialFileSuffix is appended to the index while it is being written
+	partialFileSuffix = ".tmp"
+)
+
+// CommandInvocation is the index entry of a command submitted locally
+type CommandInvocation struct {
+	CommandID     string                 `json:"commandId"`
+	DocumentName  string                 `json:"documentName"`
+	Comment       string                 `json:"comment,omitempty"`
+	Status        contracts.ResultStatus `json:"status"`
+	StartDateTime string                 `json:"startDateTime"`
+	EndDateTime   string                 `json:"endDateTime,omitempty"`
+}
+
+// CommandInvocationDetails is a command submitted locally along with the output of each of its plugins
+type CommandInvocationDetails struct {
+	CommandInvocation
+	DocumentTraceOutput string                                    `json:"documentTraceOutput,omitempty"`
+	RuntimeStatus       map[string]*contracts.PluginRuntimeStatus `json:"runtimeStatus,omitempty"`
+}
+
+// Filter selects the command invocations returned by List, zero values match all invocations
+type Filter struct {
+	Status []contracts.ResultStatus
+	// StartedAfter and StartedBefore bound the start time of the command
+	StartedAfter  time.Time
+	StartedBefore time.Time
+}
+
+// index is the content of the index file
+type index struct {
+	Invocations map[string]CommandInvocation `json:"invocations"`
+}
+
+// ResultStore stores the replies of the commands submitted locally and indexes them by command ID
+type ResultStore struct {
+	resultDir    string
+	submittedDir string
+	retention    time.Duration
+	maxCount     int
+	lock         sync.Mutex
+}
+
+// Created as a variable to allow for better testability
+var now = time.Now
+
+// NewResultStore creates the store of the local command results with the retention policy from the agent configuration
+func NewResultStore(config appconfig.SsmagentConfig) *ResultStore {
+	return New(
+		appconfig.LocalCommandRootCompleted,
+		appconfig.LocalCommandRootSubmitted,
+		time.Duration(config.Ssm.OfflineCommandResultsRetentionDurationHours)*time.Hour,
+		config.Ssm.OfflineCommandResultsMaxCount)
+}
+
+// New creates a store of the local command results in the given folder, submittedDir holds the submitted documents
+func New(resultDir string, submittedDir string, retention time.Duration, maxCount int) *ResultStore {
+	return &ResultStore{
+		resultDir:    resultDir,
+		submittedDir: submittedDir,
+		retention:    retention,
+		maxCount:     maxCount,
+	}
+}
+
+// IsTerminal returns true if the command won't be updated anymore
+func IsTerminal(status contracts.ResultStatus) bool {
+	switch status {
+	case "", contracts.ResultStatusNotStarted, contracts.ResultStatusInProgress,
+		contracts.ResultStatusSuccessAndReboot, contracts.ResultStatusPassedAndReboot:
+		return false
+	default:
+		return true
+	}
+}
+
+// SaveSubmitted records a command that has been submitted locally and not yet started
+func (s *ResultStore) SaveSubmitted(log log.T, commandID string, documentName string, comment string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idx := s.loadIndex(log)
+	idx.Invocations[commandID] = CommandInvocation{
+		CommandID:     commandID,
+		DocumentName:  documentName,
+		Comment:       comment,
+		Status:        contracts.ResultStatusNotStarted,
+		StartDateTime: times.ToIso8601UTC(now()),
+	}
+	return s.saveIndex(idx)
+}
+
+// SaveReply stores the reply payload of a command and updates its status in the index
+func (s *ResultStore) SaveReply(log log.T, commandID string, payload string) error {
+	var reply messageContracts.SendReplyPayload
+	if err := jsonutil.Unmarshal(payload, &reply); err != nil {
+		return fmt.Errorf("invalid reply for command %v: %v", commandID, err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := fileutil.WriteAllText(filepath.Join(s.resultDir, commandID), payload); err != nil {
+		return err
+	}
+	idx := s.loadIndex(log)
+	invocation, exists := idx.Invocations[commandID]
+	if !exists {
+		// the command was submitted before the index existed
+		invocation = CommandInvocation{CommandID: commandID, StartDateTime: times.ToIso8601UTC(now())}
+		invocation.DocumentName = s.findDocumentName(commandID)
+	}
+	invocation.Status = reply.DocumentStatus
+	if IsTerminal(reply.DocumentStatus) {
+		invocation.EndDateTime = reply.AdditionalInfo.DateTime
+		if invocation.EndDateTime == "" {
+			invocation.EndDateTime = times.ToIso8601UTC(now())
+		}
+	}
+	idx.Invocations[commandID] = invocation
+	return s.saveIndex(idx)
+}
+
+// Get returns the command invocation along with the output of each of its plugins
+func (s *ResultStore) Get(log log.T, commandID string) (*CommandInvocationDetails, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	invocation, exists := s.loadIndex(log).Invocations[commandID]
+	if !exists {
+		return nil, fmt.Errorf("no result found for command ID %v", commandID)
+	}
+	details := &CommandInvocationDetails{CommandInvocation: invocation}
+	resultPath := filepath.Join(s.resultDir, commandID)
+	if fileutil.Exists(resultPath) {
+		var reply messageContracts.SendReplyPayload
+		if err := jsonutil.UnmarshalFile(resultPath, &reply); err != nil {
+			return nil, fmt.Errorf("failed to read result of command %v: %v", commandID, err)
+		}
+		details.DocumentTraceOutput = reply.DocumentTraceOutput
+		details.RuntimeStatus = reply.RuntimeStatus
+	}
+	return details, nil
+}
+
+// List returns the command invocations matching the filter, most recent first
+func (s *ResultStore) List(log log.T, filter Filter) []CommandInvocation {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	invocations := make([]CommandInvocation, 0)
+	for _, invocation := range s.loadIndex(log).Invocations {
+		if filter.matches(invocation) {
+			invocations = append(invocations, invocation)
+		}
+	}
+	sortByStartTime(invocations)
+	return invocations
+}
+
+// Cleanup deletes the results of the completed commands that exceed the retention policy, most recent commands are kept.
+// The deleted invocations are returned so that the documents of these commands can be deleted as well.
+func (s *ResultStore) Cleanup(log log.T) []CommandInvocation {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idx := s.loadIndex(log)
+	completed := make([]CommandInvocation, 0, len(idx.Invocations))
+	for _, invocation := range idx.Invocations {
+		if IsTerminal(invocation.Status) {
+			completed = append(completed, invocation)
+		}
+	}
+	sortByStartTime(completed)
+
+	expired := make([]CommandInvocation, 0)
+	for i, invocation := range completed {
+		if i >= s.maxCount || now().Sub(times.ParseIso8601UTC(invocation.EndDateTime)) > s.retention {
+			expired = append(expired, invocation)
+		}
+	}
+	if len(expired) == 0 {
+		return expired
+	}
+
+	for _, invocation := range expired {
+		delete(idx.Invocations, invocation.CommandID)
+		resultPath := filepath.Join(s.resultDir, invocation.CommandID)
+		if err := fileutil.DeleteFile(resultPath); err != nil && !os.IsNotExist(err) {
+			log.Errorf("Failed to delete result of command %v: %v", invocation.CommandID, err)
+		}
+	}
+	log.Debugf("Deleted the results of %v local commands", len(expired))
+	if err := s.saveIndex(idx); err != nil {
+		log.Errorf("Failed to save local command result index: %v", err)
+	}
+	return expired
+}
+
+// matches returns true if the invocation satisfies all the criteria of the filter
+func (filter Filter) matches(invocation CommandInvocation) bool {
+	if len(filter.Status) > 0 {
+		found := false
+		for _, status := range filter.Status {
+			if strings.EqualFold(string(status), string(invocation.Status)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	start := times.ParseIso8601UTC(invocation.StartDateTime)
+	if !filter.StartedAfter.IsZero() && start.Before(filter.StartedAfter) {
+		return false
+	}
+	if !filter.StartedBefore.IsZero() && !start.Before(filter.StartedBefore) {
+		return false
+	}
+	return true
+}
+
+// sortByStartTime sorts the invocations from the most recent to the oldest
+func sortByStartTime(invocations []CommandInvocation) {
+	sort.Slice(invocations, func(i, j int) bool {
+		if invocations[i].StartDateTime == invocations[j].StartDateTime {
+			return invocations[i].CommandID < invocations[j].CommandID
+		}
+		// Iso8601 timestamps in UTC sort chronologically
+		return invocations[i].StartDateTime > invocations[j].StartDateTime
+	})
+}
+
+// loadIndex reads the index, it is rebuilt from the stored results when missing or corrupt
+func (s *ResultStore) loadIndex(log log.T) index {
+	idx := index{}
+	indexPath := filepath.Join(s.resultDir, indexFileName)
+	if fileutil.Exists(indexPath) {
+		if err := jsonutil.UnmarshalFile(indexPath, &idx); err != nil {
+			log.Errorf("Local command result index is corrupt, rebuilding it: %v", err)
+		}
+	}
+	if idx.Invocations == nil {
+		idx = s.rebuildIndex(log)
+	}
+	return idx
+}
+
+// rebuildIndex indexes the results written before the index existed, the modification time of a result is
+// the best estimate of the time the command ran
+func (s *ResultStore) rebuildIndex(log log.T) index {
+	idx := index{Invocations: make(map[string]CommandInvocation)}
+	files, err := fileutil.ReadDir(s.resultDir)
+	if err != nil {
+		return idx
+	}
+	for _, file := range files {
+		if file.IsDir() || file.Name() == indexFileName || strings.HasSuffix(file.Name(), partialFileSuffix) {
+			continue
+		}
+		commandID := file.Name()
+		var reply messageContracts.SendReplyPayload
+		if err := jsonutil.UnmarshalFile(filepath.Join(s.resultDir, commandID), &reply); err != nil {
+			log.Debugf("Skipping local command result %v: %v", commandID, err)
+			continue
+		}
+		modified := times.ToIso8601UTC(file.ModTime())
+		invocation := CommandInvocation{
+			CommandID:     commandID,
+			DocumentName:  s.findDocumentName(commandID),
+			Status:        reply.DocumentStatus,
+			StartDateTime: modified,
+		}
+		if IsTerminal(reply.DocumentStatus) {
+			invocation.EndDateTime = modified
+		}
+		idx.Invocations[commandID] = invocation
+	}
+	return idx
+}
+
+// findDocumentName looks for the submitted document of the command, which is named documentName.commandID
+func (s *ResultStore) findDocumentName(commandID string) string {
+	names, _ := fileutil.GetFileNames(s.submittedDir)
+	for _, name := range names {
+		if strings.HasSuffix(name, "."+commandID) {
+			return strings.TrimSuffix(name, "."+commandID)
+		}
+	}
+	return ""
+}
+
+// saveIndex writes the index to a temporary file and renames it, so that readers such as ssm-cli never see a partial index
+func (s *ResultStore) saveIndex(idx index) error {
+	content, err := jsonutil.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if err = fileutil.MakeDirs(s.resultDir); err != nil {
+		return err
+	}
+	indexPath := filepath.Join(s.resultDir, indexFileName)
+	if err = fileutil.WriteAllText(indexPath+partialFileSuffix, content); err != nil {
+		return err
+	}
+	return os.Rename(indexPath+partialFileSuffix, indexPath)
+}
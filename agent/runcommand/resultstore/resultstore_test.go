@@ -0,0 +1,172 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package resultstore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger = log.NewMockLog()
+
+// newTestStore creates a store in a temporary folder and returns a function deleting it
+func newTestStore(t *testing.T, retention time.Duration, maxCount int) (*ResultStore, func()) {
+	dir, err := ioutil.TempDir("", "resultstore")
+	assert.NoError(t, err)
+	submittedDir := filepath.Join(dir, "submitted")
+	assert.NoError(t, fileutil.MakeDirs(submittedDir))
+	return New(filepath.Join(dir, "completed"), submittedDir, retention, maxCount), func() { os.RemoveAll(dir) }
+}
+
+// setNow sets the current time of the store and returns a function restoring it
+func setNow(current time.Time) func() {
+	original := now
+	now = func() time.Time { return current }
+	return func() { now = original }
+}
+
+func TestSaveReply(t *testing.T) {
+	store, cleanup := newTestStore(t, time.Hour, 10)
+	defer cleanup()
+	defer setNow(time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC))()
+
+	assert.NoError(t, store.SaveSubmitted(logger, "command1", "document.json", ""))
+	assert.NoError(t, store.SaveReply(logger, "command1", `{"documentStatus": "InProgress"}`))
+
+	details, err := store.Get(logger, "command1")
+	assert.NoError(t, err)
+	assert.Equal(t, CommandInvocation{
+		CommandID:     "command1",
+		DocumentName:  "document.json",
+		Status:        contracts.ResultStatusInProgress,
+		StartDateTime: "2018-03-01T10:00:00.000Z",
+	}, details.CommandInvocation)
+
+	assert.NoError(t, store.SaveReply(logger, "command1", `{
+		"additionalInfo": {"dateTime": "2018-03-01T10:05:00.000Z"},
+		"documentStatus": "Failed",
+		"runtimeStatus": {"step1": {"status": "Failed", "code": 1, "standardError": "not found"}}
+	}`))
+
+	details, err = store.Get(logger, "command1")
+	assert.NoError(t, err)
+	assert.Equal(t, contracts.ResultStatusFailed, details.Status)
+	assert.Equal(t, "2018-03-01T10:05:00.000Z", details.EndDateTime)
+	assert.Equal(t, 1, details.RuntimeStatus["step1"].Code)
+	assert.Equal(t, "not found", details.RuntimeStatus["step1"].StandardError)
+
+	_, err = store.Get(logger, "command2")
+	assert.Error(t, err)
+	assert.Error(t, store.SaveReply(logger, "command2", "not json"))
+}
+
+func TestList(t *testing.T) {
+	store, cleanup := newTestStore(t, time.Hour, 10)
+	defer cleanup()
+
+	day := time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i, status := range []string{"Success", "Failed", "Success"} {
+		restore := setNow(day.Add(time.Duration(i) * 24 * time.Hour))
+		commandID := fmt.Sprintf("command%d", i+1)
+		assert.NoError(t, store.SaveSubmitted(logger, commandID, "document.json", ""))
+		assert.NoError(t, store.SaveReply(logger, commandID, `{"documentStatus": "`+status+`"}`))
+		restore()
+	}
+
+	commandIDs := func(invocations []CommandInvocation) []string {
+		ids := make([]string, 0)
+		for _, invocation := range invocations {
+			ids = append(ids, invocation.CommandID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"command3", "command2", "command1"}, commandIDs(store.List(logger, Filter{})))
+	assert.Equal(t, []string{"command3", "command1"}, commandIDs(store.List(logger, Filter{Status: []contracts.ResultStatus{"success"}})))
+	assert.Equal(t, []string{"command3", "command2"}, commandIDs(store.List(logger, Filter{StartedAfter: day.Add(time.Hour)})))
+	assert.Equal(t, []string{"command2"}, commandIDs(store.List(logger, Filter{
+		StartedAfter:  day.Add(time.Hour),
+		StartedBefore: day.Add(48 * time.Hour),
+	})))
+	assert.Empty(t, store.List(logger, Filter{Status: []contracts.ResultStatus{contracts.ResultStatusTimedOut}}))
+}
+
+func TestCleanup(t *testing.T) {
+	store, cleanup := newTestStore(t, 24*time.Hour, 2)
+	defer cleanup()
+
+	day := time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		restore := setNow(day.Add(time.Duration(i) * time.Hour))
+		commandID := fmt.Sprintf("command%d", i+1)
+		assert.NoError(t, store.SaveSubmitted(logger, commandID, "document.json", ""))
+		if i > 0 {
+			assert.NoError(t, store.SaveReply(logger, commandID, `{"documentStatus": "Success"}`))
+		}
+		restore()
+	}
+
+	// only the 2 most recent completed commands are kept, commands that haven't completed are never deleted
+	restore := setNow(day.Add(4 * time.Hour))
+	expired := store.Cleanup(logger)
+	restore()
+	assert.Equal(t, 1, len(expired))
+	assert.Equal(t, "command2", expired[0].CommandID)
+	assert.False(t, fileutil.Exists(filepath.Join(store.resultDir, "command2")))
+	assert.Equal(t, 3, len(store.List(logger, Filter{})))
+
+	// completed commands are deleted once older than the retention
+	restore = setNow(day.Add(26*time.Hour + 30*time.Minute))
+	expired = store.Cleanup(logger)
+	restore()
+	assert.Equal(t, 1, len(expired))
+	assert.Equal(t, "command3", expired[0].CommandID)
+	assert.Equal(t, []CommandInvocation{{
+		CommandID:     "command1",
+		DocumentName:  "document.json",
+		Status:        contracts.ResultStatusNotStarted,
+		StartDateTime: "2018-03-01T00:00:00.000Z",
+	}}, store.List(logger, Filter{Status: []contracts.ResultStatus{contracts.ResultStatusNotStarted}}))
+}
+
+func TestRebuildIndex(t *testing.T) {
+	store, cleanup := newTestStore(t, time.Hour, 10)
+	defer cleanup()
+
+	// results written before the index existed
+	assert.NoError(t, fileutil.MakeDirs(store.resultDir))
+	assert.NoError(t, fileutil.WriteAllText(filepath.Join(store.resultDir, "command1"), `{"documentStatus": "Success"}`))
+	assert.NoError(t, fileutil.WriteAllText(filepath.Join(store.resultDir, "command2"), "payload"))
+	assert.NoError(t, fileutil.WriteAllText(filepath.Join(store.submittedDir, "document.json.command1"), "{}"))
+
+	invocations := store.List(logger, Filter{})
+	assert.Equal(t, 1, len(invocations))
+	assert.Equal(t, "command1", invocations[0].CommandID)
+	assert.Equal(t, "document.json", invocations[0].DocumentName)
+	assert.Equal(t, contracts.ResultStatusSuccess, invocations[0].Status)
+	assert.NotEmpty(t, invocations[0].EndDateTime)
+
+	// a corrupt index is rebuilt as well
+	assert.NoError(t, fileutil.WriteAllText(filepath.Join(store.resultDir, indexFileName), "{"))
+	assert.Equal(t, 1, len(store.List(logger, Filter{})))
+}
@@ -98,7 +98,7 @@ func NewOfflineService(context context.T) (*RunCommandService, error) {
 	log := messageContext.Log()
 
 	log.Debug("Creating offline command document service")
-	offlineService, err := newOfflineService(log)
+	offlineService, err := newOfflineService(log, context.AppConfig())
 	if err != nil {
 		return nil, err
 	}
@@ -206,8 +206,8 @@ func processSendReply(log log.T, messageID string, mdsService mdsService.Service
 	}
 }
 
-var newOfflineService = func(log log.T) (mdsService.Service, error) {
-	return mdsService.NewOfflineService(log, string(SendCommandTopicPrefixOffline))
+var newOfflineService = func(log log.T, config appconfig.SsmagentConfig) (mdsService.Service, error) {
+	return mdsService.NewOfflineService(log, string(SendCommandTopicPrefixOffline), config)
 }
 
 var newMdsService = func(config appconfig.SsmagentConfig) mdsService.Service {
@@ -16,6 +16,7 @@ package runcommand
 
 import (
 	"crypto/sha256"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -379,13 +380,33 @@ func getPluginConfigurationsFromMainStep(mainSteps []*contracts.InstancePluginCo
 			BookKeepingFileName:    commandID,
 			PluginName:             pluginName,
 			PluginID:               pluginId,
-			Preconditions:          instancePluginConfig.Preconditions,
+			Preconditions:          getPreconditionsFromMainStep(instancePluginConfig.Preconditions),
 			IsPreconditionEnabled:  isPreconditionEnabled,
 		}
 	}
 	return
 }
 
+// getPreconditionsFromMainStep converts the comparisons of a step precondition, the test documents don't use parameters in preconditions
+func getPreconditionsFromMainStep(preconditions map[string]interface{}) (res []contracts.Precondition) {
+	var operators []string
+	for operator := range preconditions {
+		operators = append(operators, operator)
+	}
+	sort.Strings(operators)
+	for _, operator := range operators {
+		precondition := contracts.Precondition{Operator: operator}
+		for _, argument := range preconditions[operator].([]interface{}) {
+			precondition.Arguments = append(precondition.Arguments, contracts.PreconditionArgument{
+				InitialArgumentValue:  argument.(string),
+				ResolvedArgumentValue: argument.(string),
+			})
+		}
+		res = append(res, precondition)
+	}
+	return
+}
+
 func createMDSMessage(commandID string, payload string, topic string, instanceID string) ssmmds.Message {
 	messageCreatedDate := time.Date(2015, 7, 9, 23, 22, 39, 19000000, time.UTC)
 
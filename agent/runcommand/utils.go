@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
@@ -35,6 +36,14 @@ import (
 	"github.com/gabs"
 )
 
+const (
+	// cloudWatchLogGroupNamePrefix prefixes the name of the default log group of the command output
+	cloudWatchLogGroupNamePrefix = "/aws/ssm/"
+)
+
+// invalidLogGroupNameChars matches the characters of the document names not allowed in log group names
+var invalidLogGroupNameChars = regexp.MustCompile(`[^a-zA-Z0-9_/.#-]`)
+
 // empty returns true if string is empty
 func empty(s *string) bool {
 	return s == nil || *s == ""
@@ -60,6 +69,15 @@ func validate(msg *ssmmds.Message) error {
 	return nil
 }
 
+// cloudWatchLogGroupName returns the log group the output of the command is sent to, the log group of the command
+// defaults to a log group named after its document
+func cloudWatchLogGroupName(parsedMsg messageContracts.SendCommandPayload) string {
+	if parsedMsg.CloudWatchLogGroupName != "" {
+		return parsedMsg.CloudWatchLogGroupName
+	}
+	return cloudWatchLogGroupNamePrefix + invalidLogGroupNameChars.ReplaceAllString(parsedMsg.DocumentName, "_")
+}
+
 // newDocumentInfo initializes new DocumentInfo object
 func newDocumentInfo(msg ssmmds.Message, parsedMsg messageContracts.SendCommandPayload) contracts.DocumentInfo {
 
@@ -154,6 +172,10 @@ func parseSendCommandMessage(context context.T, msg *ssmmds.Message, messagesOrc
 		MessageId:        documentInfo.MessageID,
 		DocumentId:       documentInfo.DocumentID,
 	}
+	if strings.EqualFold(parsedMessage.CloudWatchOutputEnabled, "true") {
+		parserInfo.CloudWatchLogGroup = cloudWatchLogGroupName(parsedMessage)
+		parserInfo.CloudWatchLogStreamPrefix = path.Join(parsedMessage.CommandID, *msg.Destination)
+	}
 
 	//Data format persisted in Current Folder is defined by the struct - CommandState
 	docState, err := docparser.InitializeDocState(log, documentType, &parsedMessage.DocumentContent, documentInfo, parserInfo, parsedMessage.Parameters)
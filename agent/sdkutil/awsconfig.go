@@ -15,12 +15,14 @@
 package sdkutil
 
 import (
+	"net/http"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
 	"github.com/aws/amazon-ssm-agent/agent/managedInstances/rolecreds"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil/retryer"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -34,6 +36,7 @@ func AwsConfig() (awsConfig *aws.Config) {
 	awsConfig = &aws.Config{
 		Retryer:    newRetryer(),
 		SleepDelay: sleepDelay,
+		HTTPClient: &http.Client{Transport: proxyconfig.SharedTransport()},
 	}
 
 	// update region from platform
@@ -15,6 +15,7 @@ package ssm
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 	"runtime"
@@ -23,8 +24,10 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
@@ -106,6 +109,7 @@ func NewService() Service {
 		// this is to skip ssl verification for the beta self signed certs
 		if appConfig.Ssm.InsecureSkipVerify {
 			tr := &http.Transport{
+				Proxy:           proxyconfig.Proxy,
 				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 			}
 			awsConfig.HTTPClient = &http.Client{Transport: tr}
@@ -460,7 +464,7 @@ func (svc *sdkService) GetParameters(log log.T, paramNames []string) (response *
 	log.Debugf("Calling GetParameters API with params - %v", serviceParams)
 
 	if response, err = svc.sdk.GetParameters(&serviceParams); err != nil {
-		errorString := fmt.Errorf("Encountered error while calling GetParameters API. Error: %v", err)
+		errorString := getParametersError(err)
 		log.Debug(err)
 		sdkutil.HandleAwsError(log, err, ssmStopPolicy)
 		return nil, errorString
@@ -475,10 +479,23 @@ func (svc *sdkService) GetDecryptedParameters(log log.T, paramNames []string) (r
 	}
 
 	if response, err = svc.sdk.GetParameters(&serviceParams); err != nil {
-		errorString := fmt.Errorf("Encountered error while calling GetParameters API. Error: %v", err)
+		errorString := getParametersError(err)
 		log.Debug(err)
 		sdkutil.HandleAwsError(log, err, ssmStopPolicy)
 		return nil, errorString
 	}
 	return
 }
+
+// getParametersError returns the error of a GetParameters call, keeping the code and the status of the AWS error so
+// that the callers can tell a network or throttling error from an access denied
+func getParametersError(err error) error {
+	message := fmt.Sprintf("Encountered error while calling GetParameters API. Error: %v", err)
+	if failure, ok := err.(awserr.RequestFailure); ok {
+		return awserr.NewRequestFailure(awserr.New(failure.Code(), message, nil), failure.StatusCode(), failure.RequestID())
+	}
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awserr.New(awsErr.Code(), message, nil)
+	}
+	return errors.New(message)
+}
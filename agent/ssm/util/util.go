@@ -21,6 +21,7 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil/retryer"
 	"github.com/aws/aws-sdk-go/aws"
 )
@@ -53,6 +54,7 @@ func AwsConfig() *aws.Config {
 	// this is to skip ssl verification for the beta self signed certs
 	if appConfig.Ssm.InsecureSkipVerify {
 		tr := &http.Transport{
+			Proxy:           proxyconfig.Proxy,
 			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 		}
 		awsConfig.HTTPClient = &http.Client{Transport: tr}
@@ -15,10 +15,10 @@
 package ssmparameterresolver
 
 import (
-	"errors"
 	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/parameterprovider"
 	"github.com/aws/amazon-ssm-agent/agent/ssm"
 )
 
@@ -27,6 +27,18 @@ type ISsmParameterService interface {
 	getParameters(log log.T, parameterReferences []string) (map[string]SsmParameterInfo, error)
 }
 
+// resolveParameters resolves the parameters from the local parameter file, the cache or Parameter Store
+var resolveParameters = parameterprovider.Resolve
+
+// invalidParametersError is returned when parameters don't exist in Parameter Store
+type invalidParametersError struct {
+	names []string
+}
+
+func (e invalidParametersError) Error() string {
+	return "The following parameter(s) cannot be resolved: " + strings.Join(e.names, ",")
+}
+
 // SsmParameterService structure represents an SSM parameter service and implements ISsmParameterService interface.
 type SsmParameterService struct {
 	ISsmParameterService
@@ -62,7 +74,7 @@ func (s *SsmParameterService) getParameters(
 		for _, p := range parametersOutput.InvalidParameters {
 			invalidParameters = append(invalidParameters, *p)
 		}
-		return nil, errors.New("The following parameter(s) cannot be resolved: " + strings.Join(invalidParameters, ","))
+		return nil, invalidParametersError{names: invalidParameters}
 	}
 
 	resolvedParametersMap := map[string]SsmParameterInfo{}
@@ -85,6 +97,68 @@ func getParametersFromSsmParameterStore(
 	log log.T,
 	parametersToFetch []string) (map[string]SsmParameterInfo, error) {
 
+	// the parameters are resolved by name, the references of the secure and non-secure parameters with the same name
+	// are resolved to the same parameter, its type being validated afterwards
+	references := make(map[string]string)
+	names := []string{}
+	for _, reference := range parametersToFetch {
+		name := extractParameterNameFromReference(reference)
+		if _, found := references[name]; !found {
+			references[name] = reference
+			names = append(names, name)
+		}
+	}
+
+	parameters, invalidNames, err := resolveParameters(log, names, newFetchFunc(s, references))
+	if err != nil {
+		return nil, err
+	}
+	if len(invalidNames) > 0 {
+		return nil, invalidParametersError{names: invalidNames}
+	}
+
+	outputMap := make(map[string]SsmParameterInfo)
+	for _, reference := range parametersToFetch {
+		if parameter, found := parameters[extractParameterNameFromReference(reference)]; found {
+			outputMap[reference] = SsmParameterInfo{
+				Name:  parameter.Name,
+				Type:  parameter.Type,
+				Value: parameter.Value,
+			}
+		}
+	}
+
+	return outputMap, nil
+}
+
+// newFetchFunc returns the function retrieving the parameters with the given names from Parameter Store, using the
+// references of the parameters
+func newFetchFunc(s ISsmParameterService, references map[string]string) parameterprovider.FetchFunc {
+	return func(log log.T, names []string) ([]parameterprovider.Parameter, []string, error) {
+		referencesToFetch := []string{}
+		for _, name := range names {
+			referencesToFetch = append(referencesToFetch, references[name])
+		}
+		results, err := fetchParametersFromSsmParameterStore(s, log, referencesToFetch)
+		if invalidErr, ok := err.(invalidParametersError); ok {
+			return nil, invalidErr.names, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+		parameters := []parameterprovider.Parameter{}
+		for _, result := range results {
+			parameters = append(parameters, parameterprovider.Parameter{Name: result.Name, Type: result.Type, Value: result.Value})
+		}
+		return parameters, nil, nil
+	}
+}
+
+// fetchParametersFromSsmParameterStore retrieves the parameters from Parameter Store in batches
+func fetchParametersFromSsmParameterStore(
+	s ISsmParameterService,
+	log log.T,
+	parametersToFetch []string) (map[string]SsmParameterInfo, error) {
+
 	outputMap := make(map[string]SsmParameterInfo)
 
 	var totalParams = len(parametersToFetch)
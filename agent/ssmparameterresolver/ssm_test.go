@@ -21,9 +21,31 @@ import (
 	"testing"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/parameterprovider"
 	"github.com/stretchr/testify/assert"
 )
 
+func init() {
+	resolveParameters = resolveFromService
+}
+
+// resolveFromService resolves the parameters from the mocked service, without the local parameter file and the cache
+func resolveFromService(
+	log log.T,
+	names []string,
+	fetch parameterprovider.FetchFunc) (map[string]parameterprovider.Parameter, []string, error) {
+
+	fetched, invalidNames, err := fetch(log, names)
+	if err != nil {
+		return nil, nil, err
+	}
+	parameters := make(map[string]parameterprovider.Parameter)
+	for _, parameter := range fetched {
+		parameters[parameter.Name] = parameter
+	}
+	return parameters, invalidNames, nil
+}
+
 type ServiceMockedObjectWithRecords struct {
 	ISsmParameterService
 	records map[string]SsmParameterInfo
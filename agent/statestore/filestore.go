@@ -0,0 +1,590 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statestore
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+)
+
+const (
+	// snapshotFileName holds the content of the store as of a sequence number
+	snapshotFileName = "snapshot"
+	// journalFileName holds the transactions committed after the snapshot
+	journalFileName = "journal"
+	// lockFileName is locked by the processes for the duration of their transactions, shared by the read-only ones. It
+	// holds the generation of the snapshot, incremented by each compaction.
+	lockFileName = "lock"
+
+	// CorruptSuffix is appended to the files that can't be read, they are kept for investigation
+	CorruptSuffix = ".corrupt"
+	// partialFileSuffix is appended to the snapshot while it is being written
+	partialFileSuffix = ".tmp"
+
+	// frameHeaderSize is the size of the length and checksum preceding each record
+	frameHeaderSize = 8
+)
+
+// compactThreshold is the size of the journal above which the store writes a new snapshot and empties the journal
+var compactThreshold int64 = 1024 * 1024
+
+// lockTimeout is the time a transaction waits for the transactions of the other processes, so that a hung process
+// doesn't block the others forever
+var lockTimeout = 30 * time.Second
+
+// compactionStep is called after each step of the compaction, an error stopping the compaction the way a crash would
+var compactionStep = func(step string) error { return nil }
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errCorruptFrame is returned when a record has an invalid length or checksum
+var errCorruptFrame = errors.New("corrupt record")
+
+// record is a committed transaction of the journal
+type record struct {
+	Sequence uint64      `json:"seq"`
+	Ops      []operation `json:"ops"`
+}
+
+// operation is a write of a transaction
+type operation struct {
+	Bucket string `json:"b"`
+	Key    string `json:"k"`
+	Value  []byte `json:"v,omitempty"`
+	Delete bool   `json:"d,omitempty"`
+}
+
+// snapshot is the content of the store after the transaction of the sequence number
+type snapshot struct {
+	Sequence uint64                       `json:"seq"`
+	Buckets  map[string]map[string][]byte `json:"buckets"`
+}
+
+// CheckReport is the result of the consistency check of the store when it is opened
+type CheckReport struct {
+	// Records is the number of transactions replayed from the journal
+	Records int
+	// TruncatedBytes is the size of the incomplete or corrupt records discarded at the end of the journal, the
+	// transactions a crash interrupted while they were being committed
+	TruncatedBytes int64
+	// SnapshotCorrupt is true when the snapshot couldn't be read, it is moved aside with the CorruptSuffix and the
+	// store only holds the transactions of the journal
+	SnapshotCorrupt bool
+}
+
+// Healthy returns true if the store was consistent
+func (report CheckReport) Healthy() bool {
+	return report.TruncatedBytes == 0 && !report.SnapshotCorrupt
+}
+
+func (report CheckReport) String() string {
+	return fmt.Sprintf("%v transactions replayed, %v bytes of incomplete transactions discarded, snapshot corrupt: %v",
+		report.Records, report.TruncatedBytes, report.SnapshotCorrupt)
+}
+
+// FileStore is a Store persisted in a folder. The transactions are appended to a journal, each record being checksummed
+// and synced before the transaction is applied, and the journal is compacted into a snapshot that replaces the previous
+// one atomically. The store can be opened by several processes, i.e. the agent and its document workers: each
+// transaction locks the store and first loads the transactions the other processes committed.
+type FileStore struct {
+	dir         string
+	readOnly    bool
+	lock        sync.Mutex
+	lockFile    *os.File
+	journal     *os.File
+	journalSize int64
+	generation  uint64
+	sequence    uint64
+	buckets     map[string]map[string][]byte
+}
+
+// Open opens the store of the folder, creating it if needed. The consistency of the store is checked: the incomplete
+// transactions at the end of the journal are discarded and a corrupt snapshot is moved aside.
+func Open(dir string) (store *FileStore, report CheckReport, err error) {
+	if err = fileutil.MakeDirs(dir); err != nil {
+		return
+	}
+	store = &FileStore{dir: dir, buckets: make(map[string]map[string][]byte)}
+	if store.lockFile, err = openLockFile(filepath.Join(dir, lockFileName)); err != nil {
+		return nil, report, fmt.Errorf("failed to open the lock of the state store %v: %v", dir, err)
+	}
+	lock, err := store.lockStore(true)
+	if err != nil {
+		store.Close()
+		return nil, report, fmt.Errorf("failed to lock the state store %v: %v", dir, err)
+	}
+	defer releaseLock(lock)
+
+	if store.journal, err = os.OpenFile(filepath.Join(dir, journalFileName), os.O_RDWR|os.O_CREATE, appconfig.ReadWriteAccess); err == nil {
+		store.generation, err = readGeneration(store.lockFile)
+	}
+	if err == nil {
+		if err = store.loadSnapshot(&report); err == nil {
+			err = store.replayJournal(&report, true)
+		}
+	}
+	if err == nil && report.Records > 0 {
+		err = store.compact()
+	}
+	if err != nil {
+		store.Close()
+		return nil, report, fmt.Errorf("failed to open the state store %v: %v", dir, err)
+	}
+	return store, report, nil
+}
+
+// OpenReadOnly opens the existing store of the folder for the read-only transactions of a program reading the state of
+// a running agent, e.g. ssm-cli. The store is never written: it is neither created, recovered nor compacted, it is only
+// locked shared with the other readers, and its Update transactions fail with ErrReadOnly.
+func OpenReadOnly(dir string) (store *FileStore, err error) {
+	store = &FileStore{dir: dir, readOnly: true, buckets: make(map[string]map[string][]byte)}
+	if store.lockFile, err = os.Open(filepath.Join(dir, lockFileName)); err != nil {
+		return nil, fmt.Errorf("failed to open the lock of the state store %v: %v", dir, err)
+	}
+	if store.journal, err = os.Open(filepath.Join(dir, journalFileName)); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to open the state store %v: %v", dir, err)
+	}
+	lock, err := store.lockStore(false)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to lock the state store %v: %v", dir, err)
+	}
+	defer releaseLock(lock)
+
+	if store.generation, err = readGeneration(store.lockFile); err == nil {
+		if err = store.loadSnapshot(&CheckReport{}); err == nil {
+			err = store.replayJournal(&CheckReport{}, false)
+		}
+	}
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to read the state store %v: %v", dir, err)
+	}
+	return store, nil
+}
+
+// View runs a read-only transaction
+func (s *FileStore) View(fn func(tx Tx) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	lock, err := s.begin(false)
+	if err != nil {
+		return err
+	}
+	defer releaseLock(lock)
+	return fn(&transaction{store: s})
+}
+
+// Update runs a read-write transaction and commits it when fn returns no error
+func (s *FileStore) Update(fn func(tx Tx) error) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	lock, err := s.begin(true)
+	if err != nil {
+		return err
+	}
+	defer releaseLock(lock)
+	tx := &transaction{store: s, writable: true, writes: make(map[string]map[string]operation)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.ops) == 0 {
+		return nil
+	}
+	return s.commit(tx.ops)
+}
+
+// begin locks the store for a transaction and loads the transactions committed by the other processes since the
+// previous transaction of this process. The store is unlocked when it fails.
+func (s *FileStore) begin(writable bool) (lock *os.File, err error) {
+	if s.journal == nil {
+		return nil, errors.New("the state store is closed")
+	}
+	if lock, err = s.lockStore(writable); err != nil {
+		return nil, fmt.Errorf("failed to lock the state store %v: %v", s.dir, err)
+	}
+	if err = s.refresh(writable); err != nil {
+		releaseLock(lock)
+		return nil, fmt.Errorf("failed to read the state store %v: %v", s.dir, err)
+	}
+	return lock, nil
+}
+
+// refresh loads the transactions the other processes committed, the content of the store is reloaded when another
+// process compacted the journal into a new snapshot
+func (s *FileStore) refresh(writable bool) error {
+	generation, err := readGeneration(s.lockFile)
+	if err != nil {
+		return err
+	}
+	journalInfo, err := s.journal.Stat()
+	if err != nil {
+		return err
+	}
+	if generation != s.generation || journalInfo.Size() < s.journalSize {
+		s.sequence, s.journalSize, s.generation = 0, 0, generation
+		s.buckets = make(map[string]map[string][]byte)
+		if err = s.loadSnapshot(&CheckReport{}); err != nil {
+			return err
+		}
+	}
+	// the incomplete records of a process that crashed while committing are only discarded by the writers
+	return s.replayJournal(&CheckReport{}, writable)
+}
+
+// lockStore locks the store, exclusively or shared with the other readers, waiting up to lockTimeout for the other
+// processes to release it. Each transaction locks its own handle of the lock file: when the wait times out, the
+// handle is closed by the pending lock request once it is granted, releasing the lock right away.
+func (s *FileStore) lockStore(exclusive bool) (lock *os.File, err error) {
+	if s.readOnly {
+		lock, err = os.Open(filepath.Join(s.dir, lockFileName))
+	} else {
+		lock, err = openLockFile(filepath.Join(s.dir, lockFileName))
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the goroutines get the handle as an argument, lock is reset to nil when the wait times out
+	locked := make(chan error, 1)
+	go func(pending *os.File) { locked <- lockFile(pending, exclusive) }(lock)
+
+	timer := time.NewTimer(lockTimeout)
+	defer timer.Stop()
+	select {
+	case err = <-locked:
+		if err != nil {
+			lock.Close()
+			return nil, err
+		}
+		return lock, nil
+	case <-timer.C:
+		go func(abandoned *os.File) {
+			if <-locked == nil {
+				releaseLock(abandoned)
+			} else {
+				abandoned.Close()
+			}
+		}(lock)
+		return nil, fmt.Errorf("timed out after %v waiting for another process", lockTimeout)
+	}
+}
+
+// releaseLock unlocks the store and closes the handle locked by lockStore
+func releaseLock(lock *os.File) {
+	unlockFile(lock)
+	lock.Close()
+}
+
+// readGeneration returns the generation of the snapshot stored in the lock file, zero before the first compaction
+func readGeneration(lockFile *os.File) (uint64, error) {
+	content := make([]byte, 8)
+	if n, err := lockFile.ReadAt(content, 0); n < len(content) {
+		if err == io.EOF {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(content), nil
+}
+
+// Close closes the files of the store
+func (s *FileStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var err error
+	if s.journal != nil {
+		err = s.journal.Close()
+		s.journal = nil
+	}
+	if s.lockFile != nil {
+		s.lockFile.Close()
+		s.lockFile = nil
+	}
+	return err
+}
+
+// commit appends the transaction to the journal and applies it once the journal is synced
+func (s *FileStore) commit(ops []operation) error {
+	frame, err := encodeFrame(record{Sequence: s.sequence + 1, Ops: ops})
+	if err != nil {
+		return err
+	}
+	if _, err = s.journal.WriteAt(frame, s.journalSize); err == nil {
+		err = s.journal.Sync()
+	}
+	if err != nil {
+		// the partial record would be discarded when the store is opened, it is removed so that it doesn't hide the
+		// following transactions
+		s.journal.Truncate(s.journalSize)
+		return fmt.Errorf("failed to commit the transaction: %v", err)
+	}
+	s.journalSize += int64(len(frame))
+	s.sequence++
+	s.apply(ops)
+
+	if s.journalSize > compactThreshold {
+		// the journal remains valid when the compaction fails, it is attempted again by the next transaction
+		s.compact()
+	}
+	return nil
+}
+
+// apply applies the writes of a transaction to the content of the store
+func (s *FileStore) apply(ops []operation) {
+	for _, op := range ops {
+		if op.Delete {
+			delete(s.buckets[op.Bucket], op.Key)
+			if len(s.buckets[op.Bucket]) == 0 {
+				delete(s.buckets, op.Bucket)
+			}
+			continue
+		}
+		if s.buckets[op.Bucket] == nil {
+			s.buckets[op.Bucket] = make(map[string][]byte)
+		}
+		s.buckets[op.Bucket][op.Key] = op.Value
+	}
+}
+
+// compact writes the content of the store to a new snapshot and empties the journal
+func (s *FileStore) compact() error {
+	frame, err := encodeFrame(snapshot{Sequence: s.sequence, Buckets: s.buckets})
+	if err != nil {
+		return err
+	}
+	snapshotPath := filepath.Join(s.dir, snapshotFileName)
+	if err = writeFileSync(snapshotPath+partialFileSuffix, frame); err != nil {
+		return err
+	}
+	if err = compactionStep("snapshot written"); err != nil {
+		return err
+	}
+	if err = os.Rename(snapshotPath+partialFileSuffix, snapshotPath); err != nil {
+		return err
+	}
+	if err = syncDir(s.dir); err != nil {
+		return err
+	}
+	if err = compactionStep("snapshot renamed"); err != nil {
+		return err
+	}
+	// the other processes reload the store when they see the new generation, before the journal is emptied
+	generation := make([]byte, 8)
+	binary.BigEndian.PutUint64(generation, s.generation+1)
+	if _, err = s.lockFile.WriteAt(generation, 0); err != nil {
+		return err
+	}
+	s.generation++
+	if err = compactionStep("generation written"); err != nil {
+		return err
+	}
+	// the records of the journal are skipped when the store is opened before the journal is emptied
+	if err = s.journal.Truncate(0); err == nil {
+		err = s.journal.Sync()
+	}
+	if err != nil {
+		return err
+	}
+	s.journalSize = 0
+	return nil
+}
+
+// loadSnapshot loads the content of the snapshot, a corrupt snapshot is moved aside unless the store is read-only
+func (s *FileStore) loadSnapshot(report *CheckReport) error {
+	snapshotPath := filepath.Join(s.dir, snapshotFileName)
+	content, err := ioutil.ReadFile(snapshotPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var snap snapshot
+	if payload, size, err := decodeFrame(content); err != nil || size != len(content) {
+		report.SnapshotCorrupt = true
+	} else if err = json.Unmarshal(payload, &snap); err != nil {
+		report.SnapshotCorrupt = true
+	}
+	if report.SnapshotCorrupt && s.readOnly {
+		return fmt.Errorf("the snapshot %v is corrupt", snapshotPath)
+	} else if report.SnapshotCorrupt {
+		return os.Rename(snapshotPath, snapshotPath+CorruptSuffix)
+	}
+
+	s.sequence = snap.Sequence
+	if snap.Buckets != nil {
+		s.buckets = snap.Buckets
+	}
+	return nil
+}
+
+// replayJournal applies the transactions of the journal committed after the snapshot and after the records already
+// replayed, the journal is truncated after its last complete record if truncate is true
+func (s *FileStore) replayJournal(report *CheckReport, truncate bool) (err error) {
+	var info os.FileInfo
+	if info, err = s.journal.Stat(); err != nil || info.Size() <= s.journalSize {
+		return
+	}
+	content := make([]byte, info.Size()-s.journalSize)
+	if _, err = s.journal.ReadAt(content, s.journalSize); err != nil {
+		return
+	}
+
+	offset := 0
+	for offset < len(content) {
+		payload, size, err := decodeFrame(content[offset:])
+		if err != nil {
+			break
+		}
+		var rec record
+		if err = json.Unmarshal(payload, &rec); err != nil {
+			break
+		}
+		offset += size
+		if rec.Sequence <= s.sequence && !report.SnapshotCorrupt {
+			// the transaction is part of the snapshot
+			continue
+		}
+		s.sequence = rec.Sequence
+		s.apply(rec.Ops)
+		report.Records++
+	}
+
+	if offset < len(content) {
+		report.TruncatedBytes = int64(len(content) - offset)
+		if truncate {
+			if err = s.journal.Truncate(s.journalSize + int64(offset)); err == nil {
+				err = s.journal.Sync()
+			}
+		}
+	}
+	s.journalSize += int64(offset)
+	return
+}
+
+// encodeFrame encodes the JSON of v preceded by its length and checksum
+func encodeFrame(v interface{}) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	copy(frame[frameHeaderSize:], payload)
+	return frame, nil
+}
+
+// decodeFrame returns the payload of the frame at the start of the content and the size of the frame
+func decodeFrame(content []byte) (payload []byte, size int, err error) {
+	if len(content) < frameHeaderSize {
+		return nil, 0, errCorruptFrame
+	}
+	length := int(binary.BigEndian.Uint32(content[0:4]))
+	if length > len(content)-frameHeaderSize {
+		return nil, 0, errCorruptFrame
+	}
+	payload = content[frameHeaderSize : frameHeaderSize+length]
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(content[4:8]) {
+		return nil, 0, errCorruptFrame
+	}
+	return payload, frameHeaderSize + length, nil
+}
+
+// writeFileSync writes the file and syncs it to the disk
+func writeFileSync(path string, content []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, appconfig.ReadWriteAccess)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(content); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// transaction is a transaction of a FileStore, the writes of a read-write transaction are buffered until it commits
+type transaction struct {
+	store    *FileStore
+	writable bool
+	ops      []operation
+	writes   map[string]map[string]operation
+}
+
+func (tx *transaction) Get(bucket string, key string) ([]byte, bool) {
+	if op, written := tx.writes[bucket][key]; written {
+		return op.Value, !op.Delete
+	}
+	value, found := tx.store.buckets[bucket][key]
+	return value, found
+}
+
+func (tx *transaction) Keys(bucket string) []string {
+	keys := make([]string, 0, len(tx.store.buckets[bucket]))
+	for key := range tx.store.buckets[bucket] {
+		if op, written := tx.writes[bucket][key]; !written || !op.Delete {
+			keys = append(keys, key)
+		}
+	}
+	for key, op := range tx.writes[bucket] {
+		if _, stored := tx.store.buckets[bucket][key]; !stored && !op.Delete {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (tx *transaction) Put(bucket string, key string, value []byte) error {
+	return tx.write(operation{Bucket: bucket, Key: key, Value: append([]byte{}, value...)})
+}
+
+func (tx *transaction) Delete(bucket string, key string) error {
+	return tx.write(operation{Bucket: bucket, Key: key, Delete: true})
+}
+
+func (tx *transaction) write(op operation) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+	tx.ops = append(tx.ops, op)
+	if tx.writes[op.Bucket] == nil {
+		tx.writes[op.Bucket] = make(map[string]operation)
+	}
+	tx.writes[op.Bucket][op.Key] = op
+	return nil
+}
@@ -0,0 +1,499 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statestore
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "statestore")
+	assert.NoError(t, err)
+	return dir
+}
+
+func openTestStore(t *testing.T, dir string) (*FileStore, CheckReport) {
+	store, report, err := Open(dir)
+	assert.NoError(t, err)
+	return store, report
+}
+
+func get(t *testing.T, store Store, bucket string, key string) (value string, found bool) {
+	assert.NoError(t, store.View(func(tx Tx) error {
+		var content []byte
+		content, found = tx.Get(bucket, key)
+		value = string(content)
+		return nil
+	}))
+	return
+}
+
+func put(t *testing.T, store Store, bucket string, key string, value string) {
+	assert.NoError(t, store.Update(func(tx Tx) error {
+		return tx.Put(bucket, key, []byte(value))
+	}))
+}
+
+func TestUpdateIsDurable(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, report := openTestStore(t, dir)
+	assert.True(t, report.Healthy())
+
+	put(t, store, "documents", "command-1", `{"status":"InProgress"}`)
+	put(t, store, "documents", "command-2", `{"status":"Success"}`)
+	assert.NoError(t, store.Update(func(tx Tx) error {
+		return tx.Delete("documents", "command-2")
+	}))
+	assert.NoError(t, store.Close())
+
+	store, report = openTestStore(t, dir)
+	defer store.Close()
+	assert.True(t, report.Healthy())
+	assert.Equal(t, 3, report.Records)
+	value, found := get(t, store, "documents", "command-1")
+	assert.True(t, found)
+	assert.Equal(t, `{"status":"InProgress"}`, value)
+	_, found = get(t, store, "documents", "command-2")
+	assert.False(t, found)
+}
+
+func TestUpdateIsAtomic(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, _ := openTestStore(t, dir)
+	defer store.Close()
+	put(t, store, "documents", "pending", "command-1")
+
+	// the writes of a failed transaction are discarded
+	err := store.Update(func(tx Tx) error {
+		tx.Delete("documents", "pending")
+		tx.Put("documents", "current", []byte("command-1"))
+		return errors.New("failed to start the command")
+	})
+	assert.Error(t, err)
+	_, found := get(t, store, "documents", "current")
+	assert.False(t, found)
+
+	// the transaction sees its own writes
+	assert.NoError(t, store.Update(func(tx Tx) error {
+		tx.Delete("documents", "pending")
+		tx.Put("documents", "current", []byte("command-1"))
+		tx.Put("documents", "completed", []byte("command-0"))
+		assert.Equal(t, []string{"completed", "current"}, tx.Keys("documents"))
+		_, found := tx.Get("documents", "pending")
+		assert.False(t, found)
+		return nil
+	}))
+	assert.NoError(t, store.View(func(tx Tx) error {
+		assert.Equal(t, []string{"completed", "current"}, tx.Keys("documents"))
+		assert.Equal(t, ErrReadOnly, tx.Put("documents", "pending", nil))
+		return nil
+	}))
+}
+
+func TestOpenDiscardsIncompleteTransactions(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, _ := openTestStore(t, dir)
+	put(t, store, "plugins", "cloudwatch", "running")
+	firstRecord := store.journalSize
+	put(t, store, "plugins", "cloudwatch", "stopped")
+	store.Close()
+
+	// a crash while the last transaction was written leaves a partial record at the end of the journal
+	journalPath := filepath.Join(dir, journalFileName)
+	content, err := ioutil.ReadFile(journalPath)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(journalPath, content[:len(content)-3], 0600))
+
+	store, report := openTestStore(t, dir)
+	assert.False(t, report.Healthy())
+	assert.Equal(t, 1, report.Records)
+	assert.Equal(t, int64(len(content)-3)-firstRecord, report.TruncatedBytes)
+	value, _ := get(t, store, "plugins", "cloudwatch")
+	assert.Equal(t, "running", value)
+
+	// the store is consistent once recovered
+	put(t, store, "plugins", "cloudwatch", "started")
+	store.Close()
+	store, report = openTestStore(t, dir)
+	defer store.Close()
+	assert.True(t, report.Healthy())
+	value, _ = get(t, store, "plugins", "cloudwatch")
+	assert.Equal(t, "started", value)
+}
+
+func TestOpenDiscardsCorruptTransactions(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, _ := openTestStore(t, dir)
+	put(t, store, "plugins", "cloudwatch", "running")
+	firstRecord := store.journalSize
+	put(t, store, "plugins", "cloudwatch", "stopped")
+	store.Close()
+
+	journalPath := filepath.Join(dir, journalFileName)
+	content, err := ioutil.ReadFile(journalPath)
+	assert.NoError(t, err)
+	content[len(content)-2] ^= 0xff
+	assert.NoError(t, ioutil.WriteFile(journalPath, content, 0600))
+
+	store, report := openTestStore(t, dir)
+	defer store.Close()
+	assert.Equal(t, int64(len(content))-firstRecord, report.TruncatedBytes)
+	value, _ := get(t, store, "plugins", "cloudwatch")
+	assert.Equal(t, "running", value)
+}
+
+func TestCompaction(t *testing.T) {
+	compactThreshold = 512
+	defer func() { compactThreshold = 1024 * 1024 }()
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, _ := openTestStore(t, dir)
+
+	for i := 0; i < 50; i++ {
+		put(t, store, "documents", fmt.Sprintf("command-%v", i%10), fmt.Sprintf("status-%v", i))
+	}
+	assert.True(t, store.journalSize <= compactThreshold)
+	store.Close()
+
+	store, report := openTestStore(t, dir)
+	defer store.Close()
+	assert.True(t, report.Healthy())
+	assert.NoError(t, store.View(func(tx Tx) error {
+		assert.Len(t, tx.Keys("documents"), 10)
+		return nil
+	}))
+	value, _ := get(t, store, "documents", "command-9")
+	assert.Equal(t, "status-49", value)
+}
+
+func TestOpenMovesAsideCorruptSnapshot(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, _ := openTestStore(t, dir)
+	put(t, store, "plugins", "cloudwatch", "running")
+	store.Close()
+	// the journal was compacted into the snapshot when the store was opened
+	store, _ = openTestStore(t, dir)
+	store.Close()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, snapshotFileName), []byte("{}"), 0600))
+
+	store, report := openTestStore(t, dir)
+	defer store.Close()
+	assert.True(t, report.SnapshotCorrupt)
+	_, found := get(t, store, "plugins", "cloudwatch")
+	assert.False(t, found)
+	_, err := os.Stat(filepath.Join(dir, snapshotFileName+CorruptSuffix))
+	assert.NoError(t, err)
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, _ := openTestStore(t, dir)
+	put(t, store, "plugins", "cloudwatch", "running")
+	store.Close()
+	// the journal was compacted into the snapshot when the store was opened
+	store, _ = openTestStore(t, dir)
+	put(t, store, "plugins", "cloudwatch", "stopped")
+	defer store.Close()
+
+	// the transaction the agent is writing is incomplete
+	journalPath := filepath.Join(dir, journalFileName)
+	journal, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0600)
+	assert.NoError(t, err)
+	_, err = journal.Write([]byte{0, 0, 0, 42})
+	assert.NoError(t, err)
+	journal.Close()
+	content, err := ioutil.ReadFile(journalPath)
+	assert.NoError(t, err)
+
+	reader, err := OpenReadOnly(dir)
+	assert.NoError(t, err)
+	defer reader.Close()
+	value, _ := get(t, reader, "plugins", "cloudwatch")
+	assert.Equal(t, "stopped", value)
+	assert.Equal(t, ErrReadOnly, reader.Update(func(tx Tx) error { return nil }))
+
+	// the reader leaves the journal of the agent as it is
+	afterRead, err := ioutil.ReadFile(journalPath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, afterRead)
+
+	// a missing store isn't created
+	_, err = OpenReadOnly(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+	_, err = os.Stat(filepath.Join(dir, "missing"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStoreSharedByProcesses(t *testing.T) {
+	compactThreshold = 512
+	defer func() { compactThreshold = 1024 * 1024 }()
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	// the agent and a document worker open the same store
+	agentStore, _ := openTestStore(t, dir)
+	workerStore, _ := openTestStore(t, dir)
+
+	put(t, workerStore, "plugins", "cloudwatch", "running")
+	value, _ := get(t, agentStore, "plugins", "cloudwatch")
+	assert.Equal(t, "running", value)
+
+	// the other store reloads the snapshot written by a compaction
+	for i := 0; i < 50; i++ {
+		put(t, agentStore, "documents", fmt.Sprintf("command-%v", i%10), fmt.Sprintf("status-%v", i))
+	}
+	value, _ = get(t, workerStore, "documents", "command-9")
+	assert.Equal(t, "status-49", value)
+
+	// the transactions of both stores are serialized
+	const increments = 20
+	var wg sync.WaitGroup
+	for _, store := range []*FileStore{agentStore, workerStore} {
+		wg.Add(1)
+		go func(store *FileStore) {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				assert.NoError(t, store.Update(func(tx Tx) error {
+					content, _ := tx.Get("counters", "runs")
+					count, _ := strconv.Atoi(string(content))
+					return tx.Put("counters", "runs", []byte(strconv.Itoa(count+1)))
+				}))
+			}
+		}(store)
+	}
+	wg.Wait()
+	value, _ = get(t, agentStore, "counters", "runs")
+	assert.Equal(t, strconv.Itoa(2*increments), value)
+
+	workerStore.Close()
+	assert.Error(t, workerStore.View(func(tx Tx) error { return nil }))
+	value, _ = get(t, agentStore, "plugins", "cloudwatch")
+	assert.Equal(t, "running", value)
+	agentStore.Close()
+}
+
+func TestJSON(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, _ := openTestStore(t, dir)
+	defer store.Close()
+	type pluginInfo struct {
+		Name    string
+		Running bool
+	}
+
+	assert.NoError(t, store.Update(func(tx Tx) error {
+		return PutJSON(tx, "plugins", "cloudwatch", pluginInfo{Name: "aws:cloudWatch", Running: true})
+	}))
+	assert.NoError(t, store.View(func(tx Tx) error {
+		var info pluginInfo
+		found, err := GetJSON(tx, "plugins", "cloudwatch", &info)
+		assert.True(t, found)
+		assert.Equal(t, pluginInfo{Name: "aws:cloudWatch", Running: true}, info)
+		found, err = GetJSON(tx, "plugins", "missing", &info)
+		assert.False(t, found)
+		return err
+	}))
+}
+
+func TestCompactionCrash(t *testing.T) {
+	defer func() { compactionStep = func(step string) error { return nil } }()
+
+	for _, crashStep := range []string{"snapshot written", "snapshot renamed", "generation written"} {
+		dir := newTestDir(t)
+		compactingStore, _ := openTestStore(t, dir)
+		otherStore, _ := openTestStore(t, dir)
+		for i := 0; i < 5; i++ {
+			put(t, compactingStore, "documents", fmt.Sprintf("command-%v", i), "pending")
+		}
+		put(t, compactingStore, "documents", "command-0", "completed")
+
+		// the process compacting the store crashes after the step
+		compactionStep = func(step string) error {
+			if step == crashStep {
+				return errors.New("crash")
+			}
+			return nil
+		}
+		assert.Error(t, compactingStore.Update(func(tx Tx) error { return compactingStore.compact() }), crashStep)
+		compactingStore.Close()
+		compactionStep = func(step string) error { return nil }
+
+		// the other process keeps using the store
+		value, _ := get(t, otherStore, "documents", "command-0")
+		assert.Equal(t, "completed", value, crashStep)
+		put(t, otherStore, "documents", "command-5", "pending")
+
+		// and the store recovers when it's opened again
+		store, _ := openTestStore(t, dir)
+		assert.NoError(t, store.View(func(tx Tx) error {
+			assert.Len(t, tx.Keys("documents"), 6, crashStep)
+			return nil
+		}))
+		value, _ = get(t, store, "documents", "command-0")
+		assert.Equal(t, "completed", value, crashStep)
+		put(t, store, "documents", "command-1", "completed")
+		value, _ = get(t, otherStore, "documents", "command-1")
+		assert.Equal(t, "completed", value, crashStep)
+
+		store.Close()
+		otherStore.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	lockTimeout = 50 * time.Millisecond
+	defer func() { lockTimeout = 30 * time.Second }()
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, _ := openTestStore(t, dir)
+	defer store.Close()
+
+	// another process hangs in a transaction
+	hungProcess, err := openLockFile(filepath.Join(dir, lockFileName))
+	assert.NoError(t, err)
+	defer hungProcess.Close()
+	assert.NoError(t, lockFile(hungProcess, true))
+
+	err = store.Update(func(tx Tx) error { return tx.Put("plugins", "cloudwatch", []byte("running")) })
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+
+	assert.NoError(t, unlockFile(hungProcess))
+	put(t, store, "plugins", "cloudwatch", "running")
+}
+
+// helperDirEnv is the store folder of the process started by startHelperProcess
+const helperDirEnv = "STATESTORE_HELPER_DIR"
+
+// startHelperProcess starts a process running TestHelperProcess on the store of the folder
+func startHelperProcess(t *testing.T, dir string, increments int) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), helperDirEnv+"="+dir, fmt.Sprintf("STATESTORE_HELPER_INCREMENTS=%v", increments))
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	assert.NoError(t, cmd.Start())
+	return cmd
+}
+
+// increment increments the counters a and b in the same transaction
+func increment(store Store) error {
+	return store.Update(func(tx Tx) error {
+		for _, key := range []string{"a", "b"} {
+			content, _ := tx.Get("counters", key)
+			count, _ := strconv.Atoi(string(content))
+			if err := tx.Put("counters", key, []byte(strconv.Itoa(count+1))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TestHelperProcess is the process sharing the store with the multi-process tests, it increments the counters of the
+// store, forever when the number of increments is zero. It does nothing when it isn't started by the tests.
+func TestHelperProcess(t *testing.T) {
+	dir := os.Getenv(helperDirEnv)
+	if dir == "" {
+		return
+	}
+	compactThreshold = 512
+	increments, _ := strconv.Atoi(os.Getenv("STATESTORE_HELPER_INCREMENTS"))
+	store, _, err := Open(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for i := 0; increments == 0 || i < increments; i++ {
+		if err = increment(store); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	store.Close()
+	os.Exit(0)
+}
+
+func TestConcurrentProcesses(t *testing.T) {
+	compactThreshold = 512
+	defer func() { compactThreshold = 1024 * 1024 }()
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, _ := openTestStore(t, dir)
+	defer store.Close()
+
+	const increments = 50
+	var helpers []*exec.Cmd
+	for i := 0; i < 3; i++ {
+		helpers = append(helpers, startHelperProcess(t, dir, increments))
+	}
+	for i := 0; i < increments; i++ {
+		assert.NoError(t, increment(store))
+	}
+	for _, helper := range helpers {
+		assert.NoError(t, helper.Wait())
+	}
+
+	for _, key := range []string{"a", "b"} {
+		value, _ := get(t, store, "counters", key)
+		assert.Equal(t, strconv.Itoa(4*increments), value)
+	}
+}
+
+func TestProcessKilledWhileWriting(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, _ := openTestStore(t, dir)
+	defer store.Close()
+
+	// the process is killed while it commits transactions and compacts the store
+	helper := startHelperProcess(t, dir, 0)
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if value, _ := get(t, store, "counters", "a"); len(value) >= 3 {
+			break
+		}
+	}
+	assert.NoError(t, helper.Process.Kill())
+	helper.Wait()
+
+	// the store is consistent, for the processes which were using it and once it's opened again
+	a, _ := get(t, store, "counters", "a")
+	b, _ := get(t, store, "counters", "b")
+	assert.Equal(t, a, b)
+	assert.True(t, len(a) >= 3, "the process committed %v transactions", a)
+	assert.NoError(t, increment(store))
+
+	reopened, report := openTestStore(t, dir)
+	defer reopened.Close()
+	assert.False(t, report.SnapshotCorrupt)
+	a, _ = get(t, reopened, "counters", "a")
+	b, _ = get(t, reopened, "counters", "b")
+	assert.Equal(t, a, b)
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package statestore
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+const (
+	// lockfileExclusiveLock is the flag of LockFileEx requesting an exclusive lock
+	lockfileExclusiveLock = 0x2
+	// lockOffset is the byte of the file locked, after the generation of the snapshot as Windows prevents the other
+	// handles from accessing the locked bytes
+	lockOffset = 8
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// openLockFile opens the lock file of the store, which is shared by the processes using the store
+func openLockFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE, appconfig.ReadWriteAccess)
+}
+
+// lockFile locks the file, exclusively or shared with the other readers, waiting for the lock to be
+// released by the other processes. The lock is also released when the process exits.
+func lockFile(f *os.File, exclusive bool) error {
+	var flags uintptr
+	if exclusive {
+		flags = lockfileExclusiveLock
+	}
+	overlapped := syscall.Overlapped{Offset: lockOffset}
+	if r, _, err := procLockFileEx.Call(f.Fd(), flags, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped))); r == 0 {
+		return err
+	}
+	return nil
+}
+
+// unlockFile releases the lock of the file
+func unlockFile(f *os.File) error {
+	overlapped := syscall.Overlapped{Offset: lockOffset}
+	if r, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&overlapped))); r == 0 {
+		return err
+	}
+	return nil
+}
+
+// syncDir is a no-op, the renames are durable once the renamed file is synced on Windows
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// MigrateFile imports the JSON file written by the previous versions of the agent as the value of the key. The file is
+// deleted once the value is committed, a file that isn't valid JSON is renamed with the CorruptSuffix instead.
+// Migrating a missing file does nothing, so that the migration can run every time the subsystem starts. A file whose
+// key already exists was imported before it could be deleted, it is deleted without overwriting the newer value.
+func MigrateFile(log log.T, store Store, bucket string, key string, path string) (migrated bool, err error) {
+	content, valid, err := readLegacyFile(log, path)
+	if err != nil || !valid {
+		return false, err
+	}
+	if err = store.Update(func(tx Tx) error {
+		if _, exists := tx.Get(bucket, key); exists {
+			return nil
+		}
+		migrated = true
+		return tx.Put(bucket, key, content)
+	}); err != nil {
+		return false, err
+	}
+	if migrated {
+		log.Infof("Migrated %v to the local state store", path)
+	}
+	removeLegacyFile(log, path)
+	return migrated, nil
+}
+
+// MigrateDir imports the JSON files of the folder written by the previous versions of the agent, each file being the
+// value of the key of its name. The files are imported in a single transaction and deleted once it is committed, the
+// files that aren't valid JSON are renamed with the CorruptSuffix instead. The files whose key already exists were
+// imported before they could be deleted, they are deleted without overwriting the newer values.
+func MigrateDir(log log.T, store Store, bucket string, dir string) (migrated int, err error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	contents := make(map[string][]byte)
+	for _, file := range files {
+		if file.IsDir() || strings.HasSuffix(file.Name(), CorruptSuffix) {
+			continue
+		}
+		content, valid, err := readLegacyFile(log, filepath.Join(dir, file.Name()))
+		if err != nil {
+			return 0, err
+		}
+		if valid {
+			contents[file.Name()] = content
+		}
+	}
+	if len(contents) == 0 {
+		return 0, nil
+	}
+
+	if err = store.Update(func(tx Tx) error {
+		migrated = 0
+		for key, content := range contents {
+			if _, exists := tx.Get(bucket, key); exists {
+				continue
+			}
+			if err := tx.Put(bucket, key, content); err != nil {
+				return err
+			}
+			migrated++
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	if migrated > 0 {
+		log.Infof("Migrated %v files of %v to the local state store", migrated, dir)
+	}
+
+	for key := range contents {
+		removeLegacyFile(log, filepath.Join(dir, key))
+	}
+	return migrated, nil
+}
+
+// readLegacyFile reads a file to migrate, it returns false when the file is missing or corrupt
+func readLegacyFile(log log.T, path string) (content []byte, valid bool, err error) {
+	if content, err = ioutil.ReadFile(path); os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	if !json.Valid(content) {
+		log.Warnf("%v is corrupt, it is not migrated to the local state store and is kept as %v", path, path+CorruptSuffix)
+		if err = os.Rename(path, path+CorruptSuffix); err != nil {
+			return nil, false, fmt.Errorf("failed to move aside corrupt file %v: %v", path, err)
+		}
+		return nil, false, nil
+	}
+	return content, true, nil
+}
+
+// removeLegacyFile deletes a migrated file, a file that can't be deleted being skipped by the next migrations
+func removeLegacyFile(log log.T, path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Failed to delete migrated file %v: %v", path, err)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statestore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newMigrateLog() *log.Mock {
+	logMock := log.NewMockLog()
+	logMock.On("Warnf", mock.Anything, mock.Anything).Return(nil)
+	return logMock
+}
+
+func TestMigrateFile(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, _ := openTestStore(t, dir)
+	defer store.Close()
+	legacyPath := filepath.Join(dir, "i-1234567890")
+	assert.NoError(t, ioutil.WriteFile(legacyPath, []byte(`{"cloudwatch":{"Name":"aws:cloudWatch"}}`), 0600))
+
+	migrated, err := MigrateFile(newMigrateLog(), store, "plugins", "i-1234567890", legacyPath)
+	assert.NoError(t, err)
+	assert.True(t, migrated)
+	value, _ := get(t, store, "plugins", "i-1234567890")
+	assert.Equal(t, `{"cloudwatch":{"Name":"aws:cloudWatch"}}`, value)
+	_, err = os.Stat(legacyPath)
+	assert.True(t, os.IsNotExist(err))
+
+	// migrating again does nothing
+	migrated, err = MigrateFile(newMigrateLog(), store, "plugins", "i-1234567890", legacyPath)
+	assert.NoError(t, err)
+	assert.False(t, migrated)
+}
+
+func TestMigrateCorruptFile(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, _ := openTestStore(t, dir)
+	defer store.Close()
+	legacyPath := filepath.Join(dir, "i-1234567890")
+	assert.NoError(t, ioutil.WriteFile(legacyPath, []byte(`{"cloudwatch":`), 0600))
+
+	migrated, err := MigrateFile(newMigrateLog(), store, "plugins", "i-1234567890", legacyPath)
+	assert.NoError(t, err)
+	assert.False(t, migrated)
+	_, found := get(t, store, "plugins", "i-1234567890")
+	assert.False(t, found)
+	_, err = os.Stat(legacyPath + CorruptSuffix)
+	assert.NoError(t, err)
+}
+
+func TestMigrateDir(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, _ := openTestStore(t, filepath.Join(dir, "store"))
+	defer store.Close()
+	legacyDir := filepath.Join(dir, "pending")
+	assert.NoError(t, os.MkdirAll(filepath.Join(legacyDir, "subfolder"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(legacyDir, "command-1"), []byte(`{"status":"Pending"}`), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(legacyDir, "command-2"), []byte(`{"status":"Pending"}`), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(legacyDir, "command-3"), []byte(`{"status"`), 0600))
+
+	migrated, err := MigrateDir(newMigrateLog(), store, "pending", legacyDir)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, migrated)
+	assert.NoError(t, store.View(func(tx Tx) error {
+		assert.Equal(t, []string{"command-1", "command-2"}, tx.Keys("pending"))
+		return nil
+	}))
+	files, err := ioutil.ReadDir(legacyDir)
+	assert.NoError(t, err)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	assert.Equal(t, []string{"command-3" + CorruptSuffix, "subfolder"}, names)
+
+	// a missing folder has nothing to migrate
+	migrated, err = MigrateDir(newMigrateLog(), store, "pending", filepath.Join(dir, "missing"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, migrated)
+}
+
+func TestMigrateKeepsNewerValues(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	store, _ := openTestStore(t, filepath.Join(dir, "store"))
+	defer store.Close()
+	// the files were imported by a previous migration which couldn't delete them, the values were updated since
+	put(t, store, "pending", "command-1", `{"status":"InProgress"}`)
+	put(t, store, "plugins", "i-1234567890", `{"cloudwatch":{"Name":"aws:cloudWatch","Running":true}}`)
+	legacyDir := filepath.Join(dir, "pending")
+	assert.NoError(t, os.MkdirAll(legacyDir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(legacyDir, "command-1"), []byte(`{"status":"Pending"}`), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(legacyDir, "command-2"), []byte(`{"status":"Pending"}`), 0600))
+	legacyPath := filepath.Join(dir, "i-1234567890")
+	assert.NoError(t, ioutil.WriteFile(legacyPath, []byte(`{"cloudwatch":{"Name":"aws:cloudWatch"}}`), 0600))
+
+	migrated, err := MigrateDir(newMigrateLog(), store, "pending", legacyDir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, migrated)
+	value, _ := get(t, store, "pending", "command-1")
+	assert.Equal(t, `{"status":"InProgress"}`, value)
+	value, _ = get(t, store, "pending", "command-2")
+	assert.Equal(t, `{"status":"Pending"}`, value)
+	files, err := ioutil.ReadDir(legacyDir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	fileMigrated, err := MigrateFile(newMigrateLog(), store, "plugins", "i-1234567890", legacyPath)
+	assert.NoError(t, err)
+	assert.False(t, fileMigrated)
+	value, _ = get(t, store, "plugins", "i-1234567890")
+	assert.Equal(t, `{"cloudwatch":{"Name":"aws:cloudWatch","Running":true}}`, value)
+	_, err = os.Stat(legacyPath)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package statestore implements the local state store of the agent, an embedded transactional key value store
+// replacing the JSON files the subsystems of the agent persist their state in.
+package statestore
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// ErrReadOnly is returned by the writes of the transactions of View
+var ErrReadOnly = errors.New("the transaction is read-only")
+
+// Store is a transactional key value store, the keys being grouped in buckets
+type Store interface {
+	// View runs a read-only transaction
+	View(fn func(tx Tx) error) error
+	// Update runs a read-write transaction, whose writes are all committed when fn returns no error and none
+	// otherwise. The Update transactions are serialized.
+	Update(fn func(tx Tx) error) error
+	// Close closes the store
+	Close() error
+}
+
+// Tx is a transaction of a Store, it sees its own writes
+type Tx interface {
+	// Get returns the value of the key and whether the key exists
+	Get(bucket string, key string) (value []byte, found bool)
+	// Keys returns the sorted keys of the bucket
+	Keys(bucket string) []string
+	// Put sets the value of the key
+	Put(bucket string, key string, value []byte) error
+	// Delete deletes the key, deleting a missing key is not an error
+	Delete(bucket string, key string) error
+}
+
+var (
+	defaultLock  sync.Mutex
+	defaultStore *FileStore
+	defaultDir   = filepath.Join(appconfig.DefaultDataStorePath, appconfig.StateStoreLocation)
+)
+
+// OpenDefault returns the state store of the agent, which is opened, recovered and checked on first use
+func OpenDefault(log log.T) (Store, error) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+
+	if defaultStore != nil {
+		return defaultStore, nil
+	}
+
+	store, report, err := Open(defaultDir)
+	if err != nil {
+		return nil, err
+	}
+	if report.Healthy() {
+		log.Debugf("Opened the local state store %v, %v", defaultDir, report)
+	} else {
+		log.Warnf("Recovered the local state store %v, %v", defaultDir, report)
+	}
+	defaultStore = store
+	return defaultStore, nil
+}
+
+// OpenDefaultReadOnly opens the state store of the agent for the read-only transactions of another program, the store
+// being closed by the caller
+func OpenDefaultReadOnly() (Store, error) {
+	store, err := OpenReadOnly(defaultDir)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// CloseDefault closes the state store of the agent if it is open
+func CloseDefault() error {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+
+	if defaultStore == nil {
+		return nil
+	}
+	err := defaultStore.Close()
+	defaultStore = nil
+	return err
+}
+
+// GetJSON unmarshals the JSON value of the key into v, it returns false when the key doesn't exist
+func GetJSON(tx Tx, bucket string, key string, v interface{}) (found bool, err error) {
+	var value []byte
+	if value, found = tx.Get(bucket, key); !found {
+		return
+	}
+	return true, json.Unmarshal(value, v)
+}
+
+// PutJSON sets the value of the key to the JSON encoding of v
+func PutJSON(tx Tx, bucket string, key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return tx.Put(bucket, key, value)
+}
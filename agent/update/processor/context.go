@@ -92,6 +92,7 @@ type UpdateDetail struct {
 	MessageID          string                 `json:"MessageId"`
 	UpdateRoot         string                 `json:"UpdateRoot"`
 	RequiresUninstall  bool                   `json:"RequiresUninstall"`
+	HealthGate         *HealthGateResult      `json:"HealthGate"`
 }
 
 // UpdateContext holds the book keeping details for Update context
@@ -115,6 +116,9 @@ func (context *UpdateContext) IsUpdateInProgress(log log.T) bool {
 		return false
 	} else if string(context.Current.State) == "" {
 		return false
+	} else if context.Current.HealthGate != nil && time.Now().Before(context.Current.HealthGate.Deadline) {
+		// the health gate of the updated agent can outlast the maximum allowed update time
+		return true
 	} else {
 		duration := time.Since(context.Current.StartDateTime)
 		log.Infof("Attemping to retry update after %v seconds", duration.Seconds())
@@ -173,14 +177,15 @@ func (context *UpdateContext) cleanUpdate() {
 	context.Current = &UpdateDetail{}
 }
 
-// saveUpdateContext save update context to local storage
+// saveUpdateContext save update context to local storage. The file is written atomically, the updater of another
+// version of the agent reading it as a plain file.
 func (c *contextManager) saveUpdateContext(log log.T, context *UpdateContext, contextLocation string) (err error) {
 	var jsonData = []byte{}
 	if jsonData, err = json.Marshal(context); err != nil {
 		return err
 	}
 
-	if err = ioutil.WriteFile(
+	if err = fileutil.WriteFileAtomic(
 		contextLocation,
 		jsonData,
 		appconfig.ReadWriteAccess); err != nil {
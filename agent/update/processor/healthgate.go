@@ -0,0 +1,203 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package processor contains the methods for update ssm agent.
+// It also provides methods for sendReply and updateInstanceInfo
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/runcommand/resultstore"
+	"github.com/aws/amazon-ssm-agent/agent/updateutil"
+)
+
+const (
+	// probeDocumentPrefix is the prefix of the name of the probe documents submitted to the local command folder
+	probeDocumentPrefix = "amazon-ssm-agent-update-probe-"
+
+	// partialProbeDocumentSuffix is appended to the probe document while it is being written, the agent ignores it
+	partialProbeDocumentSuffix = ".tmp"
+)
+
+// HealthGateResult is the outcome of the health gate of the updated agent, recorded in the update context
+type HealthGateResult struct {
+	Deadline          time.Time              `json:"Deadline"`
+	Registered        bool                   `json:"Registered"`
+	HealthCheckPassed bool                   `json:"HealthCheckPassed"`
+	ProbeDocument     string                 `json:"ProbeDocument"`
+	ProbeStatus       contracts.ResultStatus `json:"ProbeStatus"`
+	Passed            bool                   `json:"Passed"`
+}
+
+var (
+	loadAgentConfig = func() appconfig.AgentInfo {
+		// the default configuration is returned when the configuration can't be loaded
+		config, _ := appconfig.Config(false)
+		return config.Agent
+	}
+	loadUpdateHealth = func() (*updateutil.UpdateHealth, error) {
+		return updateutil.LoadUpdateHealth(appconfig.UpdaterArtifactsRoot)
+	}
+	submitProbeDocument    = writeProbeDocument
+	probeDocumentStatus    = readProbeDocumentStatus
+	healthGatePollInterval = 5 * time.Second
+	timeNow                = time.Now
+	sleep                  = time.Sleep
+)
+
+// verifyHealthGate waits for the updated agent to register, pass its health check and complete a probe document
+// from the local command folder, if the health gate is enabled. The agent fails the health gate when it doesn't
+// within the timeout of the health gate or when the probe document fails.
+func verifyHealthGate(mgr *updateManager, log log.T, context *UpdateContext) (err error) {
+	config := loadAgentConfig().UpdateHealthGate
+	if !config.Enabled {
+		return nil
+	}
+
+	update := context.Current
+	gate := update.HealthGate
+	if gate == nil {
+		gate = &HealthGateResult{
+			Deadline: timeNow().UTC().Add(time.Duration(config.TimeoutSeconds) * time.Second),
+		}
+		update.HealthGate = gate
+		update.AppendInfo(
+			log,
+			"Verifying %v %v registers, passes its health check and completes a probe document within %v seconds",
+			update.PackageName,
+			update.TargetVersion,
+			config.TimeoutSeconds)
+	}
+
+	for {
+		if err = checkHealthGate(mgr, log, context, gate); err != nil || gate.Passed {
+			return
+		}
+		if !timeNow().Before(gate.Deadline) {
+			return fmt.Errorf("the agent did not %v before %v", gate.pending(), gate.Deadline.Format(time.RFC3339))
+		}
+		sleep(healthGatePollInterval)
+	}
+}
+
+// checkHealthGate updates the health gate with the health recorded by the updated agent and the status of the probe
+// document, which is submitted once the agent has registered
+func checkHealthGate(mgr *updateManager, log log.T, context *UpdateContext, gate *HealthGateResult) (err error) {
+	update := context.Current
+	if health, healthErr := loadUpdateHealth(); healthErr == nil &&
+		health.Version == update.TargetVersion &&
+		!health.RegisteredDateTime.Before(update.StartDateTime) {
+		gate.Registered = true
+		gate.HealthCheckPassed = !health.HealthCheckDateTime.IsZero()
+	}
+	if !gate.Registered {
+		return nil
+	}
+
+	if gate.ProbeDocument == "" {
+		if gate.ProbeDocument, err = submitProbeDocument(log, update.TargetVersion); err != nil {
+			return fmt.Errorf("failed to submit the probe document: %v", err)
+		}
+		log.Infof("Submitted probe document %v", gate.ProbeDocument)
+		// the probe document is recorded so that it is not submitted again
+		contextLocation := updateutil.UpdateContextFilePath(update.UpdateRoot)
+		if err = mgr.ctxMgr.saveUpdateContext(log, context, contextLocation); err != nil {
+			return err
+		}
+	}
+
+	gate.ProbeStatus = probeDocumentStatus(log, gate.ProbeDocument)
+	if resultstore.IsTerminal(gate.ProbeStatus) && gate.ProbeStatus != contracts.ResultStatusSuccess {
+		return fmt.Errorf("probe document %v completed with status %v", gate.ProbeDocument, gate.ProbeStatus)
+	}
+
+	gate.Passed = gate.HealthCheckPassed && gate.ProbeStatus == contracts.ResultStatusSuccess
+	return nil
+}
+
+// pending returns the checks of the health gate the agent has yet to pass
+func (gate *HealthGateResult) pending() string {
+	var pending []string
+	if !gate.Registered {
+		pending = append(pending, "register")
+	}
+	if !gate.HealthCheckPassed {
+		pending = append(pending, "pass its health check")
+	}
+	if gate.ProbeStatus != contracts.ResultStatusSuccess {
+		pending = append(pending, "complete the probe document")
+	}
+	return strings.Join(pending, ", ")
+}
+
+// writeProbeDocument submits a document echoing the version of the agent to the local command folder and returns
+// its name
+func writeProbeDocument(log log.T, version string) (name string, err error) {
+	action := "aws:runShellScript"
+	if runtime.GOOS == "windows" {
+		action = "aws:runPowerShellScript"
+	}
+	document := map[string]interface{}{
+		"comment": fmt.Sprintf("Health gate of the update of the agent to %v", version),
+		"documentContent": map[string]interface{}{
+			"schemaVersion": "2.2",
+			"description":   "Probe of the health of the updated agent",
+			"mainSteps": []map[string]interface{}{
+				{
+					"action": action,
+					"name":   "updateHealthProbe",
+					"inputs": map[string]interface{}{
+						"runCommand": []string{"echo " + version},
+					},
+				},
+			},
+		},
+	}
+	var content string
+	if content, err = jsonutil.Marshal(document); err != nil {
+		return
+	}
+
+	name = fmt.Sprintf("%v%v-%v", probeDocumentPrefix, version, timeNow().UTC().Unix())
+	documentPath := filepath.Join(appconfig.LocalCommandRoot, name)
+	if err = fileutil.MakeDirs(appconfig.LocalCommandRoot); err != nil {
+		return
+	}
+	// the document is renamed once complete so that the agent never parses a partial document
+	if err = fileutil.WriteAllText(documentPath+partialProbeDocumentSuffix, content); err != nil {
+		return
+	}
+	return name, os.Rename(documentPath+partialProbeDocumentSuffix, documentPath)
+}
+
+// readProbeDocumentStatus returns the status of the command of the probe document, empty until the agent picks it
+func readProbeDocumentStatus(log log.T, name string) contracts.ResultStatus {
+	config, _ := appconfig.Config(false)
+	for _, invocation := range resultstore.NewResultStore(config).List(log, resultstore.Filter{}) {
+		if invocation.DocumentName == name {
+			return invocation.Status
+		}
+	}
+	return ""
+}
@@ -0,0 +1,199 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package processor contains the methods for update ssm agent.
+// It also provides methods for sendReply and updateInstanceInfo
+package processor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/updateutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// healthGateStub controls the health recorded by the updated agent and the status of the probe document
+type healthGateStub struct {
+	now            time.Time
+	health         *updateutil.UpdateHealth
+	probeStatus    contracts.ResultStatus
+	probeDocuments []string
+}
+
+func setHealthGateStub(stub *healthGateStub) {
+	loadAgentConfig = func() appconfig.AgentInfo {
+		agent := appconfig.DefaultConfig().Agent
+		agent.UpdateHealthGate.Enabled = true
+		return agent
+	}
+	loadUpdateHealth = func() (*updateutil.UpdateHealth, error) {
+		if stub.health == nil {
+			return nil, fmt.Errorf("no health recorded")
+		}
+		return stub.health, nil
+	}
+	submitProbeDocument = func(log log.T, version string) (string, error) {
+		name := probeDocumentPrefix + version
+		stub.probeDocuments = append(stub.probeDocuments, name)
+		return name, nil
+	}
+	probeDocumentStatus = func(log log.T, name string) contracts.ResultStatus {
+		return stub.probeStatus
+	}
+	timeNow = func() time.Time {
+		return stub.now
+	}
+	sleep = func(d time.Duration) {
+		stub.now = stub.now.Add(d)
+	}
+}
+
+func resetHealthGateStub() {
+	loadAgentConfig = func() appconfig.AgentInfo {
+		return appconfig.DefaultConfig().Agent
+	}
+	loadUpdateHealth = func() (*updateutil.UpdateHealth, error) {
+		return updateutil.LoadUpdateHealth(appconfig.UpdaterArtifactsRoot)
+	}
+	submitProbeDocument = writeProbeDocument
+	probeDocumentStatus = readProbeDocumentStatus
+	timeNow = time.Now
+	sleep = time.Sleep
+}
+
+func createHealthGateContext() *UpdateContext {
+	context := createUpdateContext(Installed)
+	context.Current.TargetVersion = "5.0.0.0"
+	context.Current.StartDateTime = time.Date(2018, 6, 4, 12, 0, 0, 0, time.UTC)
+	return context
+}
+
+func TestVerifyHealthGateDisabled(t *testing.T) {
+	// setup
+	updater := createDefaultUpdaterStub()
+	context := createHealthGateContext()
+	loadAgentConfig = func() appconfig.AgentInfo {
+		return appconfig.DefaultConfig().Agent
+	}
+	defer resetHealthGateStub()
+
+	// action
+	err := verifyHealthGate(updater.mgr, logger, context)
+
+	// assert
+	assert.NoError(t, err)
+	assert.Nil(t, context.Current.HealthGate)
+}
+
+func TestVerifyHealthGatePassed(t *testing.T) {
+	// setup
+	updater := createDefaultUpdaterStub()
+	context := createHealthGateContext()
+	stub := &healthGateStub{
+		now: context.Current.StartDateTime.Add(time.Minute),
+		health: &updateutil.UpdateHealth{
+			Version:             "5.0.0.0",
+			RegisteredDateTime:  context.Current.StartDateTime.Add(10 * time.Second),
+			HealthCheckDateTime: context.Current.StartDateTime.Add(20 * time.Second),
+		},
+		probeStatus: contracts.ResultStatusInProgress,
+	}
+	setHealthGateStub(stub)
+	defer resetHealthGateStub()
+	probeDocumentStatus = func(log log.T, name string) contracts.ResultStatus {
+		// the probe document completes on the second poll
+		status := stub.probeStatus
+		stub.probeStatus = contracts.ResultStatusSuccess
+		return status
+	}
+
+	// action
+	err := verifyHealthGate(updater.mgr, logger, context)
+
+	// assert
+	assert.NoError(t, err)
+	gate := context.Current.HealthGate
+	assert.True(t, gate.Passed)
+	assert.True(t, gate.Registered)
+	assert.True(t, gate.HealthCheckPassed)
+	assert.Equal(t, contracts.ResultStatusSuccess, gate.ProbeStatus)
+	assert.Equal(t, []string{probeDocumentPrefix + "5.0.0.0"}, stub.probeDocuments)
+	assert.Equal(t, context.Current.StartDateTime.Add(time.Minute+300*time.Second), gate.Deadline)
+}
+
+func TestVerifyHealthGateTimeout(t *testing.T) {
+	// setup
+	updater := createDefaultUpdaterStub()
+	context := createHealthGateContext()
+	stub := &healthGateStub{
+		now: context.Current.StartDateTime.Add(time.Minute),
+		// the health recorded by the previous version isn't the health of the updated agent
+		health: &updateutil.UpdateHealth{
+			Version:             "4.0.0.0",
+			RegisteredDateTime:  context.Current.StartDateTime.Add(-time.Hour),
+			HealthCheckDateTime: context.Current.StartDateTime.Add(-time.Hour),
+		},
+	}
+	setHealthGateStub(stub)
+	defer resetHealthGateStub()
+
+	// action
+	err := verifyHealthGate(updater.mgr, logger, context)
+
+	// assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "did not register, pass its health check, complete the probe document")
+	assert.False(t, context.Current.HealthGate.Passed)
+	assert.Empty(t, stub.probeDocuments)
+	assert.False(t, stub.now.Before(context.Current.HealthGate.Deadline))
+}
+
+func TestVerifyHealthGateProbeFailed(t *testing.T) {
+	// setup
+	updater := createDefaultUpdaterStub()
+	context := createHealthGateContext()
+	stub := &healthGateStub{
+		now: context.Current.StartDateTime.Add(time.Minute),
+		health: &updateutil.UpdateHealth{
+			Version:            "5.0.0.0",
+			RegisteredDateTime: context.Current.StartDateTime.Add(10 * time.Second),
+		},
+		probeStatus: contracts.ResultStatusFailed,
+	}
+	setHealthGateStub(stub)
+	defer resetHealthGateStub()
+
+	// action
+	err := verifyHealthGate(updater.mgr, logger, context)
+
+	// assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "completed with status Failed")
+	assert.True(t, context.Current.HealthGate.Registered)
+	assert.False(t, context.Current.HealthGate.HealthCheckPassed)
+	assert.False(t, context.Current.HealthGate.Passed)
+}
+
+func TestIsUpdateInProgressDuringHealthGate(t *testing.T) {
+	context := createHealthGateContext()
+	context.Current.StartDateTime = time.Now().Add(-time.Hour)
+	assert.False(t, context.IsUpdateInProgress(logger))
+
+	context.Current.HealthGate = &HealthGateResult{Deadline: time.Now().Add(time.Minute)}
+	assert.True(t, context.IsUpdateInProgress(logger))
+}
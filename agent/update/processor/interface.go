@@ -36,6 +36,7 @@ type T interface {
 type prepare func(mgr *updateManager, log log.T, context *UpdateContext) (err error)
 type update func(mgr *updateManager, log log.T, context *UpdateContext) (err error)
 type verify func(mgr *updateManager, log log.T, context *UpdateContext, isRollback bool) (err error)
+type gate func(mgr *updateManager, log log.T, context *UpdateContext) (err error)
 type rollback func(mgr *updateManager, log log.T, context *UpdateContext) (err error)
 type uninstall func(mgr *updateManager, log log.T, version string, context *UpdateContext) (err error)
 type install func(mgr *updateManager, log log.T, version string, context *UpdateContext) (err error)
@@ -48,6 +49,7 @@ type updateManager struct {
 	prepare   prepare
 	update    update
 	verify    verify
+	gate      gate
 	rollback  rollback
 	uninstall uninstall
 	install   install
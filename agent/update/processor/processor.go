@@ -45,6 +45,7 @@ func NewUpdater() *Updater {
 			prepare:   prepareInstallationPackages,
 			update:    proceedUpdate,
 			verify:    verifyInstallation,
+			gate:      verifyHealthGate,
 			rollback:  rollbackInstallation,
 			uninstall: uninstallAgent,
 			install:   installAgent,
@@ -145,6 +146,10 @@ func prepareInstallationPackages(mgr *updateManager, log log.T, context *UpdateC
 	var instanceContext *updateutil.InstanceContext
 	updateDownload := ""
 
+	if err = updateutil.CheckUpdateBlackout(loadAgentConfig().UpdateBlackoutWindows, timeNow()); err != nil {
+		return mgr.failed(context, log, updateutil.ErrorUpdateBlackout, err.Error(), true)
+	}
+
 	if instanceContext, err = mgr.util.CreateInstanceContext(log); err != nil {
 		return mgr.failed(context, log, updateutil.ErrorEnvironmentIssue, err.Error(), false)
 	}
@@ -162,26 +167,14 @@ func prepareInstallationPackages(mgr *updateManager, log log.T, context *UpdateC
 	}
 
 	// Download source
-	downloadInput := artifact.DownloadInput{
-		SourceURL: context.Current.SourceLocation,
-		SourceChecksums: map[string]string{
-			updateutil.HashType: context.Current.SourceHash,
-		},
-		DestinationDirectory: updateDownload,
-	}
+	downloadInput := updateutil.NewDownloadInput(context.Current.SourceLocation, context.Current.SourceHash, updateDownload)
 
 	if err = mgr.download(mgr, log, downloadInput, context, context.Current.SourceVersion); err != nil {
 		return mgr.failed(context, log, updateutil.ErrorInvalidPackage, err.Error(), true)
 	}
 
 	// Download target
-	downloadInput = artifact.DownloadInput{
-		SourceURL: context.Current.TargetLocation,
-		SourceChecksums: map[string]string{
-			updateutil.HashType: context.Current.TargetHash,
-		},
-		DestinationDirectory: updateDownload,
-	}
+	downloadInput = updateutil.NewDownloadInput(context.Current.TargetLocation, context.Current.TargetHash, updateDownload)
 
 	if err = mgr.download(mgr, log, downloadInput, context, context.Current.TargetVersion); err != nil {
 		return mgr.failed(context, log, updateutil.ErrorInvalidPackage, err.Error(), true)
@@ -298,12 +291,36 @@ func verifyInstallation(mgr *updateManager, log log.T, context *UpdateContext, i
 
 	log.Infof("%v is running", context.Current.PackageName)
 	if !isRollback {
+		// Verify the agent is healthy before the update succeeds
+		if err = mgr.gate(mgr, log, context); err != nil {
+			message := updateutil.BuildMessage(err,
+				"failed to update %v to %v, %v",
+				context.Current.PackageName,
+				context.Current.TargetVersion,
+				"the agent failed its health gate")
+
+			context.Current.AppendError(log, "%v", message)
+			context.Current.AppendInfo(
+				log,
+				"Initiating rollback %v to %v",
+				context.Current.PackageName,
+				context.Current.SourceVersion)
+			// Update state to rollback
+			if err = mgr.inProgress(context, log, Rollback); err != nil {
+				return err
+			}
+			return mgr.rollback(mgr, log, context)
+		}
 		return mgr.succeeded(context, log)
 	}
 
 	message := fmt.Sprintf("rolledback %v to %v", context.Current.PackageName, context.Current.SourceVersion)
 	log.Infof("message is %v", message)
-	return mgr.failed(context, log, updateutil.ErrorCannotStartService, message, false)
+	errorCode := updateutil.ErrorCannotStartService
+	if context.Current.HealthGate != nil && !context.Current.HealthGate.Passed {
+		errorCode = updateutil.ErrorHealthGateFailed
+	}
+	return mgr.failed(context, log, errorCode, message, false)
 }
 
 // rollbackInstallation rollback installation to the source version
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
 	"github.com/aws/amazon-ssm-agent/agent/log"
@@ -158,6 +159,34 @@ func TestPreparePackagesFailCreateInstanceContext(t *testing.T) {
 	assert.Equal(t, context.Histories[0].Result, contracts.ResultStatusFailed)
 }
 
+func TestPreparePackagesRefusedDuringBlackout(t *testing.T) {
+	// setup
+	updater := createDefaultUpdaterStub()
+	context := createUpdateContext(Initialized)
+	isDownloadCalled := false
+	loadAgentConfig = func() appconfig.AgentInfo {
+		agent := appconfig.DefaultConfig().Agent
+		agent.UpdateBlackoutWindows = []appconfig.BlackoutWindow{{Start: "00:00", End: "00:00"}}
+		return agent
+	}
+	defer resetHealthGateStub()
+
+	updater.mgr.download = func(mgr *updateManager, log log.T, downloadInput artifact.DownloadInput, context *UpdateContext, version string) (err error) {
+		isDownloadCalled = true
+		return nil
+	}
+
+	// action
+	err := prepareInstallationPackages(updater.mgr, logger, context)
+
+	// assert
+	assert.NoError(t, err)
+	assert.False(t, isDownloadCalled)
+	assert.Equal(t, context.Histories[0].State, Completed)
+	assert.Equal(t, context.Histories[0].Result, contracts.ResultStatusFailed)
+	assert.Contains(t, context.Histories[0].StandardOut, "update refused during the blackout window")
+}
+
 func TestPreparePackagesFailCreateUpdateDownloadFolder(t *testing.T) {
 	// setup
 	updater := createDefaultUpdaterStub()
@@ -397,6 +426,49 @@ func TestVerifyInstallationCannotStartAgent(t *testing.T) {
 	assert.Equal(t, context.Current.State, Rollback)
 }
 
+func TestVerifyInstallationFailedHealthGate(t *testing.T) {
+	// setup
+	control := &stubControl{serviceIsRunning: true}
+	updater := createUpdaterStubs(control)
+	context := createUpdateContext(Installed)
+	isRollbackCalled := false
+
+	updater.mgr.gate = func(mgr *updateManager, log log.T, context *UpdateContext) (err error) {
+		context.Current.HealthGate = &HealthGateResult{Registered: true}
+		return fmt.Errorf("the agent did not pass its health check")
+	}
+	updater.mgr.rollback = func(mgr *updateManager, log log.T, context *UpdateContext) (err error) {
+		isRollbackCalled = true
+		return nil
+	}
+
+	// action
+	err := verifyInstallation(updater.mgr, logger, context, false)
+
+	// assert
+	assert.NoError(t, err)
+	assert.True(t, isRollbackCalled)
+	assert.Equal(t, context.Current.State, Rollback)
+	assert.Contains(t, context.Current.StandardError, "the agent failed its health gate")
+}
+
+func TestVerifyRollbackAfterFailedHealthGate(t *testing.T) {
+	// setup
+	control := &stubControl{serviceIsRunning: true}
+	updater := createUpdaterStubs(control)
+	context := createUpdateContext(RolledBack)
+	context.Current.HealthGate = &HealthGateResult{Registered: true}
+
+	// action
+	err := verifyInstallation(updater.mgr, logger, context, true)
+
+	// assert
+	assert.NoError(t, err)
+	assert.Equal(t, context.Histories[0].State, Completed)
+	assert.Equal(t, context.Histories[0].Result, contracts.ResultStatusFailed)
+	assert.False(t, context.Histories[0].HealthGate.Passed)
+}
+
 func TestVerifyRollback(t *testing.T) {
 	// setup
 	control := &stubControl{serviceIsRunning: true}
@@ -0,0 +1,123 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package updateutil
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// blackoutTimeLayout is the layout of the start and end times of the blackout windows
+const blackoutTimeLayout = "15:04"
+
+const minutesPerDay = 24 * 60
+
+// FindBlackoutWindow returns the blackout window the time falls in, nil when the agent can be updated at that time.
+// An invalid window is an error, so that a misconfigured blackout never lets an update through.
+func FindBlackoutWindow(windows []appconfig.BlackoutWindow, t time.Time) (*appconfig.BlackoutWindow, error) {
+	t = t.UTC()
+	minute := t.Hour()*60 + t.Minute()
+	for i, window := range windows {
+		start, err := blackoutMinute(window.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := blackoutMinute(window.End)
+		if err != nil {
+			return nil, err
+		}
+
+		// the day the window containing the time started on, the windows ending before their start end the next day
+		startDay, inWindow := t.Weekday(), false
+		switch {
+		case start < end:
+			inWindow = minute >= start && minute < end
+		case minute >= start:
+			inWindow = true
+		case minute < end:
+			startDay, inWindow = (startDay+6)%7, true
+		}
+		if !inWindow {
+			continue
+		}
+
+		matches, err := matchesBlackoutDay(window.Days, startDay)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			return &windows[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// CheckUpdateBlackout returns an error if the agent can't be updated at the time because of the blackout windows
+func CheckUpdateBlackout(windows []appconfig.BlackoutWindow, t time.Time) error {
+	window, err := FindBlackoutWindow(windows, t)
+	if err != nil {
+		return fmt.Errorf("update refused, %v", err)
+	}
+	if window != nil {
+		return fmt.Errorf("update refused during the blackout window %v", formatBlackoutWindow(window))
+	}
+	return nil
+}
+
+// formatBlackoutWindow returns the description of the window in the messages of the refused updates
+func formatBlackoutWindow(window *appconfig.BlackoutWindow) string {
+	if len(window.Days) == 0 {
+		return fmt.Sprintf("%v-%v UTC", window.Start, window.End)
+	}
+	return fmt.Sprintf("%v-%v UTC on %v", window.Start, window.End, strings.Join(window.Days, ", "))
+}
+
+// blackoutMinute returns the minute of the day of a time of a blackout window
+func blackoutMinute(value string) (int, error) {
+	t, err := time.Parse(blackoutTimeLayout, strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q of update blackout window, it must be in the HH:MM format", value)
+	}
+	return (t.Hour()*60 + t.Minute()) % minutesPerDay, nil
+}
+
+// matchesBlackoutDay returns true if the days of a blackout window contain the day, no day matching every day
+func matchesBlackoutDay(days []string, day time.Weekday) (bool, error) {
+	if len(days) == 0 {
+		return true, nil
+	}
+	matches := false
+	for _, value := range days {
+		weekday, err := parseWeekday(value)
+		if err != nil {
+			return false, err
+		}
+		matches = matches || weekday == day
+	}
+	return matches, nil
+}
+
+// parseWeekday parses the full or three letter name of a day, case insensitive
+func parseWeekday(value string) (time.Weekday, error) {
+	value = strings.TrimSpace(value)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(value, day.String()) || strings.EqualFold(value, day.String()[:3]) {
+			return day, nil
+		}
+	}
+	return time.Sunday, fmt.Errorf("invalid day %q of update blackout window", value)
+}
@@ -0,0 +1,96 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package updateutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindBlackoutWindow(t *testing.T) {
+	daily := appconfig.BlackoutWindow{Start: "09:00", End: "17:00"}
+	overnight := appconfig.BlackoutWindow{Start: "22:00", End: "02:00", Days: []string{"Fri"}}
+	windows := []appconfig.BlackoutWindow{daily, overnight}
+
+	testCases := []struct {
+		time     string
+		expected *appconfig.BlackoutWindow
+	}{
+		{"2018-06-04T08:59:00Z", nil},
+		{"2018-06-04T09:00:00Z", &daily},
+		{"2018-06-04T16:59:59Z", &daily},
+		{"2018-06-04T17:00:00Z", nil},
+		// the times are compared in UTC
+		{"2018-06-04T14:00:00+05:00", &daily},
+		{"2018-06-04T11:00:00+05:00", nil},
+		// friday night and the following saturday morning
+		{"2018-06-08T23:30:00Z", &overnight},
+		{"2018-06-09T01:59:00Z", &overnight},
+		{"2018-06-09T02:00:00Z", nil},
+		// the windows starting on thursday aren't blackouts
+		{"2018-06-07T23:30:00Z", nil},
+		{"2018-06-08T01:00:00Z", nil},
+	}
+	for _, testCase := range testCases {
+		now, err := time.Parse(time.RFC3339, testCase.time)
+		assert.NoError(t, err)
+
+		window, err := FindBlackoutWindow(windows, now)
+
+		assert.NoError(t, err, testCase.time)
+		if testCase.expected == nil {
+			assert.Nil(t, window, testCase.time)
+		} else if assert.NotNil(t, window, testCase.time) {
+			assert.Equal(t, *testCase.expected, *window, testCase.time)
+		}
+	}
+}
+
+func TestFindBlackoutWindowWholeDay(t *testing.T) {
+	windows := []appconfig.BlackoutWindow{{Start: "00:00", End: "00:00", Days: []string{"saturday", "Sunday"}}}
+
+	window, err := FindBlackoutWindow(windows, time.Date(2018, 6, 10, 23, 59, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.NotNil(t, window)
+
+	window, err = FindBlackoutWindow(windows, time.Date(2018, 6, 11, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Nil(t, window)
+}
+
+func TestFindBlackoutWindowInvalid(t *testing.T) {
+	now := time.Date(2018, 6, 4, 12, 0, 0, 0, time.UTC)
+	for _, window := range []appconfig.BlackoutWindow{
+		{Start: "9am", End: "17:00"},
+		{Start: "09:00", End: "25:00"},
+		{Start: "09:00", End: "17:00", Days: []string{"Someday"}},
+	} {
+		_, err := FindBlackoutWindow([]appconfig.BlackoutWindow{window}, now)
+		assert.Error(t, err, window.Start)
+	}
+}
+
+func TestCheckUpdateBlackout(t *testing.T) {
+	now := time.Date(2018, 6, 8, 23, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, CheckUpdateBlackout(nil, now))
+	assert.NoError(t, CheckUpdateBlackout([]appconfig.BlackoutWindow{{Start: "09:00", End: "17:00"}}, now))
+	assert.EqualError(t,
+		CheckUpdateBlackout([]appconfig.BlackoutWindow{{Start: "22:00", End: "02:00", Days: []string{"Fri", "Sat"}}}, now),
+		"update refused during the blackout window 22:00-02:00 UTC on Fri, Sat")
+	assert.Error(t, CheckUpdateBlackout([]appconfig.BlackoutWindow{{Start: "22:00"}}, now))
+}
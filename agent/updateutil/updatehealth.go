@@ -0,0 +1,106 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package updateutil
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// UpdateHealthFileName represents the file the running agent records its health in for the updater
+	UpdateHealthFileName = "updatehealth.json"
+
+	// partialHealthFileSuffix is appended to the health file while it is being written
+	partialHealthFileSuffix = ".tmp"
+)
+
+// UpdateHealth is the health of the running agent, which the updater verifies once it has installed a new version
+type UpdateHealth struct {
+	Version string `json:"Version"`
+	// RegisteredDateTime is when the agent started with its instance identity resolved
+	RegisteredDateTime time.Time `json:"RegisteredDateTime"`
+	// HealthCheckDateTime is when the agent last reported its health to the service since it registered
+	HealthCheckDateTime time.Time `json:"HealthCheckDateTime"`
+}
+
+var (
+	healthLock sync.Mutex
+	healthRoot = appconfig.UpdaterArtifactsRoot
+)
+
+// UpdateHealthFilePath returns the path of the health file of the running agent
+func UpdateHealthFilePath(updateRoot string) string {
+	return filepath.Join(updateRoot, UpdateHealthFileName)
+}
+
+// LoadUpdateHealth loads the health recorded by the running agent
+func LoadUpdateHealth(updateRoot string) (health *UpdateHealth, err error) {
+	var content []byte
+	if content, err = ioutil.ReadFile(UpdateHealthFilePath(updateRoot)); err != nil {
+		return
+	}
+	err = json.Unmarshal(content, &health)
+	return
+}
+
+// RecordAgentRegistration records that the agent of the version started with its instance identity resolved,
+// the health checks of the previous start being discarded
+func RecordAgentRegistration(log log.T, version string) {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+
+	saveUpdateHealth(log, &UpdateHealth{Version: version, RegisteredDateTime: time.Now().UTC()})
+}
+
+// RecordAgentHealthCheck records that the agent of the version reported its health to the service
+func RecordAgentHealthCheck(log log.T, version string) {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+
+	health, err := LoadUpdateHealth(healthRoot)
+	if err != nil || health.Version != version {
+		log.Debugf("Agent %v has no registration recorded, skipping its health check", version)
+		return
+	}
+	health.HealthCheckDateTime = time.Now().UTC()
+	saveUpdateHealth(log, health)
+}
+
+// saveUpdateHealth writes the health file, the file being replaced once complete so that the updater never reads
+// a partial file
+func saveUpdateHealth(log log.T, health *UpdateHealth) {
+	content, err := json.Marshal(health)
+	if err == nil {
+		err = fileutil.MakeDirs(healthRoot)
+	}
+	partialPath := UpdateHealthFilePath(healthRoot) + partialHealthFileSuffix
+	if err == nil {
+		err = ioutil.WriteFile(partialPath, content, appconfig.ReadWriteAccess)
+	}
+	if err == nil {
+		err = os.Rename(partialPath, UpdateHealthFilePath(healthRoot))
+	}
+	if err != nil {
+		log.Warnf("Failed to record the health of the agent for the updater: %v", err)
+	}
+}
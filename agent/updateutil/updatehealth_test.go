@@ -0,0 +1,58 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package updateutil
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordAgentHealth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "updatehealth")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	healthRoot = dir
+	defer func() { healthRoot = appconfig.UpdaterArtifactsRoot }()
+	logger := log.NewMockLog()
+
+	// the health checks of an agent that didn't register aren't recorded
+	RecordAgentHealthCheck(logger, "2.2.0.0")
+	_, err = LoadUpdateHealth(dir)
+	assert.Error(t, err)
+
+	RecordAgentRegistration(logger, "2.2.0.0")
+	health, err := LoadUpdateHealth(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.2.0.0", health.Version)
+	assert.False(t, health.RegisteredDateTime.IsZero())
+	assert.True(t, health.HealthCheckDateTime.IsZero())
+
+	RecordAgentHealthCheck(logger, "2.2.0.0")
+	health, err = LoadUpdateHealth(dir)
+	assert.NoError(t, err)
+	assert.False(t, health.HealthCheckDateTime.Before(health.RegisteredDateTime))
+
+	// the registration of another version discards the health checks of the previous version
+	RecordAgentHealthCheck(logger, "2.3.0.0")
+	RecordAgentRegistration(logger, "2.3.0.0")
+	health, err = LoadUpdateHealth(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.3.0.0", health.Version)
+	assert.True(t, health.HealthCheckDateTime.IsZero())
+}
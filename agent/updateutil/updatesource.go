@@ -0,0 +1,159 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package updateutil
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/s3util"
+)
+
+const (
+	// ManifestFileName is the name of the manifest at the root of the mirrors and local repositories
+	ManifestFileName = "ssm-agent-manifest.json"
+
+	// manifestExtension is the extension of a location pointing at the manifest rather than at the repository
+	manifestExtension = ".json"
+)
+
+// windowsDrivePath matches the absolute paths of Windows, which url.Parse reads as URLs of scheme c
+var windowsDrivePath = regexp.MustCompile(`^[a-zA-Z]:[\\/]`)
+
+var loadAppConfig = appconfig.Config
+
+// UpdateSource is the repository of the manifest and packages of the agent updates. The mirrors and local
+// repositories have the layout of the S3 buckets, the manifest at their root and each package file at
+// {PackageName}/{PackageVersion}/{FileName}.
+type UpdateSource interface {
+	// ManifestLocation returns the URL or path of the manifest
+	ManifestLocation() string
+	// URIFormat returns the format of the locations of the package files, given the UriFormat of the manifest
+	URIFormat(manifestURIFormat string) string
+}
+
+// s3Source is the manifest of an S3 bucket, the package files being at the UriFormat of the manifest
+type s3Source struct {
+	manifestURL string
+}
+
+func (s s3Source) ManifestLocation() string {
+	return s.manifestURL
+}
+
+func (s s3Source) URIFormat(manifestURIFormat string) string {
+	return manifestURIFormat
+}
+
+// mirrorSource is an https mirror of the S3 buckets
+type mirrorSource struct {
+	baseURL string
+}
+
+func (s mirrorSource) ManifestLocation() string {
+	return s.location(ManifestFileName)
+}
+
+func (s mirrorSource) URIFormat(manifestURIFormat string) string {
+	return s.location(PackageNameHolder, PackageVersionHolder, FileNameHolder)
+}
+
+func (s mirrorSource) location(elem ...string) string {
+	return strings.TrimSuffix(s.baseURL, "/") + "/" + strings.Join(elem, "/")
+}
+
+// localSource is a local directory of the files of the S3 buckets, for the hosts without access to S3
+type localSource struct {
+	dir string
+}
+
+func (s localSource) ManifestLocation() string {
+	return filepath.Join(s.dir, ManifestFileName)
+}
+
+func (s localSource) URIFormat(manifestURIFormat string) string {
+	return filepath.Join(s.dir, PackageNameHolder, PackageVersionHolder, FileNameHolder)
+}
+
+// NewUpdateSource returns the update source at the location. The location is empty for the S3 bucket of the region,
+// whose manifest is at defaultManifestURL, or it is the https URL of a manifest in S3, the https base URL of a mirror,
+// or a local directory or file:// URL. The location of a mirror or local repository can also be the manifest at its
+// root. The {Region} placeholder of the location is replaced by the region.
+func NewUpdateSource(log log.T, location string, defaultManifestURL string, region string) (UpdateSource, error) {
+	if location == "" {
+		location = defaultManifestURL
+	}
+	location = strings.Replace(location, RegionHolder, region, -1)
+
+	if windowsDrivePath.MatchString(location) || filepath.IsAbs(location) {
+		return newLocalSource(location), nil
+	}
+
+	sourceURL, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid update source %v: %v", location, err)
+	}
+	switch strings.ToLower(sourceURL.Scheme) {
+	case "file":
+		dir := sourceURL.Path
+		if windowsDrivePath.MatchString(strings.TrimPrefix(dir, "/")) {
+			dir = strings.TrimPrefix(dir, "/")
+		}
+		return newLocalSource(filepath.FromSlash(dir)), nil
+	case "https":
+		if s3util.ParseAmazonS3URL(log, sourceURL).IsBucketAndKeyPresent() {
+			return s3Source{manifestURL: location}, nil
+		}
+		if strings.HasSuffix(sourceURL.Path, manifestExtension) {
+			sourceURL.Path = path.Dir(sourceURL.Path)
+			sourceURL.RawPath = ""
+		}
+		sourceURL.RawQuery, sourceURL.Fragment = "", ""
+		return mirrorSource{baseURL: sourceURL.String()}, nil
+	}
+	return nil, fmt.Errorf("unsupported update source %v, it must be an https URL, a file:// URL or a local path", location)
+}
+
+func newLocalSource(location string) UpdateSource {
+	if strings.HasSuffix(location, manifestExtension) {
+		location = filepath.Dir(location)
+	}
+	return localSource{dir: location}
+}
+
+// NewDownloadInput returns the input of the download of a file of the update source, whose signature is verified
+// when required and whose https download trusts the CA bundle of the update source of the configuration
+func NewDownloadInput(sourceURL string, hash string, destinationDirectory string) artifact.DownloadInput {
+	input := artifact.DownloadInput{
+		SourceURL:            sourceURL,
+		DestinationDirectory: destinationDirectory,
+		VerifySignature:      true,
+	}
+	if hash != "" {
+		input.SourceChecksums = map[string]string{
+			HashType: hash,
+		}
+	}
+	if config, err := loadAppConfig(false); err == nil {
+		input.CABundle = config.Agent.UpdateSource.CABundle
+	}
+	return input
+}
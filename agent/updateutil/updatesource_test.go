@@ -0,0 +1,112 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package updateutil
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const testManifestURL = "https://s3.{Region}.amazonaws.com/amazon-ssm-{Region}/ssm-agent-manifest.json"
+
+func TestNewUpdateSource(t *testing.T) {
+	testCases := []struct {
+		location         string
+		manifestLocation string
+		uriFormat        string
+	}{
+		{
+			"",
+			"https://s3.us-east-1.amazonaws.com/amazon-ssm-us-east-1/ssm-agent-manifest.json",
+			"https://s3.amazonaws.com/manifest/{PackageName}/{PackageVersion}/{FileName}",
+		},
+		{
+			"https://s3.amazonaws.com/ssm-agent-beta-{Region}/ssm-agent-manifest.json",
+			"https://s3.amazonaws.com/ssm-agent-beta-us-east-1/ssm-agent-manifest.json",
+			"https://s3.amazonaws.com/manifest/{PackageName}/{PackageVersion}/{FileName}",
+		},
+		{
+			"https://mirror.example.com/ssm",
+			"https://mirror.example.com/ssm/ssm-agent-manifest.json",
+			"https://mirror.example.com/ssm/{PackageName}/{PackageVersion}/{FileName}",
+		},
+		{
+			"https://mirror.example.com:8443/ssm/{Region}/ssm-agent-manifest.json?version=1",
+			"https://mirror.example.com:8443/ssm/us-east-1/ssm-agent-manifest.json",
+			"https://mirror.example.com:8443/ssm/us-east-1/{PackageName}/{PackageVersion}/{FileName}",
+		},
+		{
+			"/var/lib/amazon/ssm/repository",
+			"/var/lib/amazon/ssm/repository/ssm-agent-manifest.json",
+			"/var/lib/amazon/ssm/repository/{PackageName}/{PackageVersion}/{FileName}",
+		},
+		{
+			"file:///var/lib/amazon/ssm/repository/ssm-agent-manifest.json",
+			"/var/lib/amazon/ssm/repository/ssm-agent-manifest.json",
+			"/var/lib/amazon/ssm/repository/{PackageName}/{PackageVersion}/{FileName}",
+		},
+	}
+
+	for _, testCase := range testCases {
+		source, err := NewUpdateSource(log.NewMockLog(), testCase.location, testManifestURL, "us-east-1")
+		if !assert.NoError(t, err, testCase.location) {
+			continue
+		}
+		assert.Equal(t, filepath.FromSlash(testCase.manifestLocation), filepath.FromSlash(source.ManifestLocation()), testCase.location)
+		uriFormat := source.URIFormat("https://s3.amazonaws.com/manifest/{PackageName}/{PackageVersion}/{FileName}")
+		assert.Equal(t, filepath.FromSlash(testCase.uriFormat), filepath.FromSlash(uriFormat), testCase.location)
+	}
+}
+
+func TestNewUpdateSourceWindowsPath(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("the local repositories have Windows paths on Windows only")
+	}
+	source, err := NewUpdateSource(log.NewMockLog(), `file:///C:/ProgramData/Amazon/SSM/repository`, testManifestURL, "us-east-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, `C:\ProgramData\Amazon\SSM\repository\ssm-agent-manifest.json`, source.ManifestLocation())
+}
+
+func TestNewUpdateSourceUnsupported(t *testing.T) {
+	for _, location := range []string{"http://mirror.example.com/ssm", "ftp://mirror.example.com/ssm", "mirror.example.com/ssm"} {
+		_, err := NewUpdateSource(log.NewMockLog(), location, testManifestURL, "us-east-1")
+		assert.Error(t, err, location)
+	}
+}
+
+func TestNewDownloadInput(t *testing.T) {
+	loadAppConfig = func(reload bool) (appconfig.SsmagentConfig, error) {
+		config := appconfig.DefaultConfig()
+		config.Agent.UpdateSource.CABundle = "/etc/pki/mirror-ca.pem"
+		return config, nil
+	}
+	defer func() { loadAppConfig = appconfig.Config }()
+
+	input := NewDownloadInput("https://mirror.example.com/ssm/ssm-agent-manifest.json", "", "/var/lib/amazon/ssm/update")
+
+	assert.Equal(t, "https://mirror.example.com/ssm/ssm-agent-manifest.json", input.SourceURL)
+	assert.Empty(t, input.SourceChecksums)
+	assert.True(t, input.VerifySignature)
+	assert.Equal(t, "/etc/pki/mirror-ca.pem", input.CABundle)
+
+	input = NewDownloadInput("https://mirror.example.com/ssm/amazon-ssm-agent.tar.gz", "d2b67b80", "/var/lib/amazon/ssm/update")
+
+	assert.Equal(t, map[string]string{HashType: "d2b67b80"}, input.SourceChecksums)
+}
@@ -170,6 +170,12 @@ const (
 
 	// ErrorLoadingAgentVersion represents failed for loading agent version
 	ErrorLoadingAgentVersion ErrorCode = "ErrorLoadingAgentVersion"
+
+	// ErrorHealthGateFailed represents the updated agent failed its health gate and was rolled back
+	ErrorHealthGateFailed ErrorCode = "ErrorHealthGateFailed"
+
+	// ErrorUpdateBlackout represents an update refused during an update blackout window
+	ErrorUpdateBlackout ErrorCode = "ErrorUpdateBlackout"
 )
 
 // MinimumDiskSpaceForUpdate represents 100 Mb in bytes
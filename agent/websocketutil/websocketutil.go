@@ -18,6 +18,7 @@ import (
 	"errors"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"github.com/gorilla/websocket"
 )
 
@@ -40,7 +41,7 @@ func NewWebsocketUtil(logger log.T, dialerInput *websocket.Dialer) *WebsocketUti
 
 	if dialerInput == nil {
 		websocketUtil = &WebsocketUtil{
-			dialer: websocket.DefaultDialer,
+			dialer: &websocket.Dialer{Proxy: proxyconfig.Proxy},
 			log:    logger,
 		}
 	} else {
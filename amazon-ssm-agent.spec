@@ -64,6 +64,7 @@ cp packaging/linux/amazon-ssm-agent.conf %{buildroot}%{_sysconfdir}/init/
 %endif
 cp amazon-ssm-agent.json.template %{buildroot}%{_sysconfdir}/amazon/ssm/amazon-ssm-agent.json.template
 cp seelog_unix.xml %{buildroot}%{_sysconfdir}/amazon/ssm/seelog.xml.template
+cp -r packaging/trusted-keys %{buildroot}%{_sysconfdir}/amazon/ssm/
 
 strip --strip-unneeded %{buildroot}%{_prefix}/bin/{amazon-ssm-agent,ssm-document-worker,ssm-cli}
 
@@ -71,6 +72,7 @@ strip --strip-unneeded %{buildroot}%{_prefix}/bin/{amazon-ssm-agent,ssm-document
 %defattr(-,root,root,-)
 %{_sysconfdir}/amazon/ssm/amazon-ssm-agent.json.template
 %{_sysconfdir}/amazon/ssm/seelog.xml.template
+%{_sysconfdir}/amazon/ssm/trusted-keys/
 %{_sysconfdir}/amazon/ssm/README.md
 %{_sysconfdir}/amazon/ssm/RELEASENOTES.md
 %if 0%{?amzn} >= 2
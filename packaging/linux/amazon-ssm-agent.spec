@@ -17,6 +17,7 @@ This package provides Amazon SSM Agent for managing EC2 Instances using SSM APIs
 %defattr(-,root,root,-)
 /etc/amazon/ssm/amazon-ssm-agent.json.template
 /etc/amazon/ssm/seelog.xml.template
+/etc/amazon/ssm/trusted-keys/
 /usr/bin/amazon-ssm-agent
 /usr/bin/ssm-cli
 /usr/bin/ssm-document-worker